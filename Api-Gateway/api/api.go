@@ -1,8 +1,12 @@
 package api
 
 import (
+	"log"
+
 	"api-gateway/api/handler"
+	"api-gateway/api/middleware"
 
+	"github.com/casbin/casbin/v2"
 	"github.com/gin-gonic/gin"
 	files "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -16,64 +20,129 @@ import (
 // @BasePath /
 // @securityDefinitions.apikey BearerAuth
 // @in header
-// @name Authourization
+// @name Authorization
 func NewGin(h *handler.Handler) *gin.Engine {
-	// ca, err := casbin.NewEnforcer("config/model.conf", "config/policy.csv")
-	// if err != nil {
-	// 	panic(err)
-	// }
-
-	// err = ca.LoadPolicy()
-	// if err != nil {
-	// 	log.Fatal("casbin error load policy: ", err)
-	// 	panic(err)
-	// }
+	ca, err := casbin.NewEnforcer("config/model.conf", "config/policy.csv")
+	if err != nil {
+		panic(err)
+	}
+
+	err = ca.LoadPolicy()
+	if err != nil {
+		log.Fatal("casbin error load policy: ", err)
+	}
+	// Routes that write content, or review it, check the caller's role
+	// against config/policy.csv.
+	auth := middleware.NewAuth(ca)
 
 	r := gin.Default()
-
-	// r.Group("/")
-	// router.Use(middleware.NewAuth(ca))
+	// Handlers pass the gin context to gRPC calls; with the fallback it is
+	// cancelled when the client goes away.
+	r.ContextWithFallback = true
 
 	url := ginSwagger.URL("swagger/doc.json")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(files.Handler, url))
 
 	c := r.Group("/topic")
-	c.POST("/create", h.CreateLearningTopic)
+	c.POST("/create", auth, h.CreateLearningTopic)
+	c.POST("/import", auth, h.ImportContent)
 	c.GET("/topics", h.GetLearningTopics)
-	c.PUT("/update/:id", h.UpdateLearningTopic)
-	c.DELETE("/delete/:id", h.DeleteLearningTopic)
+	c.PUT("/update/:id", auth, h.UpdateLearningTopic)
+	c.DELETE("/delete/:id", auth, h.DeleteLearningTopic)
 	c.POST("/completed", h.CompletedTopics)
 	c.GET("/getcompleted", h.GetCompletedTopics)
+	c.PUT("/prerequisites/:id", auth, h.SetTopicPrerequisites)
+	c.GET("/prerequisites/:id", h.GetTopicPrerequisites)
+
+	co := r.Group("/courses")
+	co.GET("", h.GetCourses)
+	co.POST("/create", auth, h.CreateCourse)
+	co.GET("/get/:id", h.GetCourse)
+	co.PUT("/update/:id", auth, h.UpdateCourse)
+	co.DELETE("/delete/:id", auth, h.DeleteCourse)
+	co.POST("/modules/create", auth, h.CreateCourseModule)
+	co.PUT("/modules/update/:id", auth, h.UpdateCourseModule)
+	co.DELETE("/modules/delete/:id", auth, h.DeleteCourseModule)
+	co.POST("/enroll", h.EnrollCourse)
+	co.GET("/enrollments", h.GetEnrollments)
+	co.GET("/progress/:id", h.GetCourseProgress)
 
 	q := r.Group("/quiz")
-	q.POST("/create", h.CreateQuiz)
+	q.POST("/create", auth, h.CreateQuiz)
 	q.GET("/quizzes", h.GetQuiz)
-	q.PUT("/update/:id", h.UpdateQuiz)
-	q.DELETE("/delete/:id", h.DeleteQuiz)
+	q.PUT("/update/:id", auth, h.UpdateQuiz)
+	q.DELETE("/delete/:id", auth, h.DeleteQuiz)
+	q.POST("/start", h.StartQuizAttempt)
 	q.POST("/submit", h.SubmitQuiz)
+	q.GET("/attempts", h.GetQuizAttempts)
+	q.GET("/review", h.GetQuizReview)
+	q.POST("/bank/create", auth, h.CreateBankQuestion)
+	q.GET("/bank", h.GetBankQuestions)
+	q.DELETE("/bank/delete/:id", auth, h.DeleteBankQuestion)
 
 	rs := r.Group("/extra_resources")
-	rs.POST("/create", h.CreateExtraResourses)
+	rs.POST("/create", auth, h.CreateExtraResourses)
 	rs.GET("/get", h.GetExtraResourses)
-	rs.PUT("/update/:id", h.UpdateExtraResourses)
-	rs.DELETE("/delete/:id", h.DeleteExtraResourses)
+	rs.PUT("/update/:id", auth, h.UpdateExtraResourses)
+	rs.DELETE("/delete/:id", auth, h.DeleteExtraResourses)
 	rs.POST("/completed", h.CompletedExtraResources)
 
+	ct := r.Group("/content", auth)
+	ct.GET("/revisions", h.GetContentRevisions)
+	ct.GET("/versions/:kind/:id", h.GetContentVersions)
+	ct.GET("/diff/:kind/:id", h.DiffContent)
+	ct.POST("/submit/:kind/:id", h.SubmitContent)
+	ct.POST("/approve/:kind/:id", h.ApproveContent)
+	ct.POST("/reject/:kind/:id", h.RejectContent)
+	ct.POST("/archive/:kind/:id", h.ArchiveContent)
+	ct.POST("/rollback/:kind/:id", h.RollbackContent)
+
 	ps := r.Group("/progress")
+	ps.GET("", h.GetLearningProgress)
 	ps.GET("/get", h.GetLearningProgress)
+	ps.GET("/streak", h.GetStreak)
+	ps.POST("/streak/freeze", h.BuyStreakFreeze)
+	ps.PUT("/goal", h.SetDailyGoal)
 
 	rn := r.Group("/recommendations")
 	rn.POST("/create", h.CreateLearningRecommendations)
 	rn.GET("/get", h.GetLearningRecommendations)
+	rn.POST("/refresh", h.RefreshRecommendations)
+
+	rv := r.Group("/review")
+	rv.GET("/due", h.GetDueReviews)
+	rv.POST("/answer", h.AnswerReview)
 
 	f := r.Group("/feedback")
 	f.POST("/create", h.CreateLearningFeedback)
 	f.GET("/get", h.GetLearningFeedback)
+	f.GET("/moderation", auth, h.GetFeedbackModerationQueue)
+	f.POST("/moderate/:id", auth, h.ModerateFeedback)
 
 	hw := r.Group("/homeworks")
-	hw.POST("/create", h.CreateLearningHomeworks)
+	hw.POST("/create", auth, h.CreateLearningHomeworks)
 	hw.GET("/get", h.GetLearningHomeworks)
 	hw.POST("/submit", h.SubmitHomework)
+	hw.POST("/assign", auth, h.AssignHomework)
+	hw.GET("/submissions", auth, h.GetHomeworkSubmissions)
+	hw.POST("/grade", auth, h.GradeHomework)
+
+	fi := r.Group("/files")
+	fi.POST("/upload-url", h.CreateUpload)
+	fi.POST("/complete/:id", h.CompleteUpload)
+	fi.GET("/download-url/:id", h.GetDownloadUrl)
+	fi.PUT("/avatar", h.SetAvatar)
+	fi.GET("/avatar", h.GetAvatar)
+	fi.GET("/blob/*key", h.Blob)
+	fi.HEAD("/blob/*key", h.Blob)
+	fi.PUT("/blob/*key", h.Blob)
+
+	r.GET("/search", h.Search)
+
+	gr := r.Group("/groups")
+	gr.POST("/create", auth, h.CreateStudyGroup)
+	gr.PUT("/members/:id", auth, h.SetStudyGroupMembers)
+	gr.GET("/get/:id", h.GetStudyGroup)
 
 	l := r.Group("/level")
 	l.POST("/create", h.CreateGameLevel)
@@ -93,5 +162,28 @@ func NewGin(h *handler.Handler) *gin.Engine {
 	g := r.Group("/game")
 	g.GET("/leaderboard", h.GetGameLeaderboard)
 
+	x := r.Group("/xp")
+	x.GET("", h.GetXp)
+	x.GET("/history", h.GetXpHistory)
+	x.POST("/reconcile", auth, h.ReconcileXp)
+
+	ac := r.Group("/achievements")
+	ac.GET("", h.GetAchievements)
+	ac.GET("/user", h.GetUserAchievements)
+	ac.POST("/create", auth, h.CreateAchievement)
+	ac.DELETE("/delete/:id", auth, h.DeleteAchievement)
+	ac.POST("/backfill", auth, h.BackfillAchievements)
+
+	rt := r.Group("/realtime")
+	rt.GET("/ws", h.RealtimeWebSocket)
+	rt.GET("/sse", h.RealtimeSSE)
+
+	n := r.Group("/notifications")
+	n.GET("", h.GetNotifications)
+	n.PUT("/read", h.MarkNotificationsRead)
+	n.GET("/preferences", h.GetNotificationPreferences)
+	n.PUT("/preferences", h.UpdateNotificationPreferences)
+	n.POST("/push-subscription", h.SavePushSubscription)
+
 	return r
 }
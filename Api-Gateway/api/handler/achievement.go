@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAchievement creates an achievement rule
+// @Summary Create achievement
+// @Description Create an achievement rule: a badge, and optional XP of at most 500, awarded once a user's metric reaches the threshold. Metrics: topics_completed, resources_completed, quizzes_finished, quizzes_perfect, homeworks_submitted, streak_days, xp, game_levels. level_id and max_seconds narrow game_levels to one level and to completions faster than that. Existing users are backfilled.
+// @Tags achievements
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param achievement body pb.CreateAchievementRequest true "Create achievement"
+// @Success 200 {object} pb.CreateAchievementResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /achievements/create [post]
+func (h *Handler) CreateAchievement(ctx *gin.Context) {
+	req := pb.CreateAchievementRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateAchievementResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.CreateAchievement(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateAchievementResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetAchievements lists the achievement rules
+// @Summary Get achievements
+// @Description Get the achievement rules users can earn
+// @Tags achievements
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param metric query string false "Metric, or several separated by commas"
+// @Success 200 {object} pb.GetAchievementsResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /achievements [get]
+func (h *Handler) GetAchievements(ctx *gin.Context) {
+	req := pb.GetAchievementsRequest{Metric: ctx.Query("metric")}
+
+	res, err := h.Learning.GetAchievements(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetUserAchievements lists the achievements of a user
+// @Summary Get user achievements
+// @Description Get every achievement with the user's progress on it and when it was awarded, or only the awarded ones
+// @Tags achievements
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param awarded_only query bool false "Only awarded achievements"
+// @Success 200 {object} pb.GetUserAchievementsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /achievements/user [get]
+func (h *Handler) GetUserAchievements(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetUserAchievementsRequest{
+		UserId:      userId,
+		AwardedOnly: ctx.Query("awarded_only") == "true",
+	}
+
+	res, err := h.Learning.GetUserAchievements(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// DeleteAchievement retires an achievement rule
+// @Summary Delete achievement
+// @Description Retire an achievement rule. Users who earned it keep the badge.
+// @Tags achievements
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Achievement ID"
+// @Success 200 {object} pb.DeleteAchievementResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /achievements/delete/{id} [delete]
+func (h *Handler) DeleteAchievement(ctx *gin.Context) {
+	req := pb.DeleteAchievementRequest{Id: ctx.Param("id")}
+
+	res, err := h.Learning.DeleteAchievement(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.DeleteAchievementResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// BackfillAchievements re-evaluates achievements for every user
+// @Summary Backfill achievements
+// @Description Queue an evaluation of every user against the achievement rules on a metric, or all of them
+// @Tags achievements
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pb.BackfillAchievementsRequest true "Metric to backfill, empty for all"
+// @Success 200 {object} pb.BackfillAchievementsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /achievements/backfill [post]
+func (h *Handler) BackfillAchievements(ctx *gin.Context) {
+	req := pb.BackfillAchievementsRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.BackfillAchievementsResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.BackfillAchievements(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.BackfillAchievementsResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitContent sends a draft to review
+// @Summary Submit content for review
+// @Description Send the draft of a topic, quiz or extra resource to review. Only the author who last edited it may submit it, and it cannot be edited until a reviewer approves or rejects it.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param action body pb.ContentActionRequest true "An empty object"
+// @Success 200 {object} pb.ContentActionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/submit/{kind}/{id} [post]
+func (h *Handler) SubmitContent(ctx *gin.Context) {
+	req, ok := bindContentAction(ctx)
+	if !ok {
+		return
+	}
+	res, err := h.Learning.SubmitContent(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ContentActionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// ApproveContent publishes a revision in review
+// @Summary Approve content
+// @Description Publish the revision of a topic, quiz or extra resource in review as its next version, which learners see from then on. Reviewers cannot approve their own changes.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param action body pb.ContentActionRequest true "An optional comment"
+// @Success 200 {object} pb.ContentActionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/approve/{kind}/{id} [post]
+func (h *Handler) ApproveContent(ctx *gin.Context) {
+	req, ok := bindContentAction(ctx)
+	if !ok {
+		return
+	}
+	res, err := h.Learning.ApproveContent(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ContentActionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// RejectContent sends a revision in review back to its author
+// @Summary Reject content
+// @Description Send the revision of a topic, quiz or extra resource in review back to its author as a draft. A rejection needs a comment, which is sent to the author.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param action body pb.ContentActionRequest true "Comment"
+// @Success 200 {object} pb.ContentActionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/reject/{kind}/{id} [post]
+func (h *Handler) RejectContent(ctx *gin.Context) {
+	req, ok := bindContentAction(ctx)
+	if !ok {
+		return
+	}
+	res, err := h.Learning.RejectContent(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ContentActionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// ArchiveContent takes published content away from learners
+// @Summary Archive content
+// @Description Archive a published topic, quiz or extra resource, which hides it from learners. Its versions are kept, and rolling back to one publishes it again.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param action body pb.ContentActionRequest true "An optional comment"
+// @Success 200 {object} pb.ContentActionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/archive/{kind}/{id} [post]
+func (h *Handler) ArchiveContent(ctx *gin.Context) {
+	req, ok := bindContentAction(ctx)
+	if !ok {
+		return
+	}
+	res, err := h.Learning.ArchiveContent(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ContentActionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// bindContentAction reads a workflow step's comment and takes the kind and
+// id from the path and who takes the step from the token.
+func bindContentAction(ctx *gin.Context) (*pb.ContentActionRequest, bool) {
+	userId, role, ok := caller(ctx)
+	if !ok {
+		return nil, false
+	}
+	req := pb.ContentActionRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.ContentActionResponse{Message: "Invalid input"})
+		return nil, false
+	}
+	req.Kind = ctx.Param("kind")
+	req.Id = ctx.Param("id")
+	req.UserId, req.Role = userId, role
+	return &req, true
+}
+
+// RollbackContent publishes an earlier version again
+// @Summary Roll back content
+// @Description Publish an earlier version of a topic, quiz or extra resource again, as a new version that records which one it rolls back to. An archived item is published again this way.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param rollback body pb.RollbackContentRequest true "Version and an optional comment"
+// @Success 200 {object} pb.ContentActionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/rollback/{kind}/{id} [post]
+func (h *Handler) RollbackContent(ctx *gin.Context) {
+	userId, role, ok := caller(ctx)
+	if !ok {
+		return
+	}
+	req := pb.RollbackContentRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.ContentActionResponse{Message: "Invalid input"})
+		return
+	}
+	req.Kind = ctx.Param("kind")
+	req.Id = ctx.Param("id")
+	req.UserId, req.Role = userId, role
+
+	res, err := h.Learning.RollbackContent(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ContentActionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetContentVersions lists the version history of an item
+// @Summary Get content versions
+// @Description Get the status of a topic, quiz or extra resource and its revisions: the open draft or revision in review first, then the published versions newest first.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Success 200 {object} pb.GetContentVersionsResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/versions/{kind}/{id} [get]
+func (h *Handler) GetContentVersions(ctx *gin.Context) {
+	req := pb.GetContentVersionsRequest{
+		Kind: ctx.Param("kind"),
+		Id:   ctx.Param("id"),
+	}
+	res, err := h.Learning.GetContentVersions(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// DiffContent compares two versions of an item
+// @Summary Diff content versions
+// @Description Compare two versions of a topic, quiz or extra resource field by field. Without from, the published version is compared; without to, the open draft or revision in review.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Content kind" Enums(topic, quiz, extra_resource)
+// @Param id path string true "Content ID"
+// @Param from query int false "Version to compare from (default the published version)"
+// @Param to query int false "Version to compare to (default the open revision)"
+// @Success 200 {object} pb.DiffContentResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/diff/{kind}/{id} [get]
+func (h *Handler) DiffContent(ctx *gin.Context) {
+	from, err := queryInt32(ctx, "from")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := queryInt32(ctx, "to")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.DiffContentRequest{
+		Kind: ctx.Param("kind"),
+		Id:   ctx.Param("id"),
+		From: from,
+		To:   to,
+	}
+	res, err := h.Learning.DiffContent(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetContentRevisions lists revisions across content
+// @Summary Get content revisions
+// @Description Get revisions of topics, quizzes and extra resources, such as those waiting for review (status in_review, oldest first by default) or an author's drafts.
+// @Tags content
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind query string false "Kinds, comma separated: topic, quiz, extra_resource"
+// @Param status query string false "Statuses, comma separated: draft, in_review, published"
+// @Param author_id query string false "Author ID"
+// @Param item_id query string false "Content ID"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: updated_at, created_at"
+// @Param sort_order query string false "asc or desc (default desc, asc for in_review)"
+// @Success 200 {object} pb.GetContentRevisionsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /content/revisions [get]
+func (h *Handler) GetContentRevisions(ctx *gin.Context) {
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetContentRevisionsRequest{
+		Kind:      ctx.Query("kind"),
+		Status:    ctx.Query("status"),
+		AuthorId:  ctx.Query("author_id"),
+		ItemId:    ctx.Query("item_id"),
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+		PageToken: p.PageToken,
+		SortBy:    p.SortBy,
+		SortOrder: p.SortOrder,
+	}
+	res, err := h.Learning.GetContentRevisions(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
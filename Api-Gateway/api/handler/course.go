@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCourse creates a course
+// @Summary Create course
+// @Description Create an empty course. Content is added to it as modules.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param course body pb.CreateCourseRequest true "Create course"
+// @Success 200 {object} pb.CreateCourseResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/create [post]
+func (h *Handler) CreateCourse(ctx *gin.Context) {
+	req := pb.CreateCourseRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateCourseResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.CreateCourse(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateCourseResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetCourses lists courses
+// @Summary Get courses
+// @Description Get courses, filtered and paged
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param title query string false "Title contains"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, title"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
+// @Success 200 {object} pb.GetCoursesResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses [get]
+func (h *Handler) GetCourses(ctx *gin.Context) {
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetCoursesRequest{
+		Title:       ctx.Query("title"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
+
+	res, err := h.Learning.GetCourses(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetCourse gets a course
+// @Summary Get course
+// @Description Get a course with its modules and their items, in order
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Course ID"
+// @Success 200 {object} pb.GetCourseResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/get/{id} [get]
+func (h *Handler) GetCourse(ctx *gin.Context) {
+	req := pb.GetCourseRequest{Id: ctx.Param("id")}
+
+	res, err := h.Learning.GetCourse(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// UpdateCourse updates a course
+// @Summary Update course
+// @Description Update the title and description of a course
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Course ID"
+// @Param course body pb.UpdateCourseRequest true "Update course"
+// @Success 200 {object} pb.UpdateCourseResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/update/{id} [put]
+func (h *Handler) UpdateCourse(ctx *gin.Context) {
+	req := pb.UpdateCourseRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.UpdateCourseResponse{Message: "Invalid input"})
+		return
+	}
+	req.Id = ctx.Param("id")
+
+	res, err := h.Learning.UpdateCourse(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.UpdateCourseResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// DeleteCourse deletes a course
+// @Summary Delete course
+// @Description Delete a course. Its content and enrollments are kept.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Course ID"
+// @Success 200 {object} pb.DeleteCourseResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/delete/{id} [delete]
+func (h *Handler) DeleteCourse(ctx *gin.Context) {
+	req := pb.DeleteCourseRequest{Id: ctx.Param("id")}
+
+	res, err := h.Learning.DeleteCourse(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.DeleteCourseResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// CreateCourseModule adds a module to a course
+// @Summary Create course module
+// @Description Add a module to a course, at the end unless a position is given. Items are topics, quizzes, extra resources or homeworks (type topic, quiz, extra_resource, homework) and keep the order given.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param module body pb.CreateCourseModuleRequest true "Create module"
+// @Success 200 {object} pb.CreateCourseModuleResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/modules/create [post]
+func (h *Handler) CreateCourseModule(ctx *gin.Context) {
+	req := pb.CreateCourseModuleRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateCourseModuleResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.CreateCourseModule(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateCourseModuleResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// UpdateCourseModule updates a course module
+// @Summary Update course module
+// @Description Rename or move a module and replace its items
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Module ID"
+// @Param module body pb.UpdateCourseModuleRequest true "Update module"
+// @Success 200 {object} pb.UpdateCourseModuleResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/modules/update/{id} [put]
+func (h *Handler) UpdateCourseModule(ctx *gin.Context) {
+	req := pb.UpdateCourseModuleRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.UpdateCourseModuleResponse{Message: "Invalid input"})
+		return
+	}
+	req.Id = ctx.Param("id")
+
+	res, err := h.Learning.UpdateCourseModule(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.UpdateCourseModuleResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// DeleteCourseModule deletes a course module
+// @Summary Delete course module
+// @Description Remove a module from its course. The content it listed is kept.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Module ID"
+// @Success 200 {object} pb.DeleteCourseModuleResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/modules/delete/{id} [delete]
+func (h *Handler) DeleteCourseModule(ctx *gin.Context) {
+	req := pb.DeleteCourseModuleRequest{Id: ctx.Param("id")}
+
+	res, err := h.Learning.DeleteCourseModule(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.DeleteCourseModuleResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// EnrollCourse enrolls a user in a course
+// @Summary Enroll in course
+// @Description Enroll the signed-in user in a course. Enrolling again changes nothing.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param enrollment body pb.EnrollCourseRequest true "Enroll"
+// @Success 200 {object} pb.EnrollCourseResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/enroll [post]
+func (h *Handler) EnrollCourse(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.EnrollCourseRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.EnrollCourseResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.EnrollCourse(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.EnrollCourseResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetEnrollments lists the courses of a user
+// @Summary Get enrollments
+// @Description Get the courses the signed-in user is enrolled in, with their progress in each
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.GetEnrollmentsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/enrollments [get]
+func (h *Handler) GetEnrollments(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetEnrollmentsRequest{UserId: userId}
+
+	res, err := h.Learning.GetEnrollments(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetCourseProgress gets a user's progress through a course
+// @Summary Get course progress
+// @Description Get, module by module, which items of a course the user has finished and which are locked until prerequisite topics are completed
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Course ID"
+// @Success 200 {object} pb.GetCourseProgressResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /courses/progress/{id} [get]
+func (h *Handler) GetCourseProgress(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetCourseProgressRequest{CourseId: ctx.Param("id"), UserId: userId}
+
+	res, err := h.Learning.GetCourseProgress(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SetTopicPrerequisites sets the prerequisites of a topic
+// @Summary Set topic prerequisites
+// @Description Replace the topics that must be completed before a topic, and its quizzes, unlock. Changes that would make prerequisites circular are refused.
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Topic ID"
+// @Param prerequisites body pb.SetTopicPrerequisitesRequest true "Prerequisite topic IDs"
+// @Success 200 {object} pb.SetTopicPrerequisitesResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /topic/prerequisites/{id} [put]
+func (h *Handler) SetTopicPrerequisites(ctx *gin.Context) {
+	req := pb.SetTopicPrerequisitesRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.SetTopicPrerequisitesResponse{Message: "Invalid input"})
+		return
+	}
+	req.TopicId = ctx.Param("id")
+
+	res, err := h.Learning.SetTopicPrerequisites(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.SetTopicPrerequisitesResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetTopicPrerequisites gets the prerequisites of a topic
+// @Summary Get topic prerequisites
+// @Description Get the topics that must be completed before a topic unlocks
+// @Tags courses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Topic ID"
+// @Success 200 {object} pb.GetTopicPrerequisitesResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /topic/prerequisites/{id} [get]
+func (h *Handler) GetTopicPrerequisites(ctx *gin.Context) {
+	req := pb.GetTopicPrerequisitesRequest{TopicId: ctx.Param("id")}
+
+	res, err := h.Learning.GetTopicPrerequisites(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateUpload registers a file before it is uploaded
+// @Summary Create upload
+// @Description Register a file for a purpose (resource, avatar or homework) with its name, content type, size and hex sha256, and get a pre-signed URL to PUT it to with the headers given. Each purpose takes its own content types, up to 5 MB for avatars, 25 MB for homework and 100 MB for resources. Every file is uploaded; content already stored is shared once /files/complete has checked the upload.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param upload body pb.CreateUploadRequest true "File to upload"
+// @Success 200 {object} pb.CreateUploadResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /files/upload-url [post]
+func (h *Handler) CreateUpload(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.CreateUploadRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateUploadResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.CreateUpload(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateUploadResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// CompleteUpload marks an uploaded file ready
+// @Summary Complete upload
+// @Description Check an upload against the size and sha256 it was registered with, and its content against its type. A file that matches is ready to use; one that does not is dropped and has to be registered again.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Param upload body pb.CompleteUploadRequest true "Owner of the file"
+// @Success 200 {object} pb.CompleteUploadResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /files/complete/{id} [post]
+func (h *Handler) CompleteUpload(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.CompleteUploadRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CompleteUploadResponse{Message: "Invalid input"})
+		return
+	}
+	req.Id = ctx.Param("id")
+	req.UserId = userId
+
+	res, err := h.Learning.CompleteUpload(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CompleteUploadResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetDownloadUrl gets a link to download a file
+// @Summary Get download URL
+// @Description Get a pre-signed URL to download a ready file, good for a few minutes. Homework files are only given to their owner here; reviewers find links in the submissions.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "File ID"
+// @Success 200 {object} pb.GetDownloadUrlResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /files/download-url/{id} [get]
+func (h *Handler) GetDownloadUrl(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := &pb.GetDownloadUrlRequest{Id: ctx.Param("id"), UserId: userId}
+
+	res, err := h.Learning.GetDownloadUrl(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SetAvatar sets a user's avatar
+// @Summary Set avatar
+// @Description Make a ready avatar upload of the user their avatar. An empty file_id removes it.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param avatar body pb.SetAvatarRequest true "Avatar"
+// @Success 200 {object} pb.SetAvatarResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /files/avatar [put]
+func (h *Handler) SetAvatar(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.SetAvatarRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.SetAvatarResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.SetAvatar(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.SetAvatarResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetAvatar gets a link to a user's avatar
+// @Summary Get avatar
+// @Description Get a pre-signed URL to the signed-in user's avatar, good for a few minutes.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.GetAvatarResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /files/avatar [get]
+func (h *Handler) GetAvatar(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := &pb.GetAvatarRequest{UserId: userId}
+
+	res, err := h.Learning.GetAvatar(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// Blob relays uploads and downloads to the learning service's local blob
+// storage. The signature in a pre-signed URL is what authorizes it, so no
+// token is needed.
+func (h *Handler) Blob(ctx *gin.Context) {
+	ctx.Request.URL.Path = ctx.Param("key")
+	ctx.Request.URL.RawPath = ""
+	h.Blobs.ServeHTTP(ctx.Writer, ctx.Request)
+}
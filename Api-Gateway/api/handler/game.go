@@ -1,14 +1,13 @@
 package handler
 
 import (
-	"context"
+	"encoding/json"
 	"net/http"
 
 	pb "api-gateway/genproto/game"
+	"api-gateway/realtime"
 
 	"github.com/gin-gonic/gin"
-
-	pbu "api-gateway/genproto/user"
 )
 
 // CreateGameLevel creates a new game level
@@ -146,7 +145,7 @@ func (h *Handler) BeginGameLevel(ctx *gin.Context) {
 
 // CompleteGameLevel completes a game level
 // @Summary Complete game level
-// @Description Complete Game level
+// @Description Complete a Game level as the signed-in user
 // @Tags game_level
 // @Accept json
 // @Produce json
@@ -157,11 +156,16 @@ func (h *Handler) BeginGameLevel(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /level/complete [post]
 func (h *Handler) CompleteGameLevel(c *gin.Context) {
+	userId, ok := callerId(c)
+	if !ok {
+		return
+	}
 	req := pb.CompleteGameLevelRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, &pb.CompleteGameLevelResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	// Complete the game level
 	res, err := h.Game.CompleteGameLevel(c, &req)
@@ -170,22 +174,29 @@ func (h *Handler) CompleteGameLevel(c *gin.Context) {
 		return
 	}
 
-	// Create GetXpRequest and call GetXp
-	var xpReq pbu.GetXpRequest
-	xpReq.UserId = req.GetUserId()
-	xpReq.Xp = res.Xp // Adjust according to your response struct field containing the earned XP
-
-	xpRes, err := h.User.GetXp(context.Background(), &xpReq)
+	// The learning service books the XP in its ledger. The level id is the
+	// source, so a replayed completion cannot pay out twice.
+	award, err := json.Marshal(gin.H{
+		"user_id":   req.GetUserId(),
+		"xp":        res.Xp,
+		"reason":    "game level completed",
+		"source":    "game_level",
+		"source_id": req.GetLevelId(),
+	})
+	if err == nil {
+		err = h.Kaf.ProduceMessages("learning-xp", award)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to give XP to user"})
 		return
 	}
 
-	// Ensure XP update was successful
-	if xpRes.Message != "success" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to give XP to user"})
-		return
-	}
+	h.produce("level.unlocked", gin.H{"user_id": req.GetUserId(), "level_id": req.GetLevelId()})
+	h.produce("level.completed", gin.H{
+		"user_id":            req.GetUserId(),
+		"level_id":           req.GetLevelId(),
+		"time_spent_seconds": req.GetTimeSpentSeconds(),
+	})
 
 	c.JSON(http.StatusOK, res)
 }
@@ -300,7 +311,7 @@ func (h *Handler) DeleteGameChallenge(ctx *gin.Context) {
 
 // SubmitGameChallengeAnswer submits an answer to a game challenge
 // @Summary Submit game challenge
-// @Description Submit Game challenge
+// @Description Submit the signed-in user's answer to a game challenge. The result is also streamed to their challenges channel.
 // @Tags game_challenge
 // @Accept json
 // @Produce json
@@ -311,11 +322,16 @@ func (h *Handler) DeleteGameChallenge(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /challenge/submit [post]
 func (h *Handler) SubmitChallenge(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.SubmitChallengeRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.SubmitChallengeResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Game.SubmitChallenge(ctx, &req)
 
@@ -323,6 +339,14 @@ func (h *Handler) SubmitChallenge(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, &pb.SubmitChallengeResponse{Message: err.Error()})
 		return
 	}
+
+	// Streamed to the user's challenges channel, for their other devices.
+	h.produce(realtime.TopicChallengeResult, gin.H{
+		"user_id":      req.GetUserId(),
+		"challenge_id": req.GetChallengeId(),
+		"result":       res,
+	})
+
 	ctx.JSON(http.StatusOK, res)
 }
 
@@ -1,10 +1,21 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"api-gateway/api/token"
+	"api-gateway/config"
 	pb "api-gateway/genproto/game"
 	pbl "api-gateway/genproto/learning"
 	pbu "api-gateway/genproto/user"
 	"api-gateway/kafka"
+	"api-gateway/realtime"
+
+	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
@@ -12,13 +23,96 @@ type Handler struct {
 	Game     pb.GameServiceClient
 	User     pbu.UserServiceClient
 	Kaf      kafka.KafkaProducer
+	Realtime *realtime.Hub
+	// Blobs serves the pre-signed URLs of the learning service's local blob
+	// storage.
+	Blobs http.Handler
 }
 
-func NewHandler(learn pbl.LearningServiceClient, game pb.GameServiceClient, user pbu.UserServiceClient, kaff kafka.KafkaProducer) *Handler {
+func NewHandler(learn pbl.LearningServiceClient, game pb.GameServiceClient, user pbu.UserServiceClient, kaff kafka.KafkaProducer, hub *realtime.Hub, blobs http.Handler) *Handler {
 	return &Handler{
 		Learning: learn,
 		Game:     game,
 		User:     user,
         Kaf:      kaff,
+		Realtime: hub,
+		Blobs:    blobs,
+	}
+}
+
+// produce publishes an event after the request it describes has succeeded.
+// Failures are only logged: the caller already got its result.
+func (h *Handler) produce(topic string, event interface{}) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = h.Kaf.ProduceMessages(topic, data)
+	}
+	if err != nil {
+		log.Println("cannot produce messages via kafka", err.Error())
+	}
+}
+
+var cfg = config.Load()
+
+// callerId returns the id of the user the request's token was issued to.
+// Routes about the caller's own data take it from there rather than from a
+// user_id the client sends, so that nobody can act as someone else. Without
+// a valid token it answers 401 itself.
+func callerId(ctx *gin.Context) (string, bool) {
+	userId, _, ok := caller(ctx)
+	return userId, ok
+}
+
+// caller is callerId that also returns the caller's role, for the services
+// that decide what a role may do.
+func caller(ctx *gin.Context) (string, string, bool) {
+	userId, role, err := token.GetUserFromRequest(ctx.Request, &cfg)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return "", "", false
+	}
+	return userId, role, true
+}
+
+// listParams are the paging, sorting and date range query parameters shared
+// by every list route.
+type listParams struct {
+	Limit       int32
+	Offset      int32
+	PageToken   string
+	SortBy      string
+	SortOrder   string
+	CreatedFrom string
+	CreatedTo   string
+}
+
+func parseListParams(ctx *gin.Context) (listParams, error) {
+	p := listParams{
+		PageToken:   ctx.Query("page_token"),
+		SortBy:      ctx.Query("sort_by"),
+		SortOrder:   ctx.Query("sort_order"),
+		CreatedFrom: ctx.Query("created_from"),
+		CreatedTo:   ctx.Query("created_to"),
+	}
+	var err error
+	if p.Limit, err = queryInt32(ctx, "limit"); err != nil {
+		return p, err
+	}
+	if p.Offset, err = queryInt32(ctx, "offset"); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// queryInt32 reads a non-negative integer query parameter; absent means 0.
+func queryInt32(ctx *gin.Context, name string) (int32, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
 	}
+	return int32(n), nil
 }
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AssignHomework assigns a homework to users and study groups
+// @Summary Assign homework
+// @Description Assign a homework to users and to the current members of study groups. A due date given here overrides the homework's for everyone assigned.
+// @Tags homeworks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param assignment body pb.AssignHomeworkRequest true "Assign homework"
+// @Success 200 {object} pb.AssignHomeworkResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /homeworks/assign [post]
+func (h *Handler) AssignHomework(ctx *gin.Context) {
+	req := pb.AssignHomeworkRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.AssignHomeworkResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.AssignHomework(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.AssignHomeworkResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetHomeworkSubmissions lists homework submissions
+// @Summary Get homework submissions
+// @Description List homework submissions, the oldest first. Filter by status=submitted for the ones waiting for review.
+// @Tags homeworks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param homework_id query string false "Homework ID"
+// @Param user_id query string false "User ID"
+// @Param status query string false "Comma separated: submitted, superseded, returned, graded"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, attempt"
+// @Param sort_order query string false "asc or desc (default asc)"
+// @Param created_from query string false "Submitted on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Submitted before or on (YYYY-MM-DD or RFC 3339)"
+// @Success 200 {object} pb.GetHomeworkSubmissionsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /homeworks/submissions [get]
+func (h *Handler) GetHomeworkSubmissions(ctx *gin.Context) {
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetHomeworkSubmissionsRequest{
+		HomeworkId:  ctx.Query("homework_id"),
+		UserId:      ctx.Query("user_id"),
+		Status:      ctx.Query("status"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
+	res, err := h.Learning.GetHomeworkSubmissions(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GradeHomework grades or returns a homework submission
+// @Summary Grade homework
+// @Description Grade a submission waiting for review as the signed-in reviewer or admin, scoring every rubric criterion (or a score out of 100 without a rubric), or return it with a comment for the learner to resubmit. Grading pays XP worth the grade less any late penalty.
+// @Tags homeworks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param grade body pb.GradeHomeworkRequest true "Grade"
+// @Success 200 {object} pb.GradeHomeworkResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /homeworks/grade [post]
+func (h *Handler) GradeHomework(ctx *gin.Context) {
+	userId, role, ok := caller(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GradeHomeworkRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.GradeHomeworkResponse{Message: "Invalid input"})
+		return
+	}
+	req.GraderId, req.GraderRole = userId, role
+
+	res, err := h.Learning.GradeHomework(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.GradeHomeworkResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// CreateStudyGroup creates a study group
+// @Summary Create study group
+// @Description Create a study group that homework can be assigned to
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group body pb.CreateStudyGroupRequest true "Create study group"
+// @Success 200 {object} pb.CreateStudyGroupResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /groups/create [post]
+func (h *Handler) CreateStudyGroup(ctx *gin.Context) {
+	req := pb.CreateStudyGroupRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateStudyGroupResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.CreateStudyGroup(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateStudyGroupResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SetStudyGroupMembers replaces the members of a study group
+// @Summary Set study group members
+// @Description Replace the members of a study group. Homework already assigned through the group stays with users who leave it.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Study group ID"
+// @Param members body pb.SetStudyGroupMembersRequest true "Members"
+// @Success 200 {object} pb.SetStudyGroupMembersResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /groups/members/{id} [put]
+func (h *Handler) SetStudyGroupMembers(ctx *gin.Context) {
+	req := pb.SetStudyGroupMembersRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.SetStudyGroupMembersResponse{Message: "Invalid input"})
+		return
+	}
+	req.GroupId = ctx.Param("id")
+
+	res, err := h.Learning.SetStudyGroupMembers(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.SetStudyGroupMembersResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetStudyGroup retrieves a study group
+// @Summary Get study group
+// @Description Get a study group and its members
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Study group ID"
+// @Success 200 {object} pb.GetStudyGroupResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /groups/get/{id} [get]
+func (h *Handler) GetStudyGroup(ctx *gin.Context) {
+	res, err := h.Learning.GetStudyGroup(ctx, &pb.GetStudyGroupRequest{Id: ctx.Param("id")})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -6,28 +6,34 @@ import (
 	"net/http"
 
 	pb "api-gateway/genproto/learning"
+	"api-gateway/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CreateLearningTopic creates a new topic
 // @Summary Create learning topic
-// @Description Create Learning topic
+// @Description Create a Learning topic as a draft of the signed-in author, hidden from learners until a reviewer publishes it
 // @Tags topic
 // @Accept json
 // @Produce json
 // @Security  		BearerAuth
 // @Param company body pb.CreateLearningTopicRequest true "Create topic"
-// @Success 200 {object} pb.CreateLearningTopicResponse
+// @Success 202 {object} pb.CreateLearningTopicResponse
 // @Failure 400 {string} string "Error while creating company"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /topic/create [post]
 func (h *Handler) CreateLearningTopic(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CreateLearningTopicRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CreateLearningTopicResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 	input, err := json.Marshal(&req)
 	if err != nil {
 		ctx.JSON(400, err.Error())
@@ -42,41 +48,90 @@ func (h *Handler) CreateLearningTopic(ctx *gin.Context) {
 		return
 	}
 
-	res, err := h.Learning.CreateLearningTopic(ctx, &req)
+	ctx.JSON(http.StatusAccepted, &pb.CreateLearningTopicResponse{Message: "accepted"})
+}
 
+// ImportContent queues a bulk import of learning content
+// @Summary Import learning content
+// @Description Queue topics, quizzes, extra resources and homeworks for asynchronous import
+// @Tags topic
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param content body models.ImportContentRequest true "Content to import"
+// @Success 202 {object} string "accepted"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /topic/import [post]
+func (h *Handler) ImportContent(ctx *gin.Context) {
+	req := models.ImportContentRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	input, err := json.Marshal(&req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.CreateLearningTopicResponse{Message: err.Error()})
+		ctx.JSON(400, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, res)
+	err = h.Kaf.ProduceMessages("learning-import", input)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		log.Println("cannot produce messages via kafka", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "accepted"})
 }
 
 // GetLearningTopics retrieves all learning topics
 // @Summary Get learning topics
-// @Description Get Learning topics
+// @Description Get learning topics, each with the average of its accepted review ratings, how many there are and how they spread over 1 to 5 stars
 // @Tags topic
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id query string false "Topic ID"
-// @Param name query string false "Topic Name"
-// @Param description query string false "Topic Description"
-// @Param difficulty query string false "Topic Difficulty"
+// @Param name query string false "Name contains"
+// @Param description query string false "Description contains"
+// @Param difficulty query string false "Difficulty, or several separated by commas"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, name, difficulty"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetLearningTopicsResponse
 // @Failure 400 {string} string "Error while getting topics"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /topic/topics [get]
 func (h *Handler) GetLearningTopics(ctx *gin.Context) {
-	req := pb.GetLearningTopicsRequest{}
-	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, &pb.GetLearningTopicsResponse{})
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req := pb.GetLearningTopicsRequest{
+		Id:          ctx.Query("id"),
+		Name:        ctx.Query("name"),
+		Description: ctx.Query("description"),
+		Difficulty:  ctx.Query("difficulty"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 
 	res, err := h.Learning.GetLearningTopics(ctx, &req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetLearningTopicsResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -84,7 +139,7 @@ func (h *Handler) GetLearningTopics(ctx *gin.Context) {
 
 // UpdateLearningTopic updates a learning topic
 // @Summary Update learning topic
-// @Description Update Learning topic
+// @Description Save changes to a Learning topic in the signed-in author's draft. Learners keep seeing the published version until a reviewer publishes the draft.
 // @Tags topic
 // @Accept json
 // @Produce json
@@ -96,12 +151,17 @@ func (h *Handler) GetLearningTopics(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /topic/update/{id} [put]
 func (h *Handler) UpdateLearningTopic(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	id := ctx.Param("id")
 	req := pb.UpdateLearningTopicRequest{Id: id}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.UpdateLearningTopicResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 
 	res, err := h.Learning.UpdateLearningTopic(ctx, &req)
 
@@ -114,7 +174,7 @@ func (h *Handler) UpdateLearningTopic(ctx *gin.Context) {
 
 // DeleteLearningTopic deletes a learning topic
 // @Summary Delete learning topic
-// @Description Delete Learning topic
+// @Description Delete a Learning topic that has never been published; published ones are archived instead
 // @Tags topic
 // @Accept json
 // @Produce json
@@ -143,7 +203,7 @@ func (h *Handler) DeleteLearningTopic(ctx *gin.Context) {
 
 // CompletedTopics marks a topic as completed
 // @Summary Mark topic as completed
-// @Description Mark Learning topic as completed
+// @Description Mark a Learning topic as completed by the signed-in user. The XP paid is set by the server; any xp_earned sent is ignored.
 // @Tags topic
 // @Accept json
 // @Produce json
@@ -154,11 +214,16 @@ func (h *Handler) DeleteLearningTopic(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /topic/completed [post]
 func (h *Handler) CompletedTopics(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CompletedTopicsRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CompletedTopicsResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Learning.CompletedTopics(ctx, &req)
 
@@ -176,16 +241,52 @@ func (h *Handler) CompletedTopics(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param  query  query   pb.GetCompletedTopicsRequest true  "Query parameter"
+// @Param id query string false "Completion ID"
+// @Param user_id query string false "User ID"
+// @Param topic_id query string false "Topic ID, or several separated by commas"
+// @Param min_xp query int false "Minimum XP earned"
+// @Param max_xp query int false "Maximum XP earned"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, xp_earned"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetCompletedTopicsResponse
 // @Failure 400 {string} string "Error while getting completed topics"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /topic/getcompleted [get]
 func (h *Handler) GetCompletedTopics(ctx *gin.Context) {
-	req := &pb.GetCompletedTopicsRequest{}
-	req.Id = ctx.Query("id")
-	req.TopicId = ctx.Query("topic_id")
-	req.UserId = ctx.Query("user_id")
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	minXp, err := queryInt32(ctx, "min_xp")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maxXp, err := queryInt32(ctx, "max_xp")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &pb.GetCompletedTopicsRequest{
+		Id:          ctx.Query("id"),
+		TopicId:     ctx.Query("topic_id"),
+		UserId:      ctx.Query("user_id"),
+		MinXp:       minXp,
+		MaxXp:       maxXp,
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 
 	res, err := h.Learning.GetCompletedTopics(ctx, req)
 	if err != nil {
@@ -197,7 +298,7 @@ func (h *Handler) GetCompletedTopics(ctx *gin.Context) {
 
 // CreateQuiz creates a new quiz
 // @Summary Create quiz
-// @Description Create quiz
+// @Description Create a quiz from its own questions, or a template whose rules draw a random paper from the question bank for every attempt. It starts as a draft of the signed-in author, hidden from learners until a reviewer publishes it.
 // @Tags quiz
 // @Accept json
 // @Produce json
@@ -208,11 +309,16 @@ func (h *Handler) GetCompletedTopics(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /quiz/create [post]
 func (h *Handler) CreateQuiz(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CreateQuizRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CreateQuizResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 
 	res, err := h.Learning.CreateQuiz(ctx, &req)
 
@@ -230,20 +336,42 @@ func (h *Handler) CreateQuiz(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id query string false "Quiz ID"
-// @Param topic_id query string false "Topic id"
+// @Param topic_id query string false "Topic ID, or several separated by commas"
+// @Param title query string false "Title contains"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, title"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Security BearerAuth
 // @Success 200 {object} pb.GetQuizResponse
 // @Failure 400 {string} string "Error while getting quizzes"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /quiz/quizzes [get]
 func (h *Handler) GetQuiz(ctx *gin.Context) {
-	req := &pb.GetQuizRequest{}
-	req.Id = ctx.Query("id")
-	req.TopicId = ctx.Query("topic_id")
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &pb.GetQuizRequest{
+		Id:          ctx.Query("id"),
+		TopicId:     ctx.Query("topic_id"),
+		Title:       ctx.Query("title"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 	res, err := h.Learning.GetQuiz(ctx, req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetQuizResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -251,7 +379,7 @@ func (h *Handler) GetQuiz(ctx *gin.Context) {
 
 // UpdateQuiz updates a quiz
 // @Summary Update quiz
-// @Description Update quiz
+// @Description Save changes to a quiz in the signed-in author's draft. Questions and rules left out are kept. Learners keep seeing the published version until a reviewer publishes the draft.
 // @Tags quiz
 // @Accept json
 // @Produce json
@@ -263,11 +391,16 @@ func (h *Handler) GetQuiz(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /quiz/update/{id} [put]
 func (h *Handler) UpdateQuiz(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.UpdateQuizRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.UpdateQuizResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 
 	res, err := h.Learning.UpdateQuiz(ctx, &req)
 
@@ -280,7 +413,7 @@ func (h *Handler) UpdateQuiz(ctx *gin.Context) {
 
 // DeleteQuiz deletes a quiz
 // @Summary Delete quiz
-// @Description Delete quiz
+// @Description Delete a quiz that has never been published; published ones are archived instead
 // @Tags quiz
 // @Accept json
 // @Produce json
@@ -308,7 +441,7 @@ func (h *Handler) DeleteQuiz(ctx *gin.Context) {
 
 // SubmitQuiz submits a quiz
 // @Summary Submit quiz
-// @Description Submit quiz
+// @Description Submit the signed-in user's answers to an attempt. Without attempt_id a new attempt is started and closed at once.
 // @Tags quiz
 // @Accept json
 // @Produce json
@@ -319,11 +452,16 @@ func (h *Handler) DeleteQuiz(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /quiz/submit [post]
 func (h *Handler) SubmitQuiz(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.SubmitQuizRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.SubmitQuizResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Learning.SubmitQuiz(ctx, &req)
 
@@ -334,9 +472,214 @@ func (h *Handler) SubmitQuiz(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, res)
 }
 
+// StartQuizAttempt starts or resumes an attempt at a quiz
+// @Summary Start quiz attempt
+// @Description Start the signed-in user's attempt at a quiz, or resume the open one. The questions are returned without answers.
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param attempt body pb.StartQuizAttemptRequest true "Quiz"
+// @Success 200 {object} pb.StartQuizAttemptResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/start [post]
+func (h *Handler) StartQuizAttempt(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.StartQuizAttemptRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.StartQuizAttempt(ctx, &req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetQuizAttempts lists a user's attempts at a quiz
+// @Summary Get quiz attempts
+// @Description Get the signed-in user's attempts at a quiz with their best score and the XP earned
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param quiz_id query string true "Quiz ID"
+// @Success 200 {object} pb.GetQuizAttemptsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/attempts [get]
+func (h *Handler) GetQuizAttempts(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetQuizAttemptsRequest{
+		QuizId: ctx.Query("quiz_id"),
+		UserId: userId,
+	}
+	if req.QuizId == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "quiz_id is required"})
+		return
+	}
+
+	res, err := h.Learning.GetQuizAttempts(ctx, &req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetQuizReview reviews a closed quiz attempt
+// @Summary Review quiz attempt
+// @Description Show the signed-in user's answers for a closed attempt. The correct answers and their explanations are only shown once no attempts are left or the quiz has been passed.
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param attempt_id query string true "Attempt ID"
+// @Success 200 {object} pb.GetQuizReviewResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/review [get]
+func (h *Handler) GetQuizReview(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetQuizReviewRequest{
+		AttemptId: ctx.Query("attempt_id"),
+		UserId:    userId,
+	}
+	if req.AttemptId == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "attempt_id is required"})
+		return
+	}
+
+	res, err := h.Learning.GetQuizReview(ctx, &req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// CreateBankQuestion adds a question to the question bank
+// @Summary Create bank question
+// @Description Add a tagged question to the bank that quiz templates draw from
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param question body pb.CreateBankQuestionRequest true "Question with its answer key, tags and topics"
+// @Success 200 {object} pb.CreateBankQuestionResponse
+// @Failure 400 {string} string "Error while creating bank question"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/bank/create [post]
+func (h *Handler) CreateBankQuestion(ctx *gin.Context) {
+	req := pb.CreateBankQuestionRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.CreateBankQuestionResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.CreateBankQuestion(ctx, &req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.CreateBankQuestionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetBankQuestions lists the question bank
+// @Summary Get bank questions
+// @Description Get bank questions without their answers
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Param topic_id query string false "Topic ID"
+// @Param difficulty query string false "Difficulty, or several separated by commas"
+// @Param tag query string false "Tag"
+// @Param prompt query string false "Prompt contains"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, difficulty"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Security BearerAuth
+// @Success 200 {object} pb.GetBankQuestionsResponse
+// @Failure 400 {string} string "Error while getting bank questions"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/bank [get]
+func (h *Handler) GetBankQuestions(ctx *gin.Context) {
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &pb.GetBankQuestionsRequest{
+		TopicId:    ctx.Query("topic_id"),
+		Difficulty: ctx.Query("difficulty"),
+		Tag:        ctx.Query("tag"),
+		Prompt:     ctx.Query("prompt"),
+		Limit:      p.Limit,
+		Offset:     p.Offset,
+		PageToken:  p.PageToken,
+		SortBy:     p.SortBy,
+		SortOrder:  p.SortOrder,
+	}
+	res, err := h.Learning.GetBankQuestions(ctx, req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// DeleteBankQuestion removes a question from the question bank
+// @Summary Delete bank question
+// @Description Delete bank question
+// @Tags quiz
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Question ID"
+// @Success 200 {object} pb.DeleteBankQuestionResponse
+// @Failure 400 {string} string "Error while deleting bank question"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /quiz/bank/delete/{id} [delete]
+func (h *Handler) DeleteBankQuestion(ctx *gin.Context) {
+	req := pb.DeleteBankQuestionRequest{}
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.DeleteBankQuestionResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.DeleteBankQuestion(ctx, &req)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.DeleteBankQuestionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
 // CreateExtraResources creates a new extra resource
 // @Summary Create extra resource
-// @Description Create extra resource
+// @Description Create extra resource. Its type is article, video, book, course or podcast, and it starts as a draft of the signed-in author, hidden from learners until a reviewer publishes it. Its metadata may only hold the fields of its type: author, duration_minutes and published_on for articles; author, duration_minutes, provider and published_on for videos; author, isbn, publisher, pages and published_on for books; author, duration_minutes and provider for courses; author, duration_minutes, provider, episode and published_on for podcasts. It links to an http(s) url, checked for reachability in the background, or, with file_id, to a file uploaded for the resource purpose, and covers one or more topic_ids.
 // @Tags extra_resources
 // @Accept json
 // @Produce json
@@ -347,11 +690,16 @@ func (h *Handler) SubmitQuiz(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /extra_resources/create [post]
 func (h *Handler) CreateExtraResourses(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CreateExtraResoursesRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CreateExtraResoursesResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 
 	res, err := h.Learning.CreateExtraResourses(ctx, &req)
 
@@ -364,21 +712,49 @@ func (h *Handler) CreateExtraResourses(ctx *gin.Context) {
 
 // GetExtraResources retrieves all extra resources
 // @Summary Get extra resources
-// @Description Get extra resources
+// @Description Get extra resources with their metadata, topics and url status (unchecked, reachable or unreachable). Given a user_id, each says whether that user has completed it and when.
 // @Tags extra_resources
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param type query string false "Resource types, comma separated: article, video, book, course, podcast"
+// @Param topic_id query string false "Topic ID"
+// @Param url_status query string false "Url statuses, comma separated: unchecked, reachable, unreachable"
+// @Param user_id query string false "User ID to show completion for"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, title, type"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetExtraResourcesResponse
 // @Failure 400 {string} string "Error while getting extra resources"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /extra_resources/get [get]
 func (h *Handler) GetExtraResourses(ctx *gin.Context) {
-	req := pb.GetExtraResourcesRequest{}
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetExtraResourcesRequest{
+		Type:        ctx.Query("type"),
+		TopicId:     ctx.Query("topic_id"),
+		UrlStatus:   ctx.Query("url_status"),
+		UserId:      ctx.Query("user_id"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 	res, err := h.Learning.GetExtraResourses(ctx, &req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetExtraResourcesResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -386,7 +762,7 @@ func (h *Handler) GetExtraResourses(ctx *gin.Context) {
 
 // UpdateExtraResources updates an extra resource
 // @Summary Update extra resource
-// @Description Update extra resource
+// @Description Replace an extra resource in the signed-in author's draft, under the same rules as creating one. Learners keep seeing the published version until a reviewer publishes the draft, and a changed url is checked again then.
 // @Tags extra_resources
 // @Accept json
 // @Produce json
@@ -398,11 +774,16 @@ func (h *Handler) GetExtraResourses(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /extra_resources/update/{id} [put]
 func (h *Handler) UpdateExtraResourses(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.UpdateExtraResoursesRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.UpdateExtraResoursesResponse{Message: "Invalid input"})
 		return
 	}
+	req.AuthorId = userId
 
 	res, err := h.Learning.UpdateExtraResourses(ctx, &req)
 
@@ -415,7 +796,7 @@ func (h *Handler) UpdateExtraResourses(ctx *gin.Context) {
 
 // DeleteExtraResources deletes an extra resource
 // @Summary Delete extra resource
-// @Description Delete extra resource
+// @Description Delete an extra resource that has never been published; published ones are archived instead
 // @Tags extra_resources
 // @Accept json
 // @Produce json
@@ -444,7 +825,7 @@ func (h *Handler) DeleteExtraResourses(ctx *gin.Context) {
 
 // CompletedExtraResources marks an extra resource as completed
 // @Summary Mark extra resource as completed
-// @Description Mark extra resource as completed
+// @Description Mark extra resource as completed by the signed-in user. XP is earned once per resource; completing it again returns the first completion with already_completed set.
 // @Tags extra_resources
 // @Accept json
 // @Produce json
@@ -455,11 +836,16 @@ func (h *Handler) DeleteExtraResourses(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /extra_resources/completed [post]
 func (h *Handler) CompletedExtraResources(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CompletedExtraResourcesRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CompletedExtraResourcesResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Learning.CompletedExtraResources(ctx, &req)
 
@@ -472,22 +858,34 @@ func (h *Handler) CompletedExtraResources(ctx *gin.Context) {
 
 // GetLearningProgress retrieves learning progress
 // @Summary Get learning progress
-// @Description Get learning progress
+// @Description Get the signed-in user's progress through the catalog, per topic and per difficulty, with quizzes passed, resources completed and homework submitted; XP earned per day or week of the date range; the user's numbers in the range against the medians of everyone active in it; and the days left to finish the topics at the user's pace (-1 if unknown)
 // @Tags progress
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param user_id query string true "User ID"
+// @Param from query string false "Range start (YYYY-MM-DD or RFC 3339); default 30 days before to"
+// @Param to query string false "Range end (YYYY-MM-DD or RFC 3339); default now"
+// @Param interval query string false "XP series bucket: day or week (default day)"
 // @Success 200 {object} pb.GetLearningProgressResponse
 // @Failure 400 {string} string "Error while getting learning progress"
 // @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /progress [get]
 // @Router /progress/get [get]
 func (h *Handler) GetLearningProgress(ctx *gin.Context) {
-	id := ctx.Query("user_id")
-	req := pb.GetLearningProgressRequest{UserId: id}
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetLearningProgressRequest{
+		UserId:   userId,
+		From:     ctx.Query("from"),
+		To:       ctx.Query("to"),
+		Interval: ctx.Query("interval"),
+	}
+
 	res, err := h.Learning.GetLearningProgress(ctx, &req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetLearningProgressResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -521,23 +919,76 @@ func (h *Handler) CreateLearningRecommendations(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, res)
 }
 
-// GetLearningRecommendations retrieves all learning recommendations
+// GetLearningRecommendations retrieves a user's learning recommendations
 // @Summary Get learning recommendations
-// @Description Get learning recommendations
+// @Description Get the signed-in user's recommendations: generated ones (type topic, review, extra_resource, game_level), refreshed on learning events and in the background, and any made by hand
 // @Tags recommendations
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param type query string false "Recommendation type"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, name, type, score"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetLearningRecommendationsResponse
 // @Failure 400 {string} string "Error while getting learning recommendations"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /recommendations/get [get]
 func (h *Handler) GetLearningRecommendations(ctx *gin.Context) {
-	req := pb.GetLearningRecommendationsRequest{}
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetLearningRecommendationsRequest{
+		UserId:      userId,
+		Type:        ctx.Query("type"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 	res, err := h.Learning.GetLearningRecommendations(ctx, &req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetLearningRecommendationsResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// RefreshRecommendations recomputes a user's recommendations
+// @Summary Refresh recommendations
+// @Description Recompute the signed-in user's recommendations now and return them, best first
+// @Tags recommendations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.RefreshRecommendationsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /recommendations/refresh [post]
+func (h *Handler) RefreshRecommendations(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.RefreshRecommendationsRequest{UserId: userId}
+
+	res, err := h.Learning.RefreshRecommendations(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.RefreshRecommendationsResponse{Message: err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -545,7 +996,7 @@ func (h *Handler) GetLearningRecommendations(ctx *gin.Context) {
 
 // CreateLearningFeedback creates new learning feedback
 // @Summary Create learning feedback
-// @Description Create learning feedback
+// @Description Review a topic as the signed-in user, with a rating from 1 to 5 and an optional comment. A user has one review per topic: leaving another edits it. Comments flagged for profanity or spam wait in the moderation queue with status pending; the rest are accepted at once. XP is earned once, when the review is first accepted.
 // @Tags feedback
 // @Accept json
 // @Produce json
@@ -556,11 +1007,16 @@ func (h *Handler) GetLearningRecommendations(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /feedback/create [post]
 func (h *Handler) CreateLearningFeedback(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.CreateLearningFeedbackRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.CreateLearningFeedbackResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Learning.CreateLearningFeedback(ctx, &req)
 
@@ -573,21 +1029,126 @@ func (h *Handler) CreateLearningFeedback(ctx *gin.Context) {
 
 // GetLearningFeedback retrieves all learning feedback
 // @Summary Get learning feedback
-// @Description Get learning feedback
+// @Description Get accepted reviews, with their rating, comment and when they were left and last edited.
 // @Tags feedback
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param user_id query string false "User ID"
+// @Param topic_id query string false "Topic ID"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, rating"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetLearningFeedbackResponse
 // @Failure 400 {string} string "Error while getting learning feedback"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /feedback/get [get]
 func (h *Handler) GetLearningFeedback(ctx *gin.Context) {
-	req := pb.GetLearningFeedbackRequest{}
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetLearningFeedbackRequest{
+		UserId:      ctx.Query("user_id"),
+		TopicId:     ctx.Query("topic_id"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 	res, err := h.Learning.GetLearningFeedback(ctx, &req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetLearningFeedbackResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetFeedbackModerationQueue lists reviews waiting for moderation
+// @Summary Get feedback moderation queue
+// @Description Get reviews held for moderation, oldest first by default, with the reason each was flagged. Pass status to see accepted or rejected reviews instead.
+// @Tags feedback
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Statuses, comma separated: pending, accepted, rejected (default pending)"
+// @Param user_id query string false "User ID"
+// @Param topic_id query string false "Topic ID"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, rating"
+// @Param sort_order query string false "asc or desc (default asc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
+// @Success 200 {object} pb.GetLearningFeedbackResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /feedback/moderation [get]
+func (h *Handler) GetFeedbackModerationQueue(ctx *gin.Context) {
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetLearningFeedbackRequest{
+		Status:      ctx.DefaultQuery("status", "pending"),
+		UserId:      ctx.Query("user_id"),
+		TopicId:     ctx.Query("topic_id"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   ctx.DefaultQuery("sort_order", "asc"),
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
+	res, err := h.Learning.GetLearningFeedback(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// ModerateFeedback accepts or rejects a review
+// @Summary Moderate feedback
+// @Description Accept or reject a review as the signed-in reviewer or admin; nobody moderates their own. A rejection needs a reason, which is sent to the author. Accepting a review for the first time earns its author XP.
+// @Tags feedback
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Feedback ID"
+// @Param decision body pb.ModerateFeedbackRequest true "Status (accepted or rejected) and reason"
+// @Success 200 {object} pb.ModerateFeedbackResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /feedback/moderate/{id} [post]
+func (h *Handler) ModerateFeedback(ctx *gin.Context) {
+	userId, role, ok := caller(ctx)
+	if !ok {
+		return
+	}
+	req := pb.ModerateFeedbackRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.ModerateFeedbackResponse{Message: "Invalid input"})
+		return
+	}
+	req.Id = ctx.Param("id")
+	req.ModeratorId, req.ModeratorRole = userId, role
+
+	res, err := h.Learning.ModerateFeedback(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ModerateFeedbackResponse{Message: err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -595,7 +1156,7 @@ func (h *Handler) GetLearningFeedback(ctx *gin.Context) {
 
 // CreateLearningHomeworks creates a new homework
 // @Summary Create homework
-// @Description Create homework
+// @Description Create a homework with an optional due date, late policy, cap on submissions and grading rubric, and assign it to users and study groups. Without any, it is assigned to its owner.
 // @Tags homeworks
 // @Accept json
 // @Produce json
@@ -623,21 +1184,45 @@ func (h *Handler) CreateLearningHomeworks(ctx *gin.Context) {
 
 // GetLearningHomeworks retrieves all homeworks
 // @Summary Get homeworks
-// @Description Get homeworks
+// @Description Get homeworks. Filtered by user, lists those assigned to them with their due date and the status of their latest submission.
 // @Tags homeworks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param user_id query string false "User ID"
+// @Param difficulty query string false "Homework difficulty"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, title, difficulty"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
 // @Success 200 {object} pb.GetLearningHomeworksResponse
 // @Failure 400 {string} string "Error while getting homeworks"
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /homeworks/get [get]
 func (h *Handler) GetLearningHomeworks(ctx *gin.Context) {
-	req := pb.GetLearningHomeworksRequest{}
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := pb.GetLearningHomeworksRequest{
+		UserId:      ctx.Query("user_id"),
+		Difficulty:  ctx.Query("difficulty"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
 	res, err := h.Learning.GetLearningHomeworks(ctx, &req)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, &pb.GetLearningHomeworksResponse{})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, res)
@@ -645,7 +1230,7 @@ func (h *Handler) GetLearningHomeworks(ctx *gin.Context) {
 
 // SubmitHomework submits a homework
 // @Summary Submit homework
-// @Description Submit homework
+// @Description Submit the signed-in user's text, links and attachments for an assigned homework. An attachment is a named url or a homework file uploaded through /files. Resubmitting replaces a submission still waiting for review. XP is paid when a reviewer grades it.
 // @Tags homeworks
 // @Accept json
 // @Produce json
@@ -656,11 +1241,16 @@ func (h *Handler) GetLearningHomeworks(ctx *gin.Context) {
 // @Failure 500 {string} string "500 – Internal Server Error"
 // @Router /homeworks/submit [post]
 func (h *Handler) SubmitHomework(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
 	req := pb.SubmitHomeworkRequest{}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, &pb.SubmitHomeworkResponse{Message: "Invalid input"})
 		return
 	}
+	req.UserId = userId
 
 	res, err := h.Learning.SubmitHomework(ctx, &req)
 
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications retrieves the in-app notifications of a user
+// @Summary Get notifications
+// @Description Get in-app notifications with read/unread state, newest first
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param unread_only query bool false "Only unread notifications"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {object} pb.GetNotificationsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /notifications [get]
+func (h *Handler) GetNotifications(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetNotificationsRequest{UserId: userId}
+	req.UnreadOnly, _ = strconv.ParseBool(ctx.Query("unread_only"))
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	offset, _ := strconv.Atoi(ctx.Query("offset"))
+	req.Limit, req.Offset = int32(limit), int32(offset)
+
+	res, err := h.Learning.GetNotifications(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.GetNotificationsResponse{})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// MarkNotificationsRead marks notifications as read
+// @Summary Mark notifications as read
+// @Description Mark the given notifications as read, or all of them when ids is empty
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pb.MarkNotificationsReadRequest true "Notifications to mark"
+// @Success 200 {object} pb.MarkNotificationsReadResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /notifications/read [put]
+func (h *Handler) MarkNotificationsRead(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.MarkNotificationsReadRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.MarkNotificationsReadResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.MarkNotificationsRead(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.MarkNotificationsReadResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetNotificationPreferences retrieves notification preferences
+// @Summary Get notification preferences
+// @Description Get per-event channel preferences and quiet hours of the signed-in user
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.GetNotificationPreferencesResponse
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /notifications/preferences [get]
+func (h *Handler) GetNotificationPreferences(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.GetNotificationPreferencesRequest{UserId: userId}
+
+	res, err := h.Learning.GetNotificationPreferences(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.GetNotificationPreferencesResponse{})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// UpdateNotificationPreferences updates notification preferences
+// @Summary Update notification preferences
+// @Description Enable or disable channels per event type ("*" for all) and set quiet hours as HH:MM in the given time zone
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param preferences body pb.UpdateNotificationPreferencesRequest true "Preferences"
+// @Success 200 {object} pb.UpdateNotificationPreferencesResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /notifications/preferences [put]
+func (h *Handler) UpdateNotificationPreferences(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.UpdateNotificationPreferencesRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.UpdateNotificationPreferencesResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.UpdateNotificationPreferences(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.UpdateNotificationPreferencesResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SavePushSubscription registers a web push subscription
+// @Summary Save web push subscription
+// @Description Register the browser push subscription of the signed-in user
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param subscription body pb.SavePushSubscriptionRequest true "Push subscription"
+// @Success 200 {object} pb.SavePushSubscriptionResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /notifications/push-subscription [post]
+func (h *Handler) SavePushSubscription(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.SavePushSubscriptionRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.SavePushSubscriptionResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.SavePushSubscription(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.SavePushSubscriptionResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStreak retrieves the learning streak of a user
+// @Summary Get learning streak
+// @Description Get the current and longest streak of the signed-in user, their streak freezes, daily XP goal and the XP earned on each of the last 7 days in their time zone
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.GetStreakResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /progress/streak [get]
+func (h *Handler) GetStreak(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := &pb.GetStreakRequest{UserId: userId}
+
+	res, err := h.Learning.GetStreak(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// SetDailyGoal sets the daily XP goal of a user
+// @Summary Set daily goal
+// @Description Set the daily XP goal of the signed-in user and the time zone their days, and so their streak, are counted in. Empty fields keep their current value.
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pb.SetDailyGoalRequest true "Daily goal"
+// @Success 200 {object} pb.SetDailyGoalResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /progress/goal [put]
+func (h *Handler) SetDailyGoal(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.SetDailyGoalRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.SetDailyGoalResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.SetDailyGoal(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.SetDailyGoalResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// BuyStreakFreeze buys a streak freeze with XP
+// @Summary Buy streak freeze
+// @Description Spend 50 XP on a streak freeze, which keeps the streak alive over one missed day. A user can hold 2 at most.
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pb.BuyStreakFreezeRequest true "User"
+// @Success 200 {object} pb.BuyStreakFreezeResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /progress/streak/freeze [post]
+func (h *Handler) BuyStreakFreeze(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.BuyStreakFreezeRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.BuyStreakFreezeResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.BuyStreakFreeze(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.BuyStreakFreezeResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/realtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait    = 10 * time.Second
+	pongWait     = 60 * time.Second
+	pingInterval = 25 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+type realtimeCommand struct {
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+}
+
+type realtimeReply struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// newRealtimeClient authenticates the connection and subscribes it to the
+// comma separated channels query parameter.
+func (h *Handler) newRealtimeClient(ctx *gin.Context) (*realtime.Client, bool) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	client := h.Realtime.NewClient(userId)
+	for _, channel := range strings.Split(ctx.Query("channels"), ",") {
+		if channel == "" {
+			continue
+		}
+		if err := client.Subscribe(channel); err != nil {
+			client.Close()
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	return client, true
+}
+
+// RealtimeWebSocket streams real-time updates over a WebSocket
+// @Summary Real-time updates (WebSocket)
+// @Description Upgrade to a WebSocket. Send {"action":"subscribe","channel":"xp"} to subscribe to xp, leaderboard, orders, notifications or challenges; updates arrive as {"channel":...,"data":...}
+// @Tags realtime
+// @Security BearerAuth
+// @Param token query string false "JWT, if it can't be sent in the Authorization header"
+// @Param channels query string false "Comma separated channels to subscribe to on connect"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /realtime/ws [get]
+func (h *Handler) RealtimeWebSocket(ctx *gin.Context) {
+	client, ok := h.newRealtimeClient(ctx)
+	if !ok {
+		return
+	}
+	defer client.Close()
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		log.Println("cannot upgrade to websocket: ", err)
+		return
+	}
+	defer conn.Close()
+
+	replies := make(chan realtimeReply, 8)
+	go readCommands(conn, client, replies)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-client.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"), time.Now().Add(writeWait))
+			return
+		case msg := <-client.Messages():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = conn.WriteMessage(websocket.TextMessage, msg)
+		case reply := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = conn.WriteJSON(reply)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readCommands handles subscribe/unsubscribe requests until the peer goes
+// away, then closes the client so the writer loop stops too.
+func readCommands(conn *websocket.Conn, client *realtime.Client, replies chan<- realtimeReply) {
+	defer client.Close()
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		cmd := realtimeCommand{}
+		reply := realtimeReply{}
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			reply = realtimeReply{Type: "error", Error: "invalid command"}
+		} else {
+			switch cmd.Action {
+			case "subscribe":
+				reply = realtimeReply{Type: "subscribed", Channel: cmd.Channel}
+				if err := client.Subscribe(cmd.Channel); err != nil {
+					reply = realtimeReply{Type: "error", Channel: cmd.Channel, Error: err.Error()}
+				}
+			case "unsubscribe":
+				client.Unsubscribe(cmd.Channel)
+				reply = realtimeReply{Type: "unsubscribed", Channel: cmd.Channel}
+			default:
+				reply = realtimeReply{Type: "error", Error: "unknown action " + cmd.Action}
+			}
+		}
+
+		select {
+		case replies <- reply:
+		case <-client.Done():
+			return
+		}
+	}
+}
+
+// RealtimeSSE streams real-time updates as Server-Sent Events
+// @Summary Real-time updates (SSE)
+// @Description Stream updates of the given channels (xp, leaderboard, orders, notifications, challenges) as Server-Sent Events
+// @Tags realtime
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param token query string false "JWT, if it can't be sent in the Authorization header"
+// @Param channels query string true "Comma separated channels"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {string} string "Unknown channel"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /realtime/sse [get]
+func (h *Handler) RealtimeSSE(ctx *gin.Context) {
+	client, ok := h.newRealtimeClient(ctx)
+	if !ok {
+		return
+	}
+	defer client.Close()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-client.Done():
+			return false
+		case msg := <-client.Messages():
+			ctx.SSEvent("message", string(msg))
+		case <-ping.C:
+			ctx.SSEvent("ping", "")
+		}
+		return true
+	})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDueReviews lists the quiz questions due for review
+// @Summary Get due reviews
+// @Description List the quiz questions the signed-in user is due to review, the most overdue first, how many are due in all and when the next one comes up. Questions are added to the queue as they are answered in quizzes.
+// @Tags review
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Questions to return, 20 by default and at most 100"
+// @Success 200 {object} pb.GetDueReviewsResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /review/due [get]
+func (h *Handler) GetDueReviews(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	limit, err := queryInt32(ctx, "limit")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &pb.GetDueReviewsRequest{UserId: userId, Limit: limit}
+
+	res, err := h.Learning.GetDueReviews(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// AnswerReview answers a question from the review queue
+// @Summary Answer review
+// @Description Answer a question from the review queue and schedule its next review. A correct answer may be rated hard, good (the default) or easy; recalling a question that is due earns XP.
+// @Tags review
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param answer body pb.AnswerReviewRequest true "Review answer"
+// @Success 200 {object} pb.AnswerReviewResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /review/answer [post]
+func (h *Handler) AnswerReview(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := pb.AnswerReviewRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.AnswerReviewResponse{Message: "Invalid input"})
+		return
+	}
+	req.UserId = userId
+
+	res, err := h.Learning.AnswerReview(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.AnswerReviewResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
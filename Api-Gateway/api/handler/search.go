@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search searches learning content
+// @Summary Search
+// @Description Search topics, quizzes, extra resources and homeworks at once, best matches first. Words are matched with English, Russian and Uzbek stemming, titles also despite typos, and web-search syntax works: "quoted phrases", or, -excluded. Each result has its type, its title and a snippet with matches in <mark>. Homeworks are only found for the user they belong to or are assigned to.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text, at most 200 characters"
+// @Param type query string false "Result types, comma separated: topic, quiz, extra_resource, homework"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Success 200 {object} pb.SearchResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /search [get]
+func (h *Handler) Search(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := &pb.SearchRequest{Query: ctx.Query("q"), Types: ctx.Query("type"), UserId: userId, PageToken: ctx.Query("page_token")}
+	if req.Query == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	var err error
+	if req.Limit, err = queryInt32(ctx, "limit"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Offset, err = queryInt32(ctx, "offset"); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Learning.Search(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+
+	pb "api-gateway/genproto/learning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetXp gets the XP, level and streaks of a user
+// @Summary Get XP
+// @Description Get the XP, level and streaks of the signed-in user from their learning profile
+// @Tags xp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pb.GetXpResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /xp [get]
+func (h *Handler) GetXp(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	req := &pb.GetXpRequest{UserId: userId}
+
+	res, err := h.Learning.GetXp(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetXpHistory lists the XP ledger of a user
+// @Summary Get XP history
+// @Description Get the XP ledger entries of the signed-in user, each with its source, and the balance they add up to
+// @Tags xp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param source_type query string false "Source type, or several separated by commas: topic, quiz, extra_resource, feedback, homework, game_level, command, opening"
+// @Param limit query int false "Page size (default 10, max 100)"
+// @Param offset query int false "Rows to skip; ignored with page_token"
+// @Param page_token query string false "Next page token from the previous response"
+// @Param sort_by query string false "Sort field: created_at, amount"
+// @Param sort_order query string false "asc or desc (default desc)"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD or RFC 3339)"
+// @Param created_to query string false "Created before or on (YYYY-MM-DD or RFC 3339)"
+// @Success 200 {object} pb.GetXpHistoryResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /xp/history [get]
+func (h *Handler) GetXpHistory(ctx *gin.Context) {
+	userId, ok := callerId(ctx)
+	if !ok {
+		return
+	}
+	p, err := parseListParams(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &pb.GetXpHistoryRequest{
+		UserId:      userId,
+		SourceType:  ctx.Query("source_type"),
+		Limit:       p.Limit,
+		Offset:      p.Offset,
+		PageToken:   p.PageToken,
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		CreatedFrom: p.CreatedFrom,
+		CreatedTo:   p.CreatedTo,
+	}
+
+	res, err := h.Learning.GetXpHistory(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// ReconcileXp resets XP balances to their ledger
+// @Summary Reconcile XP balances
+// @Description Reset the XP balance of one user, or of every user when user_id is empty, to the sum of their ledger
+// @Tags xp
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body pb.ReconcileXpRequest true "User to reconcile"
+// @Success 200 {object} pb.ReconcileXpResponse
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "500 – Internal Server Error"
+// @Router /xp/reconcile [post]
+func (h *Handler) ReconcileXp(ctx *gin.Context) {
+	req := pb.ReconcileXpRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, &pb.ReconcileXpResponse{Message: "Invalid input"})
+		return
+	}
+
+	res, err := h.Learning.ReconcileXp(ctx, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, &pb.ReconcileXpResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
@@ -15,8 +14,7 @@ import (
 )
 
 type JwtRoleAuth struct {
-	enforcer   *casbin.Enforcer
-	jwtHandler token.JWTHandler
+	enforcer *casbin.Enforcer
 }
 
 func NewAuth(enforce *casbin.Enforcer) gin.HandlerFunc {
@@ -29,13 +27,7 @@ func NewAuth(enforce *casbin.Enforcer) gin.HandlerFunc {
 		path := ctx.FullPath()
 		allow, err := auth.CheckPermission(ctx.Request, path)
 		if err != nil {
-			valid, _ := err.(jwt.ValidationError)
-			if valid.Errors == jwt.ValidationErrorExpired {
-				ctx.AbortWithStatusJSON(http.StatusForbidden, "Invalid token !!!")
-
-			} else {
-				ctx.AbortWithStatusJSON(401, "Access token expired")
-			}
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, "Invalid or expired token")
 		} else if !allow {
 			ctx.AbortWithStatusJSON(http.StatusForbidden, "Permission denied")
 
@@ -50,23 +42,29 @@ func (a *JwtRoleAuth) GetRole(r *http.Request) (string, error) {
 		err    error
 	)
 
-	jwtToken := r.Header.Get("Authourization")
+	jwtToken := r.Header.Get("Authorization")
 
 	if jwtToken == "" {
 		return "unauthorized", nil
 	} else if strings.Contains(jwtToken, "Basic") {
 		return "unauthorized", nil
 	}
-	a.jwtHandler.Token = jwtToken
-	a.jwtHandler.SigningKey = config.Load().TokenKey
-	claims, err = a.jwtHandler.ExtractClaims()
+	// A handler per request: the middleware serves requests concurrently.
+	jwtHandler := token.JWTHandler{
+		Token:      strings.TrimPrefix(jwtToken, "Bearer "),
+		SigningKey: config.Load().TokenKey,
+	}
+	claims, err = jwtHandler.ExtractClaims()
 
 	if err != nil {
 		log.Println("Error while extracting claims: ", err)
 		return "unauthorized", err
 	}
-	fmt.Println("role: ", claims["role"])
-	return claims["role"].(string), nil
+	role, _ := claims["role"].(string)
+	if role == "" {
+		return "unauthorized", nil
+	}
+	return role, nil
 }
 
 func (a *JwtRoleAuth) CheckPermission(r *http.Request, path string) (bool, error) {
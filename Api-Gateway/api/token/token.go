@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
@@ -122,3 +123,37 @@ func GetIdFromToken(r *http.Request, cfg *config.Config) (string, int) {
 
 	return cast.ToString(claims["username"]), 0
 }
+
+// GetUserIdFromRequest authenticates long-lived connections. Browsers can't
+// set headers on a WebSocket handshake, so the token may also come in the
+// "token" query parameter.
+func GetUserIdFromRequest(r *http.Request, cfg *config.Config) (string, error) {
+	userId, _, err := GetUserFromRequest(r, cfg)
+	return userId, err
+}
+
+// GetUserFromRequest is GetUserIdFromRequest that also returns the role the
+// token was issued with.
+func GetUserFromRequest(r *http.Request, cfg *config.Config) (string, string, error) {
+	tokenStr := r.Header.Get("Authorization")
+	if tokenStr == "" {
+		tokenStr = r.URL.Query().Get("token")
+	}
+	tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
+	if tokenStr == "" {
+		return "", "", errors.New("token is required")
+	}
+
+	claims, err := ExtractClaim(cfg, tokenStr)
+	if err != nil {
+		return "", "", err
+	}
+	if claims == nil {
+		return "", "", errors.New("invalid token")
+	}
+	userId := cast.ToString(claims["user_id"])
+	if userId == "" {
+		return "", "", errors.New("token has no user_id")
+	}
+	return userId, cast.ToString(claims["role"]), nil
+}
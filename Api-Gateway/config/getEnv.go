@@ -21,6 +21,15 @@ type Config struct {
 	DefaultLimit  string
 
 	TokenKey string
+
+	KafkaBrokers string
+	RedisAddr    string
+
+	LeaderboardTopN int
+
+	RequestTimeout string
+
+	BlobServiceURL string
 }
 
 func Load() Config {
@@ -41,6 +50,13 @@ func Load() Config {
 	config.DefaultOffset = cast.ToString(getOrReturnDefaultValue("DEFAULT_OFFSET", "0"))
 	config.DefaultLimit = cast.ToString(getOrReturnDefaultValue("DEFAULT_LIMIT", "10"))
 	config.TokenKey = cast.ToString(getOrReturnDefaultValue("TokenKey", "my_secret_key"))
+
+	config.KafkaBrokers = cast.ToString(getOrReturnDefaultValue("KAFKA_BROKERS", "kafka:9092"))
+	config.RedisAddr = cast.ToString(getOrReturnDefaultValue("REDIS_ADDR", "redis:6379"))
+
+	config.LeaderboardTopN = cast.ToInt(getOrReturnDefaultValue("LEADERBOARD_TOP_N", 10))
+	config.RequestTimeout = cast.ToString(getOrReturnDefaultValue("REQUEST_TIMEOUT", "10s"))
+	config.BlobServiceURL = cast.ToString(getOrReturnDefaultValue("BLOB_SERVICE_URL", "http://learning_service:8071"))
 	return config
 }
 
@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"context"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaConsumer interface {
+	ConsumeMessages(ctx context.Context, handler func(topic string, message []byte) error) error
+	Close() error
+}
+
+type Consumer struct {
+	reader *kafka.Reader
+}
+
+// NewKafkaConsumer joins groupID on the given topics. Gateway replicas share
+// the group, so each message is handled by exactly one of them.
+func NewKafkaConsumer(brokers []string, groupID string, topics ...string) KafkaConsumer {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     groupID,
+		GroupTopics: topics,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+	})
+	return &Consumer{reader: reader}
+}
+
+// ConsumeMessages commits a message after handler returns, whatever the
+// result: real-time updates are best effort and must not block the stream.
+func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(topic string, message []byte) error) error {
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := handler(m.Topic, m.Value); err != nil {
+			log.Printf("cannot handle message from %s: %v", m.Topic, err)
+		}
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (c *Consumer) Close() error {
+	return c.reader.Close()
+}
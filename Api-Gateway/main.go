@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
 
 	"api-gateway/api"
 	"api-gateway/api/handler"
+	"api-gateway/config"
 	pbl "api-gateway/genproto/learning"
 	pbu "api-gateway/genproto/user"
 	"api-gateway/kafka"
-	
+	"api-gateway/realtime"
+
+	"github.com/redis/go-redis/v9"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -18,7 +26,15 @@ import (
 )
 
 func main() {
-	LearningConn, err := grpc.NewClient(fmt.Sprintf("learning_service%s", ":8070"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	cfg := config.Load()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timeout, err := time.ParseDuration(cfg.RequestTimeout)
+	if err != nil {
+		log.Fatal("Error while parsing REQUEST_TIMEOUT: ", err.Error())
+	}
+	LearningConn, err := grpc.NewClient(fmt.Sprintf("learning_service%s", ":8070"), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(withTimeout(timeout)))
 	if err != nil {
 		log.Fatal("Error while Newclient: ", err.Error())
 	}
@@ -36,7 +52,8 @@ func main() {
 	}
 	defer GameCon.Close()
 
-	kaf, err := kafka.NewKafkaProducer([]string{"kafka:9092"})
+	brokers := strings.Split(cfg.KafkaBrokers, ",")
+	kaf, err := kafka.NewKafkaProducer(brokers)
 	if err != nil {
 		log.Fatal("Error while NewKafkaProducer: ", err.Error())
 	}
@@ -47,7 +64,30 @@ func main() {
 	usr := pbu.NewUserServiceClient(UsrCon)
 
 
-	h := handler.NewHandler(us, cs, usr, kaf)
+	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	defer rdb.Close()
+
+	hub := realtime.NewHub(rdb)
+	go func() {
+		if err := hub.Run(ctx); err != nil {
+			log.Println("Error while relaying realtime messages: ", err.Error())
+		}
+	}()
+
+	consumer := kafka.NewKafkaConsumer(brokers, "api-gateway-realtime", realtime.Topics...)
+	defer consumer.Close()
+	go func() {
+		if err := realtime.NewRelay(hub, cs, cfg.LeaderboardTopN).Run(ctx, consumer); err != nil {
+			log.Println("Error while consuming kafka: ", err.Error())
+		}
+	}()
+
+	blobUrl, err := url.Parse(cfg.BlobServiceURL)
+	if err != nil {
+		log.Fatal("Error while parsing BLOB_SERVICE_URL: ", err.Error())
+	}
+
+	h := handler.NewHandler(us, cs, usr, kaf, hub, httputil.NewSingleHostReverseProxy(blobUrl))
 	r := api.NewGin(h)
 
 	fmt.Println("Server started on port:8077")
@@ -56,3 +96,16 @@ func main() {
 		log.Fatal("Error while running server: ", err.Error())
 	}
 }
+
+// withTimeout gives calls that have no deadline of their own one, so that a
+// slow query is cancelled in the learning service once nobody waits for it.
+func withTimeout(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
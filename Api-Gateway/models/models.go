@@ -0,0 +1,12 @@
+package models
+
+import (
+	pb "api-gateway/genproto/learning"
+)
+
+type ImportContentRequest struct {
+	Topics         []*pb.CreateLearningTopicRequest     `json:"topics"`
+	Quizzes        []*pb.CreateQuizRequest              `json:"quizzes"`
+	ExtraResources []*pb.CreateExtraResoursesRequest    `json:"extra_resources"`
+	Homeworks      []*pb.CreateLearningHomeworksRequest `json:"homeworks"`
+}
@@ -0,0 +1,215 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Channels a client may subscribe to. Per-user channels are always scoped to
+// the authenticated user, so nobody can listen to someone else's stream.
+const (
+	ChannelXp            = "xp"
+	ChannelLeaderboard   = "leaderboard"
+	ChannelOrders        = "orders"
+	ChannelNotifications = "notifications"
+	ChannelChallenges    = "challenges"
+)
+
+const (
+	redisPrefix = "rt:"
+	sendBuffer  = 64
+)
+
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Hub keeps the connections of this gateway replica. Everything published
+// goes through Redis pub/sub first, so a message reaches the subscribers of
+// every replica no matter which one produced it.
+type Hub struct {
+	rdb  *redis.Client
+	mu   sync.RWMutex
+	subs map[string]map[*Client]struct{}
+}
+
+func NewHub(rdb *redis.Client) *Hub {
+	return &Hub{rdb: rdb, subs: make(map[string]map[*Client]struct{})}
+}
+
+func UserKey(userID, channel string) string {
+	return "user:" + userID + ":" + channel
+}
+
+// Publish fans data out to every replica subscribed to key.
+func (h *Hub) Publish(ctx context.Context, key string, data []byte) error {
+	return h.rdb.Publish(ctx, redisPrefix+key, data).Err()
+}
+
+// Run relays Redis messages to local clients until ctx is cancelled.
+func (h *Hub) Run(ctx context.Context) error {
+	ps := h.rdb.PSubscribe(ctx, redisPrefix+"*")
+	defer ps.Close()
+
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			h.deliver(strings.TrimPrefix(msg.Channel, redisPrefix), []byte(msg.Payload))
+		}
+	}
+}
+
+func (h *Hub) deliver(key string, data []byte) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.subs[key]))
+	for c := range h.subs[key] {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range clients {
+		c.enqueue(key, data)
+	}
+}
+
+func (h *Hub) NewClient(userID string) *Client {
+	return &Client{
+		UserID:   userID,
+		hub:      h,
+		send:     make(chan []byte, sendBuffer),
+		done:     make(chan struct{}),
+		channels: make(map[string]string),
+	}
+}
+
+// Client is one WebSocket or SSE connection.
+type Client struct {
+	UserID string
+
+	hub      *Hub
+	send     chan []byte
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	channels map[string]string // redis key -> public channel name
+}
+
+type envelope struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (c *Client) Subscribe(channel string) error {
+	var key string
+	switch channel {
+	case ChannelLeaderboard:
+		key = ChannelLeaderboard
+	case ChannelXp, ChannelOrders, ChannelNotifications, ChannelChallenges:
+		key = UserKey(c.UserID, channel)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, channel)
+	}
+
+	c.mu.Lock()
+	c.channels[key] = channel
+	c.mu.Unlock()
+
+	c.hub.mu.Lock()
+	if c.hub.subs[key] == nil {
+		c.hub.subs[key] = make(map[*Client]struct{})
+	}
+	c.hub.subs[key][c] = struct{}{}
+	c.hub.mu.Unlock()
+	return nil
+}
+
+func (c *Client) Unsubscribe(channel string) {
+	c.mu.Lock()
+	var keys []string
+	for key, name := range c.channels {
+		if name == channel {
+			keys = append(keys, key)
+			delete(c.channels, key)
+		}
+	}
+	c.mu.Unlock()
+
+	c.hub.mu.Lock()
+	for _, key := range keys {
+		c.hub.remove(key, c)
+	}
+	c.hub.mu.Unlock()
+}
+
+func (h *Hub) remove(key string, c *Client) {
+	delete(h.subs[key], c)
+	if len(h.subs[key]) == 0 {
+		delete(h.subs, key)
+	}
+}
+
+// Messages yields encoded envelopes ready to be written to the connection.
+func (c *Client) Messages() <-chan []byte {
+	return c.send
+}
+
+// Done is closed once the client is closed, either by the connection
+// handler or because it could not keep up.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Close() {
+	c.once.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		keys := make([]string, 0, len(c.channels))
+		for key := range c.channels {
+			keys = append(keys, key)
+		}
+		c.mu.Unlock()
+
+		c.hub.mu.Lock()
+		for _, key := range keys {
+			c.hub.remove(key, c)
+		}
+		c.hub.mu.Unlock()
+	})
+}
+
+// enqueue never blocks the hub. A client whose buffer is full is too slow
+// to be worth serving and gets disconnected; it can reconnect and resync.
+func (c *Client) enqueue(key string, data []byte) {
+	c.mu.Lock()
+	channel, ok := c.channels[key]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	msg, err := json.Marshal(envelope{Channel: channel, Data: data})
+	if err != nil {
+		log.Println("realtime: cannot encode message: ", err)
+		return
+	}
+
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		log.Printf("realtime: dropping slow client %s", c.UserID)
+		c.Close()
+	}
+}
@@ -0,0 +1,130 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	pb "api-gateway/genproto/game"
+	"api-gateway/kafka"
+)
+
+// Kafka topics turned into real-time updates.
+const (
+	TopicXpChanged           = "xp.changed"
+	TopicOrderStatus         = "order.status"
+	TopicCourierStatus       = "courier.status"
+	TopicNotificationCreated = "notification.created"
+	TopicChallengeResult     = "challenge.result"
+)
+
+var Topics = []string{TopicXpChanged, TopicOrderStatus, TopicCourierStatus, TopicNotificationCreated, TopicChallengeResult}
+
+const leaderboardDebounce = 2 * time.Second
+
+// Relay feeds the hub from Kafka. Each event is consumed by one gateway
+// replica and published through Redis to all of them.
+type Relay struct {
+	hub     *Hub
+	game    pb.GameServiceClient
+	topN    int
+	refresh chan struct{}
+}
+
+func NewRelay(hub *Hub, game pb.GameServiceClient, topN int) *Relay {
+	return &Relay{hub: hub, game: game, topN: topN, refresh: make(chan struct{}, 1)}
+}
+
+func (r *Relay) Run(ctx context.Context, consumer kafka.KafkaConsumer) error {
+	go r.refreshLeaderboard(ctx)
+	return consumer.ConsumeMessages(ctx, func(topic string, message []byte) error {
+		return r.handle(ctx, topic, message)
+	})
+}
+
+func (r *Relay) handle(ctx context.Context, topic string, message []byte) error {
+	event := struct {
+		UserId string `json:"user_id"`
+	}{}
+	if err := json.Unmarshal(message, &event); err != nil {
+		return err
+	}
+	if event.UserId == "" {
+		return errors.New("event without user_id")
+	}
+
+	switch topic {
+	case TopicXpChanged:
+		r.scheduleLeaderboard()
+		return r.hub.Publish(ctx, UserKey(event.UserId, ChannelXp), message)
+	case TopicOrderStatus, TopicCourierStatus:
+		return r.hub.Publish(ctx, UserKey(event.UserId, ChannelOrders), message)
+	case TopicNotificationCreated:
+		return r.hub.Publish(ctx, UserKey(event.UserId, ChannelNotifications), message)
+	case TopicChallengeResult:
+		return r.hub.Publish(ctx, UserKey(event.UserId, ChannelChallenges), message)
+	}
+	return nil
+}
+
+func (r *Relay) scheduleLeaderboard() {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// refreshLeaderboard collapses bursts of XP changes into one leaderboard
+// query per debounce window.
+func (r *Relay) refreshLeaderboard(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.refresh:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderboardDebounce):
+		}
+
+		res, err := r.game.GetGameLeaderboard(ctx, &pb.GetGameLeaderboardRequest{})
+		if err != nil {
+			log.Println("realtime: cannot get leaderboard: ", err)
+			continue
+		}
+		data, err := topN(res, r.topN)
+		if err != nil {
+			log.Println("realtime: cannot encode leaderboard: ", err)
+			continue
+		}
+		if err := r.hub.Publish(ctx, ChannelLeaderboard, data); err != nil {
+			log.Println("realtime: cannot publish leaderboard: ", err)
+		}
+	}
+}
+
+// topN encodes the leaderboard keeping only the first n entries of every
+// list in it, which is the ranked entries.
+func topN(res interface{}, n int) ([]byte, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, raw := range fields {
+		var list []json.RawMessage
+		if json.Unmarshal(raw, &list) != nil || len(list) <= n {
+			continue
+		}
+		fields[name], _ = json.Marshal(list[:n])
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,150 @@
+// Package achievement awards badges. Rules live in the database; each is
+// re-evaluated for a user whenever an event moves the metric it is about.
+package achievement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"learning-service/kafka"
+	"learning-service/models"
+	s "learning-service/storage"
+)
+
+// TopicBackfill is the command that evaluates rules for every user, e.g.
+// after a rule was added. Its payload is a BackfillCommand.
+const TopicBackfill = "learning-achievements-backfill"
+
+type BackfillCommand struct {
+	// Metric limits the backfill to rules on one metric.
+	Metric string `json:"metric"`
+}
+
+const backfillBatch = 100
+
+type Engine struct {
+	stg s.InitRoot
+	kaf kafka.KafkaProducer
+}
+
+func NewEngine(stg s.InitRoot, kaf kafka.KafkaProducer) *Engine {
+	return &Engine{stg: stg, kaf: kaf}
+}
+
+func (e *Engine) Register(c *kafka.Consumer) {
+	c.Handle(models.EventActivityRecorded, e.activityRecorded)
+	c.Handle(models.EventXpChanged, e.xpChanged)
+	c.Handle(models.EventLevelCompleted, e.levelCompleted)
+	c.Handle(TopicBackfill, e.backfill)
+}
+
+// Evaluate measures the user's pending rules on metrics, or on every metric
+// if none are given, and awards those now reached.
+func (e *Engine) Evaluate(ctx context.Context, userId string, metrics ...string) error {
+	rules, err := e.stg.Achievement().GetPendingRules(ctx, userId, metrics)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		progress, err := e.stg.Achievement().Measure(ctx, userId, rule)
+		if err != nil {
+			return fmt.Errorf("measure %s: %w", rule.Code, err)
+		}
+		awarded, err := e.stg.Achievement().SaveProgress(ctx, userId, rule, progress)
+		if err != nil {
+			return fmt.Errorf("save %s: %w", rule.Code, err)
+		}
+		if awarded {
+			e.unlocked(userId, rule)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) unlocked(userId string, rule *models.Achievement) {
+	kafka.Publish(e.kaf, models.EventAchievementUnlocked, models.AchievementUnlockedEvent{
+		UserId:        userId,
+		AchievementId: rule.Id,
+		Code:          rule.Code,
+		Name:          rule.Name,
+		Xp:            rule.Xp,
+	})
+	if rule.Xp > 0 {
+		kafka.Publish(e.kaf, models.EventXpChanged, models.XpChangedEvent{UserId: userId, Delta: rule.Xp, Source: models.XpSourceAchievement})
+	}
+}
+
+func (e *Engine) activityRecorded(ctx context.Context, msg kafka.Message) error {
+	event := models.ActivityRecordedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("event without user_id"))
+	}
+	metrics, ok := models.ActivityMetrics[event.Kind]
+	if !ok {
+		return nil
+	}
+	return e.Evaluate(ctx, event.UserId, metrics...)
+}
+
+func (e *Engine) xpChanged(ctx context.Context, msg kafka.Message) error {
+	event := models.XpChangedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("event without user_id"))
+	}
+	if event.Delta <= 0 {
+		return nil
+	}
+	return e.Evaluate(ctx, event.UserId, models.MetricXp)
+}
+
+func (e *Engine) levelCompleted(ctx context.Context, msg kafka.Message) error {
+	event := models.LevelCompletedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" || event.LevelId == "" || event.TimeSpentSeconds < 0 {
+		return kafka.Permanent(fmt.Errorf("invalid level completion %+v", event))
+	}
+	if err := e.stg.Achievement().RecordGameLevel(ctx, event.UserId, event.LevelId, event.TimeSpentSeconds); err != nil {
+		return err
+	}
+	return e.Evaluate(ctx, event.UserId, models.ActivityMetrics[models.XpSourceGameLevel]...)
+}
+
+// backfill evaluates every live profile. Evaluating is idempotent, so a
+// backfill that fails halfway is simply redelivered and run again.
+func (e *Engine) backfill(ctx context.Context, msg kafka.Message) error {
+	cmd := BackfillCommand{}
+	if err := json.Unmarshal(msg.Value, &cmd); err != nil {
+		return kafka.Permanent(err)
+	}
+	var metrics []string
+	if cmd.Metric != "" {
+		metrics = []string{cmd.Metric}
+	}
+
+	after := ""
+	for {
+		ids, err := e.stg.Profile().GetUserIds(ctx, after, backfillBatch)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := e.Evaluate(ctx, id, metrics...); err != nil {
+				return fmt.Errorf("backfill achievements of %s: %w", id, err)
+			}
+		}
+		if len(ids) < backfillBatch {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,88 @@
+package authoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"learning-service/models"
+)
+
+// Diff lists the fields that differ between two snapshots, by their path
+// in the JSON such as "questions[2].options[0]", sorted by path with list
+// items in order.
+func Diff(from, to []byte) ([]models.ContentChange, error) {
+	before, err := flatten(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flatten(to)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]string{}
+	for path, f := range before {
+		keys[path] = f.key
+	}
+	for path, f := range after {
+		keys[path] = f.key
+	}
+	paths := make([]string, 0, len(keys))
+	for path := range keys {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return keys[paths[i]] < keys[paths[j]] })
+
+	changes := []models.ContentChange{}
+	for _, path := range paths {
+		if before[path].value != after[path].value {
+			changes = append(changes, models.ContentChange{Field: path, From: before[path].value, To: after[path].value})
+		}
+	}
+	return changes, nil
+}
+
+// field is a leaf of a snapshot. Its key sorts list items by number, so
+// that item 10 comes after item 9.
+type field struct {
+	key   string
+	value string
+}
+
+func flatten(raw []byte) (map[string]field, error) {
+	fields := map[string]field{}
+	if len(raw) == 0 {
+		return fields, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	walk("", "", doc, fields)
+	return fields, nil
+}
+
+func walk(path, key string, v interface{}, fields map[string]field) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, child := range v {
+			if path == "" {
+				walk(name, name, child, fields)
+			} else {
+				walk(path+"."+name, key+"."+name, child, fields)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			walk(fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%s[%08d]", key, i), child, fields)
+		}
+	case string:
+		fields[path] = field{key: key, value: v}
+	default:
+		raw, _ := json.Marshal(v)
+		fields[path] = field{key: key, value: strings.TrimSpace(string(raw))}
+	}
+}
@@ -0,0 +1,83 @@
+package authoring
+
+import (
+	"reflect"
+	"testing"
+
+	"learning-service/models"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     []models.ContentChange
+	}{
+		{
+			name: "same",
+			from: `{"name":"Go","difficulty":"easy"}`,
+			to:   `{"difficulty":"easy","name":"Go"}`,
+			want: []models.ContentChange{},
+		},
+		{
+			name: "changed, added and removed fields",
+			from: `{"name":"Go","description":"old","xp":10}`,
+			to:   `{"name":"Go basics","xp":20,"difficulty":"easy"}`,
+			want: []models.ContentChange{
+				{Field: "description", From: "old"},
+				{Field: "difficulty", To: "easy"},
+				{Field: "name", From: "Go", To: "Go basics"},
+				{Field: "xp", From: "10", To: "20"},
+			},
+		},
+		{
+			name: "nested lists",
+			from: `{"questions":[{"text":"a","options":["x","y"]}]}`,
+			to:   `{"questions":[{"text":"a","options":["x","z"]},{"text":"b"}]}`,
+			want: []models.ContentChange{
+				{Field: "questions[0].options[1]", From: "y", To: "z"},
+				{Field: "questions[1].text", To: "b"},
+			},
+		},
+		{
+			name: "list items in numeric order",
+			from: `{"tags":["0","1","2","3","4","5","6","7","8","9","10"]}`,
+			to:   `{"tags":["0","1","2","3","4","5","6","7","8","x","y"]}`,
+			want: []models.ContentChange{
+				{Field: "tags[9]", From: "9", To: "x"},
+				{Field: "tags[10]", From: "10", To: "y"},
+			},
+		},
+		{
+			name: "first version",
+			to:   `{"name":"Go","shuffle":true}`,
+			want: []models.ContentChange{
+				{Field: "name", To: "Go"},
+				{Field: "shuffle", To: "true"},
+			},
+		},
+		{
+			name: "null is left out",
+			from: `{"name":"Go","description":null}`,
+			to:   `{"name":"Go"}`,
+			want: []models.ContentChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff([]byte(tt.from), []byte(tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInvalidJson(t *testing.T) {
+	if _, err := Diff([]byte(`{"name":`), nil); err == nil {
+		t.Error("Diff() of invalid JSON succeeded")
+	}
+}
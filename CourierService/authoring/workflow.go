@@ -0,0 +1,89 @@
+// Package authoring holds the rules learning content is written and
+// published by: which step may follow which, and what changed from one
+// revision to the next.
+package authoring
+
+import (
+	"errors"
+	"fmt"
+
+	"learning-service/models"
+)
+
+// Steps an author or reviewer takes on a piece of content.
+const (
+	Edit    = "edit"
+	Submit  = "submit"
+	Approve = "approve"
+	Reject  = "reject"
+	Archive = "archive"
+)
+
+var ErrNotAllowed = errors.New("not allowed in the content's current state")
+
+// Next returns the statuses an item and its open revision move to when
+// action is taken. revision is empty while the item has none open.
+//
+// An item keeps its status while a revision of it is worked on, so that
+// learners go on seeing the published version until the next one is
+// approved.
+func Next(action, item, revision string) (string, string, error) {
+	switch action {
+	case Edit:
+		if revision == models.ContentInReview {
+			return "", "", fmt.Errorf("%w: it is in review, and can be edited again once it is approved or rejected", ErrNotAllowed)
+		}
+		return item, models.ContentDraft, nil
+	case Submit:
+		if revision != models.ContentDraft {
+			return "", "", fmt.Errorf("%w: only a draft can be submitted for review", ErrNotAllowed)
+		}
+		if item == models.ContentDraft {
+			item = models.ContentInReview
+		}
+		return item, models.ContentInReview, nil
+	case Approve:
+		if revision != models.ContentInReview {
+			return "", "", fmt.Errorf("%w: only a revision in review can be approved", ErrNotAllowed)
+		}
+		return models.ContentPublished, models.ContentPublished, nil
+	case Reject:
+		if revision != models.ContentInReview {
+			return "", "", fmt.Errorf("%w: only a revision in review can be rejected", ErrNotAllowed)
+		}
+		if item == models.ContentInReview {
+			item = models.ContentDraft
+		}
+		return item, models.ContentDraft, nil
+	case Archive:
+		if item != models.ContentPublished {
+			return "", "", fmt.Errorf("%w: only published content can be archived", ErrNotAllowed)
+		}
+		return models.ContentArchived, revision, nil
+	}
+	return "", "", fmt.Errorf("%w: unknown action %q", ErrNotAllowed, action)
+}
+
+// CanRollback checks that version of an item can be published again in
+// place of the current one.
+func CanRollback(item *models.ContentItem, version int32) error {
+	if item.PublishedVersion == 0 {
+		return fmt.Errorf("%w: it has never been published", ErrNotAllowed)
+	}
+	if version < 1 || version > item.PublishedVersion {
+		return fmt.Errorf("%w: version %d does not exist", ErrNotAllowed, version)
+	}
+	if version == item.PublishedVersion && item.Status == models.ContentPublished {
+		return fmt.Errorf("%w: version %d is already published", ErrNotAllowed, version)
+	}
+	return nil
+}
+
+// CanDelete checks that an item may be deleted. Once learners have seen
+// it, it is archived instead, so that its history is kept.
+func CanDelete(item *models.ContentItem) error {
+	if item.PublishedVersion > 0 {
+		return fmt.Errorf("%w: published content is archived rather than deleted", ErrNotAllowed)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package authoring
+
+import (
+	"errors"
+	"testing"
+
+	"learning-service/models"
+)
+
+const (
+	draft     = models.ContentDraft
+	inReview  = models.ContentInReview
+	published = models.ContentPublished
+	archived  = models.ContentArchived
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name              string
+		action, item, rev string
+		wantItem, wantRev string
+		wantErr           bool
+	}{
+		// A new item is a draft until its first revision is published.
+		{"edit a new item", Edit, draft, draft, draft, draft, false},
+		{"submit a new item", Submit, draft, draft, inReview, inReview, false},
+		{"approve a new item", Approve, inReview, inReview, published, published, false},
+		{"reject a new item", Reject, inReview, inReview, draft, draft, false},
+
+		// A published item stays published while its next revision is
+		// worked on.
+		{"start a revision", Edit, published, "", published, draft, false},
+		{"submit a revision", Submit, published, draft, published, inReview, false},
+		{"approve a revision", Approve, published, inReview, published, published, false},
+		{"reject a revision", Reject, published, inReview, published, draft, false},
+		{"archive with a draft open", Archive, published, draft, archived, draft, false},
+		{"edit an archived item", Edit, archived, "", archived, draft, false},
+		{"approve a revision of an archived item", Approve, archived, inReview, published, published, false},
+
+		{"edit in review", Edit, published, inReview, "", "", true},
+		{"submit without a draft", Submit, published, "", "", "", true},
+		{"submit twice", Submit, inReview, inReview, "", "", true},
+		{"approve a draft", Approve, draft, draft, "", "", true},
+		{"reject a draft", Reject, published, draft, "", "", true},
+		{"archive a draft", Archive, draft, draft, "", "", true},
+		{"archive twice", Archive, archived, "", "", "", true},
+		{"unknown action", "publish", draft, draft, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, rev, err := Next(tt.action, tt.item, tt.rev)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotAllowed) {
+					t.Fatalf("Next() error %v, want %v", err, ErrNotAllowed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item != tt.wantItem || rev != tt.wantRev {
+				t.Errorf("Next() = %s, %s, want %s, %s", item, rev, tt.wantItem, tt.wantRev)
+			}
+		})
+	}
+}
+
+func TestCanRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    models.ContentItem
+		version int32
+		wantErr bool
+	}{
+		{"earlier version", models.ContentItem{Status: published, PublishedVersion: 3}, 1, false},
+		{"current version of an archived item", models.ContentItem{Status: archived, PublishedVersion: 3}, 3, false},
+		{"current version", models.ContentItem{Status: published, PublishedVersion: 3}, 3, true},
+		{"future version", models.ContentItem{Status: published, PublishedVersion: 3}, 4, true},
+		{"version 0", models.ContentItem{Status: published, PublishedVersion: 3}, 0, true},
+		{"never published", models.ContentItem{Status: draft}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CanRollback(&tt.item, tt.version)
+			if tt.wantErr && !errors.Is(err, ErrNotAllowed) {
+				t.Errorf("CanRollback() = %v, want %v", err, ErrNotAllowed)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CanRollback() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestCanDelete(t *testing.T) {
+	if err := CanDelete(&models.ContentItem{Status: draft}); err != nil {
+		t.Errorf("unpublished item: %v", err)
+	}
+	if err := CanDelete(&models.ContentItem{Status: archived, PublishedVersion: 1}); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("once published item: %v, want %v", err, ErrNotAllowed)
+	}
+}
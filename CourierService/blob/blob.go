@@ -0,0 +1,93 @@
+// Package blob keeps the bytes of uploaded files. Clients move them
+// directly to and from the store over pre-signed URLs; the service only
+// hands those URLs out and checks what arrived.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is a flat namespace of immutable objects.
+type Store interface {
+	// PresignPut returns a URL an object can be uploaded to, with a PUT
+	// sending contentType, until it expires.
+	PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+	// PresignGet returns a URL the object can be downloaded from as
+	// filename until it expires.
+	PresignGet(ctx context.Context, key, contentType, filename string, expires time.Duration) (string, error)
+	// Stat returns the size of an object.
+	Stat(ctx context.Context, key string) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Move puts the object at from under to instead, replacing what was
+	// there.
+	Move(ctx context.Context, from, to string) error
+	// Delete removes an object; deleting one that is not there is no error.
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*(/[a-z0-9][a-z0-9._-]*)*$`)
+
+func validKey(key string) bool {
+	return len(key) <= 512 && keyPattern.MatchString(key) && !strings.Contains(key, "..")
+}
+
+// Key is where content with the given hex sha256 is kept. Files with the
+// same content share it.
+func Key(sha string) string {
+	return "sha256/" + sha
+}
+
+// UploadKey is where the upload of a file waits to be checked. Uploads never
+// go to a shared key directly, or one could overwrite content already in use.
+func UploadKey(fileId string) string {
+	return "uploads/" + fileId
+}
+
+// Summary is what Inspect found in an object.
+type Summary struct {
+	Size   int64
+	Sha256 string
+	// ContentType is sniffed from the first bytes of the content.
+	ContentType string
+}
+
+// Inspect reads an object through and sums it up.
+func Inspect(ctx context.Context, s Store, key string) (*Summary, error) {
+	r, err := s.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	h := sha256.New()
+	h.Write(head)
+	rest, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return &Summary{
+		Size:        int64(n) + rest,
+		Sha256:      hex.EncodeToString(h.Sum(nil)),
+		ContentType: http.DetectContentType(head),
+	}, nil
+}
@@ -0,0 +1,241 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps objects as files under a directory and serves its own
+// pre-signed URLs: it is an http.Handler for the PUTs and GETs they sign.
+type LocalStore struct {
+	dir     string
+	baseUrl string
+	key     []byte
+	maxSize int64
+}
+
+// NewLocalStore keeps objects under dir. Its URLs start with baseUrl, which
+// must reach the store's handler, and are signed with signingKey. No upload
+// may be larger than maxSize.
+func NewLocalStore(dir, baseUrl, signingKey string, maxSize int64) (*LocalStore, error) {
+	if signingKey == "" {
+		return nil, fmt.Errorf("blob: a signing key is required")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseUrl: strings.TrimRight(baseUrl, "/"), key: []byte(signingKey), maxSize: maxSize}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// sign covers everything a URL allows: the method, the object, the content
+// type and how long it is good for.
+func (s *LocalStore) sign(method, key, contentType, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%d", method, key, contentType, filename, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) presign(method, key, contentType, filename string, expires time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	exp := time.Now().Add(expires).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("content_type", contentType)
+	if filename != "" {
+		q.Set("filename", filename)
+	}
+	q.Set("signature", s.sign(method, key, contentType, filename, exp))
+	return s.baseUrl + "/" + key + "?" + q.Encode(), nil
+}
+
+func (s *LocalStore) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodPut, key, contentType, "", expires)
+}
+
+func (s *LocalStore) PresignGet(ctx context.Context, key, contentType, filename string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodGet, key, contentType, filename, expires)
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) Move(ctx context.Context, from, to string) error {
+	src, err := s.path(from)
+	if err != nil {
+		return err
+	}
+	dst, err := s.path(to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	err = os.Rename(src, dst)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP answers the requests the store's URLs sign, with the key as the
+// path. A request must be the one that was signed and not have expired.
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	path, err := s.path(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	contentType, filename := q.Get("content_type"), q.Get("filename")
+	exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		http.Error(w, "url has expired", http.StatusForbidden)
+		return
+	}
+	want := s.sign(method, key, contentType, filename, exp)
+	if !hmac.Equal([]byte(want), []byte(q.Get("signature"))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	switch method {
+	case http.MethodPut:
+		if r.Header.Get("Content-Type") != contentType {
+			http.Error(w, "Content-Type must be "+contentType, http.StatusBadRequest)
+			return
+		}
+		s.put(w, r, path)
+	case http.MethodGet:
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Println("blob: ", err)
+			http.Error(w, "cannot read file", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			log.Println("blob: ", err)
+			http.Error(w, "cannot read file", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if filename != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		}
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// put writes the body next to path and moves it in place once it is all
+// there, so a broken upload never leaves half an object behind.
+func (s *LocalStore) put(w http.ResponseWriter, r *http.Request, path string) {
+	if r.ContentLength > s.maxSize {
+		http.Error(w, "file is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		log.Println("blob: ", err)
+		http.Error(w, "cannot store file", http.StatusInternalServerError)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		log.Println("blob: ", err)
+		http.Error(w, "cannot store file", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(r.Body, s.maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println("blob: ", err)
+		http.Error(w, "cannot store file", http.StatusInternalServerError)
+		return
+	}
+	if n > s.maxSize {
+		http.Error(w, "file is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Println("blob: ", err)
+		http.Error(w, "cannot store file", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,240 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket on S3 or on a compatible server such as MinIO.
+type S3Config struct {
+	// Endpoint is where the service reaches the server, PublicEndpoint where
+	// clients do; it defaults to Endpoint.
+	Endpoint       string
+	PublicEndpoint string
+	Region         string
+	Bucket         string
+	AccessKey      string
+	SecretKey      string
+	// PathStyle puts the bucket in the path rather than the host name,
+	// which MinIO needs.
+	PathStyle bool
+}
+
+// S3Store keeps objects in an S3 bucket. Every request it makes or hands out
+// is pre-signed with Signature Version 4.
+type S3Store struct {
+	cfg      S3Config
+	internal *url.URL
+	public   *url.URL
+	client   *http.Client
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("blob: s3 needs a bucket and credentials")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PublicEndpoint == "" {
+		cfg.PublicEndpoint = cfg.Endpoint
+	}
+	internal, err := url.Parse(cfg.Endpoint)
+	if err != nil || internal.Host == "" {
+		return nil, fmt.Errorf("blob: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	public, err := url.Parse(cfg.PublicEndpoint)
+	if err != nil || public.Host == "" {
+		return nil, fmt.Errorf("blob: invalid s3 endpoint %q", cfg.PublicEndpoint)
+	}
+	return &S3Store{cfg: cfg, internal: internal, public: public, client: &http.Client{Timeout: time.Minute}}, nil
+}
+
+// awsEscape percent-encodes s the way SigV4 canonical requests expect.
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// presign signs a request for key against endpoint. Only the host and the
+// given headers are signed; the payload is not.
+func (s *S3Store) presign(endpoint *url.URL, method, key string, query url.Values, headers map[string]string, expires time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	host, path := endpoint.Host, "/"+key
+	if s.cfg.PathStyle {
+		path = "/" + s.cfg.Bucket + path
+	} else {
+		host = s.cfg.Bucket + "." + host
+	}
+	path = strings.TrimRight(endpoint.Path, "/") + path
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	signed := map[string]string{"host": host}
+	for k, v := range headers {
+		signed[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	params := make([]string, 0, len(query))
+	for k := range query {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	pairs := make([]string, len(params))
+	for i, k := range params {
+		pairs[i] = awsEscape(k, false) + "=" + awsEscape(query.Get(k), false)
+	}
+	canonicalQuery := strings.Join(pairs, "&")
+	canonicalPath := awsEscape(path, true)
+
+	canonicalRequest := strings.Join([]string{
+		method, canonicalPath, canonicalQuery, canonicalHeaders.String(), signedHeaders, "UNSIGNED-PAYLOAD",
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	signingKey := hmacSha256([]byte("AWS4"+s.cfg.SecretKey), date)
+	signingKey = hmacSha256(signingKey, s.cfg.Region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	return endpoint.Scheme + "://" + host + canonicalPath + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func (s *S3Store) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	return s.presign(s.public, http.MethodPut, key, nil, map[string]string{"Content-Type": contentType}, expires)
+}
+
+func (s *S3Store) PresignGet(ctx context.Context, key, contentType, filename string, expires time.Duration) (string, error) {
+	query := url.Values{}
+	query.Set("response-content-type", contentType)
+	if filename != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	return s.presign(s.public, http.MethodGet, key, query, nil, expires)
+}
+
+// do sends a signed request of the service's own to the bucket.
+func (s *S3Store) do(ctx context.Context, method, key string, headers map[string]string) (*http.Response, error) {
+	u, err := s.presign(s.internal, method, key, nil, headers, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+		return nil, fmt.Errorf("blob: s3 %s %s: %s: %s", method, key, res.Status, body)
+	}
+	return res, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (int64, error) {
+	res, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.ContentLength, nil
+}
+
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Move copies the object on the server and deletes the original, S3 having
+// no rename.
+func (s *S3Store) Move(ctx context.Context, from, to string) error {
+	if !validKey(from) {
+		return ErrInvalidKey
+	}
+	res, err := s.do(ctx, http.MethodPut, to, map[string]string{"X-Amz-Copy-Source": awsEscape("/"+s.cfg.Bucket+"/"+from, true)})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return s.Delete(ctx, from)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
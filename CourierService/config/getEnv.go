@@ -20,7 +20,42 @@ type Config struct {
   DefaultOffset string
   DefaultLimit  string
 
+  QueryTimeout string
+
   TokenKey string
+
+  KafkaBrokers string
+  KafkaGroupID string
+
+  AuthServiceURL string
+  InternalToken  string
+
+  VapidSubscriber string
+  VapidPublicKey  string
+  VapidPrivateKey string
+
+  StreakNudgeHour int
+  RecommendationRefreshHours int
+
+  BlobBackend       string
+  BlobLocalDir      string
+  BlobPublicURL     string
+  BlobSigningKey    string
+  BlobHTTPPort      string
+  BlobURLTTLMinutes int
+  BlobOrphanHours   int
+
+  S3Endpoint       string
+  S3PublicEndpoint string
+  S3Region         string
+  S3Bucket         string
+  S3AccessKey      string
+  S3SecretKey      string
+  S3PathStyle      bool
+
+  LinkRecheckHours int
+
+  ModerationBlockedWords string
 }
 
 
@@ -41,7 +76,41 @@ func Load() Config {
 
   config.DefaultOffset = cast.ToString(GetOrReturnDefaultValue("DEFAULT_OFFSET", "0"))
   config.DefaultLimit = cast.ToString(GetOrReturnDefaultValue("DEFAULT_LIMIT", "10"))
+  config.QueryTimeout = cast.ToString(GetOrReturnDefaultValue("DB_QUERY_TIMEOUT", "5s"))
   config.TokenKey=cast.ToString(GetOrReturnDefaultValue("TokenKey", "my_secret_key"))
+
+  config.KafkaBrokers = cast.ToString(GetOrReturnDefaultValue("KAFKA_BROKERS", "kafka:9092"))
+  config.KafkaGroupID = cast.ToString(GetOrReturnDefaultValue("KAFKA_GROUP_ID", "learning-service"))
+
+  config.AuthServiceURL = cast.ToString(GetOrReturnDefaultValue("AUTH_SERVICE_URL", "http://auth_service:8088"))
+  config.InternalToken = cast.ToString(GetOrReturnDefaultValue("INTERNAL_TOKEN", ""))
+
+  config.VapidSubscriber = cast.ToString(GetOrReturnDefaultValue("VAPID_SUBSCRIBER", "mailto:admin@example.com"))
+  config.VapidPublicKey = cast.ToString(GetOrReturnDefaultValue("VAPID_PUBLIC_KEY", ""))
+  config.VapidPrivateKey = cast.ToString(GetOrReturnDefaultValue("VAPID_PRIVATE_KEY", ""))
+
+  config.StreakNudgeHour = cast.ToInt(GetOrReturnDefaultValue("STREAK_NUDGE_HOUR", 18))
+  config.RecommendationRefreshHours = cast.ToInt(GetOrReturnDefaultValue("RECOMMENDATION_REFRESH_HOURS", 24))
+
+  config.BlobBackend = cast.ToString(GetOrReturnDefaultValue("BLOB_BACKEND", "local"))
+  config.BlobLocalDir = cast.ToString(GetOrReturnDefaultValue("BLOB_LOCAL_DIR", "/var/lib/learning/blobs"))
+  config.BlobPublicURL = cast.ToString(GetOrReturnDefaultValue("BLOB_PUBLIC_URL", "http://localhost:8077/files/blob"))
+  config.BlobSigningKey = cast.ToString(GetOrReturnDefaultValue("BLOB_SIGNING_KEY", "blob_secret"))
+  config.BlobHTTPPort = cast.ToString(GetOrReturnDefaultValue("BLOB_HTTP_PORT", ":8071"))
+  config.BlobURLTTLMinutes = cast.ToInt(GetOrReturnDefaultValue("BLOB_URL_TTL_MINUTES", 15))
+  config.BlobOrphanHours = cast.ToInt(GetOrReturnDefaultValue("BLOB_ORPHAN_HOURS", 24))
+
+  config.S3Endpoint = cast.ToString(GetOrReturnDefaultValue("S3_ENDPOINT", "http://minio:9000"))
+  config.S3PublicEndpoint = cast.ToString(GetOrReturnDefaultValue("S3_PUBLIC_ENDPOINT", ""))
+  config.S3Region = cast.ToString(GetOrReturnDefaultValue("S3_REGION", "us-east-1"))
+  config.S3Bucket = cast.ToString(GetOrReturnDefaultValue("S3_BUCKET", "learning"))
+  config.S3AccessKey = cast.ToString(GetOrReturnDefaultValue("S3_ACCESS_KEY", ""))
+  config.S3SecretKey = cast.ToString(GetOrReturnDefaultValue("S3_SECRET_KEY", ""))
+  config.S3PathStyle = cast.ToBool(GetOrReturnDefaultValue("S3_PATH_STYLE", true))
+
+  config.LinkRecheckHours = cast.ToInt(GetOrReturnDefaultValue("LINK_RECHECK_HOURS", 168))
+
+  config.ModerationBlockedWords = cast.ToString(GetOrReturnDefaultValue("MODERATION_BLOCKED_WORDS", ""))
   return config
 }
 
@@ -0,0 +1,146 @@
+// Package homework holds the rules homework submissions are checked and
+// graded by: what a submission must carry, how late it is, what a rubric
+// scores and the XP a grade is worth.
+package homework
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"learning-service/models"
+	"learning-service/resource"
+)
+
+// MaxScore is what a homework without a rubric is graded out of.
+const MaxScore = 100
+
+const (
+	maxLinks       = 10
+	maxAttachments = 10
+)
+
+var (
+	ErrInvalidRubric     = errors.New("invalid rubric")
+	ErrInvalidSubmission = errors.New("invalid submission")
+	ErrInvalidGrade      = errors.New("invalid grade")
+	ErrPastDue           = errors.New("homework is past due and no longer accepts submissions")
+)
+
+// ValidateRubric checks that criteria have distinct names and are worth
+// something.
+func ValidateRubric(rubric []*models.RubricCriterion) error {
+	seen := map[string]bool{}
+	for _, c := range rubric {
+		c.Name = strings.TrimSpace(c.Name)
+		if c.Name == "" {
+			return fmt.Errorf("%w: a criterion needs a name", ErrInvalidRubric)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: criterion %q is listed twice", ErrInvalidRubric, c.Name)
+		}
+		seen[c.Name] = true
+		if c.MaxPoints <= 0 {
+			return fmt.Errorf("%w: criterion %q must be worth more than 0 points", ErrInvalidRubric, c.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateSubmission checks that a submission carries something and that
+// its links and attachments point somewhere.
+func ValidateSubmission(s *models.HomeworkSubmission) error {
+	if strings.TrimSpace(s.Text) == "" && len(s.Links) == 0 && len(s.Attachments) == 0 {
+		return fmt.Errorf("%w: add text, a link or an attachment", ErrInvalidSubmission)
+	}
+	if len(s.Links) > maxLinks || len(s.Attachments) > maxAttachments {
+		return fmt.Errorf("%w: at most %d links and %d attachments", ErrInvalidSubmission, maxLinks, maxAttachments)
+	}
+	for _, l := range s.Links {
+		if !resource.ValidUrl(l) {
+			return fmt.Errorf("%w: %q is not an http(s) link", ErrInvalidSubmission, l)
+		}
+	}
+	for _, a := range s.Attachments {
+		// An uploaded file is checked against its upload instead.
+		if a.FileId == "" && (strings.TrimSpace(a.Name) == "" || !resource.ValidUrl(a.Url)) {
+			return fmt.Errorf("%w: an attachment needs an uploaded file, or a name and an http(s) url", ErrInvalidSubmission)
+		}
+		if a.Size < 0 {
+			return fmt.Errorf("%w: attachment %q has a negative size", ErrInvalidSubmission, a.Name)
+		}
+	}
+	return nil
+}
+
+// DaysLate counts the days, started ones included, at is past due. A zero
+// due date is never late.
+func DaysLate(due, at time.Time) int32 {
+	if due.IsZero() || !at.After(due) {
+		return 0
+	}
+	return int32(math.Ceil(at.Sub(due).Hours() / 24))
+}
+
+// Accepts reports whether hw takes a submission daysLate days late.
+func Accepts(hw *models.Homework, daysLate int32) error {
+	if daysLate > 0 && hw.LatePolicy == models.LatePolicyReject {
+		return ErrPastDue
+	}
+	return nil
+}
+
+// Score totals a reviewer's grade. With a rubric every criterion is scored
+// once, within its points; without one the grade is score out of MaxScore.
+func Score(hw *models.Homework, scores []*models.RubricScore, score float64) (float64, float64, error) {
+	if len(hw.Rubric) == 0 {
+		if len(scores) > 0 {
+			return 0, 0, fmt.Errorf("%w: this homework has no rubric", ErrInvalidGrade)
+		}
+		if score < 0 || score > MaxScore {
+			return 0, 0, fmt.Errorf("%w: score must be between 0 and %d", ErrInvalidGrade, MaxScore)
+		}
+		return score, MaxScore, nil
+	}
+
+	byName := make(map[string]*models.RubricScore, len(scores))
+	for _, s := range scores {
+		if byName[s.Criterion] != nil {
+			return 0, 0, fmt.Errorf("%w: criterion %q is scored twice", ErrInvalidGrade, s.Criterion)
+		}
+		byName[s.Criterion] = s
+	}
+	var total, max float64
+	for _, c := range hw.Rubric {
+		s, ok := byName[c.Name]
+		if !ok {
+			return 0, 0, fmt.Errorf("%w: criterion %q is not scored", ErrInvalidGrade, c.Name)
+		}
+		if s.Points < 0 || s.Points > c.MaxPoints {
+			return 0, 0, fmt.Errorf("%w: criterion %q is worth 0 to %g points", ErrInvalidGrade, c.Name, c.MaxPoints)
+		}
+		total += s.Points
+		max += c.MaxPoints
+		delete(byName, c.Name)
+	}
+	for name := range byName {
+		return 0, 0, fmt.Errorf("%w: %q is not a criterion of this homework", ErrInvalidGrade, name)
+	}
+	return total, max, nil
+}
+
+// Xp is what a grade of score out of max earns on hw, less the late penalty
+// when the homework has one.
+func Xp(hw *models.Homework, score, max float64, daysLate int32) int32 {
+	if max <= 0 {
+		return 0
+	}
+	xp := float64(hw.MaxXp) * score / max
+	if hw.LatePolicy == models.LatePolicyPenalty && daysLate > 0 {
+		penalty := math.Min(100, float64(hw.LatePenaltyPercent*daysLate))
+		xp *= 1 - penalty/100
+	}
+	return int32(math.Round(xp))
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClosed is returned by readers and writers used after Close.
+var ErrClosed = errors.New("kafka: closed")
+
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+}
+
+// Broker hands out readers bound to a consumer group and writers.
+// It is implemented by the real Kafka cluster and by MemoryBroker.
+type Broker interface {
+	Reader(topic, groupID string) Reader
+	Writer() Writer
+}
+
+type Reader interface {
+	// Fetch blocks until the next message for the group is available.
+	Fetch(ctx context.Context) (Message, error)
+	// Commit marks the message as processed for the group.
+	Commit(ctx context.Context, msg Message) error
+	// Close leaves the group; uncommitted messages are redelivered to
+	// the remaining members.
+	Close() error
+}
+
+type Writer interface {
+	Write(ctx context.Context, msgs ...Message) error
+	Close() error
+}
@@ -0,0 +1,196 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderOriginalTopic = "x-original-topic"
+	HeaderError         = "x-error"
+	HeaderAttempts      = "x-attempts"
+)
+
+// Handler processes one message. Returning an error makes the consumer retry
+// with backoff; wrap it with Permanent to send the message straight to the
+// dead-letter topic.
+type Handler func(ctx context.Context, msg Message) error
+
+// MessageId derives an id from where msg sits in its topic, so that a
+// redelivered message maps to the same id as its first delivery.
+func MessageId(msg Message) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL,
+		[]byte(fmt.Sprintf("kafka://%s/%d/%d", msg.Topic, msg.Partition, msg.Offset))).String()
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a payload that can't be decoded.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type ConsumerConfig struct {
+	GroupID          string
+	MaxRetries       int
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	DeadLetterSuffix string
+}
+
+func DefaultConsumerConfig(groupID string) ConsumerConfig {
+	return ConsumerConfig{
+		GroupID:          groupID,
+		MaxRetries:       5,
+		InitialBackoff:   200 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		DeadLetterSuffix: ".dlq",
+	}
+}
+
+// Consumer runs one group member per registered topic. A message is
+// committed only after its handler succeeded or it was parked on the
+// dead-letter topic, so messages in flight during a rebalance or shutdown
+// are redelivered rather than lost. Handlers must therefore be idempotent.
+type Consumer struct {
+	broker   Broker
+	cfg      ConsumerConfig
+	handlers map[string]Handler
+	dlq      Writer
+}
+
+func NewConsumer(broker Broker, cfg ConsumerConfig) *Consumer {
+	return &Consumer{
+		broker:   broker,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+	}
+}
+
+func (c *Consumer) Handle(topic string, h Handler) {
+	c.handlers[topic] = h
+}
+
+// Run blocks until ctx is cancelled or a topic loop fails, and every topic
+// loop has returned. A failing loop stops the others, so that the consumer
+// is either running as a whole or not at all; the first error is returned.
+func (c *Consumer) Run(ctx context.Context) error {
+	c.dlq = c.broker.Writer()
+	defer c.dlq.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(c.handlers))
+	for topic, h := range c.handlers {
+		wg.Add(1)
+		go func(topic string, h Handler) {
+			defer wg.Done()
+			if err := c.consume(ctx, topic, h); err != nil {
+				errs <- fmt.Errorf("consume %s: %w", topic, err)
+				cancel()
+			}
+		}(topic, h)
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (c *Consumer) consume(ctx context.Context, topic string, h Handler) error {
+	reader := c.broker.Reader(topic, c.cfg.GroupID)
+	defer reader.Close()
+
+	log.Printf("kafka: consuming %s as %s", topic, c.cfg.GroupID)
+	for {
+		msg, err := reader.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := c.process(ctx, h, msg); err != nil {
+			if ctx.Err() != nil {
+				// Shutting down mid-retry: leave it uncommitted for the next member.
+				return nil
+			}
+			return err
+		}
+
+		if err := reader.Commit(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, h Handler, msg Message) error {
+	backoff := c.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := h(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if IsPermanent(err) || attempt > c.cfg.MaxRetries {
+			log.Printf("kafka: %s@%d failed after %d attempt(s): %v", msg.Topic, msg.Offset, attempt, err)
+			return c.deadLetter(ctx, msg, err, attempt)
+		}
+
+		log.Printf("kafka: %s@%d attempt %d failed, retrying in %s: %v", msg.Topic, msg.Offset, attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.cfg.MaxBackoff {
+			backoff = c.cfg.MaxBackoff
+		}
+	}
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, msg Message, cause error, attempts int) error {
+	headers := make(map[string]string, len(msg.Headers)+3)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderOriginalTopic] = msg.Topic
+	headers[HeaderError] = cause.Error()
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+
+	return c.dlq.Write(ctx, Message{
+		Topic:   msg.Topic + c.cfg.DeadLetterSuffix,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
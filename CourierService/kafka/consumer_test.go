@@ -0,0 +1,258 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig() ConsumerConfig {
+	cfg := DefaultConsumerConfig("test")
+	cfg.MaxRetries = 3
+	cfg.InitialBackoff = 10 * time.Millisecond
+	cfg.MaxBackoff = 20 * time.Millisecond
+	return cfg
+}
+
+func produce(t *testing.T, b *MemoryBroker, topic string, values ...string) {
+	t.Helper()
+	w := b.Writer()
+	defer w.Close()
+	for _, v := range values {
+		if err := w.Write(context.Background(), Message{Topic: topic, Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// run starts c and returns a function that stops it and returns what Run did.
+func run(c *Consumer) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumerRetriesWithBackoff(t *testing.T) {
+	b := NewMemoryBroker()
+	produce(t, b, "jobs", "a")
+
+	var mu sync.Mutex
+	var calls []time.Time
+	c := NewConsumer(b, testConfig())
+	c.Handle("jobs", func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, time.Now())
+		if len(calls) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	stop := run(c)
+	eventually(t, "the message to be committed", func() bool { return b.Committed("jobs", "test") == 1 })
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("handler called %d times, want 3", len(calls))
+	}
+	// 10ms before the second attempt, then doubled to 20ms before the third.
+	if d := calls[1].Sub(calls[0]); d < 10*time.Millisecond {
+		t.Errorf("first backoff %s, want at least 10ms", d)
+	}
+	if d := calls[2].Sub(calls[1]); d < 20*time.Millisecond {
+		t.Errorf("second backoff %s, want at least 20ms", d)
+	}
+	if dlq := b.Messages("jobs.dlq"); len(dlq) != 0 {
+		t.Errorf("%d message(s) dead-lettered, want none", len(dlq))
+	}
+}
+
+func TestConsumerDeadLetters(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts string
+	}{
+		{"permanent", Permanent(errors.New("bad payload")), "1"},
+		{"retries exhausted", errors.New("still down"), "4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMemoryBroker()
+			w := b.Writer()
+			msg := Message{Topic: "jobs", Key: []byte("k"), Value: []byte("v"), Headers: map[string]string{"trace": "1"}}
+			if err := w.Write(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+
+			var calls int32
+			c := NewConsumer(b, testConfig())
+			c.Handle("jobs", func(ctx context.Context, msg Message) error {
+				atomic.AddInt32(&calls, 1)
+				return tt.err
+			})
+			stop := run(c)
+			eventually(t, "the message to be committed", func() bool { return b.Committed("jobs", "test") == 1 })
+			if err := stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strconv.Itoa(int(atomic.LoadInt32(&calls))); got != tt.attempts {
+				t.Errorf("handler called %s times, want %s", got, tt.attempts)
+			}
+			dlq := b.Messages("jobs.dlq")
+			if len(dlq) != 1 {
+				t.Fatalf("%d message(s) dead-lettered, want 1", len(dlq))
+			}
+			got := dlq[0]
+			if string(got.Key) != "k" || string(got.Value) != "v" {
+				t.Errorf("dead letter is %q=%q, want k=v", got.Key, got.Value)
+			}
+			want := map[string]string{
+				"trace":             "1",
+				HeaderOriginalTopic: "jobs",
+				HeaderError:         tt.err.Error(),
+				HeaderAttempts:      tt.attempts,
+			}
+			for k, v := range want {
+				if got.Headers[k] != v {
+					t.Errorf("header %s = %q, want %q", k, got.Headers[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryReaderRedeliversUncommitted(t *testing.T) {
+	b := NewMemoryBroker()
+	produce(t, b, "jobs", "a", "b")
+	ctx := context.Background()
+
+	first := b.Reader("jobs", "test")
+	a, err := first.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Commit(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Fetch(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Fetch after Close: %v, want ErrClosed", err)
+	}
+
+	second := b.Reader("jobs", "test")
+	defer second.Close()
+	msg, err := second.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Value) != "b" {
+		t.Fatalf("redelivered %q, want the uncommitted b", msg.Value)
+	}
+}
+
+func TestConsumerRedeliversAfterShutdown(t *testing.T) {
+	b := NewMemoryBroker()
+	produce(t, b, "jobs", "a")
+
+	started := make(chan struct{})
+	c := NewConsumer(b, testConfig())
+	c.Handle("jobs", func(ctx context.Context, msg Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	stop := run(c)
+	<-started
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.Committed("jobs", "test"); n != 0 {
+		t.Fatalf("committed %d after shutdown mid-message, want 0", n)
+	}
+
+	var got []string
+	var mu sync.Mutex
+	c = NewConsumer(b, testConfig())
+	c.Handle("jobs", func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, string(msg.Value))
+		return nil
+	})
+	stop = run(c)
+	eventually(t, "the message to be redelivered", func() bool { return b.Committed("jobs", "test") == 1 })
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("redelivered %q, want [a]", got)
+	}
+}
+
+// failingBroker is a MemoryBroker whose readers for one topic cannot fetch.
+type failingBroker struct {
+	*MemoryBroker
+	topic string
+	err   error
+}
+
+func (b *failingBroker) Reader(topic, groupID string) Reader {
+	if topic == b.topic {
+		return failingReader{b.err}
+	}
+	return b.MemoryBroker.Reader(topic, groupID)
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Fetch(ctx context.Context) (Message, error)    { return Message{}, r.err }
+func (r failingReader) Commit(ctx context.Context, msg Message) error { return nil }
+func (r failingReader) Close() error                                  { return nil }
+
+func TestConsumerStopsWhenATopicFails(t *testing.T) {
+	broken := errors.New("broker unreachable")
+	b := &failingBroker{MemoryBroker: NewMemoryBroker(), topic: "broken", err: broken}
+
+	c := NewConsumer(b, testConfig())
+	c.Handle("broken", func(ctx context.Context, msg Message) error { return nil })
+	c.Handle("healthy", func(ctx context.Context, msg Message) error { return nil })
+
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, broken) {
+			t.Fatalf("Run returned %v, want %v", err, broken)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run kept the healthy topic running after the other one failed")
+	}
+}
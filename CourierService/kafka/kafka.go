@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaBroker struct {
+	brokers []string
+}
+
+func NewKafkaBroker(brokers []string) *KafkaBroker {
+	return &KafkaBroker{brokers: brokers}
+}
+
+func (b *KafkaBroker) Reader(topic, groupID string) Reader {
+	return &kafkaReader{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers:          b.brokers,
+		Topic:            topic,
+		GroupID:          groupID,
+		MinBytes:         1,
+		MaxBytes:         10e6,
+		CommitInterval:   0, // commit synchronously so a rebalance never loses acknowledged work
+		RebalanceTimeout: 30 * time.Second,
+		StartOffset:      kafka.FirstOffset,
+	})}
+}
+
+func (b *KafkaBroker) Writer() Writer {
+	return &kafkaWriter{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(b.brokers...),
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+type kafkaReader struct {
+	reader *kafka.Reader
+}
+
+func (r *kafkaReader) Fetch(ctx context.Context) (Message, error) {
+	m, err := r.reader.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	headers := make(map[string]string, len(m.Headers))
+	for _, h := range m.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   headers,
+		Time:      m.Time,
+	}, nil
+}
+
+func (r *kafkaReader) Commit(ctx context.Context, msg Message) error {
+	return r.reader.CommitMessages(ctx, kafka.Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	})
+}
+
+func (r *kafkaReader) Close() error {
+	return r.reader.Close()
+}
+
+type kafkaWriter struct {
+	writer *kafka.Writer
+}
+
+func (w *kafkaWriter) Write(ctx context.Context, msgs ...Message) error {
+	out := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		headers := make([]kafka.Header, 0, len(m.Headers))
+		for k, v := range m.Headers {
+			headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+		out = append(out, kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Headers: headers})
+	}
+	return w.writer.WriteMessages(ctx, out...)
+}
+
+func (w *kafkaWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,183 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is an in-process Broker used to run consumers without a
+// Kafka cluster. Every topic is a single partition; readers sharing a
+// group id compete for messages the same way members of a Kafka group do.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string][]Message
+	groups map[string]*memoryGroup
+	notify chan struct{}
+}
+
+type memoryGroup struct {
+	next      int64
+	committed int64
+	inflight  map[int64]*memoryReader
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics: make(map[string][]Message),
+		groups: make(map[string]*memoryGroup),
+		notify: make(chan struct{}),
+	}
+}
+
+func (b *MemoryBroker) Reader(topic, groupID string) Reader {
+	return &memoryReader{broker: b, topic: topic, group: groupID}
+}
+
+func (b *MemoryBroker) Writer() Writer {
+	return &memoryWriter{broker: b}
+}
+
+// Messages returns a copy of everything written to topic so far.
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.topics[topic]...)
+}
+
+// Committed returns the committed offset of the group on topic.
+func (b *MemoryBroker) Committed(topic, groupID string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if g, ok := b.groups[groupKey(topic, groupID)]; ok {
+		return g.committed
+	}
+	return 0
+}
+
+func (b *MemoryBroker) group(topic, groupID string) *memoryGroup {
+	key := groupKey(topic, groupID)
+	g, ok := b.groups[key]
+	if !ok {
+		g = &memoryGroup{inflight: make(map[int64]*memoryReader)}
+		b.groups[key] = g
+	}
+	return g
+}
+
+func (b *MemoryBroker) publish(msgs ...Message) {
+	b.mu.Lock()
+	for _, m := range msgs {
+		m.Partition = 0
+		m.Offset = int64(len(b.topics[m.Topic]))
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		b.topics[m.Topic] = append(b.topics[m.Topic], m)
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+	b.mu.Unlock()
+}
+
+func groupKey(topic, groupID string) string {
+	return groupID + "/" + topic
+}
+
+type memoryReader struct {
+	broker *MemoryBroker
+	topic  string
+	group  string
+	closed bool
+}
+
+func (r *memoryReader) Fetch(ctx context.Context) (Message, error) {
+	for {
+		r.broker.mu.Lock()
+		if r.closed {
+			r.broker.mu.Unlock()
+			return Message{}, ErrClosed
+		}
+		g := r.broker.group(r.topic, r.group)
+		log := r.broker.topics[r.topic]
+		if g.next < int64(len(log)) {
+			msg := log[g.next]
+			g.inflight[g.next] = r
+			g.next++
+			r.broker.mu.Unlock()
+			return msg, nil
+		}
+		wait := r.broker.notify
+		r.broker.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (r *memoryReader) Commit(ctx context.Context, msg Message) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	g := r.broker.group(r.topic, r.group)
+	delete(g.inflight, msg.Offset)
+	if msg.Offset+1 > g.committed {
+		g.committed = msg.Offset + 1
+	}
+	return nil
+}
+
+// Close behaves like a member leaving the group: anything it fetched but
+// never committed is handed back to the group for redelivery.
+func (r *memoryReader) Close() error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	g := r.broker.group(r.topic, r.group)
+	for offset, owner := range g.inflight {
+		if owner != r {
+			continue
+		}
+		delete(g.inflight, offset)
+		if offset < g.next {
+			g.next = offset
+		}
+	}
+	close(r.broker.notify)
+	r.broker.notify = make(chan struct{})
+	return nil
+}
+
+type memoryWriter struct {
+	broker *MemoryBroker
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *memoryWriter) Write(ctx context.Context, msgs ...Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.broker.publish(msgs...)
+	return nil
+}
+
+func (w *memoryWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
@@ -0,0 +1,40 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+type KafkaProducer interface {
+	ProduceMessages(topic string, message []byte) error
+	Close() error
+}
+
+type Producer struct {
+	writer Writer
+}
+
+func NewKafkaProducer(broker Broker) KafkaProducer {
+	return &Producer{writer: broker.Writer()}
+}
+
+func (p *Producer) ProduceMessages(topic string, message []byte) error {
+	return p.writer.Write(context.Background(), Message{Topic: topic, Value: message})
+}
+
+// Publish marshals event and produces it to topic. Events report writes
+// that have already succeeded, so a failure is logged rather than returned.
+func Publish(p KafkaProducer, topic string, event interface{}) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = p.ProduceMessages(topic, data)
+	}
+	if err != nil {
+		log.Println("cannot produce messages via kafka: ", err)
+	}
+}
+
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
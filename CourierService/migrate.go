@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"learning-service/config"
+	"learning-service/migrations"
+	postgres "learning-service/storage/postgres"
+)
+
+const migrateUsage = `usage: migrate <command>
+  up [N]      apply all pending migrations, or the next N
+  down N      revert the last N migrations
+  version     print the current schema version
+  force V     mark version V as applied and clean, after a failed migration was fixed by hand`
+
+// runMigrate is the migrate subcommand: it applies the migrations embedded in
+// the binary, so the schema always matches the code that ships with it.
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	db, err := postgres.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	m, err := migrations.New(db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	defer m.Close()
+
+	n := 0
+	if len(args) > 1 {
+		// Version 0, the base schema, can only be forced.
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 || n == 0 && args[0] != "force" {
+			return fmt.Errorf("invalid count %q\n%s", args[1], migrateUsage)
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		if n > 0 {
+			err = m.Steps(n)
+		} else {
+			err = m.Up()
+		}
+	case "down":
+		if n == 0 {
+			return errors.New("down needs the number of migrations to revert\n" + migrateUsage)
+		}
+		err = m.Steps(-n)
+	case "force":
+		if len(args) < 2 {
+			return errors.New("force needs a version\n" + migrateUsage)
+		}
+		err = m.Force(n)
+	case "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		log.Println("schema version: none")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("schema version: %d (dirty: %t)", version, dirty)
+	return nil
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS submitted_homeworks;
+DROP TABLE IF EXISTS homeworks;
+DROP TABLE IF EXISTS feedback;
+DROP TABLE IF EXISTS recommendations;
+DROP TABLE IF EXISTS completed_extra_resources;
+DROP TABLE IF EXISTS extra_resources;
+DROP TABLE IF EXISTS quizzes;
+DROP TABLE IF EXISTS completed_topics;
+DROP TABLE IF EXISTS topics;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,89 @@
+-- The learning schema as the service first used it. Later migrations build
+-- on it; IF NOT EXISTS lets databases set up by hand adopt the versioning.
+-- It is version 0 so that migrations 1 to 8 keep the numbers databases
+-- already record: those skip it, and only new databases apply it first.
+
+-- Learners as far as this service is concerned: their id and XP balance.
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,
+    xp INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS topics (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    difficulty VARCHAR(32) NOT NULL DEFAULT '',
+    -- Unix seconds; 0 while the topic is live.
+    deleted_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS topics_difficulty_idx ON topics (difficulty) WHERE deleted_at = 0;
+
+CREATE TABLE IF NOT EXISTS completed_topics (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    xp_earned INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS completed_topics_user_idx ON completed_topics (user_id);
+CREATE INDEX IF NOT EXISTS completed_topics_topic_idx ON completed_topics (topic_id);
+
+CREATE TABLE IF NOT EXISTS quizzes (
+    id UUID PRIMARY KEY,
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    question TEXT NOT NULL,
+    -- Comma separated.
+    options TEXT NOT NULL,
+    answer TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS extra_resources (
+    id UUID PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    type VARCHAR(64) NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS completed_extra_resources (
+    user_id UUID NOT NULL,
+    extra_resource_id UUID NOT NULL REFERENCES extra_resources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS completed_extra_resources_user_idx ON completed_extra_resources (user_id);
+
+CREATE TABLE IF NOT EXISTS recommendations (
+    id UUID PRIMARY KEY,
+    type VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS feedback (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    rating INT NOT NULL,
+    comment TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS feedback_user_idx ON feedback (user_id);
+
+CREATE TABLE IF NOT EXISTS homeworks (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    difficulty VARCHAR(32) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS submitted_homeworks (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    homework_id UUID NOT NULL REFERENCES homeworks(id) ON DELETE CASCADE,
+    xp_earned INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS submitted_homeworks_user_idx ON submitted_homeworks (user_id, homework_id);
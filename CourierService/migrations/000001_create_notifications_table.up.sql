@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_created_idx ON notifications (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    notification_id UUID NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+    channel VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    send_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    UNIQUE (notification_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS notification_deliveries_due_idx ON notification_deliveries (send_after) WHERE status = 'pending';
+
+-- event_type '*' applies to every event unless a more specific row exists
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, event_type, channel)
+);
+
+-- quiet hours are minutes after local midnight in the user's time zone
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id UUID PRIMARY KEY,
+    quiet_start SMALLINT,
+    quiet_end SMALLINT,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    endpoint TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS push_subscriptions_user_idx ON push_subscriptions (user_id);
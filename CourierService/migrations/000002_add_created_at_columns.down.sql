@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS homeworks_user_created_at_idx;
+DROP INDEX IF EXISTS feedback_topic_created_at_idx;
+DROP INDEX IF EXISTS recommendations_user_created_at_idx;
+DROP INDEX IF EXISTS extra_resources_created_at_idx;
+
+ALTER TABLE homeworks DROP COLUMN IF EXISTS created_at;
+ALTER TABLE feedback DROP COLUMN IF EXISTS created_at;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS created_at;
+ALTER TABLE extra_resources DROP COLUMN IF EXISTS created_at;
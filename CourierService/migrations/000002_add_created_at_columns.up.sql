@@ -0,0 +1,9 @@
+ALTER TABLE extra_resources ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE homeworks ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS extra_resources_created_at_idx ON extra_resources (created_at, id);
+CREATE INDEX IF NOT EXISTS recommendations_user_created_at_idx ON recommendations (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS feedback_topic_created_at_idx ON feedback (topic_id, created_at, id);
+CREATE INDEX IF NOT EXISTS homeworks_user_created_at_idx ON homeworks (user_id, created_at, id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS quizzes_topic_created_at_idx;
+DROP INDEX IF EXISTS completed_topics_user_created_at_idx;
+DROP INDEX IF EXISTS topics_created_at_idx;
+
+ALTER TABLE quizzes DROP COLUMN IF EXISTS created_at;
+ALTER TABLE completed_topics DROP COLUMN IF EXISTS created_at;
+ALTER TABLE topics DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,7 @@
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE completed_topics ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS topics_created_at_idx ON topics (created_at, id) WHERE deleted_at = 0;
+CREATE INDEX IF NOT EXISTS completed_topics_user_created_at_idx ON completed_topics (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS quizzes_topic_created_at_idx ON quizzes (topic_id, created_at, id);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS quiz_results;
+DROP TABLE IF EXISTS quiz_attempt_answers;
+DROP TABLE IF EXISTS quiz_attempts;
+DROP TABLE IF EXISTS quiz_questions;
+
+ALTER TABLE quizzes
+    DROP COLUMN IF EXISTS xp,
+    DROP COLUMN IF EXISTS partial_credit,
+    DROP COLUMN IF EXISTS max_attempts,
+    DROP COLUMN IF EXISTS time_limit_seconds,
+    DROP COLUMN IF EXISTS title;
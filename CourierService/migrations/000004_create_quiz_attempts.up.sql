@@ -0,0 +1,71 @@
+ALTER TABLE quizzes
+    ADD COLUMN IF NOT EXISTS title VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS time_limit_seconds INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS partial_credit BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS xp INT NOT NULL DEFAULT 10;
+
+-- Questions now live in quiz_questions; the old single-question columns are
+-- only kept for quizzes created before this migration.
+ALTER TABLE quizzes
+    ALTER COLUMN question DROP NOT NULL,
+    ALTER COLUMN options DROP NOT NULL,
+    ALTER COLUMN answer DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS quiz_questions (
+    id UUID PRIMARY KEY,
+    quiz_id UUID NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('single_choice', 'multi_choice', 'true_false', 'numeric', 'short_text')),
+    prompt TEXT NOT NULL,
+    options JSONB NOT NULL DEFAULT '[]',
+    answer JSONB NOT NULL DEFAULT '[]',
+    tolerance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    points DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (points > 0),
+    UNIQUE (quiz_id, position)
+);
+
+INSERT INTO quiz_questions (id, quiz_id, position, type, prompt, options, answer)
+SELECT md5(id::text)::uuid, id, 1, 'single_choice', question,
+       to_jsonb(string_to_array(options, ',')), jsonb_build_array(answer)
+FROM quizzes
+WHERE question IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE quizzes SET title = question WHERE title IS NULL;
+
+CREATE TABLE IF NOT EXISTS quiz_attempts (
+    id UUID PRIMARY KEY,
+    quiz_id UUID NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'in_progress' CHECK (status IN ('in_progress', 'finished', 'expired')),
+    started_at TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    max_score DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+-- At most one open attempt per user and quiz.
+CREATE UNIQUE INDEX IF NOT EXISTS quiz_attempts_open_idx ON quiz_attempts (quiz_id, user_id) WHERE status = 'in_progress';
+CREATE INDEX IF NOT EXISTS quiz_attempts_user_idx ON quiz_attempts (user_id, quiz_id, started_at);
+
+CREATE TABLE IF NOT EXISTS quiz_attempt_answers (
+    attempt_id UUID NOT NULL REFERENCES quiz_attempts(id) ON DELETE CASCADE,
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    answer JSONB NOT NULL DEFAULT '[]',
+    points DOUBLE PRECISION NOT NULL DEFAULT 0,
+    correct BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (attempt_id, question_id)
+);
+
+-- Best result per user and quiz, and the XP already paid out for it.
+CREATE TABLE IF NOT EXISTS quiz_results (
+    user_id UUID NOT NULL,
+    quiz_id UUID NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+    best_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    max_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    xp_awarded INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, quiz_id)
+);
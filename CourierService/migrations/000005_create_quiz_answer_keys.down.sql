@@ -0,0 +1,12 @@
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS answer TEXT;
+ALTER TABLE quiz_questions
+    ADD COLUMN IF NOT EXISTS answer JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS tolerance DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE quiz_questions q
+SET answer = k.answer, tolerance = k.tolerance
+FROM quiz_answer_keys k
+WHERE k.question_id = q.id;
+
+DROP TABLE IF EXISTS quiz_answer_resources;
+DROP TABLE IF EXISTS quiz_answer_keys;
@@ -0,0 +1,21 @@
+-- Answer keys are kept apart from the questions so that no query serving a
+-- quiz to a learner can select them by accident.
+CREATE TABLE IF NOT EXISTS quiz_answer_keys (
+    question_id UUID PRIMARY KEY REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    answer JSONB NOT NULL DEFAULT '[]',
+    tolerance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    explanation TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS quiz_answer_resources (
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    extra_resource_id UUID NOT NULL REFERENCES extra_resources(id) ON DELETE CASCADE,
+    PRIMARY KEY (question_id, extra_resource_id)
+);
+
+INSERT INTO quiz_answer_keys (question_id, answer, tolerance)
+SELECT id, answer, tolerance FROM quiz_questions
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE quiz_questions DROP COLUMN IF EXISTS answer, DROP COLUMN IF EXISTS tolerance;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS answer;
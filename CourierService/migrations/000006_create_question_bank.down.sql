@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS quiz_attempt_questions;
+ALTER TABLE quiz_attempts DROP COLUMN IF EXISTS seed;
+
+DROP TABLE IF EXISTS quiz_template_rules;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS shuffle;
+
+DROP TABLE IF EXISTS quiz_question_topics;
+DROP TABLE IF EXISTS quiz_question_tags;
+DROP INDEX IF EXISTS quiz_questions_bank_idx;
+
+DELETE FROM quiz_questions WHERE quiz_id IS NULL;
+ALTER TABLE quiz_questions
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS difficulty,
+    ALTER COLUMN position SET NOT NULL,
+    ALTER COLUMN quiz_id SET NOT NULL;
@@ -0,0 +1,55 @@
+-- Questions without a quiz form the question bank that template quizzes
+-- draw from.
+ALTER TABLE quiz_questions
+    ALTER COLUMN quiz_id DROP NOT NULL,
+    ALTER COLUMN position DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS difficulty VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS quiz_questions_bank_idx ON quiz_questions (difficulty, id) WHERE quiz_id IS NULL;
+
+CREATE TABLE IF NOT EXISTS quiz_question_tags (
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (question_id, tag)
+);
+CREATE INDEX IF NOT EXISTS quiz_question_tags_tag_idx ON quiz_question_tags (tag);
+
+CREATE TABLE IF NOT EXISTS quiz_question_topics (
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    PRIMARY KEY (question_id, topic_id)
+);
+CREATE INDEX IF NOT EXISTS quiz_question_topics_topic_idx ON quiz_question_topics (topic_id);
+
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS shuffle BOOLEAN NOT NULL DEFAULT false;
+
+-- A quiz with rules is a template: each attempt draws its own questions.
+CREATE TABLE IF NOT EXISTS quiz_template_rules (
+    id UUID PRIMARY KEY,
+    quiz_id UUID NOT NULL REFERENCES quizzes(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    topic_id UUID REFERENCES topics(id) ON DELETE CASCADE,
+    difficulty VARCHAR(20),
+    tag VARCHAR(50),
+    count INT NOT NULL CHECK (count > 0),
+    UNIQUE (quiz_id, position)
+);
+
+ALTER TABLE quiz_attempts ADD COLUMN IF NOT EXISTS seed BIGINT NOT NULL DEFAULT 0;
+
+-- The paper an attempt was given: which questions, in which order, with the
+-- options in the order they were shown.
+CREATE TABLE IF NOT EXISTS quiz_attempt_questions (
+    attempt_id UUID NOT NULL REFERENCES quiz_attempts(id) ON DELETE CASCADE,
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    options JSONB NOT NULL DEFAULT '[]',
+    PRIMARY KEY (attempt_id, question_id)
+);
+
+INSERT INTO quiz_attempt_questions (attempt_id, question_id, position, options)
+SELECT a.id, q.id, q.position, q.options
+FROM quiz_attempts a
+JOIN quiz_questions q ON q.quiz_id = a.quiz_id
+ON CONFLICT DO NOTHING;
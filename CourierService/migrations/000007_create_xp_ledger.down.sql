@@ -0,0 +1 @@
+DROP TABLE IF EXISTS xp_ledger;
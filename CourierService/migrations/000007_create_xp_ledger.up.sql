@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS xp_ledger (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    source_type VARCHAR(32) NOT NULL,
+    source_id VARCHAR(64) NOT NULL,
+    amount INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_id, source_type, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS xp_ledger_user_created_at_idx ON xp_ledger (user_id, created_at, id);
+
+-- Backfill the awards that can still be traced to their source. Repeated
+-- completions of the same topic or resource collapse into their first award.
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason, created_at)
+SELECT DISTINCT ON (user_id, topic_id) gen_random_uuid(), user_id, 'topic', topic_id::text, xp_earned, 'topic completed', created_at
+FROM completed_topics
+WHERE xp_earned <> 0
+ORDER BY user_id, topic_id, created_at
+ON CONFLICT DO NOTHING;
+
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason)
+SELECT DISTINCT ON (user_id, extra_resource_id) gen_random_uuid(), user_id, 'extra_resource', extra_resource_id::text, 10, 'extra resource completed'
+FROM completed_extra_resources
+ON CONFLICT DO NOTHING;
+
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason, created_at)
+SELECT gen_random_uuid(), user_id, 'feedback', id::text, 10, 'feedback left', created_at
+FROM feedback
+ON CONFLICT DO NOTHING;
+
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason)
+SELECT DISTINCT ON (user_id, homework_id) gen_random_uuid(), user_id, 'homework', homework_id::text, xp_earned, 'homework submitted'
+FROM submitted_homeworks
+WHERE xp_earned <> 0
+ON CONFLICT DO NOTHING;
+
+-- Quiz XP is awarded per attempt, but past top-ups cannot be told apart, so
+-- each quiz's total is booked against the quiz itself.
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason, created_at)
+SELECT gen_random_uuid(), user_id, 'quiz', quiz_id::text, xp_awarded, 'quiz result', updated_at
+FROM quiz_results
+WHERE xp_awarded <> 0
+ON CONFLICT DO NOTHING;
+
+-- Whatever the traced awards do not explain becomes an opening balance, so
+-- that every balance equals the sum of its ledger from here on.
+INSERT INTO xp_ledger (id, user_id, source_type, source_id, amount, reason)
+SELECT gen_random_uuid(), u.id, 'opening', 'opening', u.xp - COALESCE(l.total, 0), 'opening balance'
+FROM users u
+LEFT JOIN (SELECT user_id, SUM(amount) AS total FROM xp_ledger GROUP BY user_id) l ON l.user_id = u.id
+WHERE u.xp <> COALESCE(l.total, 0)
+ON CONFLICT DO NOTHING;
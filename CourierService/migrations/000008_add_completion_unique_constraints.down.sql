@@ -0,0 +1,2 @@
+ALTER TABLE completed_extra_resources DROP CONSTRAINT IF EXISTS completed_extra_resources_user_resource_key;
+ALTER TABLE completed_topics DROP CONSTRAINT IF EXISTS completed_topics_user_topic_key;
@@ -0,0 +1,15 @@
+-- Keep the first of any repeated completions before enforcing uniqueness.
+DELETE FROM completed_topics a
+USING completed_topics b
+WHERE a.user_id = b.user_id AND a.topic_id = b.topic_id
+  AND (a.created_at, a.ctid) > (b.created_at, b.ctid);
+
+DELETE FROM completed_extra_resources a
+USING completed_extra_resources b
+WHERE a.user_id = b.user_id AND a.extra_resource_id = b.extra_resource_id
+  AND a.ctid > b.ctid;
+
+ALTER TABLE completed_topics
+    ADD CONSTRAINT completed_topics_user_topic_key UNIQUE (user_id, topic_id);
+ALTER TABLE completed_extra_resources
+    ADD CONSTRAINT completed_extra_resources_user_resource_key UNIQUE (user_id, extra_resource_id);
@@ -0,0 +1,16 @@
+-- Restore the stand-in users table, unless a users table (the auth
+-- service's) is already there.
+DO $$
+BEGIN
+    IF to_regclass('users') IS NULL THEN
+        CREATE TABLE users (
+            id UUID PRIMARY KEY,
+            xp INT NOT NULL DEFAULT 0
+        );
+        INSERT INTO users (id, xp)
+        SELECT user_id, xp FROM learning_profiles WHERE deleted_at IS NULL;
+    END IF;
+END
+$$;
+
+DROP TABLE IF EXISTS learning_profiles;
@@ -0,0 +1,36 @@
+-- XP, level and streaks of each auth user, owned by the learning service.
+CREATE TABLE IF NOT EXISTS learning_profiles (
+    user_id UUID PRIMARY KEY,
+    xp INT NOT NULL DEFAULT 0,
+    level INT NOT NULL DEFAULT 1,
+    current_streak INT NOT NULL DEFAULT 0,
+    longest_streak INT NOT NULL DEFAULT 0,
+    last_active_on DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    -- Set when the auth user is deleted; the row stays so that late awards
+    -- cannot bring the profile back.
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS learning_profiles_xp_idx ON learning_profiles (xp DESC) WHERE deleted_at IS NULL;
+
+-- The ledger already accounts for every balance. Levels follow models.Level.
+INSERT INTO learning_profiles (user_id, xp, level)
+SELECT user_id, SUM(amount), floor(sqrt(GREATEST(SUM(amount), 0) / 100.0))::int + 1
+FROM xp_ledger
+GROUP BY user_id
+ON CONFLICT DO NOTHING;
+
+-- Drop the stand-in users table of 000001, but never the auth service's own
+-- table in a database the two services share.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email'
+    ) THEN
+        DROP TABLE IF EXISTS users;
+    END IF;
+END
+$$;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS learning_profiles_streak_idx;
+DROP TABLE IF EXISTS learning_activity;
+
+ALTER TABLE learning_profiles
+    DROP COLUMN IF EXISTS streak_nudged_on,
+    DROP COLUMN IF EXISTS streak_freezes,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS daily_goal;
@@ -0,0 +1,23 @@
+ALTER TABLE learning_profiles
+    ADD COLUMN IF NOT EXISTS daily_goal INT NOT NULL DEFAULT 50,
+    ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC',
+    ADD COLUMN IF NOT EXISTS streak_freezes INT NOT NULL DEFAULT 0,
+    -- The local day the user was last told their streak is at risk.
+    ADD COLUMN IF NOT EXISTS streak_nudged_on DATE;
+
+-- Users who already picked a time zone for their notifications keep it.
+UPDATE learning_profiles p SET timezone = s.timezone
+FROM notification_settings s
+WHERE s.user_id = p.user_id AND s.timezone <> '';
+
+-- One row per user and local day they were active on.
+CREATE TABLE IF NOT EXISTS learning_activity (
+    user_id UUID NOT NULL REFERENCES learning_profiles(user_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    xp INT NOT NULL DEFAULT 0,
+    activities INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS learning_profiles_streak_idx ON learning_profiles (last_active_on)
+WHERE current_streak > 0 AND deleted_at IS NULL;
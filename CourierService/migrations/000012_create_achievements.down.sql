@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS game_level_completions;
+DROP TABLE IF EXISTS achievement_progress;
+DROP TABLE IF EXISTS achievements;
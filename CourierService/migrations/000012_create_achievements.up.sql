@@ -0,0 +1,52 @@
+-- A rule awards its badge once a user's metric reaches the threshold.
+-- level_id and max_seconds only narrow the game_levels metric.
+CREATE TABLE IF NOT EXISTS achievements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    metric VARCHAR(32) NOT NULL CHECK (metric IN (
+        'topics_completed', 'resources_completed', 'quizzes_finished', 'quizzes_perfect',
+        'homeworks_submitted', 'streak_days', 'xp', 'game_levels')),
+    threshold INT NOT NULL CHECK (threshold > 0),
+    level_id TEXT NOT NULL DEFAULT '',
+    max_seconds INT NOT NULL DEFAULT 0,
+    xp INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS achievements_metric_idx ON achievements (metric) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS achievement_progress (
+    user_id UUID NOT NULL,
+    achievement_id UUID NOT NULL REFERENCES achievements(id) ON DELETE CASCADE,
+    progress INT NOT NULL DEFAULT 0,
+    awarded_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, achievement_id)
+);
+
+CREATE INDEX IF NOT EXISTS achievement_progress_awarded_idx ON achievement_progress (user_id, awarded_at)
+WHERE awarded_at IS NOT NULL;
+
+-- The game service keeps its own data; this is the best time of each level
+-- per user, as reported by level.completed events.
+CREATE TABLE IF NOT EXISTS game_level_completions (
+    user_id UUID NOT NULL,
+    level_id TEXT NOT NULL,
+    best_seconds INT NOT NULL,
+    completed_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, level_id)
+);
+
+INSERT INTO achievements (code, name, description, metric, threshold, xp) VALUES
+    ('first-topic', 'First steps', 'Complete your first topic', 'topics_completed', 1, 10),
+    ('topics-10', 'Bookworm', 'Complete 10 topics', 'topics_completed', 10, 50),
+    ('resources-10', 'Explorer', 'Complete 10 extra resources', 'resources_completed', 10, 30),
+    ('perfect-quizzes-5', 'Perfectionist', 'Get a perfect score on 5 quizzes', 'quizzes_perfect', 5, 50),
+    ('homeworks-10', 'Diligent', 'Submit 10 homeworks', 'homeworks_submitted', 10, 50),
+    ('streak-7', 'On a roll', 'Keep a 7-day streak', 'streak_days', 7, 30),
+    ('streak-30', 'Unstoppable', 'Keep a 30-day streak', 'streak_days', 30, 100),
+    ('xp-1000', 'Scholar', 'Earn 1000 XP', 'xp', 1000, 0)
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS xp_ledger_created_at_idx;
+DROP INDEX IF EXISTS submitted_homeworks_user_created_at_idx;
+DROP INDEX IF EXISTS completed_extra_resources_user_created_at_idx;
+
+ALTER TABLE submitted_homeworks DROP COLUMN IF EXISTS created_at;
+ALTER TABLE completed_extra_resources DROP COLUMN IF EXISTS created_at;
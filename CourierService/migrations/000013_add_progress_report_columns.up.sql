@@ -0,0 +1,9 @@
+-- Progress reports filter and bucket activity by when it happened.
+ALTER TABLE completed_extra_resources ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE submitted_homeworks ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS completed_extra_resources_user_created_at_idx ON completed_extra_resources (user_id, created_at);
+CREATE INDEX IF NOT EXISTS submitted_homeworks_user_created_at_idx ON submitted_homeworks (user_id, created_at);
+
+-- Finds the cohort of users who earned XP in a date range.
+CREATE INDEX IF NOT EXISTS xp_ledger_created_at_idx ON xp_ledger (created_at) WHERE amount > 0;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS course_enrollments;
+DROP TABLE IF EXISTS topic_prerequisites;
+DROP TABLE IF EXISTS course_module_items;
+DROP TABLE IF EXISTS course_modules;
+DROP TABLE IF EXISTS courses;
@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS courses (
+    id UUID PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS courses_created_at_idx ON courses (created_at, id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS course_modules (
+    id UUID PRIMARY KEY,
+    course_id UUID NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS course_modules_course_idx ON course_modules (course_id, position);
+
+-- The ordered content of a module. item_id points into the table item_type
+-- names, so it cannot carry a foreign key.
+CREATE TABLE IF NOT EXISTS course_module_items (
+    module_id UUID NOT NULL REFERENCES course_modules(id) ON DELETE CASCADE,
+    item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('topic', 'quiz', 'extra_resource', 'homework')),
+    item_id UUID NOT NULL,
+    position INT NOT NULL,
+    PRIMARY KEY (module_id, item_type, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS course_module_items_item_idx ON course_module_items (item_type, item_id);
+
+-- topic_id stays locked for a user until they completed prerequisite_id.
+-- The edges form a DAG; the service refuses edges that close a cycle.
+CREATE TABLE IF NOT EXISTS topic_prerequisites (
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    prerequisite_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    PRIMARY KEY (topic_id, prerequisite_id),
+    CHECK (topic_id <> prerequisite_id)
+);
+
+CREATE INDEX IF NOT EXISTS topic_prerequisites_prerequisite_idx ON topic_prerequisites (prerequisite_id);
+
+CREATE TABLE IF NOT EXISTS course_enrollments (
+    user_id UUID NOT NULL,
+    course_id UUID NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    enrolled_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, course_id)
+);
+
+CREATE INDEX IF NOT EXISTS course_enrollments_course_idx ON course_enrollments (course_id);
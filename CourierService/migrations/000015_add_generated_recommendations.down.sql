@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS learning_profiles_recommended_at_idx;
+ALTER TABLE learning_profiles DROP COLUMN IF EXISTS recommended_at;
+
+DELETE FROM recommendations WHERE generated;
+DROP INDEX IF EXISTS recommendations_generated_idx;
+ALTER TABLE recommendations
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS generated,
+    DROP COLUMN IF EXISTS score,
+    DROP COLUMN IF EXISTS item_id;
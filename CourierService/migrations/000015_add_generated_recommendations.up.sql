@@ -0,0 +1,15 @@
+-- Recommendations the engine generates are keyed by what they recommend, so
+-- a refresh updates them in place. Those created by hand keep item_id empty.
+ALTER TABLE recommendations
+    ADD COLUMN IF NOT EXISTS item_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS generated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+
+CREATE UNIQUE INDEX IF NOT EXISTS recommendations_generated_idx ON recommendations (user_id, type, item_id) WHERE generated;
+
+-- When the engine last refreshed the user's recommendations.
+ALTER TABLE learning_profiles ADD COLUMN IF NOT EXISTS recommended_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS learning_profiles_recommended_at_idx ON learning_profiles (recommended_at NULLS FIRST)
+WHERE deleted_at IS NULL;
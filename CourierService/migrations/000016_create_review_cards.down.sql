@@ -0,0 +1,4 @@
+ALTER TABLE learning_activity DROP COLUMN IF EXISTS reviews;
+
+DROP TABLE IF EXISTS review_log;
+DROP TABLE IF EXISTS review_cards;
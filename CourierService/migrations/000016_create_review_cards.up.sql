@@ -0,0 +1,46 @@
+-- A review card schedules one quiz question for one user with SM-2: ease
+-- scales the interval after every successful review, a lapse starts the
+-- repetitions over.
+CREATE TABLE IF NOT EXISTS review_cards (
+    user_id UUID NOT NULL,
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    ease DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    interval_days INT NOT NULL DEFAULT 0,
+    repetitions INT NOT NULL DEFAULT 0,
+    lapses INT NOT NULL DEFAULT 0,
+    due_at TIMESTAMP NOT NULL,
+    last_reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, question_id)
+);
+
+CREATE INDEX IF NOT EXISTS review_cards_due_idx ON review_cards (user_id, due_at);
+
+-- Every answer a card was scheduled on, from quizzes and from reviews.
+CREATE TABLE IF NOT EXISTS review_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    question_id UUID NOT NULL REFERENCES quiz_questions(id) ON DELETE CASCADE,
+    grade SMALLINT NOT NULL CHECK (grade BETWEEN 0 AND 5),
+    source VARCHAR(10) NOT NULL CHECK (source IN ('quiz', 'review')),
+    interval_days INT NOT NULL,
+    reviewed_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS review_log_user_idx ON review_log (user_id, reviewed_at);
+
+ALTER TABLE learning_activity ADD COLUMN IF NOT EXISTS reviews INT NOT NULL DEFAULT 0;
+
+-- Questions answered before the queue existed come up for review a day
+-- after their last answer.
+INSERT INTO review_cards (user_id, question_id, repetitions, lapses, interval_days, due_at, last_reviewed_at)
+SELECT DISTINCT ON (a.user_id, aa.question_id)
+    a.user_id, aa.question_id,
+    CASE WHEN aa.correct THEN 1 ELSE 0 END,
+    CASE WHEN aa.correct THEN 0 ELSE 1 END,
+    1, a.finished_at + interval '1 day', a.finished_at
+FROM quiz_attempt_answers aa
+JOIN quiz_attempts a ON a.id = aa.attempt_id
+WHERE a.status = 'finished' AND a.finished_at IS NOT NULL
+ORDER BY a.user_id, aa.question_id, a.finished_at DESC
+ON CONFLICT DO NOTHING;
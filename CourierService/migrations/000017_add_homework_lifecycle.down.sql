@@ -0,0 +1,28 @@
+DROP INDEX IF EXISTS submitted_homeworks_status_idx;
+DROP INDEX IF EXISTS submitted_homeworks_attempt_idx;
+
+ALTER TABLE submitted_homeworks
+    DROP COLUMN IF EXISTS graded_at,
+    DROP COLUMN IF EXISTS graded_by,
+    DROP COLUMN IF EXISTS comment,
+    DROP COLUMN IF EXISTS rubric_scores,
+    DROP COLUMN IF EXISTS max_score,
+    DROP COLUMN IF EXISTS score,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS days_late,
+    DROP COLUMN IF EXISTS attachments,
+    DROP COLUMN IF EXISTS links,
+    DROP COLUMN IF EXISTS content,
+    DROP COLUMN IF EXISTS attempt;
+
+DROP TABLE IF EXISTS homework_assignments;
+DROP TABLE IF EXISTS study_group_members;
+DROP TABLE IF EXISTS study_groups;
+
+ALTER TABLE homeworks
+    DROP COLUMN IF EXISTS rubric,
+    DROP COLUMN IF EXISTS max_submissions,
+    DROP COLUMN IF EXISTS late_penalty_percent,
+    DROP COLUMN IF EXISTS late_policy,
+    DROP COLUMN IF EXISTS max_xp,
+    DROP COLUMN IF EXISTS due_at;
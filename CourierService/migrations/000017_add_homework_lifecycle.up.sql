@@ -0,0 +1,80 @@
+-- A homework now has a due date, a late policy, a cap on resubmissions
+-- and the XP a full score earns. Its rubric is a list of criteria, each
+-- with the points it is worth.
+ALTER TABLE homeworks
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS max_xp INT NOT NULL DEFAULT 20 CHECK (max_xp >= 0),
+    ADD COLUMN IF NOT EXISTS late_policy VARCHAR(10) NOT NULL DEFAULT 'accept' CHECK (late_policy IN ('accept', 'penalty', 'reject')),
+    ADD COLUMN IF NOT EXISTS late_penalty_percent INT NOT NULL DEFAULT 0 CHECK (late_penalty_percent BETWEEN 0 AND 100),
+    ADD COLUMN IF NOT EXISTS max_submissions INT NOT NULL DEFAULT 0 CHECK (max_submissions >= 0),
+    ADD COLUMN IF NOT EXISTS rubric JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE IF NOT EXISTS study_groups (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS study_group_members (
+    group_id UUID NOT NULL REFERENCES study_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    added_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS study_group_members_user_idx ON study_group_members (user_id);
+
+-- Who a homework is assigned to. Assigning a group assigns its members at
+-- the time; due_at overrides the homework's. xp_awarded is what the user's
+-- graded submissions have paid so far.
+CREATE TABLE IF NOT EXISTS homework_assignments (
+    homework_id UUID NOT NULL REFERENCES homeworks(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    group_id UUID REFERENCES study_groups(id) ON DELETE SET NULL,
+    due_at TIMESTAMP,
+    xp_awarded INT NOT NULL DEFAULT 0,
+    assigned_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (homework_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS homework_assignments_user_idx ON homework_assignments (user_id, assigned_at);
+
+ALTER TABLE submitted_homeworks
+    ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS content TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS links JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS attachments JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS days_late INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS status VARCHAR(12) NOT NULL DEFAULT 'submitted'
+        CHECK (status IN ('submitted', 'superseded', 'returned', 'graded')),
+    ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS max_score DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS rubric_scores JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS comment TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS graded_by UUID,
+    ADD COLUMN IF NOT EXISTS graded_at TIMESTAMP;
+
+-- Homeworks so far were owned by the one user they were made for, and
+-- submissions paid out what the client asked on the spot: they count as
+-- assigned and graded, and what they paid as already awarded.
+INSERT INTO homework_assignments (homework_id, user_id, assigned_at)
+SELECT id, user_id, created_at FROM homeworks
+ON CONFLICT DO NOTHING;
+
+INSERT INTO homework_assignments (homework_id, user_id, assigned_at)
+SELECT homework_id, user_id, MIN(created_at) FROM submitted_homeworks GROUP BY homework_id, user_id
+ON CONFLICT DO NOTHING;
+
+UPDATE homework_assignments a SET xp_awarded = l.amount
+FROM xp_ledger l
+WHERE l.user_id = a.user_id AND l.source_type = 'homework' AND l.source_id = a.homework_id::text;
+
+UPDATE submitted_homeworks s SET status = 'graded', graded_at = s.created_at, attempt = n.attempt
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY user_id, homework_id ORDER BY created_at, id) AS attempt
+    FROM submitted_homeworks
+) n
+WHERE n.id = s.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS submitted_homeworks_attempt_idx ON submitted_homeworks (homework_id, user_id, attempt);
+CREATE INDEX IF NOT EXISTS submitted_homeworks_status_idx ON submitted_homeworks (status, created_at) WHERE status = 'submitted';
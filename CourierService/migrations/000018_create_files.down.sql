@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS submitted_homeworks_attachments_idx;
+DROP INDEX IF EXISTS learning_profiles_avatar_idx;
+DROP INDEX IF EXISTS extra_resources_file_idx;
+
+ALTER TABLE learning_profiles DROP COLUMN IF EXISTS avatar_file_id;
+ALTER TABLE extra_resources DROP COLUMN IF EXISTS file_id;
+
+DROP TABLE IF EXISTS blob_deletions;
+DROP TABLE IF EXISTS files;
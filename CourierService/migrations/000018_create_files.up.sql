@@ -0,0 +1,35 @@
+-- A file is one upload. Its bytes live in blob storage under the sha256 of
+-- the content, so files with the same content share them; a file is pending
+-- until its upload has been checked against the size and sha256 it claimed.
+CREATE TABLE IF NOT EXISTS files (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('resource', 'avatar', 'homework')),
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(127) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    sha256 CHAR(64) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready')),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS files_sha256_idx ON files (sha256);
+CREATE INDEX IF NOT EXISTS files_status_idx ON files (status, created_at);
+
+-- Blobs no file points at any more, waiting to be deleted from the store.
+CREATE TABLE IF NOT EXISTS blob_deletions (
+    key TEXT PRIMARY KEY,
+    queued_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE extra_resources
+    ADD COLUMN IF NOT EXISTS file_id UUID REFERENCES files(id) ON DELETE SET NULL;
+
+ALTER TABLE learning_profiles
+    ADD COLUMN IF NOT EXISTS avatar_file_id UUID REFERENCES files(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS extra_resources_file_idx ON extra_resources (file_id);
+CREATE INDEX IF NOT EXISTS learning_profiles_avatar_idx ON learning_profiles (avatar_file_id);
+-- Attachments point at files by file_id inside the JSON.
+CREATE INDEX IF NOT EXISTS submitted_homeworks_attachments_idx ON submitted_homeworks USING gin (attachments jsonb_path_ops);
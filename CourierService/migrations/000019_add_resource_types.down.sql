@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS completed_extra_resources_id_idx;
+ALTER TABLE completed_extra_resources DROP COLUMN IF EXISTS id;
+
+DROP TABLE IF EXISTS resource_topics;
+
+DROP INDEX IF EXISTS extra_resources_url_check_idx;
+
+ALTER TABLE extra_resources DROP CONSTRAINT IF EXISTS extra_resources_type_check;
+ALTER TABLE extra_resources ALTER COLUMN type SET DEFAULT '';
+
+UPDATE extra_resources SET type = metadata->>'legacy_type' WHERE metadata ? 'legacy_type';
+
+ALTER TABLE extra_resources
+    DROP COLUMN IF EXISTS url_checked_at,
+    DROP COLUMN IF EXISTS url_status_code,
+    DROP COLUMN IF EXISTS url_status,
+    DROP COLUMN IF EXISTS metadata,
+    DROP COLUMN IF EXISTS description;
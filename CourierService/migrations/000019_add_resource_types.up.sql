@@ -0,0 +1,36 @@
+-- Resource types used to be free text. Known ones are kept; anything else
+-- becomes an article and keeps what it said in metadata.
+ALTER TABLE extra_resources
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS url_status VARCHAR(20) NOT NULL DEFAULT 'unchecked'
+        CHECK (url_status IN ('unchecked', 'reachable', 'unreachable')),
+    ADD COLUMN IF NOT EXISTS url_status_code INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS url_checked_at TIMESTAMP;
+
+UPDATE extra_resources
+SET metadata = jsonb_build_object('legacy_type', type), type = 'article'
+WHERE lower(trim(type)) NOT IN ('article', 'video', 'book', 'course', 'podcast');
+
+UPDATE extra_resources SET type = lower(trim(type));
+
+ALTER TABLE extra_resources
+    ALTER COLUMN type SET DEFAULT 'article',
+    ADD CONSTRAINT extra_resources_type_check CHECK (type IN ('article', 'video', 'book', 'course', 'podcast'));
+
+CREATE INDEX IF NOT EXISTS extra_resources_url_check_idx ON extra_resources (url_checked_at NULLS FIRST) WHERE url <> '';
+
+-- Resources cover one or more topics.
+CREATE TABLE IF NOT EXISTS resource_topics (
+    extra_resource_id UUID NOT NULL REFERENCES extra_resources(id) ON DELETE CASCADE,
+    topic_id UUID NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    PRIMARY KEY (extra_resource_id, topic_id)
+);
+
+CREATE INDEX IF NOT EXISTS resource_topics_topic_idx ON resource_topics (topic_id);
+
+-- Completions get an id of their own, to be referred to.
+ALTER TABLE completed_extra_resources
+    ADD COLUMN IF NOT EXISTS id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX IF NOT EXISTS completed_extra_resources_id_idx ON completed_extra_resources (id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS feedback_pending_idx;
+DROP INDEX IF EXISTS feedback_topic_rating_idx;
+
+ALTER TABLE feedback
+    DROP CONSTRAINT IF EXISTS feedback_user_topic_key,
+    DROP CONSTRAINT IF EXISTS feedback_rating_check,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS moderated_at,
+    DROP COLUMN IF EXISTS moderated_by,
+    DROP COLUMN IF EXISTS moderation_reason,
+    DROP COLUMN IF EXISTS status;
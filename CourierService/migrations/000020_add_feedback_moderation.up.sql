@@ -0,0 +1,23 @@
+-- One review per user per topic: keep the latest of any repeated ones.
+DELETE FROM feedback a
+USING feedback b
+WHERE a.user_id = b.user_id AND a.topic_id = b.topic_id
+  AND (a.created_at, a.ctid) < (b.created_at, b.ctid);
+
+UPDATE feedback SET rating = LEAST(GREATEST(rating, 1), 5) WHERE rating NOT BETWEEN 1 AND 5;
+
+-- Reviews already there were shown, so they count as accepted.
+ALTER TABLE feedback
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'accepted'
+        CHECK (status IN ('pending', 'accepted', 'rejected')),
+    ADD COLUMN IF NOT EXISTS moderation_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS moderated_by UUID,
+    ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP,
+    ADD CONSTRAINT feedback_rating_check CHECK (rating BETWEEN 1 AND 5),
+    ADD CONSTRAINT feedback_user_topic_key UNIQUE (user_id, topic_id);
+
+ALTER TABLE feedback ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS feedback_topic_rating_idx ON feedback (topic_id, rating) WHERE status = 'accepted';
+CREATE INDEX IF NOT EXISTS feedback_pending_idx ON feedback (created_at, id) WHERE status = 'pending';
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS homeworks_search ON homeworks;
+DROP TRIGGER IF EXISTS extra_resources_search ON extra_resources;
+DROP TRIGGER IF EXISTS quizzes_search ON quizzes;
+DROP TRIGGER IF EXISTS topics_search ON topics;
+
+DROP FUNCTION IF EXISTS search_index_homework();
+DROP FUNCTION IF EXISTS search_index_extra_resource();
+DROP FUNCTION IF EXISTS search_index_quiz();
+DROP FUNCTION IF EXISTS search_index_topic();
+DROP FUNCTION IF EXISTS search_index(TEXT, UUID, TEXT, TEXT);
+
+DROP TABLE IF EXISTS search_documents;
+
+DROP FUNCTION IF EXISTS search_query(TEXT);
+DROP FUNCTION IF EXISTS search_language(TEXT);
+DROP FUNCTION IF EXISTS search_normalize(TEXT);
+DROP TEXT SEARCH CONFIGURATION IF EXISTS uzbek;
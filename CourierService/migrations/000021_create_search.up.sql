@@ -0,0 +1,144 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- Postgres has no Uzbek stemmer: Uzbek words are indexed whole, without
+-- accents, and typos and suffixes are left to trigram matching.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'uzbek') THEN
+        CREATE TEXT SEARCH CONFIGURATION uzbek (COPY = simple);
+        ALTER TEXT SEARCH CONFIGURATION uzbek
+            ALTER MAPPING FOR asciiword, asciihword, hword_asciipart, word, hword, hword_part WITH unaccent, simple;
+    END IF;
+END
+$$;
+
+-- Uzbek Latin writes o‘ and g‘ with any of several apostrophes, which would
+-- otherwise split words. Queries go through the same function.
+CREATE OR REPLACE FUNCTION search_normalize(t TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(coalesce(t, ''), '''‘’ʻʼ`', '')
+$$;
+
+-- The language a document is stemmed in: Russian for Cyrillic, Uzbek for
+-- Latin with Uzbek letters or common words, English otherwise.
+CREATE OR REPLACE FUNCTION search_language(t TEXT) RETURNS regconfig
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT CASE
+        WHEN t ~ '[а-яА-ЯёЁ]' THEN 'russian'::regconfig
+        WHEN t ~* '(o|g)[''‘’ʻʼ`]|\m(va|uchun|bilan|haqida|nima|qanday|bo''yicha|dars|mashq)\M' THEN 'uzbek'::regconfig
+        ELSE 'english'::regconfig
+    END
+$$;
+
+-- A query matches in any of the languages, each stemming it its own way.
+CREATE OR REPLACE FUNCTION search_query(q TEXT) RETURNS tsquery
+LANGUAGE sql STABLE PARALLEL SAFE AS $$
+    SELECT websearch_to_tsquery('english', search_normalize(q))
+        || websearch_to_tsquery('russian', search_normalize(q))
+        || websearch_to_tsquery('uzbek', search_normalize(q))
+$$;
+
+CREATE TABLE IF NOT EXISTS search_documents (
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('topic', 'quiz', 'extra_resource', 'homework')),
+    id UUID NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    language regconfig NOT NULL,
+    document tsvector NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (kind, id)
+);
+
+CREATE INDEX IF NOT EXISTS search_documents_document_idx ON search_documents USING GIN (document);
+CREATE INDEX IF NOT EXISTS search_documents_title_trgm_idx ON search_documents USING GIN (lower(title) gin_trgm_ops);
+
+CREATE OR REPLACE FUNCTION search_index(d_kind TEXT, d_id UUID, d_title TEXT, d_body TEXT) RETURNS void
+LANGUAGE plpgsql AS $$
+DECLARE
+    lang regconfig := search_language(coalesce(d_title, '') || ' ' || coalesce(d_body, ''));
+BEGIN
+    INSERT INTO search_documents (kind, id, title, body, language, document, updated_at)
+    VALUES (d_kind, d_id, coalesce(d_title, ''), coalesce(d_body, ''), lang,
+        setweight(to_tsvector(lang, search_normalize(d_title)), 'A') ||
+        setweight(to_tsvector(lang, search_normalize(d_body)), 'B'),
+        now())
+    ON CONFLICT (kind, id) DO UPDATE
+    SET title = EXCLUDED.title, body = EXCLUDED.body, language = EXCLUDED.language,
+        document = EXCLUDED.document, updated_at = EXCLUDED.updated_at;
+END
+$$;
+
+-- Each searchable table keeps its documents current from a trigger.
+CREATE OR REPLACE FUNCTION search_index_topic() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = OLD.id;
+    ELSIF NEW.deleted_at <> 0 THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = NEW.id;
+    ELSE
+        PERFORM search_index('topic', NEW.id, NEW.name, NEW.description);
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_quiz() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'quiz' AND id = OLD.id;
+    ELSE
+        PERFORM search_index('quiz', NEW.id, NEW.title, '');
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_extra_resource() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'extra_resource' AND id = OLD.id;
+    ELSE
+        PERFORM search_index('extra_resource', NEW.id, NEW.title,
+            concat_ws(' ', NEW.description, NEW.metadata->>'author', NEW.metadata->>'publisher', NEW.metadata->>'provider'));
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_homework() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'homework' AND id = OLD.id;
+    ELSE
+        PERFORM search_index('homework', NEW.id, NEW.title, NEW.description);
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS topics_search ON topics;
+CREATE TRIGGER topics_search AFTER INSERT OR DELETE OR UPDATE OF name, description, deleted_at ON topics
+    FOR EACH ROW EXECUTE FUNCTION search_index_topic();
+
+DROP TRIGGER IF EXISTS quizzes_search ON quizzes;
+CREATE TRIGGER quizzes_search AFTER INSERT OR DELETE OR UPDATE OF title ON quizzes
+    FOR EACH ROW EXECUTE FUNCTION search_index_quiz();
+
+DROP TRIGGER IF EXISTS extra_resources_search ON extra_resources;
+CREATE TRIGGER extra_resources_search AFTER INSERT OR DELETE OR UPDATE OF title, description, metadata ON extra_resources
+    FOR EACH ROW EXECUTE FUNCTION search_index_extra_resource();
+
+DROP TRIGGER IF EXISTS homeworks_search ON homeworks;
+CREATE TRIGGER homeworks_search AFTER INSERT OR DELETE OR UPDATE OF title, description ON homeworks
+    FOR EACH ROW EXECUTE FUNCTION search_index_homework();
+
+SELECT search_index('topic', id, name, description) FROM topics WHERE deleted_at = 0;
+SELECT search_index('quiz', id, title, '') FROM quizzes;
+SELECT search_index('extra_resource', id, title,
+    concat_ws(' ', description, metadata->>'author', metadata->>'publisher', metadata->>'provider')) FROM extra_resources;
+SELECT search_index('homework', id, title, description) FROM homeworks;
@@ -0,0 +1,78 @@
+CREATE OR REPLACE FUNCTION search_index_topic() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = OLD.id;
+    ELSIF NEW.deleted_at <> 0 THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = NEW.id;
+    ELSE
+        PERFORM search_index('topic', NEW.id, NEW.name, NEW.description);
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_quiz() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'quiz' AND id = OLD.id;
+    ELSE
+        PERFORM search_index('quiz', NEW.id, NEW.title, '');
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_extra_resource() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'extra_resource' AND id = OLD.id;
+    ELSE
+        PERFORM search_index('extra_resource', NEW.id, NEW.title,
+            concat_ws(' ', NEW.description, NEW.metadata->>'author', NEW.metadata->>'publisher', NEW.metadata->>'provider'));
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS topics_search ON topics;
+CREATE TRIGGER topics_search AFTER INSERT OR DELETE OR UPDATE OF name, description, deleted_at ON topics
+    FOR EACH ROW EXECUTE FUNCTION search_index_topic();
+
+DROP TRIGGER IF EXISTS quizzes_search ON quizzes;
+CREATE TRIGGER quizzes_search AFTER INSERT OR DELETE OR UPDATE OF title ON quizzes
+    FOR EACH ROW EXECUTE FUNCTION search_index_quiz();
+
+DROP TRIGGER IF EXISTS extra_resources_search ON extra_resources;
+CREATE TRIGGER extra_resources_search AFTER INSERT OR DELETE OR UPDATE OF title, description, metadata ON extra_resources
+    FOR EACH ROW EXECUTE FUNCTION search_index_extra_resource();
+
+-- Everything becomes live again, so everything is searchable again.
+SELECT search_index('topic', id, name, description) FROM topics WHERE deleted_at = 0 AND status <> 'published';
+SELECT search_index('quiz', id, title, '') FROM quizzes WHERE status <> 'published';
+SELECT search_index('extra_resource', id, title,
+    concat_ws(' ', description, metadata->>'author', metadata->>'publisher', metadata->>'provider'))
+FROM extra_resources WHERE status <> 'published';
+
+DROP TABLE IF EXISTS content_revisions;
+
+DROP INDEX IF EXISTS extra_resources_published_idx;
+DROP INDEX IF EXISTS quizzes_published_idx;
+DROP INDEX IF EXISTS topics_published_idx;
+
+ALTER TABLE extra_resources
+    DROP COLUMN IF EXISTS published_version,
+    DROP COLUMN IF EXISTS author_id,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE quizzes
+    DROP COLUMN IF EXISTS published_version,
+    DROP COLUMN IF EXISTS author_id,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE topics
+    DROP COLUMN IF EXISTS published_version,
+    DROP COLUMN IF EXISTS author_id,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,137 @@
+-- Content goes draft → in_review → published → archived. Content already
+-- there was live, so it counts as published.
+ALTER TABLE topics
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'in_review', 'published', 'archived')),
+    ADD COLUMN IF NOT EXISTS author_id UUID,
+    ADD COLUMN IF NOT EXISTS published_version INT NOT NULL DEFAULT 0;
+ALTER TABLE topics ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE quizzes
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'in_review', 'published', 'archived')),
+    ADD COLUMN IF NOT EXISTS author_id UUID,
+    ADD COLUMN IF NOT EXISTS published_version INT NOT NULL DEFAULT 0;
+ALTER TABLE quizzes ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE extra_resources
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'in_review', 'published', 'archived')),
+    ADD COLUMN IF NOT EXISTS author_id UUID,
+    ADD COLUMN IF NOT EXISTS published_version INT NOT NULL DEFAULT 0;
+ALTER TABLE extra_resources ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX IF NOT EXISTS topics_published_idx ON topics (created_at, id) WHERE status = 'published' AND deleted_at = 0;
+CREATE INDEX IF NOT EXISTS quizzes_published_idx ON quizzes (topic_id) WHERE status = 'published';
+CREATE INDEX IF NOT EXISTS extra_resources_published_idx ON extra_resources (created_at, id) WHERE status = 'published';
+
+-- Every change to a piece of content is a revision holding a snapshot of
+-- all of it. An item has at most one open revision, a draft or one in
+-- review; published revisions are numbered and never change again.
+CREATE TABLE IF NOT EXISTS content_revisions (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('topic', 'quiz', 'extra_resource')),
+    item_id UUID NOT NULL,
+    version INT,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('draft', 'in_review', 'published')),
+    content JSONB NOT NULL,
+    -- Null for content imported or published before the workflow.
+    author_id UUID,
+    reviewer_id UUID,
+    review_comment TEXT NOT NULL DEFAULT '',
+    -- The version a rollback republished.
+    rollback_of INT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    submitted_at TIMESTAMP,
+    published_at TIMESTAMP,
+    CHECK ((status = 'published') = (version IS NOT NULL)),
+    UNIQUE (kind, item_id, version)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS content_revisions_open_idx ON content_revisions (kind, item_id) WHERE status IN ('draft', 'in_review');
+CREATE INDEX IF NOT EXISTS content_revisions_review_idx ON content_revisions (submitted_at, id) WHERE status = 'in_review';
+CREATE INDEX IF NOT EXISTS content_revisions_author_idx ON content_revisions (author_id, updated_at);
+
+-- What is live now becomes version 1. Quiz snapshots leave questions out:
+-- a revision without questions keeps the ones the quiz has.
+INSERT INTO content_revisions (id, kind, item_id, version, status, content, published_at)
+SELECT gen_random_uuid(), 'topic', id, 1, 'published',
+    jsonb_build_object('name', name, 'description', description, 'difficulty', difficulty), now()
+FROM topics WHERE deleted_at = 0
+ON CONFLICT DO NOTHING;
+
+INSERT INTO content_revisions (id, kind, item_id, version, status, content, published_at)
+SELECT gen_random_uuid(), 'quiz', id, 1, 'published',
+    jsonb_build_object('topic_id', topic_id, 'title', COALESCE(title, ''), 'time_limit_seconds', time_limit_seconds,
+        'max_attempts', max_attempts, 'partial_credit', partial_credit, 'shuffle', shuffle, 'xp', xp), now()
+FROM quizzes
+ON CONFLICT DO NOTHING;
+
+INSERT INTO content_revisions (id, kind, item_id, version, status, content, published_at)
+SELECT gen_random_uuid(), 'extra_resource', r.id, 1, 'published',
+    jsonb_build_object('title', r.title, 'type', r.type, 'url', r.url, 'file_id', COALESCE(r.file_id::text, ''),
+        'description', r.description, 'metadata', r.metadata,
+        'topic_ids', (SELECT COALESCE(jsonb_agg(t.topic_id ORDER BY t.topic_id), '[]') FROM resource_topics t WHERE t.extra_resource_id = r.id)), now()
+FROM extra_resources r
+ON CONFLICT DO NOTHING;
+
+UPDATE topics SET published_version = 1 WHERE deleted_at = 0;
+UPDATE quizzes SET published_version = 1;
+UPDATE extra_resources SET published_version = 1;
+
+-- Learners only find what is published.
+CREATE OR REPLACE FUNCTION search_index_topic() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = OLD.id;
+    ELSIF NEW.deleted_at <> 0 OR NEW.status <> 'published' THEN
+        DELETE FROM search_documents WHERE kind = 'topic' AND id = NEW.id;
+    ELSE
+        PERFORM search_index('topic', NEW.id, NEW.name, NEW.description);
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_quiz() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'quiz' AND id = OLD.id;
+    ELSIF NEW.status <> 'published' THEN
+        DELETE FROM search_documents WHERE kind = 'quiz' AND id = NEW.id;
+    ELSE
+        PERFORM search_index('quiz', NEW.id, NEW.title, '');
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION search_index_extra_resource() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents WHERE kind = 'extra_resource' AND id = OLD.id;
+    ELSIF NEW.status <> 'published' THEN
+        DELETE FROM search_documents WHERE kind = 'extra_resource' AND id = NEW.id;
+    ELSE
+        PERFORM search_index('extra_resource', NEW.id, NEW.title,
+            concat_ws(' ', NEW.description, NEW.metadata->>'author', NEW.metadata->>'publisher', NEW.metadata->>'provider'));
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS topics_search ON topics;
+CREATE TRIGGER topics_search AFTER INSERT OR DELETE OR UPDATE OF name, description, deleted_at, status ON topics
+    FOR EACH ROW EXECUTE FUNCTION search_index_topic();
+
+DROP TRIGGER IF EXISTS quizzes_search ON quizzes;
+CREATE TRIGGER quizzes_search AFTER INSERT OR DELETE OR UPDATE OF title, status ON quizzes
+    FOR EACH ROW EXECUTE FUNCTION search_index_quiz();
+
+DROP TRIGGER IF EXISTS extra_resources_search ON extra_resources;
+CREATE TRIGGER extra_resources_search AFTER INSERT OR DELETE OR UPDATE OF title, description, metadata, status ON extra_resources
+    FOR EACH ROW EXECUTE FUNCTION search_index_extra_resource();
@@ -0,0 +1,8 @@
+-- Commands from other services already applied, by the id of the message
+-- that carried them. Delivery is at least once, and a redelivered command
+-- must not create its content twice.
+CREATE TABLE IF NOT EXISTS processed_commands (
+    id UUID PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT now()
+);
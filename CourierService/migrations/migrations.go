@@ -0,0 +1,86 @@
+// Package migrations embeds the learning schema and applies it with
+// golang-migrate. Versions are never renumbered once shipped: the base
+// schema is version 0 for that reason, and version 9 is unused.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var ErrOutdated = errors.New("database schema is out of date")
+
+// New returns a migrator for db. Closing it closes db too.
+func New(db *sql.DB) (*migrate.Migrate, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", src, "postgres", driver)
+}
+
+// Latest is the version of the newest embedded migration.
+func Latest() (uint, error) {
+	names, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint
+	for _, name := range names {
+		v, err := strconv.ParseUint(strings.SplitN(name, "_", 2)[0], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s: %w", name, err)
+		}
+		if uint(v) > latest {
+			latest = uint(v)
+		}
+	}
+	return latest, nil
+}
+
+// Check fails unless db has every embedded migration applied cleanly. It
+// reads golang-migrate's bookkeeping table directly, so db stays open.
+func Check(ctx context.Context, db *sql.DB) error {
+	latest, err := Latest()
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+	var table sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations')::text`).Scan(&table); err != nil {
+		return err
+	}
+	if table.Valid {
+		err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: migration %d failed halfway and must be fixed by hand", ErrOutdated, version)
+	}
+	if version < latest {
+		return fmt.Errorf("%w: at version %d, want %d; run `migrate up`", ErrOutdated, version, latest)
+	}
+	return nil
+}
@@ -0,0 +1,781 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	pb "learning-service/genproto/learning"
+)
+
+// ImportContentCommand is the payload of the bulk content import topic.
+type ImportContentCommand struct {
+	Topics         []*pb.CreateLearningTopicRequest     `json:"topics"`
+	Quizzes        []*pb.CreateQuizRequest              `json:"quizzes"`
+	ExtraResources []*pb.CreateExtraResoursesRequest    `json:"extra_resources"`
+	Homeworks      []*pb.CreateLearningHomeworksRequest `json:"homeworks"`
+}
+
+// AwardXpCommand asks the learning service to credit xp to a user. Commands
+// with the same Source and SourceId are only credited once; Source defaults
+// to XpSourceCommand and SourceId to the id of the Kafka message.
+type AwardXpCommand struct {
+	UserId   string `json:"user_id"`
+	Xp       int32  `json:"xp"`
+	Reason   string `json:"reason"`
+	Source   string `json:"source"`
+	SourceId string `json:"source_id"`
+}
+
+// Sources of XP ledger entries.
+const (
+	XpSourceTopic         = "topic"
+	XpSourceQuiz          = "quiz"
+	XpSourceExtraResource = "extra_resource"
+	XpSourceFeedback      = "feedback"
+	XpSourceHomework      = "homework"
+	XpSourceGameLevel     = "game_level"
+	XpSourceCommand       = "command"
+	XpSourceOpening       = "opening"
+	XpSourceStreakFreeze  = "streak_freeze"
+	XpSourceAchievement   = "achievement"
+	XpSourceReview        = "review"
+)
+
+// XpEntry is one award in the XP ledger. A user's balance is the sum of
+// their entries, and each source pays out at most once per user.
+type XpEntry struct {
+	UserId     string
+	SourceType string
+	SourceId   string
+	Amount     int32
+	Reason     string
+}
+
+// LearningProfile is what the learning service keeps per auth user. It is
+// created on registration, or on the first award if that comes first.
+type LearningProfile struct {
+	UserId        string
+	Xp            int32
+	Level         int32
+	CurrentStreak int32
+	LongestStreak int32
+	StreakFreezes int32
+	// LastActiveOn is a local day of the user's, see Day.
+	LastActiveOn time.Time
+	DailyGoal    int32
+	Timezone     string
+}
+
+// A streak freeze covers one missed day. Users buy them with XP.
+const (
+	StreakFreezeCost = 50
+	MaxStreakFreezes = 2
+)
+
+// Day is the day t falls on in the user's time zone, as midnight UTC so that
+// days compare and subtract exactly.
+func (p *LearningProfile) Day(t time.Time) time.Time {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// missed is the number of days without activity between the last active day
+// and day.
+func (p *LearningProfile) missed(day time.Time) int32 {
+	return int32(day.Sub(p.LastActiveOn).Hours()/24) - 1
+}
+
+// Touch records activity on day. Missed days are bridged with streak freezes
+// while there are enough of them; otherwise the streak starts over.
+func (p *LearningProfile) Touch(day time.Time) {
+	if !p.LastActiveOn.IsZero() && !day.After(p.LastActiveOn) {
+		return
+	}
+	switch missed := p.missed(day); {
+	case p.LastActiveOn.IsZero() || p.CurrentStreak == 0:
+		p.CurrentStreak = 1
+	case missed <= p.StreakFreezes:
+		p.StreakFreezes -= missed
+		p.CurrentStreak++
+	default:
+		p.CurrentStreak = 1
+	}
+	p.LastActiveOn = day
+	if p.CurrentStreak > p.LongestStreak {
+		p.LongestStreak = p.CurrentStreak
+	}
+}
+
+// StreakOn is the streak as it stands on day: the one stored, unless more
+// days have been missed since than the user's freezes can cover.
+func (p *LearningProfile) StreakOn(day time.Time) int32 {
+	if p.LastActiveOn.IsZero() || p.missed(day) > p.StreakFreezes {
+		return 0
+	}
+	return p.CurrentStreak
+}
+
+// AtRisk reports whether the streak is lost unless the user is active before
+// day ends.
+func (p *LearningProfile) AtRisk(day time.Time) bool {
+	return p.StreakOn(day) > 0 && p.missed(day) == p.StreakFreezes
+}
+
+// QuizPassRatio is the share of a quiz's points a finished attempt needs to
+// pass it.
+const QuizPassRatio = 0.6
+
+// Kinds of content a course module is made of.
+const (
+	CourseItemTopic         = "topic"
+	CourseItemQuiz          = "quiz"
+	CourseItemExtraResource = "extra_resource"
+	CourseItemHomework      = "homework"
+)
+
+// Kinds of recommendation the engine makes.
+const (
+	RecommendationTopic     = "topic"
+	RecommendationReview    = "review"
+	RecommendationResource  = "extra_resource"
+	RecommendationGameLevel = "game_level"
+)
+
+type Recommendation struct {
+	Id     string
+	UserId string
+	Type   string
+	// ItemId is the topic, resource or game level recommended.
+	ItemId string
+	Name   string
+	Reason string
+	Score  float64
+}
+
+// LearnerSignals is what the recommendation engine knows about a user.
+type LearnerSignals struct {
+	UserId    string
+	Level     int32
+	Topics    []*TopicSignal
+	Resources []*ResourceSignal
+	Levels    []*GameLevelSignal
+}
+
+type TopicSignal struct {
+	Id         string
+	Name       string
+	Difficulty string
+	Completed  bool
+	// Prerequisites counts the topic's prerequisites, Missing those the
+	// user has not completed.
+	Prerequisites int32
+	Missing       int32
+	// BestScore is the user's best finished quiz score on the topic as a
+	// share of the maximum; Attempted tells whether there is one.
+	Attempted bool
+	BestScore float64
+	// AvgRating is the mean feedback rating of Ratings ratings; UserRating
+	// is the user's own, 0 if none.
+	AvgRating  float64
+	Ratings    int32
+	UserRating int32
+}
+
+// ResourceSignal is an extra resource the user has not completed, attached
+// to questions on a topic that they answered wrong.
+type ResourceSignal struct {
+	Id      string
+	Title   string
+	TopicId string
+	Missed  int32
+}
+
+// GameLevelSignal is a game level the user has not completed, with how
+// learners of about the user's level did on it.
+type GameLevelSignal struct {
+	LevelId       string
+	Players       int32
+	MedianSeconds int32
+}
+
+// Metrics an achievement can be earned on.
+const (
+	MetricTopicsCompleted    = "topics_completed"
+	MetricResourcesCompleted = "resources_completed"
+	MetricQuizzesFinished    = "quizzes_finished"
+	MetricQuizzesPerfect     = "quizzes_perfect"
+	MetricHomeworksSubmitted = "homeworks_submitted"
+	MetricStreakDays         = "streak_days"
+	MetricXp                 = "xp"
+	MetricGameLevels         = "game_levels"
+)
+
+// ActivityMetrics lists the metrics each kind of learning activity can move.
+var ActivityMetrics = map[string][]string{
+	XpSourceTopic:         {MetricTopicsCompleted, MetricStreakDays},
+	XpSourceExtraResource: {MetricResourcesCompleted, MetricStreakDays},
+	XpSourceQuiz:          {MetricQuizzesFinished, MetricQuizzesPerfect, MetricStreakDays},
+	XpSourceHomework:      {MetricHomeworksSubmitted, MetricStreakDays},
+	XpSourceGameLevel:     {MetricGameLevels},
+	XpSourceReview:        {MetricStreakDays},
+}
+
+// Achievement is a rule that awards a badge, and Xp, once the user's Metric
+// reaches Threshold. LevelId and MaxSeconds narrow MetricGameLevels to one
+// level and to completions faster than that.
+type Achievement struct {
+	Id          string
+	Code        string
+	Name        string
+	Description string
+	Metric      string
+	Threshold   int32
+	LevelId     string
+	MaxSeconds  int32
+	Xp          int32
+}
+
+// DailyActivity is what a user did on one of their local days.
+type DailyActivity struct {
+	Day        time.Time
+	Xp         int32
+	Activities int32
+	Reviews    int32
+}
+
+// XpPerLevel scales the level curve: level n starts at XpPerLevel*(n-1)^2 XP.
+const XpPerLevel = 100
+
+// Level is the level reached with xp.
+func Level(xp int32) int32 {
+	if xp <= 0 {
+		return 1
+	}
+	return int32(math.Sqrt(float64(xp)/XpPerLevel)) + 1
+}
+
+// Domain events published on Kafka. Each topic carries one event type.
+const (
+	EventHomeworkAssigned      = "homework.assigned"
+	EventHomeworkGraded        = "homework.graded"
+	EventRecommendationCreated = "recommendation.created"
+	EventLevelUnlocked         = "level.unlocked"
+	EventUserBanned            = "user.banned"
+	EventUserRegistered        = "user.registered"
+	EventUserDeleted           = "user.deleted"
+	EventStreakAtRisk          = "streak.at_risk"
+	EventLevelCompleted        = "level.completed"
+	EventActivityRecorded      = "learning.activity"
+	EventAchievementUnlocked   = "achievement.unlocked"
+	EventFeedbackModerated     = "feedback.moderated"
+	EventContentReviewed       = "content.reviewed"
+
+	EventXpChanged           = "xp.changed"
+	EventNotificationCreated = "notification.created"
+)
+
+type XpChangedEvent struct {
+	UserId string `json:"user_id"`
+	Delta  int32  `json:"delta"`
+	Source string `json:"source"`
+}
+
+type NotificationCreatedEvent struct {
+	UserId         string `json:"user_id"`
+	NotificationId string `json:"notification_id"`
+	Type           string `json:"type"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+}
+
+type HomeworkAssignedEvent struct {
+	UserId     string `json:"user_id"`
+	HomeworkId string `json:"homework_id"`
+	Title      string `json:"title"`
+	DueAt      string `json:"due_at,omitempty"`
+}
+
+// HomeworkGradedEvent is published when a reviewer grades a submission or
+// returns it for another try; Status tells which.
+type HomeworkGradedEvent struct {
+	UserId       string  `json:"user_id"`
+	HomeworkId   string  `json:"homework_id"`
+	SubmissionId string  `json:"submission_id"`
+	Title        string  `json:"title"`
+	Status       string  `json:"status"`
+	Score        float64 `json:"score"`
+	MaxScore     float64 `json:"max_score"`
+	Xp           int32   `json:"xp"`
+}
+
+// FeedbackModeratedEvent is published when a moderator accepts or rejects a
+// review held for moderation.
+type FeedbackModeratedEvent struct {
+	UserId     string `json:"user_id"`
+	FeedbackId string `json:"feedback_id"`
+	TopicId    string `json:"topic_id"`
+	TopicName  string `json:"topic_name"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	Xp         int32  `json:"xp"`
+}
+
+// ContentReviewedEvent is published to the author when a reviewer
+// publishes their revision or sends it back; Status tells which.
+type ContentReviewedEvent struct {
+	UserId  string `json:"user_id"`
+	Kind    string `json:"kind"`
+	ItemId  string `json:"item_id"`
+	Title   string `json:"title"`
+	Status  string `json:"status"`
+	Version int32  `json:"version,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type RecommendationCreatedEvent struct {
+	UserId           string `json:"user_id"`
+	RecommendationId string `json:"recommendation_id"`
+	Name             string `json:"name"`
+	Reason           string `json:"reason"`
+}
+
+type LevelUnlockedEvent struct {
+	UserId  string `json:"user_id"`
+	LevelId string `json:"level_id"`
+}
+
+type UserBannedEvent struct {
+	UserId string `json:"user_id"`
+}
+
+type UserRegisteredEvent struct {
+	UserId string `json:"user_id"`
+}
+
+type UserDeletedEvent struct {
+	UserId string `json:"user_id"`
+}
+
+// ActivityRecordedEvent is published after a completion, quiz submission or
+// homework. Kind is the XpSource of the activity.
+type ActivityRecordedEvent struct {
+	UserId   string `json:"user_id"`
+	Kind     string `json:"kind"`
+	SourceId string `json:"source_id"`
+}
+
+type LevelCompletedEvent struct {
+	UserId           string `json:"user_id"`
+	LevelId          string `json:"level_id"`
+	TimeSpentSeconds int32  `json:"time_spent_seconds"`
+}
+
+type AchievementUnlockedEvent struct {
+	UserId        string `json:"user_id"`
+	AchievementId string `json:"achievement_id"`
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Xp            int32  `json:"xp"`
+}
+
+type StreakAtRiskEvent struct {
+	UserId        string `json:"user_id"`
+	CurrentStreak int32  `json:"current_streak"`
+	StreakFreezes int32  `json:"streak_freezes"`
+	// Day is the user's local day, YYYY-MM-DD.
+	Day string `json:"day"`
+}
+
+type Notification struct {
+	Id     string
+	UserId string
+	Type   string
+	Title  string
+	Body   string
+	InApp  bool
+}
+
+// Delivery is a pending send of a notification over one external channel.
+type Delivery struct {
+	Id           string
+	Channel      string
+	SendAfter    time.Time
+	Attempts     int
+	Notification Notification
+}
+
+// NotificationSettings is everything needed to route one event to a user.
+type NotificationSettings struct {
+	Channels   map[string]bool
+	QuietStart *int
+	QuietEnd   *int
+	Timezone   string
+}
+
+type PushSubscription struct {
+	Endpoint string
+	UserId   string
+	P256dh   string
+	Auth     string
+}
+
+// Quiz question types.
+const (
+	QuestionSingleChoice = "single_choice"
+	QuestionMultiChoice  = "multi_choice"
+	QuestionTrueFalse    = "true_false"
+	QuestionNumeric      = "numeric"
+	QuestionShortText    = "short_text"
+)
+
+// Quiz attempt states.
+const (
+	AttemptInProgress = "in_progress"
+	AttemptFinished   = "finished"
+	AttemptExpired    = "expired"
+)
+
+// Quiz holds the attempt policy of a quiz. Zero TimeLimit and MaxAttempts
+// mean no limit. A quiz with Rules is a template whose questions are drawn
+// from the question bank for every attempt.
+type Quiz struct {
+	Id            string
+	TopicId       string
+	Title         string
+	TimeLimit     time.Duration
+	MaxAttempts   int32
+	PartialCredit bool
+	Shuffle       bool
+	Xp            int32
+	Rules         []*QuizTemplateRule
+}
+
+// QuizTemplateRule draws Count bank questions matching every criterion set,
+// e.g. 5 easy questions on a topic.
+type QuizTemplateRule struct {
+	TopicId    string
+	Difficulty string
+	Tag        string
+	Count      int32
+}
+
+// QuizQuestion is a question as shown to learners. Its answer is an
+// AnswerKey, stored and loaded separately.
+type QuizQuestion struct {
+	Id         string
+	QuizId     string
+	Position   int32
+	Type       string
+	Prompt     string
+	Options    []string
+	Points     float64
+	Difficulty string
+}
+
+// AnswerKey holds the correct option(s), the accepted texts, or the number,
+// depending on the question type, plus what learners see on review.
+type AnswerKey struct {
+	QuestionId  string
+	Answer      []string
+	Tolerance   float64
+	Explanation string
+}
+
+// AttemptGrace absorbs the latency between a client's timer running out and
+// its submission arriving.
+const AttemptGrace = 10 * time.Second
+
+type QuizAttempt struct {
+	Id         string
+	QuizId     string
+	UserId     string
+	Status     string
+	StartedAt  time.Time
+	ExpiresAt  *time.Time
+	FinishedAt *time.Time
+	Score      float64
+	MaxScore   float64
+	Seed       int64
+}
+
+// Expired reports whether the attempt's time limit has run out.
+func (a *QuizAttempt) Expired(now time.Time) bool {
+	return a.ExpiresAt != nil && now.After(a.ExpiresAt.Add(AttemptGrace))
+}
+
+// GradedAnswer is a user's answer to one question and the credit it earned.
+type GradedAnswer struct {
+	QuestionId string
+	Values     []string
+	Points     float64
+	Correct    bool
+}
+
+// QuizAttemptResult closes an attempt. Xp is what this attempt is worth;
+// the user is only paid the part that exceeds their previous best.
+type QuizAttemptResult struct {
+	Attempt *QuizAttempt
+	Status  string
+	Answers []*GradedAnswer
+	Xp      int32
+}
+
+// ReviewCard schedules a quiz question for spaced repetition. Question is
+// loaded with the card, without its answer.
+type ReviewCard struct {
+	UserId         string
+	QuestionId     string
+	Ease           float64
+	IntervalDays   int32
+	Repetitions    int32
+	Lapses         int32
+	DueAt          time.Time
+	LastReviewedAt time.Time
+	Question       *QuizQuestion
+}
+
+// Where a review card was answered.
+const (
+	ReviewSourceQuiz   = "quiz"
+	ReviewSourceReview = "review"
+)
+
+// QuizResult is a user's best result on a quiz and the XP paid for it.
+type QuizResult struct {
+	BestScore float64
+	MaxScore  float64
+	XpAwarded int32
+}
+
+// Homework is what learners are assigned and reviewers grade against. A zero
+// DueAt means no due date and a zero MaxSubmissions no cap on resubmissions.
+type Homework struct {
+	Id                 string
+	UserId             string
+	Title              string
+	Description        string
+	Difficulty         string
+	DueAt              time.Time
+	MaxXp              int32
+	LatePolicy         string
+	LatePenaltyPercent int32
+	MaxSubmissions     int32
+	Rubric             []*RubricCriterion
+}
+
+// What happens to submissions after the due date: accepted as is, accepted
+// with LatePenaltyPercent off the XP per day late, or refused.
+const (
+	LatePolicyAccept  = "accept"
+	LatePolicyPenalty = "penalty"
+	LatePolicyReject  = "reject"
+)
+
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	MaxPoints   float64 `json:"max_points"`
+}
+
+type RubricScore struct {
+	Criterion string  `json:"criterion"`
+	Points    float64 `json:"points"`
+	Comment   string  `json:"comment"`
+}
+
+// HomeworkAssignment is a homework as assigned to one user. DueAt is the
+// homework's unless the assignment overrides it.
+type HomeworkAssignment struct {
+	HomeworkId string
+	UserId     string
+	GroupId    string
+	DueAt      time.Time
+	XpAwarded  int32
+	AssignedAt time.Time
+}
+
+// HomeworkAttachment is a link to a file elsewhere or, with FileId, a file
+// uploaded to blob storage.
+type HomeworkAttachment struct {
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	FileId      string `json:"file_id,omitempty"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// HomeworkSubmission is one attempt at a homework. Score and the fields after
+// it are set once a reviewer has looked at it.
+type HomeworkSubmission struct {
+	Id          string
+	HomeworkId  string
+	UserId      string
+	Attempt     int32
+	Text        string
+	Links       []string
+	Attachments []*HomeworkAttachment
+	DaysLate    int32
+	Status      string
+	SubmittedAt time.Time
+	Score       float64
+	MaxScore    float64
+	Scores      []*RubricScore
+	Comment     string
+	GradedBy    string
+	GradedAt    time.Time
+	XpEarned    int32
+}
+
+// Statuses of a homework submission. Only the latest submission is open for
+// review; a resubmission supersedes an earlier one still waiting.
+const (
+	SubmissionSubmitted  = "submitted"
+	SubmissionSuperseded = "superseded"
+	SubmissionReturned   = "returned"
+	SubmissionGraded     = "graded"
+)
+
+// File is an upload kept in blob storage. Its content is checked against
+// Size and Sha256 before it becomes ready.
+type File struct {
+	Id          string
+	OwnerId     string
+	Purpose     string
+	Filename    string
+	ContentType string
+	Size        int64
+	Sha256      string
+	Status      string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
+
+// What a file is uploaded for, which decides what it may contain and who
+// may download it.
+const (
+	FilePurposeResource = "resource"
+	FilePurposeAvatar   = "avatar"
+	FilePurposeHomework = "homework"
+)
+
+const (
+	FilePending = "pending"
+	FileReady   = "ready"
+)
+
+// Types of extra resource.
+const (
+	ResourceArticle = "article"
+	ResourceVideo   = "video"
+	ResourceBook    = "book"
+	ResourceCourse  = "course"
+	ResourcePodcast = "podcast"
+)
+
+// ExtraResource is what the resource rules look at: a resource of Type
+// found at Url or uploaded as FileId, and described by Metadata.
+type ExtraResource struct {
+	Type     string
+	Url      string
+	FileId   string
+	Metadata ResourceMetadata
+}
+
+// ResourceMetadata describes an extra resource. Which fields apply depends
+// on its type.
+type ResourceMetadata struct {
+	Author          string `json:"author,omitempty"`
+	DurationMinutes int32  `json:"duration_minutes,omitempty"`
+	Isbn            string `json:"isbn,omitempty"`
+	Publisher       string `json:"publisher,omitempty"`
+	Pages           int32  `json:"pages,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	Episode         int32  `json:"episode,omitempty"`
+	PublishedOn     string `json:"published_on,omitempty"`
+	// LegacyType is the free-form type of a resource from before types
+	// were fixed.
+	LegacyType string `json:"legacy_type,omitempty"`
+}
+
+// Whether a resource's url answered when last checked.
+const (
+	UrlUnchecked   = "unchecked"
+	UrlReachable   = "reachable"
+	UrlUnreachable = "unreachable"
+)
+
+// ResourceLink is a resource url due for a reachability check.
+type ResourceLink struct {
+	Id  string
+	Url string
+}
+
+// A review is accepted unless moderation flags it, in which case it waits
+// pending for a moderator.
+const (
+	FeedbackPending  = "pending"
+	FeedbackAccepted = "accepted"
+	FeedbackRejected = "rejected"
+)
+
+// Feedback is a review as moderation needs it.
+type Feedback struct {
+	Id        string
+	UserId    string
+	TopicId   string
+	TopicName string
+	Status    string
+}
+
+// Roles of auth-service users that may review the work of others. Requests
+// carry the caller's role as the gateway read it from their token.
+const (
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+)
+
+// Topics, quizzes and extra resources are written as drafts, reviewed and
+// published; learners only ever see what is published.
+const (
+	ContentDraft     = "draft"
+	ContentInReview  = "in_review"
+	ContentPublished = "published"
+	ContentArchived  = "archived"
+)
+
+// ContentItem is where a topic, quiz or extra resource is in the workflow.
+type ContentItem struct {
+	Kind     string
+	Id       string
+	Status   string
+	AuthorId string
+	// PublishedVersion is the version learners see; 0 if there is none.
+	PublishedVersion int32
+}
+
+// ContentRevision is one change to a piece of content. Content is a
+// snapshot of all of it, as the request that updates the item.
+type ContentRevision struct {
+	Id         string
+	Kind       string
+	ItemId     string
+	Version    int32
+	Status     string
+	Content    []byte
+	AuthorId   string
+	ReviewerId string
+	Comment    string
+	RollbackOf int32
+}
+
+// ContentChange is a field that differs between two revisions, each side
+// empty where the field is missing.
+type ContentChange struct {
+	Field string
+	From  string
+	To    string
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestTouch(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     LearningProfile
+		on          time.Time
+		wantStreak  int32
+		wantLongest int32
+		wantFreezes int32
+		wantLast    time.Time
+	}{
+		{"first activity", LearningProfile{}, day(1), 1, 1, 0, day(1)},
+		{"next day", LearningProfile{CurrentStreak: 3, LongestStreak: 3, LastActiveOn: day(1)}, day(2), 4, 4, 0, day(2)},
+		{"same day", LearningProfile{CurrentStreak: 3, LongestStreak: 5, LastActiveOn: day(2)}, day(2), 3, 5, 0, day(2)},
+		{"one day missed, one freeze", LearningProfile{CurrentStreak: 3, LongestStreak: 3, StreakFreezes: 1, LastActiveOn: day(1)}, day(3), 4, 4, 0, day(3)},
+		{"two days missed, two freezes", LearningProfile{CurrentStreak: 3, LongestStreak: 3, StreakFreezes: 2, LastActiveOn: day(1)}, day(4), 4, 4, 0, day(4)},
+		{"one day missed, two freezes", LearningProfile{CurrentStreak: 3, LongestStreak: 3, StreakFreezes: 2, LastActiveOn: day(1)}, day(3), 4, 4, 1, day(3)},
+		{"one day missed, no freeze", LearningProfile{CurrentStreak: 3, LongestStreak: 3, LastActiveOn: day(1)}, day(3), 1, 3, 0, day(3)},
+		{"two days missed, one freeze", LearningProfile{CurrentStreak: 3, LongestStreak: 3, StreakFreezes: 1, LastActiveOn: day(1)}, day(4), 1, 3, 1, day(4)},
+		{"after a lost streak", LearningProfile{CurrentStreak: 0, LongestStreak: 6, LastActiveOn: day(1)}, day(2), 1, 6, 0, day(2)},
+		{"day before the last active one", LearningProfile{CurrentStreak: 3, LongestStreak: 3, LastActiveOn: day(5)}, day(4), 3, 3, 0, day(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.profile
+			p.Touch(tt.on)
+			if p.CurrentStreak != tt.wantStreak || p.LongestStreak != tt.wantLongest || p.StreakFreezes != tt.wantFreezes {
+				t.Errorf("streak %d, longest %d, freezes %d, want %d, %d, %d",
+					p.CurrentStreak, p.LongestStreak, p.StreakFreezes, tt.wantStreak, tt.wantLongest, tt.wantFreezes)
+			}
+			if !p.LastActiveOn.Equal(tt.wantLast) {
+				t.Errorf("last active on %s, want %s", p.LastActiveOn, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestStreakOnAndAtRisk(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    LearningProfile
+		on         time.Time
+		wantStreak int32
+		wantRisk   bool
+	}{
+		{"active today", LearningProfile{CurrentStreak: 4, LastActiveOn: day(2)}, day(2), 4, false},
+		{"active yesterday", LearningProfile{CurrentStreak: 4, LastActiveOn: day(1)}, day(2), 4, true},
+		{"day missed, no freeze", LearningProfile{CurrentStreak: 4, LastActiveOn: day(1)}, day(3), 0, false},
+		{"day missed, one freeze", LearningProfile{CurrentStreak: 4, StreakFreezes: 1, LastActiveOn: day(1)}, day(3), 4, true},
+		{"day missed, two freezes", LearningProfile{CurrentStreak: 4, StreakFreezes: 2, LastActiveOn: day(1)}, day(3), 4, false},
+		{"active yesterday with a freeze", LearningProfile{CurrentStreak: 4, StreakFreezes: 1, LastActiveOn: day(1)}, day(2), 4, false},
+		{"before the last active day", LearningProfile{CurrentStreak: 4, LastActiveOn: day(3)}, day(2), 4, false},
+		{"never active", LearningProfile{}, day(2), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.StreakOn(tt.on); got != tt.wantStreak {
+				t.Errorf("StreakOn() = %d, want %d", got, tt.wantStreak)
+			}
+			if got := tt.profile.AtRisk(tt.on); got != tt.wantRisk {
+				t.Errorf("AtRisk() = %t, want %t", got, tt.wantRisk)
+			}
+		})
+	}
+}
+
+func TestDay(t *testing.T) {
+	at := time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		timezone string
+		want     time.Time
+	}{
+		{"", day(1)},
+		{"UTC", day(1)},
+		{"Asia/Tashkent", day(2)},
+		{"America/New_York", day(1)},
+		{"Not/AZone", day(1)},
+	}
+	for _, tt := range tests {
+		p := LearningProfile{Timezone: tt.timezone}
+		if got := p.Day(at); !got.Equal(tt.want) {
+			t.Errorf("Day() in %q = %s, want %s", tt.timezone, got, tt.want)
+		}
+	}
+}
+
+func TestTouchAfterTimezoneChange(t *testing.T) {
+	// Active in Tashkent just after midnight, then moved to New York where
+	// it is still the evening before.
+	p := LearningProfile{Timezone: "Asia/Tashkent", CurrentStreak: 2, LongestStreak: 2, LastActiveOn: day(1)}
+	p.Touch(p.Day(time.Date(2024, 3, 1, 19, 30, 0, 0, time.UTC)))
+	if p.CurrentStreak != 3 || !p.LastActiveOn.Equal(day(2)) {
+		t.Fatalf("streak %d on %s, want 3 on %s", p.CurrentStreak, p.LastActiveOn, day(2))
+	}
+
+	p.Timezone = "America/New_York"
+	back := p.Day(time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC))
+	if !back.Equal(day(1)) {
+		t.Fatalf("New York day = %s, want %s", back, day(1))
+	}
+	p.Touch(back)
+	if p.CurrentStreak != 3 || !p.LastActiveOn.Equal(day(2)) {
+		t.Errorf("going back a day: streak %d on %s, want 3 on %s", p.CurrentStreak, p.LastActiveOn, day(2))
+	}
+	if got := p.StreakOn(back); got != 3 {
+		t.Errorf("StreakOn() the day before = %d, want 3", got)
+	}
+	if p.AtRisk(back) {
+		t.Error("at risk on a day already covered")
+	}
+
+	// The streak carries on from the last active day, not the New York one.
+	p.Touch(p.Day(time.Date(2024, 3, 3, 18, 0, 0, 0, time.UTC)))
+	if p.CurrentStreak != 4 || !p.LastActiveOn.Equal(day(3)) {
+		t.Errorf("next day: streak %d on %s, want 4 on %s", p.CurrentStreak, p.LastActiveOn, day(3))
+	}
+}
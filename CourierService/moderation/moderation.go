@@ -0,0 +1,154 @@
+// Package moderation decides whether a review comment can be shown as it
+// is or waits for a moderator: it flags profanity and the usual shapes of
+// spam, and leaves the judgement to people.
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"learning-service/models"
+)
+
+const (
+	maxLinks        = 2
+	maxRepeatedRune = 5
+	maxRepeatedWord = 4
+	minShoutLength  = 20
+)
+
+// Words flagged out of the box. Deployments add their own.
+var defaultBlocked = []string{
+	"fuck", "fucking", "shit", "bitch", "bastard", "asshole", "dick", "cunt", "whore", "slut",
+	"retard", "idiot", "moron",
+	"blyat", "suka", "pizdec", "nahuy",
+}
+
+var (
+	linkPattern  = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+	emailPattern = regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s()-]{8,}\d`)
+)
+
+// Spelling tricks undone before words are compared.
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// Verdict is what a comment gets: accepted, or pending with the reason it
+// was flagged.
+type Verdict struct {
+	Status string
+	Reason string
+}
+
+type Filter struct {
+	blocked map[string]bool
+}
+
+// NewFilter flags the default words and those given.
+func NewFilter(blocked ...string) *Filter {
+	f := &Filter{blocked: map[string]bool{}}
+	for _, w := range append(defaultBlocked, blocked...) {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			f.blocked[w] = true
+		}
+	}
+	return f
+}
+
+// Check looks a comment over. An empty comment, a bare rating, is accepted.
+func (f *Filter) Check(comment string) Verdict {
+	if reason := f.flag(comment); reason != "" {
+		return Verdict{Status: models.FeedbackPending, Reason: reason}
+	}
+	return Verdict{Status: models.FeedbackAccepted}
+}
+
+func (f *Filter) flag(comment string) string {
+	if strings.TrimSpace(comment) == "" {
+		return ""
+	}
+	words := strings.FieldsFunc(strings.ToLower(comment), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '@' && r != '$'
+	})
+	for _, w := range words {
+		if f.blocked[w] || f.blocked[leet.Replace(w)] || f.blocked[squeeze(leet.Replace(w))] {
+			return "profanity"
+		}
+	}
+
+	switch {
+	case len(linkPattern.FindAllString(comment, -1)) > maxLinks:
+		return "spam: too many links"
+	case emailPattern.MatchString(comment) || phonePattern.MatchString(comment):
+		return "spam: contact details"
+	case repeatedRune(comment) > maxRepeatedRune:
+		return "spam: repeated characters"
+	case repeatedWord(words) > maxRepeatedWord:
+		return "spam: repeated words"
+	case shouting(comment):
+		return "spam: all capitals"
+	}
+	return ""
+}
+
+// squeeze collapses runs of a letter, so that "shiiit" reads "shit".
+func squeeze(w string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range w {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+// repeatedRune is the longest run of one character other than a space.
+func repeatedRune(s string) int {
+	longest, run := 0, 0
+	var last rune
+	for _, r := range s {
+		if r == last && !unicode.IsSpace(r) {
+			run++
+		} else {
+			run = 1
+		}
+		last = r
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// repeatedWord is the longest run of one word.
+func repeatedWord(words []string) int {
+	longest, run := 0, 0
+	for i, w := range words {
+		if i > 0 && w == words[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// shouting reports a comment of some length written in capitals.
+func shouting(s string) bool {
+	letters, upper := 0, 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= minShoutLength && upper*10 >= letters*9
+}
@@ -0,0 +1,63 @@
+package moderation
+
+import (
+	"testing"
+
+	"learning-service/models"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		reason  string
+	}{
+		{"bare rating", "", ""},
+		{"plain review", "Great topic, the examples on channels helped a lot.", ""},
+		{"word containing a blocked one", "The assessment was fair and the class was fun.", ""},
+		{"two links", "See https://go.dev and https://pkg.go.dev for more.", ""},
+		{"a few repeated letters", "Sooo good", ""},
+		{"short capitals", "I LOVE IT", ""},
+
+		{"blocked word", "What an idiot wrote this", "profanity"},
+		{"blocked word in capitals", "IDIOT", "profanity"},
+		{"leet digits", "this is sh1t", "profanity"},
+		{"leet symbols", "what an @ssh0le", "profanity"},
+		{"leet dollar", "total $hit", "profanity"},
+		{"stretched letters", "shiiiiit explanation", "profanity"},
+		{"stretched leet", "5hiiit", "profanity"},
+
+		{"three links", "go to http://a.com then http://b.com and www.c.com", "spam: too many links"},
+		{"email", "write to john.doe@example.com for answers", "spam: contact details"},
+		{"phone", "call me on +998 90 123-45-67", "spam: contact details"},
+		{"repeated characters", "Nooooooo way", "spam: repeated characters"},
+		{"repeated words", "buy buy buy buy buy now", "spam: repeated words"},
+		{"shouting", "THIS TOPIC IS THE BEST THING EVER WRITTEN", "spam: all capitals"},
+	}
+	f := NewFilter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Check(tt.comment)
+			want := Verdict{Status: models.FeedbackAccepted}
+			if tt.reason != "" {
+				want = Verdict{Status: models.FeedbackPending, Reason: tt.reason}
+			}
+			if got != want {
+				t.Errorf("Check(%q) = %+v, want %+v", tt.comment, got, want)
+			}
+		})
+	}
+}
+
+func TestNewFilterAddsWords(t *testing.T) {
+	f := NewFilter(" Spoiler ", "")
+	if got := f.Check("no spoiler please"); got.Reason != "profanity" {
+		t.Errorf("added word not flagged: %+v", got)
+	}
+	if got := f.Check("idiot"); got.Reason != "profanity" {
+		t.Errorf("default word no longer flagged: %+v", got)
+	}
+	if got := NewFilter().Check("no spoiler please"); got.Status != models.FeedbackAccepted {
+		t.Errorf("word added to one filter flagged by another: %+v", got)
+	}
+}
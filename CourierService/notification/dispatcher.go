@@ -0,0 +1,77 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	s "learning-service/storage"
+)
+
+// ErrGone tells the dispatcher that a delivery can never succeed, e.g. the
+// user has no push subscription left.
+var ErrGone = errors.New("notification: recipient gone")
+
+const (
+	dispatchBatch       = 50
+	dispatchMaxAttempts = 6
+)
+
+// Dispatcher sends pending deliveries. Several instances may run at once;
+// rows are leased in the database.
+type Dispatcher struct {
+	stg      s.InitRoot
+	channels map[string]Channel
+	interval time.Duration
+}
+
+func NewDispatcher(stg s.InitRoot, interval time.Duration, channels ...Channel) *Dispatcher {
+	byName := make(map[string]Channel, len(channels))
+	for _, ch := range channels {
+		byName[ch.Name()] = ch
+	}
+	return &Dispatcher{stg: stg, channels: byName, interval: interval}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	deliveries, err := d.stg.Notification().ClaimDueDeliveries(ctx, dispatchBatch)
+	if err != nil {
+		log.Println("notification: claim deliveries: ", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		ch, ok := d.channels[delivery.Channel]
+		if !ok {
+			err = fmt.Errorf("unknown channel %q", delivery.Channel)
+			d.stg.Notification().FailDelivery(ctx, delivery.Id, err, 0, true)
+			continue
+		}
+
+		err := ch.Send(ctx, delivery.Notification)
+		if err == nil {
+			d.stg.Notification().CompleteDelivery(ctx, delivery.Id)
+			continue
+		}
+
+		final := errors.Is(err, ErrGone) || delivery.Attempts >= dispatchMaxAttempts
+		retryAfter := time.Duration(1<<delivery.Attempts) * time.Minute
+		log.Printf("notification: %s delivery %s attempt %d failed: %v", delivery.Channel, delivery.Id, delivery.Attempts, err)
+		d.stg.Notification().FailDelivery(ctx, delivery.Id, err, retryAfter, final)
+	}
+}
@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"learning-service/models"
+)
+
+// EmailChannel sends mail through the auth service, which owns user emails
+// and the SMTP account.
+type EmailChannel struct {
+	authURL string
+	token   string
+	client  *http.Client
+}
+
+func NewEmailChannel(authURL, internalToken string) *EmailChannel {
+	return &EmailChannel{
+		authURL: authURL,
+		token:   internalToken,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (e *EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (e *EmailChannel) Send(ctx context.Context, n models.Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"user_id": n.UserId,
+		"subject": n.Title,
+		"body":    n.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.authURL+"/internal/send-email", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", e.token)
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: auth service answered %s", ErrGone, res.Status)
+	default:
+		return fmt.Errorf("auth service answered %s", res.Status)
+	}
+}
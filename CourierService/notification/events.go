@@ -0,0 +1,186 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"learning-service/kafka"
+	"learning-service/models"
+)
+
+// Register subscribes the notifier to every domain event it renders.
+func (n *Notifier) Register(c *kafka.Consumer) {
+	c.Handle(models.EventHomeworkAssigned, n.handle(homeworkAssigned))
+	c.Handle(models.EventHomeworkGraded, n.handle(homeworkGraded))
+	c.Handle(models.EventRecommendationCreated, n.handle(recommendationCreated))
+	c.Handle(models.EventLevelUnlocked, n.handle(levelUnlocked))
+	c.Handle(models.EventUserBanned, n.handle(userBanned))
+	c.Handle(models.EventStreakAtRisk, n.handle(streakAtRisk))
+	c.Handle(models.EventAchievementUnlocked, n.handle(achievementUnlocked))
+	c.Handle(models.EventFeedbackModerated, n.handle(feedbackModerated))
+	c.Handle(models.EventContentReviewed, n.handle(contentReviewed))
+}
+
+type render func(value []byte) (models.Notification, error)
+
+func (n *Notifier) handle(r render) kafka.Handler {
+	return func(ctx context.Context, msg kafka.Message) error {
+		notification, err := r(msg.Value)
+		if err != nil {
+			return kafka.Permanent(err)
+		}
+		if notification.UserId == "" {
+			return kafka.Permanent(errors.New("event without user_id"))
+		}
+		// Derived from the message position so a redelivery maps to the same row.
+		notification.Id = kafka.MessageId(msg)
+		notification.Type = msg.Topic
+		return n.Notify(ctx, notification)
+	}
+}
+
+func homeworkAssigned(value []byte) (models.Notification, error) {
+	e := models.HomeworkAssignedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	n := models.Notification{
+		UserId: e.UserId,
+		Title:  "New homework assigned",
+		Body:   fmt.Sprintf("You have a new homework: %s", e.Title),
+	}
+	if e.DueAt != "" {
+		n.Body += fmt.Sprintf(", due %s", e.DueAt)
+	}
+	return n, nil
+}
+
+func homeworkGraded(value []byte) (models.Notification, error) {
+	e := models.HomeworkGradedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	n := models.Notification{UserId: e.UserId, Title: "Homework graded"}
+	if e.Status == models.SubmissionReturned {
+		n.Title = "Homework returned"
+		n.Body = fmt.Sprintf("Your submission for %s was returned with comments. Please resubmit.", e.Title)
+		return n, nil
+	}
+	n.Body = fmt.Sprintf("Your submission for %s scored %g/%g", e.Title, e.Score, e.MaxScore)
+	if e.Xp > 0 {
+		n.Body += fmt.Sprintf(" and earned %d XP", e.Xp)
+	}
+	return n, nil
+}
+
+func feedbackModerated(value []byte) (models.Notification, error) {
+	e := models.FeedbackModeratedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	if e.Status == models.FeedbackRejected {
+		return models.Notification{
+			UserId: e.UserId,
+			Title:  "Review rejected",
+			Body:   fmt.Sprintf("Your review of %s was not published: %s. You can edit it and try again.", e.TopicName, e.Reason),
+		}, nil
+	}
+	n := models.Notification{
+		UserId: e.UserId,
+		Title:  "Review published",
+		Body:   fmt.Sprintf("Your review of %s is now published", e.TopicName),
+	}
+	if e.Xp > 0 {
+		n.Body += fmt.Sprintf(" and earned %d XP", e.Xp)
+	}
+	return n, nil
+}
+
+func contentReviewed(value []byte) (models.Notification, error) {
+	e := models.ContentReviewedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	if e.Status == models.ContentPublished {
+		return models.Notification{
+			UserId: e.UserId,
+			Title:  "Changes published",
+			Body:   fmt.Sprintf("Your changes to %s are published as version %d.", e.Title, e.Version),
+		}, nil
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "Changes sent back",
+		Body:   fmt.Sprintf("Your changes to %s need more work: %s", e.Title, e.Comment),
+	}, nil
+}
+
+func recommendationCreated(value []byte) (models.Notification, error) {
+	e := models.RecommendationCreatedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	body := fmt.Sprintf("We recommend %s for you.", e.Name)
+	if e.Reason != "" {
+		body += " " + e.Reason
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "New recommendation",
+		Body:   body,
+	}, nil
+}
+
+func levelUnlocked(value []byte) (models.Notification, error) {
+	e := models.LevelUnlockedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "Level unlocked",
+		Body:   "You have unlocked a new game level. Keep going!",
+	}, nil
+}
+
+func userBanned(value []byte) (models.Notification, error) {
+	e := models.UserBannedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "Your account has been banned",
+		Body:   "An administrator has banned your account. Contact support if you think this is a mistake.",
+	}, nil
+}
+
+func streakAtRisk(value []byte) (models.Notification, error) {
+	e := models.StreakAtRiskEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "Your streak is at risk",
+		Body:   fmt.Sprintf("Finish a lesson today to keep your %d-day streak going.", e.CurrentStreak),
+	}, nil
+}
+
+func achievementUnlocked(value []byte) (models.Notification, error) {
+	e := models.AchievementUnlockedEvent{}
+	if err := json.Unmarshal(value, &e); err != nil {
+		return models.Notification{}, err
+	}
+	body := fmt.Sprintf("You earned the %q badge.", e.Name)
+	if e.Xp > 0 {
+		body += fmt.Sprintf(" +%d XP", e.Xp)
+	}
+	return models.Notification{
+		UserId: e.UserId,
+		Title:  "Achievement unlocked",
+		Body:   body,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"learning-service/kafka"
+	"learning-service/models"
+	s "learning-service/storage"
+)
+
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// Channel delivers a stored notification outside of the in-app inbox.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n models.Notification) error
+}
+
+// Notifier turns an event into an inbox entry plus one pending delivery
+// per enabled external channel, honouring the user's quiet hours.
+type Notifier struct {
+	stg      s.InitRoot
+	kaf      kafka.KafkaProducer
+	channels []Channel
+	now      func() time.Time
+}
+
+func NewNotifier(stg s.InitRoot, kaf kafka.KafkaProducer, channels ...Channel) *Notifier {
+	return &Notifier{stg: stg, kaf: kaf, channels: channels, now: time.Now}
+}
+
+func (n *Notifier) Notify(ctx context.Context, notification models.Notification) error {
+	settings, err := n.stg.Notification().GetNotificationSettings(ctx, notification.UserId, notification.Type)
+	if err != nil {
+		return err
+	}
+
+	notification.InApp = enabled(settings, ChannelInApp)
+	sendAfter := n.sendAfter(settings)
+
+	var deliveries []*models.Delivery
+	for _, ch := range n.channels {
+		if enabled(settings, ch.Name()) {
+			deliveries = append(deliveries, &models.Delivery{Channel: ch.Name(), SendAfter: sendAfter})
+		}
+	}
+	if !notification.InApp && len(deliveries) == 0 {
+		return nil
+	}
+	if err := n.stg.Notification().CreateNotification(ctx, &notification, deliveries); err != nil {
+		return err
+	}
+
+	if notification.InApp {
+		// Lets connected clients refresh their inbox without polling.
+		kafka.Publish(n.kaf, models.EventNotificationCreated, models.NotificationCreatedEvent{
+			UserId:         notification.UserId,
+			NotificationId: notification.Id,
+			Type:           notification.Type,
+			Title:          notification.Title,
+			Body:           notification.Body,
+		})
+	}
+	return nil
+}
+
+// enabled treats channels without an explicit preference as opted in.
+func enabled(settings *models.NotificationSettings, channel string) bool {
+	on, ok := settings.Channels[channel]
+	return !ok || on
+}
+
+// sendAfter returns now, or the end of the user's quiet hours if now falls
+// inside them.
+func (n *Notifier) sendAfter(settings *models.NotificationSettings) time.Time {
+	now := n.now()
+	if settings.QuietStart == nil || settings.QuietEnd == nil {
+		return now
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return quietUntil(now.In(loc), *settings.QuietStart, *settings.QuietEnd)
+}
+
+func quietUntil(local time.Time, start, end int) time.Time {
+	minute := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	if start <= end {
+		quiet = minute >= start && minute < end
+	} else {
+		// the window wraps midnight, e.g. 22:00-07:00
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return local
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	until := midnight.Add(time.Duration(end) * time.Minute)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
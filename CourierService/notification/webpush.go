@@ -0,0 +1,86 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"learning-service/models"
+	s "learning-service/storage"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+type WebPushChannel struct {
+	stg        s.InitRoot
+	subscriber string
+	publicKey  string
+	privateKey string
+}
+
+func NewWebPushChannel(stg s.InitRoot, subscriber, publicKey, privateKey string) *WebPushChannel {
+	return &WebPushChannel{stg: stg, subscriber: subscriber, publicKey: publicKey, privateKey: privateKey}
+}
+
+func (w *WebPushChannel) Name() string {
+	return ChannelPush
+}
+
+// Send pushes to every browser the user subscribed from. Subscriptions the
+// push service reports as expired are dropped.
+func (w *WebPushChannel) Send(ctx context.Context, n models.Notification) error {
+	subs, err := w.stg.Notification().GetPushSubscriptions(ctx, n.UserId)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return ErrGone
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"id":    n.Id,
+		"type":  n.Type,
+		"title": n.Title,
+		"body":  n.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	delivered := 0
+	for _, sub := range subs {
+		res, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+			Endpoint: sub.Endpoint,
+			Keys:     webpush.Keys{P256dh: sub.P256dh, Auth: sub.Auth},
+		}, &webpush.Options{
+			Subscriber:      w.subscriber,
+			VAPIDPublicKey:  w.publicKey,
+			VAPIDPrivateKey: w.privateKey,
+			TTL:             86400,
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+			w.stg.Notification().DeletePushSubscription(ctx, sub.Endpoint)
+		case res.StatusCode >= 300:
+			lastErr = fmt.Errorf("push service answered %s", res.Status)
+		default:
+			delivered++
+		}
+	}
+
+	if delivered > 0 {
+		return nil
+	}
+	if lastErr == nil {
+		return ErrGone
+	}
+	return lastErr
+}
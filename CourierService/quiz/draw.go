@@ -0,0 +1,54 @@
+package quiz
+
+import (
+	"fmt"
+	"math/rand"
+
+	"learning-service/models"
+)
+
+// Draw picks the questions of an attempt. pools holds the candidates of each
+// rule, sorted by id, so that the same seed always draws the same paper. A
+// question picked for one rule is not picked again for a later one.
+func Draw(seed int64, rules []*models.QuizTemplateRule, pools [][]*models.QuizQuestion) ([]*models.QuizQuestion, error) {
+	rng := rand.New(rand.NewSource(seed))
+	picked := map[string]bool{}
+
+	var paper []*models.QuizQuestion
+	for i, rule := range rules {
+		var candidates []*models.QuizQuestion
+		for _, q := range pools[i] {
+			if !picked[q.Id] {
+				candidates = append(candidates, q)
+			}
+		}
+		if len(candidates) < int(rule.Count) {
+			return nil, fmt.Errorf("not enough questions in the bank for rule %d: need %d, have %d", i+1, rule.Count, len(candidates))
+		}
+		rng.Shuffle(len(candidates), func(a, b int) {
+			candidates[a], candidates[b] = candidates[b], candidates[a]
+		})
+		for _, q := range candidates[:rule.Count] {
+			picked[q.Id] = true
+			paper = append(paper, q)
+		}
+	}
+	return paper, nil
+}
+
+// ShuffleOptions reorders the options of every choice question in place.
+// Answers are matched by option text, so grading is unaffected.
+func ShuffleOptions(seed int64, questions []*models.QuizQuestion) {
+	// Offset the seed so the option order doesn't mirror the draw.
+	rng := rand.New(rand.NewSource(seed + 1))
+	for _, q := range questions {
+		if q.Type != models.QuestionSingleChoice && q.Type != models.QuestionMultiChoice {
+			continue
+		}
+		options := append([]string(nil), q.Options...)
+		rng.Shuffle(len(options), func(a, b int) {
+			options[a], options[b] = options[b], options[a]
+		})
+		q.Options = options
+	}
+}
@@ -0,0 +1,154 @@
+package quiz
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"learning-service/models"
+)
+
+func pool(prefix string, n int) []*models.QuizQuestion {
+	var questions []*models.QuizQuestion
+	for i := 1; i <= n; i++ {
+		questions = append(questions, &models.QuizQuestion{
+			Id:      fmt.Sprintf("%s%d", prefix, i),
+			Type:    models.QuestionSingleChoice,
+			Options: []string{"a", "b", "c", "d", "e"},
+		})
+	}
+	return questions
+}
+
+func ids(questions []*models.QuizQuestion) []string {
+	var list []string
+	for _, q := range questions {
+		list = append(list, q.Id)
+	}
+	return list
+}
+
+func TestDrawSameSeedSamePaper(t *testing.T) {
+	rules := []*models.QuizTemplateRule{{Count: 3}, {Count: 2}}
+	pools := [][]*models.QuizQuestion{pool("easy", 10), pool("hard", 10)}
+
+	first, err := Draw(42, rules, pools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Draw(42, rules, pools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(first), ids(second)) {
+		t.Errorf("same seed drew %v, then %v", ids(first), ids(second))
+	}
+	if len(first) != 5 {
+		t.Fatalf("drew %d questions, want 5", len(first))
+	}
+	for i, q := range first {
+		want := "easy"
+		if i >= 3 {
+			want = "hard"
+		}
+		if !strings.HasPrefix(q.Id, want) {
+			t.Errorf("question %d is %s, want one from the %s pool", i, q.Id, want)
+		}
+	}
+
+	// Some seed among a handful must draw another paper, or the draw ignores
+	// its seed.
+	differs := false
+	for seed := int64(1); seed <= 10 && !differs; seed++ {
+		other, err := Draw(seed, rules, pools)
+		if err != nil {
+			t.Fatal(err)
+		}
+		differs = !reflect.DeepEqual(ids(first), ids(other))
+	}
+	if !differs {
+		t.Error("every seed drew the same paper")
+	}
+}
+
+func TestDrawNoRepeats(t *testing.T) {
+	// Both rules match the same questions: the second gets what the first
+	// left.
+	shared := pool("q", 4)
+	rules := []*models.QuizTemplateRule{{Count: 2}, {Count: 2}}
+	paper, err := Draw(7, rules, [][]*models.QuizQuestion{shared, shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, q := range paper {
+		if seen[q.Id] {
+			t.Errorf("%s drawn twice", q.Id)
+		}
+		seen[q.Id] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("drew %d distinct questions, want 4", len(seen))
+	}
+}
+
+func TestDrawPoolTooSmall(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []*models.QuizTemplateRule
+		pools [][]*models.QuizQuestion
+	}{
+		{"one rule", []*models.QuizTemplateRule{{Count: 4}}, [][]*models.QuizQuestion{pool("q", 3)}},
+		{"used up by an earlier rule", []*models.QuizTemplateRule{{Count: 2}, {Count: 2}}, [][]*models.QuizQuestion{pool("q", 3), pool("q", 3)}},
+		{"empty pool", []*models.QuizTemplateRule{{Count: 1}}, [][]*models.QuizQuestion{nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if paper, err := Draw(1, tt.rules, tt.pools); err == nil {
+				t.Errorf("Draw() = %v, want an error", ids(paper))
+			}
+		})
+	}
+}
+
+func TestShuffleOptions(t *testing.T) {
+	questions := func() []*models.QuizQuestion {
+		return []*models.QuizQuestion{
+			{Id: "single", Type: models.QuestionSingleChoice, Options: []string{"a", "b", "c", "d", "e", "f"}},
+			{Id: "multi", Type: models.QuestionMultiChoice, Options: []string{"a", "b", "c", "d", "e", "f"}},
+			{Id: "text", Type: models.QuestionShortText, Options: []string{"a", "b", "c", "d", "e", "f"}},
+		}
+	}
+
+	first, second := questions(), questions()
+	ShuffleOptions(42, first)
+	ShuffleOptions(42, second)
+	for i := range first {
+		if !reflect.DeepEqual(first[i].Options, second[i].Options) {
+			t.Errorf("%s: same seed shuffled to %v, then %v", first[i].Id, first[i].Options, second[i].Options)
+		}
+	}
+
+	// Shuffled, but still the same options.
+	for _, q := range first[:2] {
+		got := append([]string(nil), q.Options...)
+		sort.Strings(got)
+		if strings.Join(got, "") != "abcdef" {
+			t.Errorf("%s options became %v", q.Id, q.Options)
+		}
+	}
+	if !reflect.DeepEqual(first[2].Options, []string{"a", "b", "c", "d", "e", "f"}) {
+		t.Errorf("short text options reordered: %v", first[2].Options)
+	}
+
+	// The shuffle copies the options, so a question shared with the bank
+	// keeps its order.
+	shared := questions()
+	original := shared[0].Options
+	ShuffleOptions(42, shared)
+	if strings.Join(original, "") != "abcdef" {
+		t.Errorf("shuffle changed the original slice: %v", original)
+	}
+}
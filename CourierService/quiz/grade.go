@@ -0,0 +1,173 @@
+package quiz
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"learning-service/models"
+)
+
+var ErrInvalidQuestion = errors.New("invalid question")
+
+// Validate checks that a question can be graded with its key before either
+// is stored.
+func Validate(q *models.QuizQuestion, key *models.AnswerKey) error {
+	if strings.TrimSpace(q.Prompt) == "" {
+		return fmt.Errorf("%w: empty prompt", ErrInvalidQuestion)
+	}
+	if q.Points < 0 {
+		return fmt.Errorf("%w: negative points", ErrInvalidQuestion)
+	}
+	if len(key.Answer) == 0 {
+		return fmt.Errorf("%w: no answer", ErrInvalidQuestion)
+	}
+
+	switch q.Type {
+	case models.QuestionSingleChoice, models.QuestionMultiChoice:
+		if len(q.Options) < 2 {
+			return fmt.Errorf("%w: a choice question needs at least two options", ErrInvalidQuestion)
+		}
+		if q.Type == models.QuestionSingleChoice && len(key.Answer) != 1 {
+			return fmt.Errorf("%w: a single choice question has exactly one answer", ErrInvalidQuestion)
+		}
+		for _, a := range key.Answer {
+			if !contains(q.Options, a) {
+				return fmt.Errorf("%w: answer %q is not an option", ErrInvalidQuestion, a)
+			}
+		}
+	case models.QuestionTrueFalse:
+		if _, ok := parseBool(key.Answer[0]); !ok || len(key.Answer) != 1 {
+			return fmt.Errorf("%w: answer must be true or false", ErrInvalidQuestion)
+		}
+	case models.QuestionNumeric:
+		if _, err := strconv.ParseFloat(key.Answer[0], 64); err != nil || len(key.Answer) != 1 {
+			return fmt.Errorf("%w: answer must be a number", ErrInvalidQuestion)
+		}
+		if key.Tolerance < 0 {
+			return fmt.Errorf("%w: negative tolerance", ErrInvalidQuestion)
+		}
+	case models.QuestionShortText:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidQuestion, q.Type)
+	}
+	return nil
+}
+
+// Grade scores values against the question's key. Only multi-choice
+// questions can earn partial credit, and only if the quiz allows it.
+func Grade(q *models.QuizQuestion, key *models.AnswerKey, values []string, partialCredit bool) (points float64, correct bool) {
+	if len(values) == 0 || key == nil || len(key.Answer) == 0 {
+		return 0, false
+	}
+
+	switch q.Type {
+	case models.QuestionSingleChoice:
+		correct = len(values) == 1 && values[0] == key.Answer[0]
+	case models.QuestionTrueFalse:
+		got, ok := parseBool(values[0])
+		want, _ := parseBool(key.Answer[0])
+		correct = ok && len(values) == 1 && got == want
+	case models.QuestionNumeric:
+		got, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		want, _ := strconv.ParseFloat(key.Answer[0], 64)
+		correct = err == nil && len(values) == 1 && math.Abs(got-want) <= key.Tolerance
+	case models.QuestionShortText:
+		if len(values) == 1 {
+			got := normalize(values[0])
+			for _, a := range key.Answer {
+				if normalize(a) == got {
+					correct = true
+					break
+				}
+			}
+		}
+	case models.QuestionMultiChoice:
+		return gradeMulti(q, key, values, partialCredit)
+	}
+
+	if correct {
+		return q.Points, true
+	}
+	return 0, false
+}
+
+// gradeMulti gives a share of the points for every correct option picked and
+// takes one back for every wrong one, never going below zero.
+func gradeMulti(q *models.QuizQuestion, key *models.AnswerKey, values []string, partialCredit bool) (float64, bool) {
+	picked := map[string]bool{}
+	for _, v := range values {
+		picked[v] = true
+	}
+	var right, wrong int
+	for v := range picked {
+		if contains(key.Answer, v) {
+			right++
+		} else {
+			wrong++
+		}
+	}
+	if right == len(key.Answer) && wrong == 0 {
+		return q.Points, true
+	}
+	if !partialCredit {
+		return 0, false
+	}
+	share := float64(right-wrong) / float64(len(key.Answer))
+	if share <= 0 {
+		return 0, false
+	}
+	return q.Points * share, false
+}
+
+// MaxScore is the sum of the points of all questions.
+func MaxScore(questions []*models.QuizQuestion) float64 {
+	var max float64
+	for _, q := range questions {
+		max += q.Points
+	}
+	return max
+}
+
+// Xp scales the quiz's XP by the share of points scored.
+func Xp(quizXp int32, score, maxScore float64) int32 {
+	if maxScore <= 0 || score <= 0 {
+		return 0
+	}
+	return int32(math.Round(float64(quizXp) * math.Min(score/maxScore, 1)))
+}
+
+// RevealKeys tells whether a user's closed attempts may show the answer keys:
+// only once no attempts are left or the best score passes the quiz, see
+// models.QuizPassRatio.
+func RevealKeys(maxAttempts int32, used int, result *models.QuizResult) bool {
+	if maxAttempts > 0 && used >= int(maxAttempts) {
+		return true
+	}
+	return result != nil && result.MaxScore > 0 && result.BestScore >= result.MaxScore*models.QuizPassRatio
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "1":
+		return true, true
+	case "false", "f", "no", "0":
+		return false, true
+	}
+	return false, false
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package quiz
+
+import (
+	"testing"
+
+	"learning-service/models"
+)
+
+func TestGrade(t *testing.T) {
+	single := &models.QuizQuestion{Type: models.QuestionSingleChoice, Options: []string{"a", "b", "c"}, Points: 2}
+	multi := &models.QuizQuestion{Type: models.QuestionMultiChoice, Options: []string{"a", "b", "c", "d"}, Points: 4}
+	trueFalse := &models.QuizQuestion{Type: models.QuestionTrueFalse, Points: 1}
+	numeric := &models.QuizQuestion{Type: models.QuestionNumeric, Points: 3}
+	text := &models.QuizQuestion{Type: models.QuestionShortText, Points: 1}
+
+	tests := []struct {
+		name        string
+		q           *models.QuizQuestion
+		key         *models.AnswerKey
+		values      []string
+		partial     bool
+		wantPoints  float64
+		wantCorrect bool
+	}{
+		{"single right", single, &models.AnswerKey{Answer: []string{"b"}}, []string{"b"}, false, 2, true},
+		{"single wrong", single, &models.AnswerKey{Answer: []string{"b"}}, []string{"c"}, false, 0, false},
+		{"single with two picks", single, &models.AnswerKey{Answer: []string{"b"}}, []string{"b", "c"}, false, 0, false},
+		{"no answer", single, &models.AnswerKey{Answer: []string{"b"}}, nil, false, 0, false},
+		{"no key", single, nil, []string{"b"}, false, 0, false},
+
+		{"true", trueFalse, &models.AnswerKey{Answer: []string{"true"}}, []string{" Yes "}, false, 1, true},
+		{"false", trueFalse, &models.AnswerKey{Answer: []string{"false"}}, []string{"true"}, false, 0, false},
+		{"not a boolean", trueFalse, &models.AnswerKey{Answer: []string{"false"}}, []string{"maybe"}, false, 0, false},
+
+		{"numeric exact", numeric, &models.AnswerKey{Answer: []string{"3.14"}}, []string{"3.14"}, false, 3, true},
+		{"numeric within tolerance", numeric, &models.AnswerKey{Answer: []string{"3.14"}, Tolerance: 0.01}, []string{" 3.15 "}, false, 3, true},
+		{"numeric outside tolerance", numeric, &models.AnswerKey{Answer: []string{"3.14"}, Tolerance: 0.01}, []string{"3.16"}, false, 0, false},
+		{"numeric without tolerance", numeric, &models.AnswerKey{Answer: []string{"3.14"}}, []string{"3.15"}, false, 0, false},
+		{"not a number", numeric, &models.AnswerKey{Answer: []string{"3"}}, []string{"three"}, false, 0, false},
+
+		{"text ignores case and spaces", text, &models.AnswerKey{Answer: []string{"Go routine", "goroutine"}}, []string{"  go   ROUTINE "}, false, 1, true},
+		{"text other accepted answer", text, &models.AnswerKey{Answer: []string{"Go routine", "goroutine"}}, []string{"Goroutine"}, false, 1, true},
+		{"text wrong", text, &models.AnswerKey{Answer: []string{"goroutine"}}, []string{"thread"}, false, 0, false},
+
+		{"multi all right", multi, &models.AnswerKey{Answer: []string{"a", "c"}}, []string{"c", "a"}, false, 4, true},
+		{"multi picked twice", multi, &models.AnswerKey{Answer: []string{"a", "c"}}, []string{"a", "c", "a"}, false, 4, true},
+		{"multi half without partial credit", multi, &models.AnswerKey{Answer: []string{"a", "c"}}, []string{"a"}, false, 0, false},
+		{"multi half", multi, &models.AnswerKey{Answer: []string{"a", "c"}}, []string{"a"}, true, 2, false},
+		{"multi wrong pick takes one back", multi, &models.AnswerKey{Answer: []string{"a", "b"}}, []string{"a", "b", "c"}, true, 2, false},
+		{"multi right and wrong cancel out", multi, &models.AnswerKey{Answer: []string{"a", "c"}}, []string{"a", "b"}, true, 0, false},
+		{"multi never below zero", multi, &models.AnswerKey{Answer: []string{"a"}}, []string{"b", "c", "d"}, true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, correct := Grade(tt.q, tt.key, tt.values, tt.partial)
+			if points != tt.wantPoints || correct != tt.wantCorrect {
+				t.Errorf("Grade() = %g, %t, want %g, %t", points, correct, tt.wantPoints, tt.wantCorrect)
+			}
+		})
+	}
+}
+
+func TestXp(t *testing.T) {
+	tests := []struct {
+		name       string
+		quizXp     int32
+		score, max float64
+		want       int32
+	}{
+		{"full marks", 30, 10, 10, 30},
+		{"rounded share", 30, 5.5, 10, 17},
+		{"capped at the quiz's XP", 30, 12, 10, 30},
+		{"nothing scored", 30, 0, 10, 0},
+		{"no points to score", 30, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Xp(tt.quizXp, tt.score, tt.max); got != tt.want {
+				t.Errorf("Xp() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		q    *models.QuizQuestion
+		key  *models.AnswerKey
+		ok   bool
+	}{
+		{"single choice", &models.QuizQuestion{Type: models.QuestionSingleChoice, Prompt: "?", Options: []string{"a", "b"}}, &models.AnswerKey{Answer: []string{"a"}}, true},
+		{"numeric", &models.QuizQuestion{Type: models.QuestionNumeric, Prompt: "?"}, &models.AnswerKey{Answer: []string{"2.5"}, Tolerance: 0.1}, true},
+		{"empty prompt", &models.QuizQuestion{Type: models.QuestionShortText, Prompt: " "}, &models.AnswerKey{Answer: []string{"a"}}, false},
+		{"no answer", &models.QuizQuestion{Type: models.QuestionShortText, Prompt: "?"}, &models.AnswerKey{}, false},
+		{"one option", &models.QuizQuestion{Type: models.QuestionMultiChoice, Prompt: "?", Options: []string{"a"}}, &models.AnswerKey{Answer: []string{"a"}}, false},
+		{"answer not an option", &models.QuizQuestion{Type: models.QuestionMultiChoice, Prompt: "?", Options: []string{"a", "b"}}, &models.AnswerKey{Answer: []string{"c"}}, false},
+		{"single choice with two answers", &models.QuizQuestion{Type: models.QuestionSingleChoice, Prompt: "?", Options: []string{"a", "b"}}, &models.AnswerKey{Answer: []string{"a", "b"}}, false},
+		{"true or false", &models.QuizQuestion{Type: models.QuestionTrueFalse, Prompt: "?"}, &models.AnswerKey{Answer: []string{"perhaps"}}, false},
+		{"negative tolerance", &models.QuizQuestion{Type: models.QuestionNumeric, Prompt: "?"}, &models.AnswerKey{Answer: []string{"1"}, Tolerance: -1}, false},
+		{"unknown type", &models.QuizQuestion{Type: "essay", Prompt: "?"}, &models.AnswerKey{Answer: []string{"a"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.q, tt.key)
+			if tt.ok && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("Validate() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestRevealKeys(t *testing.T) {
+	passed := &models.QuizResult{BestScore: 6, MaxScore: 10}
+	partial := &models.QuizResult{BestScore: 5.5, MaxScore: 10}
+	tests := []struct {
+		name        string
+		maxAttempts int32
+		used        int
+		result      *models.QuizResult
+		want        bool
+	}{
+		{"attempts left", 3, 1, partial, false},
+		{"no attempts left", 3, 3, partial, true},
+		{"passed with attempts left", 3, 1, passed, true},
+		{"unlimited attempts", 0, 5, partial, false},
+		{"unlimited attempts, passed", 0, 5, passed, true},
+		{"nothing scored yet", 0, 1, &models.QuizResult{}, false},
+		{"no result", 2, 1, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RevealKeys(tt.maxAttempts, tt.used, tt.result); got != tt.want {
+				t.Errorf("RevealKeys() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package recommendation generates each user's recommendations from what they
+// completed, how they did on quizzes, how topics are rated and which topics
+// they have unlocked. They are refreshed on learning events and, for users
+// without recent events, by a background job.
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"learning-service/kafka"
+	"learning-service/models"
+	s "learning-service/storage"
+)
+
+// refreshBatch is how many stale users the job refreshes per tick.
+const refreshBatch = 100
+
+type Engine struct {
+	stg s.InitRoot
+	kaf kafka.KafkaProducer
+}
+
+func NewEngine(stg s.InitRoot, kaf kafka.KafkaProducer) *Engine {
+	return &Engine{stg: stg, kaf: kaf}
+}
+
+// Register subscribes the engine to the events that change what it would
+// recommend. The achievements engine handles the same topics, so c must be
+// a consumer of its own group.
+func (e *Engine) Register(c *kafka.Consumer) {
+	c.Handle(models.EventActivityRecorded, e.activityRecorded)
+	c.Handle(models.EventLevelCompleted, e.levelCompleted)
+	c.Handle(models.EventXpChanged, e.xpChanged)
+}
+
+// Refresh recomputes the user's recommendations and returns them, best
+// first. The user is notified when the best one is new.
+func (e *Engine) Refresh(ctx context.Context, userId string) ([]*models.Recommendation, error) {
+	signals, err := e.stg.Recommendation().GetLearnerSignals(ctx, userId, maxLevels)
+	if err != nil {
+		return nil, err
+	}
+	recs := Rank(signals)
+	created, err := e.stg.Recommendation().SaveRecommendations(ctx, userId, recs)
+	if err != nil {
+		return nil, err
+	}
+	if len(created) > 0 && created[0] == recs[0] {
+		kafka.Publish(e.kaf, models.EventRecommendationCreated, models.RecommendationCreatedEvent{
+			UserId:           userId,
+			RecommendationId: recs[0].Id,
+			Name:             recs[0].Name,
+			Reason:           recs[0].Reason,
+		})
+	}
+	return recs, nil
+}
+
+// Run refreshes, every interval, the users whose recommendations are older
+// than maxAge.
+func (e *Engine) Run(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.refreshStale(ctx, maxAge)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Engine) refreshStale(ctx context.Context, maxAge time.Duration) {
+	ids, err := e.stg.Recommendation().ClaimStaleRecommendations(ctx, maxAge, refreshBatch)
+	if err != nil {
+		log.Println("recommendation: claim stale users: ", err)
+		return
+	}
+	for _, id := range ids {
+		if err := e.refresh(ctx, id); err != nil {
+			log.Printf("recommendation: refresh %s: %v", id, err)
+		}
+	}
+}
+
+// refresh is Refresh for background callers, to whom a deleted user is no
+// error.
+func (e *Engine) refresh(ctx context.Context, userId string) error {
+	_, err := e.Refresh(ctx, userId)
+	if errors.Is(err, s.ErrProfileNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (e *Engine) activityRecorded(ctx context.Context, msg kafka.Message) error {
+	event := models.ActivityRecordedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("event without user_id"))
+	}
+	return e.refresh(ctx, event.UserId)
+}
+
+func (e *Engine) levelCompleted(ctx context.Context, msg kafka.Message) error {
+	event := models.LevelCompletedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("event without user_id"))
+	}
+	return e.refresh(ctx, event.UserId)
+}
+
+// xpChanged refreshes after feedback, the one input that records no
+// activity.
+func (e *Engine) xpChanged(ctx context.Context, msg kafka.Message) error {
+	event := models.XpChangedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("event without user_id"))
+	}
+	if event.Source != models.XpSourceFeedback {
+		return nil
+	}
+	return e.refresh(ctx, event.UserId)
+}
@@ -0,0 +1,190 @@
+package recommendation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"learning-service/models"
+)
+
+// How many recommendations of each kind a user gets.
+const (
+	maxTopics   = 5
+	maxRemedial = 5
+	maxLevels   = 3
+)
+
+// minRatings is how many feedback ratings a topic needs before they count.
+const minRatings = 3
+
+// difficultyRanks orders the difficulty names topics use. Topics with any
+// other difficulty are not ranked by it.
+var difficultyRanks = map[string]int{
+	"easy":         1,
+	"beginner":     1,
+	"medium":       2,
+	"intermediate": 2,
+	"hard":         3,
+	"advanced":     3,
+}
+
+func difficultyRank(difficulty string) int {
+	return difficultyRanks[strings.ToLower(strings.TrimSpace(difficulty))]
+}
+
+// Rank turns a user's signals into recommendations, best first: topics to
+// take next, resources and reviews for topics whose quizzes they failed, and
+// game levels popular with learners of their level. Every recommendation
+// says why it was made.
+func Rank(s *models.LearnerSignals) []*models.Recommendation {
+	weak := map[string]*models.TopicSignal{}
+	// The hardest difficulty the user completed without failing its quizzes.
+	mastered := 1
+	for _, t := range s.Topics {
+		if t.Attempted && t.BestScore < models.QuizPassRatio {
+			weak[t.Id] = t
+			continue
+		}
+		if r := difficultyRank(t.Difficulty); t.Completed && r > mastered {
+			mastered = r
+		}
+	}
+
+	var topics []*models.Recommendation
+	for _, t := range s.Topics {
+		if !t.Completed && t.Missing == 0 {
+			topics = append(topics, nextTopic(t, mastered))
+		}
+	}
+
+	// A resource may cover several weak topics; it is recommended once, for
+	// the one it helps most with.
+	var remedial []*models.Recommendation
+	covered := map[string]bool{}
+	resources := map[string]*models.Recommendation{}
+	for _, r := range s.Resources {
+		t, ok := weak[r.TopicId]
+		if !ok {
+			continue
+		}
+		covered[t.Id] = true
+		rec, seen := resources[r.Id]
+		score := 70 + gap(t)*100 + float64(r.Missed)*5
+		if seen && rec.Score >= score {
+			continue
+		}
+		if !seen {
+			rec = &models.Recommendation{Type: models.RecommendationResource, ItemId: r.Id, Name: r.Title}
+			resources[r.Id] = rec
+			remedial = append(remedial, rec)
+		}
+		rec.Score = score
+		rec.Reason = fmt.Sprintf("Your best quiz score on %s is %s. This covers %s you got wrong.",
+			t.Name, percent(t.BestScore), plural(r.Missed, "question"))
+	}
+	for _, t := range s.Topics {
+		if weak[t.Id] == nil || covered[t.Id] {
+			continue
+		}
+		remedial = append(remedial, &models.Recommendation{
+			Type:   models.RecommendationReview,
+			ItemId: t.Id,
+			Name:   t.Name,
+			Score:  60 + gap(t)*100,
+			Reason: fmt.Sprintf("Your best quiz score on %s is %s. Review the topic and try its quiz again.", t.Name, percent(t.BestScore)),
+		})
+	}
+
+	var levels []*models.Recommendation
+	for _, l := range s.Levels {
+		players := l.Players
+		if players > 20 {
+			players = 20
+		}
+		levels = append(levels, &models.Recommendation{
+			Type:   models.RecommendationGameLevel,
+			ItemId: l.LevelId,
+			Name:   l.LevelId,
+			Score:  40 + float64(players),
+			Reason: fmt.Sprintf("%s around your level completed it, typically in %s.",
+				plural(l.Players, "learner"), time.Duration(l.MedianSeconds)*time.Second),
+		})
+	}
+
+	var recs []*models.Recommendation
+	recs = append(recs, best(topics, maxTopics)...)
+	recs = append(recs, best(remedial, maxRemedial)...)
+	recs = append(recs, best(levels, maxLevels)...)
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Score > recs[j].Score })
+	return recs
+}
+
+// nextTopic scores an unlocked topic the user has not completed. Topics at
+// the difficulty they mastered, or one step above, come first.
+func nextTopic(t *models.TopicSignal, mastered int) *models.Recommendation {
+	score := 50.0
+	var reasons []string
+	if t.Prerequisites > 0 {
+		score += 20
+		reasons = append(reasons, "You have completed all its prerequisites.")
+	}
+	switch r := difficultyRank(t.Difficulty); {
+	case r == 0:
+	case r == mastered:
+		score += 15
+		reasons = append(reasons, "It matches the level you are working at.")
+	case r == mastered+1:
+		score += 5
+		reasons = append(reasons, "It is a step up from what you have mastered.")
+	case r > mastered+1:
+		score -= 20
+	default:
+		score -= 5
+	}
+	if t.Ratings >= minRatings {
+		score += (t.AvgRating - 3) * 10
+		if t.AvgRating >= 4 {
+			reasons = append(reasons, fmt.Sprintf("Learners rate it %.1f/5.", t.AvgRating))
+		}
+	}
+	if t.UserRating > 0 && t.UserRating <= 2 {
+		score -= 30
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "It is next in the catalog.")
+	}
+	return &models.Recommendation{
+		Type:   models.RecommendationTopic,
+		ItemId: t.Id,
+		Name:   t.Name,
+		Score:  score,
+		Reason: strings.Join(reasons, " "),
+	}
+}
+
+// gap is how far the topic's best quiz score is below passing.
+func gap(t *models.TopicSignal) float64 {
+	return models.QuizPassRatio - t.BestScore
+}
+
+// best returns the n highest scored of recs. Ties keep their order.
+func best(recs []*models.Recommendation, n int) []*models.Recommendation {
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Score > recs[j].Score })
+	if len(recs) > n {
+		recs = recs[:n]
+	}
+	return recs
+}
+
+func percent(ratio float64) string {
+	return fmt.Sprintf("%.0f%%", ratio*100)
+}
+
+func plural(n int32, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
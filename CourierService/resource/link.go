@@ -0,0 +1,83 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"learning-service/models"
+)
+
+var errBlockedAddress = errors.New("address is not public")
+
+// NewLinkClient returns the client urls are checked with. It only connects
+// to public addresses, so that a resource url cannot probe the network the
+// service runs in.
+func NewLinkClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// CheckLink reports whether url answers, and with which status. A server
+// that will not answer HEAD is asked with a GET. Statuses below 400 are
+// reachable, and so are those of pages that exist but turn robots away.
+func CheckLink(ctx context.Context, client *http.Client, url string) (string, int32) {
+	code, err := request(ctx, client, http.MethodHead, url)
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented) {
+		code, err = request(ctx, client, http.MethodGet, url)
+	}
+	if err != nil {
+		return models.UrlUnreachable, 0
+	}
+	switch {
+	case code < 400, code == http.StatusUnauthorized, code == http.StatusForbidden, code == http.StatusTooManyRequests:
+		return models.UrlReachable, int32(code)
+	}
+	return models.UrlUnreachable, int32(code)
+}
+
+func request(ctx context.Context, client *http.Client, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "learning-service link checker")
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	return res.StatusCode, nil
+}
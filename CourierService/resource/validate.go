@@ -0,0 +1,139 @@
+// Package resource holds the rules extra resources follow: a fixed set of
+// types, metadata that fits the type, a url or an uploaded file, and how a
+// url is checked for reachability.
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"learning-service/models"
+)
+
+var ErrInvalidResource = errors.New("invalid resource")
+
+const (
+	maxUrlLength  = 2048
+	maxTextLength = 255
+)
+
+// Metadata fields, as named in JSON, each type of resource may carry.
+var typeFields = map[string]map[string]bool{
+	models.ResourceArticle: {"author": true, "duration_minutes": true, "published_on": true},
+	models.ResourceVideo:   {"author": true, "duration_minutes": true, "provider": true, "published_on": true},
+	models.ResourceBook:    {"author": true, "isbn": true, "publisher": true, "pages": true, "published_on": true},
+	models.ResourceCourse:  {"author": true, "duration_minutes": true, "provider": true},
+	models.ResourcePodcast: {"author": true, "duration_minutes": true, "provider": true, "episode": true, "published_on": true},
+}
+
+// Validate checks a resource and normalizes it: its type lower-cased, its
+// text trimmed and its ISBN without separators.
+func Validate(r *models.ExtraResource) error {
+	r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+	fields, ok := typeFields[r.Type]
+	if !ok {
+		return fmt.Errorf("%w: type must be article, video, book, course or podcast", ErrInvalidResource)
+	}
+
+	r.Url = strings.TrimSpace(r.Url)
+	switch {
+	case r.Url == "" && r.FileId == "":
+		return fmt.Errorf("%w: a resource needs a url or an uploaded file", ErrInvalidResource)
+	case r.Url != "" && r.FileId != "":
+		return fmt.Errorf("%w: a resource has a url or an uploaded file, not both", ErrInvalidResource)
+	case r.Url != "" && !ValidUrl(r.Url):
+		return fmt.Errorf("%w: %q is not an http(s) url", ErrInvalidResource, r.Url)
+	}
+
+	m := &r.Metadata
+	m.Author = strings.TrimSpace(m.Author)
+	m.Publisher = strings.TrimSpace(m.Publisher)
+	m.Provider = strings.TrimSpace(m.Provider)
+	m.PublishedOn = strings.TrimSpace(m.PublishedOn)
+	m.Isbn = strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(m.Isbn))
+
+	set := []struct {
+		field string
+		isSet bool
+	}{
+		{"author", m.Author != ""},
+		{"duration_minutes", m.DurationMinutes != 0},
+		{"isbn", m.Isbn != ""},
+		{"publisher", m.Publisher != ""},
+		{"pages", m.Pages != 0},
+		{"provider", m.Provider != ""},
+		{"episode", m.Episode != 0},
+		{"published_on", m.PublishedOn != ""},
+	}
+	for _, f := range set {
+		if f.isSet && !fields[f.field] {
+			return fmt.Errorf("%w: %s resources have no %s", ErrInvalidResource, r.Type, f.field)
+		}
+	}
+
+	for _, s := range []string{m.Author, m.Publisher, m.Provider} {
+		if len(s) > maxTextLength {
+			return fmt.Errorf("%w: author, publisher and provider are at most %d characters", ErrInvalidResource, maxTextLength)
+		}
+	}
+	if m.DurationMinutes < 0 || m.Pages < 0 || m.Episode < 0 {
+		return fmt.Errorf("%w: duration_minutes, pages and episode cannot be negative", ErrInvalidResource)
+	}
+	if m.Isbn != "" && !ValidIsbn(m.Isbn) {
+		return fmt.Errorf("%w: %q is not a valid ISBN-10 or ISBN-13", ErrInvalidResource, m.Isbn)
+	}
+	if m.PublishedOn != "" {
+		if _, err := time.Parse(time.DateOnly, m.PublishedOn); err != nil {
+			return fmt.Errorf("%w: published_on must be a YYYY-MM-DD date", ErrInvalidResource)
+		}
+	}
+	return nil
+}
+
+// ValidUrl accepts absolute http(s) urls without credentials in them.
+func ValidUrl(s string) bool {
+	if len(s) > maxUrlLength {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User == nil
+}
+
+// ValidIsbn checks the check digit of an ISBN-10 or ISBN-13 written without
+// separators.
+func ValidIsbn(isbn string) bool {
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, c := range isbn {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, c := range isbn {
+			if c < '0' || c > '9' {
+				return false
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
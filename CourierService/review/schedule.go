@@ -0,0 +1,104 @@
+// Package review schedules quiz questions for spaced repetition with SM-2.
+// Grades run from 0, no answer, to 5, a perfect recall; below 3 is a lapse.
+package review
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"learning-service/models"
+)
+
+const (
+	InitialEase = 2.5
+	MinEase     = 1.3
+	// PassingGrade is the lowest grade that counts as recalled.
+	PassingGrade = 3
+)
+
+// Ratings a learner may give a review they answered correctly. An empty
+// rating is RatingGood.
+const (
+	RatingHard = "hard"
+	RatingGood = "good"
+	RatingEasy = "easy"
+)
+
+var ratingGrades = map[string]int32{
+	RatingHard: 3,
+	RatingGood: 4,
+	"":         4,
+	RatingEasy: 5,
+}
+
+var ErrInvalidRating = errors.New("rating must be hard, good or easy")
+
+// Grade turns a graded review answer and the learner's rating into an SM-2
+// grade. A wrong answer is a lapse whatever the rating; partial credit is a
+// near miss.
+func Grade(answer *models.GradedAnswer, rating string) (int32, error) {
+	grade, ok := ratingGrades[rating]
+	if !ok {
+		return 0, ErrInvalidRating
+	}
+	if !answer.Correct {
+		return missGrade(answer), nil
+	}
+	return grade, nil
+}
+
+// QuizGrade is the SM-2 grade of an answer given in a quiz, where learners
+// do not rate their recall.
+func QuizGrade(answer *models.GradedAnswer) int32 {
+	if !answer.Correct {
+		return missGrade(answer)
+	}
+	return ratingGrades[RatingGood]
+}
+
+func missGrade(answer *models.GradedAnswer) int32 {
+	switch {
+	case len(answer.Values) == 0:
+		return 0
+	case answer.Points > 0:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// NewCard is the card of a question the user has not been scheduled on yet.
+func NewCard(userId, questionId string, now time.Time) *models.ReviewCard {
+	return &models.ReviewCard{UserId: userId, QuestionId: questionId, Ease: InitialEase, DueAt: now}
+}
+
+// Schedule applies an answer graded grade at now to c: the first two
+// successful reviews are a day and six days apart, later ones the previous
+// interval times the ease. A lapse starts over at one day.
+func Schedule(c *models.ReviewCard, grade int32, now time.Time) {
+	if grade < PassingGrade {
+		c.Repetitions = 0
+		c.Lapses++
+		c.IntervalDays = 1
+	} else {
+		c.Repetitions++
+		switch c.Repetitions {
+		case 1:
+			c.IntervalDays = 1
+		case 2:
+			c.IntervalDays = 6
+		default:
+			c.IntervalDays = int32(math.Round(float64(c.IntervalDays) * c.Ease))
+		}
+	}
+	miss := float64(5 - grade)
+	c.Ease = math.Max(MinEase, c.Ease+0.1-miss*(0.08+miss*0.02))
+	c.LastReviewedAt = now
+	c.DueAt = now.AddDate(0, 0, int(c.IntervalDays))
+}
+
+// Due reports whether c is due for review at now.
+func Due(c *models.ReviewCard, now time.Time) bool {
+	return !c.DueAt.After(now)
+}
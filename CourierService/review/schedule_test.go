@@ -0,0 +1,129 @@
+package review
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"learning-service/models"
+)
+
+var now = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func TestScheduleIntervals(t *testing.T) {
+	c := NewCard("u", "q", now)
+	steps := []struct {
+		grade        int32
+		wantInterval int32
+		wantEase     float64
+		wantReps     int32
+		wantLapses   int32
+	}{
+		{4, 1, 2.5, 1, 0},
+		{4, 6, 2.5, 2, 0},
+		{4, 15, 2.5, 3, 0},
+		// The interval uses the ease from before this answer.
+		{5, 38, 2.6, 4, 0},
+		{1, 1, 2.06, 0, 1},
+		{3, 1, 1.92, 1, 1},
+		{3, 6, 1.78, 2, 1},
+		{4, 11, 1.78, 3, 1},
+	}
+	for i, s := range steps {
+		at := now.AddDate(0, 0, i*30)
+		Schedule(c, s.grade, at)
+		if c.IntervalDays != s.wantInterval {
+			t.Errorf("step %d (grade %d): interval %d, want %d", i, s.grade, c.IntervalDays, s.wantInterval)
+		}
+		if math.Abs(c.Ease-s.wantEase) > 1e-9 {
+			t.Errorf("step %d (grade %d): ease %.4f, want %.4f", i, s.grade, c.Ease, s.wantEase)
+		}
+		if c.Repetitions != s.wantReps || c.Lapses != s.wantLapses {
+			t.Errorf("step %d (grade %d): %d repetitions and %d lapses, want %d and %d",
+				i, s.grade, c.Repetitions, c.Lapses, s.wantReps, s.wantLapses)
+		}
+		if want := at.AddDate(0, 0, int(s.wantInterval)); !c.DueAt.Equal(want) {
+			t.Errorf("step %d: due %s, want %s", i, c.DueAt, want)
+		}
+		if !c.LastReviewedAt.Equal(at) {
+			t.Errorf("step %d: last reviewed %s, want %s", i, c.LastReviewedAt, at)
+		}
+	}
+}
+
+func TestScheduleEaseFloor(t *testing.T) {
+	tests := []struct {
+		name  string
+		ease  float64
+		grade int32
+	}{
+		{"blackout", 1.4, 0},
+		{"hard at the floor", MinEase, 3},
+		{"lapse at the floor", MinEase, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &models.ReviewCard{Ease: tt.ease, DueAt: now}
+			Schedule(c, tt.grade, now)
+			if c.Ease != MinEase {
+				t.Errorf("ease %.4f, want the floor %.1f", c.Ease, MinEase)
+			}
+		})
+	}
+}
+
+func TestGrade(t *testing.T) {
+	correct := &models.GradedAnswer{Values: []string{"a"}, Points: 1, Correct: true}
+	partial := &models.GradedAnswer{Values: []string{"a", "b"}, Points: 0.5}
+	wrong := &models.GradedAnswer{Values: []string{"c"}}
+	blank := &models.GradedAnswer{}
+
+	tests := []struct {
+		name    string
+		answer  *models.GradedAnswer
+		rating  string
+		want    int32
+		wantErr error
+	}{
+		{"hard", correct, RatingHard, 3, nil},
+		{"good", correct, RatingGood, 4, nil},
+		{"no rating is good", correct, "", 4, nil},
+		{"easy", correct, RatingEasy, 5, nil},
+		{"wrong whatever the rating", wrong, RatingEasy, 1, nil},
+		{"partial credit", partial, RatingGood, 2, nil},
+		{"no answer", blank, "", 0, nil},
+		{"unknown rating", correct, "trivial", 0, ErrInvalidRating},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Grade(tt.answer, tt.rating)
+			if err != tt.wantErr {
+				t.Fatalf("Grade() error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Grade() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := QuizGrade(correct); got != 4 {
+		t.Errorf("QuizGrade(correct) = %d, want 4", got)
+	}
+	if got := QuizGrade(partial); got != 2 {
+		t.Errorf("QuizGrade(partial) = %d, want 2", got)
+	}
+}
+
+func TestDue(t *testing.T) {
+	c := NewCard("u", "q", now)
+	if !Due(c, now) {
+		t.Error("a new card is not due at once")
+	}
+	Schedule(c, 4, now)
+	if Due(c, now.Add(23*time.Hour)) {
+		t.Error("card due before its interval has passed")
+	}
+	if !Due(c, now.AddDate(0, 0, 1)) {
+		t.Error("card not due when its interval has passed")
+	}
+}
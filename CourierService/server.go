@@ -1,16 +1,45 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"google.golang.org/grpc"
+	"learning-service/achievement"
+	"learning-service/blob"
+	"learning-service/config"
 	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/moderation"
+	"learning-service/notification"
+	"learning-service/recommendation"
 	"learning-service/service"
 	postgres "learning-service/storage/postgres"
-	"google.golang.org/grpc"
 )
 
+// consumerRestartDelay is how long a failed consumer waits before it joins
+// its groups again.
+const consumerRestartDelay = 5 * time.Second
+
 func main() {
+	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal("Error while migrating: ", err.Error())
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	db, err := postgres.NewpostgresStorage()
 	if err != nil {
 		log.Fatal("Error while connection on db: ", err.Error())
@@ -20,11 +49,115 @@ func main() {
 		log.Fatal("Error while connection on tcp: ", err.Error())
 	}
 
+	blobs, err := newBlobStore(ctx, cfg)
+	if err != nil {
+		log.Fatal("Error while opening blob storage: ", err.Error())
+	}
+
+	var broker kafka.Broker
+	if cfg.KafkaBrokers == "memory" {
+		broker = kafka.NewMemoryBroker()
+	} else {
+		broker = kafka.NewKafkaBroker(strings.Split(cfg.KafkaBrokers, ","))
+	}
+	producer := kafka.NewKafkaProducer(broker)
+	defer producer.Close()
+
+	channels := []notification.Channel{
+		notification.NewEmailChannel(cfg.AuthServiceURL, cfg.InternalToken),
+		notification.NewWebPushChannel(db, cfg.VapidSubscriber, cfg.VapidPublicKey, cfg.VapidPrivateKey),
+	}
+	go notification.NewDispatcher(db, 10*time.Second, channels...).Run(ctx)
+	go service.NewStreakWatcher(db, producer, 5*time.Minute, int32(cfg.StreakNudgeHour)).Run(ctx)
+	recommender := recommendation.NewEngine(db, producer)
+	go recommender.Run(ctx, 10*time.Minute, time.Duration(cfg.RecommendationRefreshHours)*time.Hour)
+	go service.NewFileJanitor(db, blobs, time.Hour, time.Duration(cfg.BlobOrphanHours)*time.Hour).Run(ctx)
+	go service.NewLinkChecker(db, 10*time.Minute, time.Duration(cfg.LinkRecheckHours)*time.Hour).Run(ctx)
+
+	consumer := kafka.NewConsumer(broker, kafka.DefaultConsumerConfig(cfg.KafkaGroupID))
+	service.NewCommandHandler(db, producer).Register(consumer)
+	notification.NewNotifier(db, producer, channels...).Register(consumer)
+	achievement.NewEngine(db, producer).Register(consumer)
+
+	// The recommender listens to topics the consumer above already handles,
+	// so it reads them as a group of its own.
+	recommendations := kafka.NewConsumer(broker, kafka.DefaultConsumerConfig(cfg.KafkaGroupID+"-recommendations"))
+	recommender.Register(recommendations)
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		runConsumer(ctx, consumer)
+	}()
+	recommendationsDone := make(chan struct{})
+	go func() {
+		defer close(recommendationsDone)
+		runConsumer(ctx, recommendations)
+	}()
+
 	s := grpc.NewServer()
-	pb.RegisterLearningServiceServer(s, service.NewLearningService(db))
+	moderator := moderation.NewFilter(strings.Split(cfg.ModerationBlockedWords, ",")...)
+	pb.RegisterLearningServiceServer(s, service.NewLearningService(db, producer, blobs, time.Duration(cfg.BlobURLTTLMinutes)*time.Minute, moderator))
+
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
 
 	log.Printf("Server listening at %v", liss.Addr())
 	if err := s.Serve(liss); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+	<-consumerDone
+	<-recommendationsDone
+}
+
+// runConsumer runs c until ctx is cancelled, starting it again a little
+// after it fails; what it had not committed is redelivered then.
+func runConsumer(ctx context.Context, c *kafka.Consumer) {
+	for {
+		err := c.Run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		log.Println("Error while consuming kafka: ", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumerRestartDelay):
+		}
+	}
+}
+
+// newBlobStore opens the configured blob storage. The local store serves its
+// own pre-signed URLs, on BLOB_HTTP_PORT behind the gateway.
+func newBlobStore(ctx context.Context, cfg config.Config) (blob.Store, error) {
+	if cfg.BlobBackend == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:       cfg.S3Endpoint,
+			PublicEndpoint: cfg.S3PublicEndpoint,
+			Region:         cfg.S3Region,
+			Bucket:         cfg.S3Bucket,
+			AccessKey:      cfg.S3AccessKey,
+			SecretKey:      cfg.S3SecretKey,
+			PathStyle:      cfg.S3PathStyle,
+		})
+	}
+
+	store, err := blob.NewLocalStore(cfg.BlobLocalDir, cfg.BlobPublicURL, cfg.BlobSigningKey, service.MaxUploadSize)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Addr: cfg.BlobHTTPPort, Handler: store}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		log.Printf("Blob storage listening at %v", cfg.BlobHTTPPort)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Error while serving blob storage: ", err.Error())
+		}
+	}()
+	return store, nil
 }
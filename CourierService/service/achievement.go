@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"learning-service/achievement"
+	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/models"
+)
+
+var metrics = map[string]bool{
+	models.MetricTopicsCompleted:    true,
+	models.MetricResourcesCompleted: true,
+	models.MetricQuizzesFinished:    true,
+	models.MetricQuizzesPerfect:     true,
+	models.MetricHomeworksSubmitted: true,
+	models.MetricStreakDays:         true,
+	models.MetricXp:                 true,
+	models.MetricGameLevels:         true,
+}
+
+// maxAchievementXp caps what one badge pays: a rule is backfilled to every
+// user who already meets it.
+const maxAchievementXp = 500
+
+// CreateAchievement adds a rule and backfills it, so users who already meet
+// it get the badge too.
+func (s *LearningService) CreateAchievement(ctx context.Context, req *pb.CreateAchievementRequest) (*pb.CreateAchievementResponse, error) {
+	if req.Code == "" || req.Name == "" {
+		return nil, errors.New("code and name are required")
+	}
+	if !metrics[req.Metric] {
+		return nil, errors.New("unknown metric")
+	}
+	if req.Threshold <= 0 || req.Xp < 0 || req.MaxSeconds < 0 {
+		return nil, errors.New("threshold must be positive, xp and max_seconds not negative")
+	}
+	if req.Xp > maxAchievementXp {
+		return nil, fmt.Errorf("xp cannot be more than %d", maxAchievementXp)
+	}
+	if req.Metric != models.MetricGameLevels && (req.LevelId != "" || req.MaxSeconds != 0) {
+		return nil, errors.New("level_id and max_seconds only apply to the game_levels metric")
+	}
+	res, err := s.stg.Achievement().CreateAchievement(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	kafka.Publish(s.kaf, achievement.TopicBackfill, achievement.BackfillCommand{Metric: req.Metric})
+	return res, nil
+}
+
+func (s *LearningService) GetAchievements(ctx context.Context, req *pb.GetAchievementsRequest) (*pb.GetAchievementsResponse, error) {
+	res, err := s.stg.Achievement().GetAchievements(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) DeleteAchievement(ctx context.Context, req *pb.DeleteAchievementRequest) (*pb.DeleteAchievementResponse, error) {
+	res, err := s.stg.Achievement().DeleteAchievement(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetUserAchievements(ctx context.Context, req *pb.GetUserAchievementsRequest) (*pb.GetUserAchievementsResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	res, err := s.stg.Achievement().GetUserAchievements(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BackfillAchievements queues an evaluation of every user against the rules
+// on req.Metric, or all rules.
+func (s *LearningService) BackfillAchievements(ctx context.Context, req *pb.BackfillAchievementsRequest) (*pb.BackfillAchievementsResponse, error) {
+	if req.Metric != "" && !metrics[req.Metric] {
+		return nil, errors.New("unknown metric")
+	}
+	kafka.Publish(s.kaf, achievement.TopicBackfill, achievement.BackfillCommand{Metric: req.Metric})
+	return &pb.BackfillAchievementsResponse{Message: "backfill queued"}, nil
+}
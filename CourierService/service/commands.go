@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/models"
+	s "learning-service/storage"
+)
+
+const (
+	TopicCreateTopic   = "app-c"
+	TopicImportContent = "learning-import"
+	TopicAwardXp       = "learning-xp"
+)
+
+// CommandHandler applies asynchronous commands published to Kafka, and keeps
+// learning profiles in step with the auth service's user events.
+type CommandHandler struct {
+	stg s.InitRoot
+	kaf kafka.KafkaProducer
+}
+
+func NewCommandHandler(stg s.InitRoot, kaf kafka.KafkaProducer) *CommandHandler {
+	return &CommandHandler{stg: stg, kaf: kaf}
+}
+
+func (h *CommandHandler) Register(c *kafka.Consumer) {
+	c.Handle(TopicCreateTopic, h.CreateTopic)
+	c.Handle(TopicImportContent, h.ImportContent)
+	c.Handle(TopicAwardXp, h.AwardXp)
+	c.Handle(models.EventUserRegistered, h.UserRegistered)
+	c.Handle(models.EventUserDeleted, h.UserDeleted)
+}
+
+// CreateTopic and ImportContent come from trusted tooling, so what they
+// create is published straight away rather than reviewed.
+func (h *CommandHandler) CreateTopic(ctx context.Context, msg kafka.Message) error {
+	req := pb.CreateLearningTopicRequest{}
+	if err := json.Unmarshal(msg.Value, &req); err != nil {
+		return kafka.Permanent(err)
+	}
+	if req.Name == "" {
+		return kafka.Permanent(errors.New("topic name is required"))
+	}
+	return h.once(ctx, msg, func(tx s.InitRoot) error {
+		return importTopic(ctx, tx, &req)
+	})
+}
+
+// once applies the command msg carries as one unit of work, unless a
+// delivery of it was applied before.
+func (h *CommandHandler) once(ctx context.Context, msg kafka.Message, fn func(tx s.InitRoot) error) error {
+	return h.stg.WithTx(ctx, func(tx s.InitRoot) error {
+		first, err := tx.Learning().MarkCommandProcessed(ctx, kafka.MessageId(msg), msg.Topic)
+		if err != nil || !first {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+func importTopic(ctx context.Context, tx s.InitRoot, req *pb.CreateLearningTopicRequest) error {
+	res, err := tx.Learning().CreateLearningTopic(ctx, req)
+	if err != nil {
+		return err
+	}
+	content, err := topicContent(&pb.UpdateLearningTopicRequest{Name: req.Name, Description: req.Description, Difficulty: req.Difficulty})
+	if err != nil {
+		return err
+	}
+	return publishImported(ctx, tx, models.CourseItemTopic, res.Id, content)
+}
+
+func (h *CommandHandler) ImportContent(ctx context.Context, msg kafka.Message) error {
+	cmd := models.ImportContentCommand{}
+	if err := json.Unmarshal(msg.Value, &cmd); err != nil {
+		return kafka.Permanent(err)
+	}
+
+	for _, q := range cmd.Quizzes {
+		if err := prepareQuiz(q); err != nil {
+			return kafka.Permanent(fmt.Errorf("import quiz %q: %w", q.Title, err))
+		}
+	}
+	for _, hw := range cmd.Homeworks {
+		if err := prepareHomework(hw); err != nil {
+			return kafka.Permanent(fmt.Errorf("import homework %q: %w", hw.Title, err))
+		}
+	}
+
+	// A failed import is retried, so it must leave nothing behind.
+	return h.once(ctx, msg, func(tx s.InitRoot) error {
+		for _, t := range cmd.Topics {
+			if err := importTopic(ctx, tx, t); err != nil {
+				return fmt.Errorf("import topic %q: %w", t.Name, err)
+			}
+		}
+		for _, q := range cmd.Quizzes {
+			if err := importQuiz(ctx, tx, q); err != nil {
+				return fmt.Errorf("import quiz %q: %w", q.Title, err)
+			}
+		}
+		for _, r := range cmd.ExtraResources {
+			if err := prepareResource(ctx, tx, r); err != nil {
+				return kafka.Permanent(fmt.Errorf("import resource %q: %w", r.Title, err))
+			}
+			if err := importResource(ctx, tx, r); err != nil {
+				return fmt.Errorf("import resource %q: %w", r.Title, err)
+			}
+		}
+		for _, hw := range cmd.Homeworks {
+			if _, err := tx.Learning().CreateLearningHomeworks(ctx, hw); err != nil {
+				return fmt.Errorf("import homework %q: %w", hw.Title, err)
+			}
+		}
+		return nil
+	})
+}
+
+func importQuiz(ctx context.Context, tx s.InitRoot, req *pb.CreateQuizRequest) error {
+	res, err := tx.Learning().CreateQuiz(ctx, req)
+	if err != nil {
+		return err
+	}
+	content, err := quizContent(quizUpdate(req))
+	if err != nil {
+		return err
+	}
+	return publishImported(ctx, tx, models.CourseItemQuiz, res.Id, content)
+}
+
+func importResource(ctx context.Context, tx s.InitRoot, req *pb.CreateExtraResoursesRequest) error {
+	res, err := tx.Learning().CreateExtraResourses(ctx, req)
+	if err != nil {
+		return err
+	}
+	content, err := resourceContent(resourceUpdate(req))
+	if err != nil {
+		return err
+	}
+	return publishImported(ctx, tx, models.CourseItemExtraResource, res.Id, content)
+}
+
+func (h *CommandHandler) AwardXp(ctx context.Context, msg kafka.Message) error {
+	cmd := models.AwardXpCommand{}
+	if err := json.Unmarshal(msg.Value, &cmd); err != nil {
+		return kafka.Permanent(err)
+	}
+	if cmd.UserId == "" || cmd.Xp <= 0 {
+		return kafka.Permanent(fmt.Errorf("invalid xp award %+v", cmd))
+	}
+	// Without a source id of its own, a command is told apart by where it
+	// sits in the topic, which a redelivery does not change.
+	if cmd.SourceId == "" {
+		cmd.SourceId = kafka.MessageId(msg)
+	}
+	awarded, err := h.stg.Xp().AwardXp(ctx, &cmd)
+	if err != nil {
+		return err
+	}
+	if !awarded {
+		return nil
+	}
+
+	source := cmd.Source
+	if source == "" {
+		source = models.XpSourceCommand
+	}
+	kafka.Publish(h.kaf, models.EventXpChanged, models.XpChangedEvent{UserId: cmd.UserId, Delta: cmd.Xp, Source: source})
+	return nil
+}
+
+func (h *CommandHandler) UserRegistered(ctx context.Context, msg kafka.Message) error {
+	event := models.UserRegisteredEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("user_id is required"))
+	}
+	return h.stg.Profile().CreateProfile(ctx, event.UserId)
+}
+
+func (h *CommandHandler) UserDeleted(ctx context.Context, msg kafka.Message) error {
+	event := models.UserDeletedEvent{}
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return kafka.Permanent(err)
+	}
+	if event.UserId == "" {
+		return kafka.Permanent(errors.New("user_id is required"))
+	}
+	return h.stg.Profile().DeleteProfile(ctx, event.UserId)
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"learning-service/authoring"
+	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+// contentKinds are the kinds of content written and published through the
+// authoring workflow.
+var contentKinds = map[string]bool{
+	models.CourseItemTopic:         true,
+	models.CourseItemQuiz:          true,
+	models.CourseItemExtraResource: true,
+}
+
+var errReviewersOnly = errors.New("only reviewers and admins can publish, reject or archive content")
+
+func checkContentAction(kind, id, userId string) error {
+	if !contentKinds[kind] {
+		return errors.New("kind must be topic, quiz or extra_resource")
+	}
+	if id == "" || userId == "" {
+		return errors.New("id and user_id are required")
+	}
+	return nil
+}
+
+// A revision's content is the request that updates the item to it, less
+// the fields that name the item and who asks.
+
+func topicContent(req *pb.UpdateLearningTopicRequest) ([]byte, error) {
+	return json.Marshal(&pb.UpdateLearningTopicRequest{Name: req.Name, Description: req.Description, Difficulty: req.Difficulty})
+}
+
+func quizContent(req *pb.UpdateQuizRequest) ([]byte, error) {
+	return json.Marshal(&pb.UpdateQuizRequest{
+		TopicId:          req.TopicId,
+		Title:            req.Title,
+		TimeLimitSeconds: req.TimeLimitSeconds,
+		MaxAttempts:      req.MaxAttempts,
+		PartialCredit:    req.PartialCredit,
+		Shuffle:          req.Shuffle,
+		Xp:               req.Xp,
+		Questions:        req.Questions,
+		Rules:            req.Rules,
+	})
+}
+
+func resourceContent(req *pb.UpdateExtraResoursesRequest) ([]byte, error) {
+	return json.Marshal(&pb.UpdateExtraResoursesRequest{
+		Title:       req.Title,
+		Type:        req.Type,
+		Url:         req.Url,
+		FileId:      req.FileId,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		TopicIds:    req.TopicIds,
+	})
+}
+
+// normalizeContent decodes and encodes content again, so that snapshots
+// written by hand, as the migration's were, compare like the others.
+func normalizeContent(kind string, raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	switch kind {
+	case models.CourseItemTopic:
+		req := pb.UpdateLearningTopicRequest{}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return topicContent(&req)
+	case models.CourseItemQuiz:
+		req := pb.UpdateQuizRequest{}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return quizContent(&req)
+	default:
+		req := pb.UpdateExtraResoursesRequest{}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return resourceContent(&req)
+	}
+}
+
+// contentTitle is what an item is called in a revision: its title, or a
+// topic's name.
+func contentTitle(raw []byte) string {
+	var c struct {
+		Title string `json:"title"`
+		Name  string `json:"name"`
+	}
+	json.Unmarshal(raw, &c)
+	if c.Title != "" {
+		return c.Title
+	}
+	return c.Name
+}
+
+// startContent records a new item as its author's first draft.
+func startContent(ctx context.Context, tx storage.InitRoot, kind, id, authorId string, content []byte) error {
+	if err := tx.Content().SetContentAuthor(ctx, kind, id, authorId); err != nil {
+		return err
+	}
+	rev := &models.ContentRevision{Kind: kind, ItemId: id, Status: models.ContentDraft, Content: content, AuthorId: authorId}
+	return tx.Content().SaveRevision(ctx, rev)
+}
+
+// publishImported publishes an item that came in through a trusted import
+// straight away, as its first version.
+func publishImported(ctx context.Context, tx storage.InitRoot, kind, id string, content []byte) error {
+	item := &models.ContentItem{Kind: kind, Id: id, Status: models.ContentPublished, PublishedVersion: 1}
+	if err := tx.Content().SetContentStatus(ctx, item); err != nil {
+		return err
+	}
+	rev := &models.ContentRevision{Kind: kind, ItemId: id, Version: 1, Status: models.ContentPublished, Content: content}
+	return tx.Content().SaveRevision(ctx, rev)
+}
+
+// saveDraft puts an edit into the item's draft, starting one if it has
+// none. The live item is left alone until the draft is approved. draft
+// builds the content from the latest there is, the open revision's or the
+// published version's, so that it can keep what the edit leaves out.
+func saveDraft(ctx context.Context, tx storage.InitRoot, kind, id, authorId string, draft func(latest []byte) ([]byte, error)) error {
+	item, err := tx.Content().GetContentItem(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	rev, err := tx.Content().GetOpenRevision(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	var status string
+	var latest []byte
+	if rev != nil {
+		status, latest = rev.Status, rev.Content
+	} else if item.PublishedVersion > 0 {
+		published, err := tx.Content().GetRevision(ctx, kind, id, item.PublishedVersion)
+		if err != nil && err != storage.ErrRevisionNotFound {
+			return err
+		}
+		if published != nil {
+			latest = published.Content
+		}
+	}
+	if _, _, err := authoring.Next(authoring.Edit, item.Status, status); err != nil {
+		return err
+	}
+
+	if rev == nil {
+		rev = &models.ContentRevision{Kind: kind, ItemId: id, Status: models.ContentDraft}
+	}
+	if rev.Content, err = draft(latest); err != nil {
+		return err
+	}
+	rev.AuthorId = authorId
+	return tx.Content().SaveRevision(ctx, rev)
+}
+
+// deleteContent checks that an item has never been published and forgets
+// its revisions; remove deletes the item itself.
+func deleteContent(ctx context.Context, tx storage.InitRoot, kind, id string, remove func() error) error {
+	item, err := tx.Content().GetContentItem(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if err := authoring.CanDelete(item); err != nil {
+		return err
+	}
+	if err := remove(); err != nil {
+		return err
+	}
+	return tx.Content().DeleteRevisions(ctx, kind, id)
+}
+
+// publishRevision writes rev's content to the live item, which is what
+// learners see.
+func publishRevision(ctx context.Context, tx storage.InitRoot, item *models.ContentItem, rev *models.ContentRevision) error {
+	switch item.Kind {
+	case models.CourseItemTopic:
+		req := pb.UpdateLearningTopicRequest{}
+		if err := json.Unmarshal(rev.Content, &req); err != nil {
+			return err
+		}
+		req.Id = item.Id
+		_, err := tx.Learning().UpdateLearningTopic(ctx, &req)
+		return err
+	case models.CourseItemQuiz:
+		req := pb.UpdateQuizRequest{}
+		if err := json.Unmarshal(rev.Content, &req); err != nil {
+			return err
+		}
+		req.Id = item.Id
+		// The questions of a quiz that has been attempted cannot change,
+		// so questions the revision keeps as they were are left alone.
+		if item.PublishedVersion > 0 && len(req.Questions) > 0 {
+			current, err := tx.Content().GetRevision(ctx, item.Kind, item.Id, item.PublishedVersion)
+			if err != nil && err != storage.ErrRevisionNotFound {
+				return err
+			}
+			if current != nil {
+				same, err := sameQuestions(current.Content, req.Questions)
+				if err != nil {
+					return err
+				}
+				if same {
+					req.Questions = nil
+				}
+			}
+		}
+		_, err := tx.Learning().UpdateQuiz(ctx, &req)
+		return err
+	default:
+		req := pb.UpdateExtraResoursesRequest{}
+		if err := json.Unmarshal(rev.Content, &req); err != nil {
+			return err
+		}
+		req.Id = item.Id
+		_, err := tx.Learning().UpdateExtraResourses(ctx, &req)
+		return err
+	}
+}
+
+func sameQuestions(content []byte, questions []*pb.QuizQuestion) (bool, error) {
+	current := pb.UpdateQuizRequest{}
+	if err := json.Unmarshal(content, &current); err != nil {
+		return false, err
+	}
+	before, err := json.Marshal(current.Questions)
+	if err != nil {
+		return false, err
+	}
+	after, err := json.Marshal(questions)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(before, after), nil
+}
+
+// SubmitContent sends an item's draft to review. Only the author who last
+// edited the draft submits it.
+func (s *LearningService) SubmitContent(ctx context.Context, req *pb.ContentActionRequest) (*pb.ContentActionResponse, error) {
+	res, _, err := s.reviewContent(ctx, req, authoring.Submit)
+	return res, err
+}
+
+// ApproveContent publishes the revision in review as the item's next
+// version. Only reviewers and admins approve, and not their own revisions.
+func (s *LearningService) ApproveContent(ctx context.Context, req *pb.ContentActionRequest) (*pb.ContentActionResponse, error) {
+	res, rev, err := s.reviewContent(ctx, req, authoring.Approve)
+	if err != nil {
+		return nil, err
+	}
+	s.contentReviewed(rev)
+	return res, nil
+}
+
+// RejectContent sends the revision in review back to its author as a draft,
+// with a comment saying why.
+func (s *LearningService) RejectContent(ctx context.Context, req *pb.ContentActionRequest) (*pb.ContentActionResponse, error) {
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Comment == "" {
+		return nil, errors.New("a rejection needs a comment")
+	}
+	res, rev, err := s.reviewContent(ctx, req, authoring.Reject)
+	if err != nil {
+		return nil, err
+	}
+	s.contentReviewed(rev)
+	return res, nil
+}
+
+// ArchiveContent takes published content away from learners. Approving a
+// new revision of it or rolling it back publishes it again.
+func (s *LearningService) ArchiveContent(ctx context.Context, req *pb.ContentActionRequest) (*pb.ContentActionResponse, error) {
+	res, _, err := s.reviewContent(ctx, req, authoring.Archive)
+	return res, err
+}
+
+// reviewContent takes a workflow step on an item and its open revision,
+// and returns the revision as it left it.
+func (s *LearningService) reviewContent(ctx context.Context, req *pb.ContentActionRequest, action string) (*pb.ContentActionResponse, *models.ContentRevision, error) {
+	if err := checkContentAction(req.Kind, req.Id, req.UserId); err != nil {
+		return nil, nil, err
+	}
+	if action != authoring.Submit && !canReview(req.Role) {
+		return nil, nil, errReviewersOnly
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+
+	var rev *models.ContentRevision
+	res := &pb.ContentActionResponse{Message: "success"}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		item, err := tx.Content().GetContentItem(ctx, req.Kind, req.Id)
+		if err != nil {
+			return err
+		}
+		if rev, err = tx.Content().GetOpenRevision(ctx, req.Kind, req.Id); err != nil {
+			return err
+		}
+		var revStatus string
+		if rev != nil {
+			revStatus = rev.Status
+		}
+		itemStatus, revStatus, err := authoring.Next(action, item.Status, revStatus)
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case authoring.Submit:
+			if rev.AuthorId != req.UserId {
+				return errors.New("a draft can only be submitted by its author")
+			}
+		case authoring.Approve:
+			if rev.AuthorId == req.UserId {
+				return errors.New("a revision cannot be approved by its own author")
+			}
+			if err := publishRevision(ctx, tx, item, rev); err != nil {
+				return err
+			}
+			item.PublishedVersion++
+			rev.Version = item.PublishedVersion
+			rev.ReviewerId, rev.Comment = req.UserId, req.Comment
+		case authoring.Reject:
+			rev.ReviewerId, rev.Comment = req.UserId, req.Comment
+		}
+		if rev != nil && action != authoring.Archive {
+			rev.Status = revStatus
+			if err := tx.Content().SaveRevision(ctx, rev); err != nil {
+				return err
+			}
+		}
+
+		item.Status = itemStatus
+		if err := tx.Content().SetContentStatus(ctx, item); err != nil {
+			return err
+		}
+		res.Status, res.Version = item.Status, item.PublishedVersion
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, rev, nil
+}
+
+// contentReviewed tells the author what became of their revision.
+func (s *LearningService) contentReviewed(rev *models.ContentRevision) {
+	if rev.AuthorId == "" {
+		return
+	}
+	kafka.Publish(s.kaf, models.EventContentReviewed, models.ContentReviewedEvent{
+		UserId:  rev.AuthorId,
+		Kind:    rev.Kind,
+		ItemId:  rev.ItemId,
+		Title:   contentTitle(rev.Content),
+		Status:  rev.Status,
+		Version: rev.Version,
+		Comment: rev.Comment,
+	})
+}
+
+// RollbackContent publishes an earlier version again. It becomes a new
+// version, so the history keeps what was published in between.
+func (s *LearningService) RollbackContent(ctx context.Context, req *pb.RollbackContentRequest) (*pb.ContentActionResponse, error) {
+	if err := checkContentAction(req.Kind, req.Id, req.UserId); err != nil {
+		return nil, err
+	}
+	if !canReview(req.Role) {
+		return nil, errReviewersOnly
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+
+	res := &pb.ContentActionResponse{Message: "success"}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		item, err := tx.Content().GetContentItem(ctx, req.Kind, req.Id)
+		if err != nil {
+			return err
+		}
+		if err := authoring.CanRollback(item, req.Version); err != nil {
+			return err
+		}
+		old, err := tx.Content().GetRevision(ctx, req.Kind, req.Id, req.Version)
+		if err != nil {
+			return err
+		}
+
+		rev := &models.ContentRevision{
+			Kind:       req.Kind,
+			ItemId:     req.Id,
+			Status:     models.ContentPublished,
+			Content:    old.Content,
+			AuthorId:   old.AuthorId,
+			ReviewerId: req.UserId,
+			Comment:    req.Comment,
+			RollbackOf: req.Version,
+		}
+		if err := publishRevision(ctx, tx, item, rev); err != nil {
+			return err
+		}
+		item.PublishedVersion++
+		item.Status = models.ContentPublished
+		rev.Version = item.PublishedVersion
+		if err := tx.Content().SaveRevision(ctx, rev); err != nil {
+			return err
+		}
+		if err := tx.Content().SetContentStatus(ctx, item); err != nil {
+			return err
+		}
+		res.Status, res.Version = item.Status, item.PublishedVersion
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetContentVersions lists an item's open revision and published versions.
+func (s *LearningService) GetContentVersions(ctx context.Context, req *pb.GetContentVersionsRequest) (*pb.GetContentVersionsResponse, error) {
+	if !contentKinds[req.Kind] || req.Id == "" {
+		return nil, errors.New("kind and id are required")
+	}
+	res, err := s.stg.Content().GetContentVersions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetContentRevisions lists revisions across content. The review queue
+// shows those waiting longest first.
+func (s *LearningService) GetContentRevisions(ctx context.Context, req *pb.GetContentRevisionsRequest) (*pb.GetContentRevisionsResponse, error) {
+	if req.Status == models.ContentInReview && req.SortOrder == "" {
+		req.SortOrder = "asc"
+	}
+	res, err := s.stg.Content().GetContentRevisions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DiffContent compares two versions of an item. To left out stands for its
+// open revision and From left out for the version published now.
+func (s *LearningService) DiffContent(ctx context.Context, req *pb.DiffContentRequest) (*pb.DiffContentResponse, error) {
+	if !contentKinds[req.Kind] || req.Id == "" {
+		return nil, errors.New("kind and id are required")
+	}
+
+	var from, to []byte
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		item, err := tx.Content().GetContentItem(ctx, req.Kind, req.Id)
+		if err != nil {
+			return err
+		}
+		if req.From == 0 {
+			req.From = item.PublishedVersion
+		}
+		if req.From > 0 {
+			rev, err := tx.Content().GetRevision(ctx, req.Kind, req.Id, req.From)
+			if err != nil {
+				return err
+			}
+			from = rev.Content
+		}
+
+		if req.To > 0 {
+			rev, err := tx.Content().GetRevision(ctx, req.Kind, req.Id, req.To)
+			if err != nil {
+				return err
+			}
+			to = rev.Content
+			return nil
+		}
+		rev, err := tx.Content().GetOpenRevision(ctx, req.Kind, req.Id)
+		if err != nil {
+			return err
+		}
+		if rev == nil {
+			return storage.ErrRevisionNotFound
+		}
+		to = rev.Content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if from, err = normalizeContent(req.Kind, from); err != nil {
+		return nil, err
+	}
+	if to, err = normalizeContent(req.Kind, to); err != nil {
+		return nil, err
+	}
+	changes, err := authoring.Diff(from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.DiffContentResponse{From: req.From, To: req.To}
+	for _, c := range changes {
+		res.Changes = append(res.Changes, &pb.ContentChange{Field: c.Field, From: c.From, To: c.To})
+	}
+	return res, nil
+}
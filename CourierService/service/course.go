@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+var courseItemTypes = map[string]bool{
+	models.CourseItemTopic:         true,
+	models.CourseItemQuiz:          true,
+	models.CourseItemExtraResource: true,
+	models.CourseItemHomework:      true,
+}
+
+func validateCourseItems(items []*pb.CourseItem) error {
+	seen := map[string]bool{}
+	for _, item := range items {
+		if !courseItemTypes[item.Type] {
+			return fmt.Errorf("unknown item type %q", item.Type)
+		}
+		if item.Id == "" {
+			return errors.New("item id is required")
+		}
+		key := item.Type + "/" + item.Id
+		if seen[key] {
+			return fmt.Errorf("%s %s is listed twice", item.Type, item.Id)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// checkUnlocked fails with storage.ErrTopicLocked while the user has not
+// completed every prerequisite of the topic.
+func (s *LearningService) checkUnlocked(ctx context.Context, stg storage.InitRoot, userId, topicId string) error {
+	missing, err := stg.Course().MissingPrerequisites(ctx, userId, topicId)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: complete %s first", storage.ErrTopicLocked, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (s *LearningService) CreateCourse(ctx context.Context, req *pb.CreateCourseRequest) (*pb.CreateCourseResponse, error) {
+	if req.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	res, err := s.stg.Course().CreateCourse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetCourses(ctx context.Context, req *pb.GetCoursesRequest) (*pb.GetCoursesResponse, error) {
+	res, err := s.stg.Course().GetCourses(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetCourse(ctx context.Context, req *pb.GetCourseRequest) (*pb.GetCourseResponse, error) {
+	res, err := s.stg.Course().GetCourse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) UpdateCourse(ctx context.Context, req *pb.UpdateCourseRequest) (*pb.UpdateCourseResponse, error) {
+	if req.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	res, err := s.stg.Course().UpdateCourse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) DeleteCourse(ctx context.Context, req *pb.DeleteCourseRequest) (*pb.DeleteCourseResponse, error) {
+	res, err := s.stg.Course().DeleteCourse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) CreateCourseModule(ctx context.Context, req *pb.CreateCourseModuleRequest) (*pb.CreateCourseModuleResponse, error) {
+	if req.CourseId == "" || req.Title == "" {
+		return nil, errors.New("course_id and title are required")
+	}
+	if req.Position < 0 {
+		return nil, errors.New("position must not be negative")
+	}
+	if err := validateCourseItems(req.Items); err != nil {
+		return nil, err
+	}
+	res, err := s.stg.Course().CreateCourseModule(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) UpdateCourseModule(ctx context.Context, req *pb.UpdateCourseModuleRequest) (*pb.UpdateCourseModuleResponse, error) {
+	if req.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if req.Position < 0 {
+		return nil, errors.New("position must not be negative")
+	}
+	if err := validateCourseItems(req.Items); err != nil {
+		return nil, err
+	}
+	res, err := s.stg.Course().UpdateCourseModule(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) DeleteCourseModule(ctx context.Context, req *pb.DeleteCourseModuleRequest) (*pb.DeleteCourseModuleResponse, error) {
+	res, err := s.stg.Course().DeleteCourseModule(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) SetTopicPrerequisites(ctx context.Context, req *pb.SetTopicPrerequisitesRequest) (*pb.SetTopicPrerequisitesResponse, error) {
+	if req.TopicId == "" {
+		return nil, errors.New("topic_id is required")
+	}
+	res, err := s.stg.Course().SetTopicPrerequisites(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetTopicPrerequisites(ctx context.Context, req *pb.GetTopicPrerequisitesRequest) (*pb.GetTopicPrerequisitesResponse, error) {
+	res, err := s.stg.Course().GetTopicPrerequisites(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) EnrollCourse(ctx context.Context, req *pb.EnrollCourseRequest) (*pb.EnrollCourseResponse, error) {
+	if req.UserId == "" || req.CourseId == "" {
+		return nil, errors.New("user_id and course_id are required")
+	}
+	res, err := s.stg.Course().EnrollCourse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetEnrollments(ctx context.Context, req *pb.GetEnrollmentsRequest) (*pb.GetEnrollmentsResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	res, err := s.stg.Course().GetEnrollments(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetCourseProgress(ctx context.Context, req *pb.GetCourseProgressRequest) (*pb.GetCourseProgressResponse, error) {
+	if req.UserId == "" || req.CourseId == "" {
+		return nil, errors.New("user_id and course_id are required")
+	}
+	res, err := s.stg.Course().GetCourseProgress(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
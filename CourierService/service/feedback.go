@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+// feedbackXp is paid once per review, when it is first accepted.
+const feedbackXp = 10
+
+const maxCommentLength = 2000
+
+// CreateLearningFeedback saves the user's review of a topic; leaving another
+// edits it. Reviews moderation flags wait for a moderator, and XP is paid
+// when a review is first accepted.
+func (s *LearningService) CreateLearningFeedback(ctx context.Context, req *pb.CreateLearningFeedbackRequest) (*pb.CreateLearningFeedbackResponse, error) {
+	if req.UserId == "" || req.TopicId == "" {
+		return nil, errors.New("user_id and topic_id are required")
+	}
+	if req.Rating < 1 || req.Rating > 5 {
+		return nil, errors.New("rating must be between 1 and 5")
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if utf8.RuneCountInString(req.Comment) > maxCommentLength {
+		return nil, errors.New("comment is too long")
+	}
+
+	verdict := s.moderator.Check(req.Comment)
+	var res *pb.CreateLearningFeedbackResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if res, err = tx.Learning().CreateLearningFeedback(ctx, req, verdict.Status, verdict.Reason); err != nil {
+			return err
+		}
+		res.XpEarned, err = awardFeedbackXp(ctx, tx, req.UserId, res.Id, res.Status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(req.UserId, res.XpEarned, models.XpSourceFeedback)
+	return res, nil
+}
+
+// awardFeedbackXp pays for an accepted review. The ledger pays a review
+// once, however often it is edited or accepted again.
+func awardFeedbackXp(ctx context.Context, tx storage.InitRoot, userId, feedbackId, status string) (int32, error) {
+	if status != models.FeedbackAccepted {
+		return 0, nil
+	}
+	entry := &models.XpEntry{UserId: userId, SourceType: models.XpSourceFeedback, SourceId: feedbackId, Amount: feedbackXp, Reason: "feedback left"}
+	return tx.Xp().Award(ctx, entry)
+}
+
+// GetLearningFeedback lists accepted reviews unless other statuses are asked
+// for, as the moderation queue does.
+func (s *LearningService) GetLearningFeedback(ctx context.Context, req *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error) {
+	if req.Status == "" {
+		req.Status = models.FeedbackAccepted
+	}
+	res, err := s.stg.Learning().GetLearningFeedback(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ModerateFeedback accepts or rejects a review, and pays for it if it is
+// accepted for the first time.
+func (s *LearningService) ModerateFeedback(ctx context.Context, req *pb.ModerateFeedbackRequest) (*pb.ModerateFeedbackResponse, error) {
+	if req.ModeratorId == "" {
+		return nil, errors.New("moderator_id is required")
+	}
+	if !canReview(req.ModeratorRole) {
+		return nil, errors.New("only reviewers and admins can moderate feedback")
+	}
+	if req.Status != models.FeedbackAccepted && req.Status != models.FeedbackRejected {
+		return nil, errors.New("status must be accepted or rejected")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Status == models.FeedbackRejected && req.Reason == "" {
+		return nil, errors.New("a rejection needs a reason")
+	}
+
+	var fb *models.Feedback
+	res := &pb.ModerateFeedbackResponse{Message: "success"}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if fb, err = tx.Learning().ModerateFeedback(ctx, req); err != nil {
+			return err
+		}
+		if fb.UserId == req.ModeratorId {
+			return errors.New("you cannot moderate your own review")
+		}
+		res.XpEarned, err = awardFeedbackXp(ctx, tx, fb.UserId, fb.Id, fb.Status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(fb.UserId, res.XpEarned, models.XpSourceFeedback)
+	kafka.Publish(s.kaf, models.EventFeedbackModerated, models.FeedbackModeratedEvent{
+		UserId:     fb.UserId,
+		FeedbackId: fb.Id,
+		TopicId:    fb.TopicId,
+		TopicName:  fb.TopicName,
+		Status:     fb.Status,
+		Reason:     req.Reason,
+		Xp:         res.XpEarned,
+	})
+	return res, nil
+}
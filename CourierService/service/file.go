@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"learning-service/blob"
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+// filePolicy is what may be uploaded for one purpose: how large a file may
+// be, and the content types accepted, each mapped to what its content must
+// sniff as.
+type filePolicy struct {
+	maxSize int64
+	types   map[string]string
+}
+
+const (
+	mimeDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimePptx = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+)
+
+var filePolicies = map[string]filePolicy{
+	models.FilePurposeAvatar: {maxSize: 5 << 20, types: map[string]string{
+		"image/png": "image/png", "image/jpeg": "image/jpeg", "image/gif": "image/gif", "image/webp": "image/webp",
+	}},
+	models.FilePurposeHomework: {maxSize: 25 << 20, types: map[string]string{
+		"application/pdf": "application/pdf", "image/png": "image/png", "image/jpeg": "image/jpeg",
+		"text/plain": "text/plain", "text/markdown": "text/plain", "application/zip": "application/zip", mimeDocx: "application/zip",
+	}},
+	models.FilePurposeResource: {maxSize: MaxUploadSize, types: map[string]string{
+		"application/pdf": "application/pdf", "image/png": "image/png", "image/jpeg": "image/jpeg", "image/gif": "image/gif",
+		"image/webp": "image/webp", "video/mp4": "video/mp4", "text/plain": "text/plain", "text/markdown": "text/plain",
+		"application/zip": "application/zip", mimeDocx: "application/zip", mimePptx: "application/zip",
+	}},
+}
+
+// MaxUploadSize bounds uploads of any purpose.
+const MaxUploadSize = 100 << 20
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+func fileToPb(f *models.File) *pb.File {
+	return &pb.File{
+		Id:          f.Id,
+		OwnerId:     f.OwnerId,
+		Purpose:     f.Purpose,
+		Filename:    f.Filename,
+		ContentType: f.ContentType,
+		Size:        f.Size,
+		Sha256:      f.Sha256,
+		Status:      f.Status,
+		CreatedAt:   f.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// CreateUpload registers a file the user is about to upload and returns
+// where to PUT it. Every file is uploaded, even if its content is already
+// stored: a sha256 proves nothing about holding the bytes. Content is shared
+// only once CompleteUpload has checked it.
+func (s *LearningService) CreateUpload(ctx context.Context, req *pb.CreateUploadRequest) (*pb.CreateUploadResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	policy, ok := filePolicies[req.Purpose]
+	if !ok {
+		return nil, errors.New("purpose must be resource, avatar or homework")
+	}
+	contentType := mediaType(req.ContentType)
+	if _, ok := policy.types[contentType]; !ok {
+		return nil, fmt.Errorf("%s files cannot be of type %q", req.Purpose, req.ContentType)
+	}
+	if req.Size <= 0 || req.Size > policy.maxSize {
+		return nil, fmt.Errorf("%s files must be between 1 byte and %d MB", req.Purpose, policy.maxSize>>20)
+	}
+	sha := strings.ToLower(req.Sha256)
+	if raw, err := hex.DecodeString(sha); err != nil || len(raw) != 32 {
+		return nil, errors.New("sha256 must be the hex sha256 of the content")
+	}
+	filename := strings.TrimSpace(path.Base(strings.ReplaceAll(req.Filename, `\`, "/")))
+	if filename == "" || filename == "." || filename == "/" || len(filename) > 255 {
+		return nil, errors.New("filename is required, at most 255 characters")
+	}
+
+	f := &models.File{
+		OwnerId:     req.UserId,
+		Purpose:     req.Purpose,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        req.Size,
+		Sha256:      sha,
+		Status:      models.FilePending,
+	}
+	if err := s.stg.File().CreateFile(ctx, f); err != nil {
+		return nil, err
+	}
+	url, err := s.blobs.PresignPut(ctx, blob.UploadKey(f.Id), contentType, s.urlTtl)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateUploadResponse{
+		FileId:    f.Id,
+		Status:    f.Status,
+		UploadUrl: url,
+		Method:    "PUT",
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: time.Now().Add(s.urlTtl).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// CompleteUpload checks an upload against what was announced for it: its
+// size, its sha256 and, sniffed from the content, its type. A file that
+// does not match is dropped and has to be registered again.
+func (s *LearningService) CompleteUpload(ctx context.Context, req *pb.CompleteUploadRequest) (*pb.CompleteUploadResponse, error) {
+	if req.UserId == "" || req.Id == "" {
+		return nil, errors.New("user_id and id are required")
+	}
+	f, err := s.stg.File().GetFile(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if f.OwnerId != req.UserId {
+		return nil, storage.ErrFileNotFound
+	}
+	if f.Status == models.FileReady {
+		return &pb.CompleteUploadResponse{Message: "success", File: fileToPb(f)}, nil
+	}
+
+	upload := blob.UploadKey(f.Id)
+	sum, err := blob.Inspect(ctx, s.blobs, upload)
+	if err == blob.ErrNotFound {
+		return nil, errors.New("the file has not been uploaded yet")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mismatch string
+	switch {
+	case sum.Size != f.Size:
+		mismatch = fmt.Sprintf("%d bytes were uploaded, not %d", sum.Size, f.Size)
+	case sum.Sha256 != f.Sha256:
+		mismatch = "its sha256 does not match"
+	case mediaType(sum.ContentType) != filePolicies[f.Purpose].types[f.ContentType]:
+		mismatch = fmt.Sprintf("its content is not %s", f.ContentType)
+	}
+	if mismatch != "" {
+		if err := s.blobs.Delete(ctx, upload); err != nil {
+			log.Println("file: delete rejected upload: ", err)
+		}
+		if err := s.stg.File().DeleteFile(ctx, f.Id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("upload rejected: %s", mismatch)
+	}
+
+	// A concurrent call for the same file may have moved the upload already.
+	if err := s.blobs.Move(ctx, upload, blob.Key(f.Sha256)); err == blob.ErrNotFound {
+		if f, err = s.stg.File().GetFile(ctx, f.Id); err != nil {
+			return nil, err
+		}
+		if f.Status != models.FileReady {
+			return nil, errors.New("the file has not been uploaded yet")
+		}
+		return &pb.CompleteUploadResponse{Message: "success", File: fileToPb(f)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if f, err = s.stg.File().CompleteFile(ctx, f.Id); err != nil {
+		return nil, err
+	}
+	return &pb.CompleteUploadResponse{Message: "success", File: fileToPb(f)}, nil
+}
+
+// GetDownloadUrl returns a link to a ready file, good for a while only.
+// Homework files are only handed out this way to their owner; reviewers
+// get theirs with the submissions.
+func (s *LearningService) GetDownloadUrl(ctx context.Context, req *pb.GetDownloadUrlRequest) (*pb.GetDownloadUrlResponse, error) {
+	if req.UserId == "" || req.Id == "" {
+		return nil, errors.New("user_id and id are required")
+	}
+	f, err := s.stg.File().GetFile(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status != models.FileReady || (f.Purpose == models.FilePurposeHomework && f.OwnerId != req.UserId) {
+		return nil, storage.ErrFileNotFound
+	}
+	url, err := s.blobs.PresignGet(ctx, blob.Key(f.Sha256), f.ContentType, f.Filename, s.urlTtl)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetDownloadUrlResponse{Url: url, ExpiresAt: time.Now().Add(s.urlTtl).UTC().Format(time.RFC3339), File: fileToPb(f)}, nil
+}
+
+// SetAvatar makes one of the user's avatar uploads their avatar; no file
+// clears it.
+func (s *LearningService) SetAvatar(ctx context.Context, req *pb.SetAvatarRequest) (*pb.SetAvatarResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	if req.FileId != "" {
+		f, err := s.stg.File().GetFile(ctx, req.FileId)
+		if err != nil {
+			return nil, err
+		}
+		if f.OwnerId != req.UserId || f.Purpose != models.FilePurposeAvatar {
+			return nil, storage.ErrFileNotFound
+		}
+		if f.Status != models.FileReady {
+			return nil, errors.New("file upload is not complete")
+		}
+	}
+	if err := s.stg.File().SetAvatar(ctx, req.UserId, req.FileId); err != nil {
+		return nil, err
+	}
+	return &pb.SetAvatarResponse{Message: "success"}, nil
+}
+
+func (s *LearningService) GetAvatar(ctx context.Context, req *pb.GetAvatarRequest) (*pb.GetAvatarResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	f, err := s.stg.File().GetAvatar(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	url, err := s.blobs.PresignGet(ctx, blob.Key(f.Sha256), f.ContentType, "", s.urlTtl)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetAvatarResponse{Url: url, ExpiresAt: time.Now().Add(s.urlTtl).UTC().Format(time.RFC3339)}, nil
+}
+
+// resourceFile checks that a resource points at a ready resource upload, if
+// at any.
+func resourceFile(ctx context.Context, stg storage.InitRoot, fileId string) error {
+	if fileId == "" {
+		return nil
+	}
+	f, err := stg.File().GetFile(ctx, fileId)
+	if err != nil {
+		return err
+	}
+	if f.Purpose != models.FilePurposeResource {
+		return errors.New("file was not uploaded as a resource")
+	}
+	if f.Status != models.FileReady {
+		return errors.New("file upload is not complete")
+	}
+	return nil
+}
+
+// attachFiles fills uploaded attachments in from their files, which must be
+// the user's own homework uploads.
+func attachFiles(ctx context.Context, stg storage.InitRoot, userId string, attachments []*models.HomeworkAttachment) error {
+	var ids []string
+	for _, a := range attachments {
+		if a.FileId != "" {
+			ids = append(ids, a.FileId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	files, err := stg.File().GetFiles(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if a.FileId == "" {
+			continue
+		}
+		f := files[a.FileId]
+		if f == nil || f.OwnerId != userId || f.Purpose != models.FilePurposeHomework {
+			return storage.ErrFileNotFound
+		}
+		if f.Status != models.FileReady {
+			return errors.New("file upload is not complete")
+		}
+		if strings.TrimSpace(a.Name) == "" {
+			a.Name = f.Filename
+		}
+		a.Url, a.ContentType, a.Size = "", f.ContentType, f.Size
+	}
+	return nil
+}
+
+// signAttachments sets the url of uploaded attachments to a download link.
+func (s *LearningService) signAttachments(ctx context.Context, attachments []*pb.HomeworkAttachment) error {
+	var ids []string
+	for _, a := range attachments {
+		if a.FileId != "" {
+			ids = append(ids, a.FileId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	files, err := s.stg.File().GetFiles(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		f := files[a.FileId]
+		if f == nil {
+			continue
+		}
+		if a.Url, err = s.blobs.PresignGet(ctx, blob.Key(f.Sha256), f.ContentType, a.Name, s.urlTtl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Upload cleanup: how long an upload may stay incomplete, and how many files
+// one pass deletes at a time.
+const (
+	pendingUploadAge = 24 * time.Hour
+	fileCleanupBatch = 100
+)
+
+// FileJanitor deletes uploads never completed, files nothing points at any
+// more and, once no file shares them, their blobs.
+type FileJanitor struct {
+	stg       storage.InitRoot
+	blobs     blob.Store
+	interval  time.Duration
+	orphanAge time.Duration
+}
+
+// NewFileJanitor gives a ready file orphanAge to be attached to something
+// before it counts as an orphan.
+func NewFileJanitor(stg storage.InitRoot, blobs blob.Store, interval, orphanAge time.Duration) *FileJanitor {
+	return &FileJanitor{stg: stg, blobs: blobs, interval: interval, orphanAge: orphanAge}
+}
+
+func (j *FileJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.clean(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *FileJanitor) clean(ctx context.Context) {
+	for {
+		n, err := j.stg.File().DeleteOrphanFiles(ctx, pendingUploadAge, j.orphanAge, fileCleanupBatch)
+		if err != nil {
+			log.Println("file: delete orphan files: ", err)
+			return
+		}
+		if n < fileCleanupBatch {
+			break
+		}
+	}
+	for {
+		keys, err := j.stg.File().ClaimBlobDeletions(ctx, fileCleanupBatch)
+		if err != nil {
+			log.Println("file: claim blob deletions: ", err)
+			return
+		}
+		for _, key := range keys {
+			if err := j.blobs.Delete(ctx, key); err != nil {
+				log.Println("file: delete blob: ", err)
+				return
+			}
+			if err := j.stg.File().BlobDeleted(ctx, key); err != nil {
+				log.Println("file: blob deleted: ", err)
+				return
+			}
+		}
+		if len(keys) < fileCleanupBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/homework"
+	"learning-service/kafka"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+// defaultHomeworkXp is what a full score earns on a homework that does not
+// say.
+const defaultHomeworkXp = 20
+
+var latePolicies = map[string]bool{
+	models.LatePolicyAccept:  true,
+	models.LatePolicyPenalty: true,
+	models.LatePolicyReject:  true,
+}
+
+func checkDueAt(dueAt string) error {
+	if dueAt == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, dueAt); err != nil {
+		return errors.New("due_at must be an RFC 3339 timestamp")
+	}
+	return nil
+}
+
+// prepareHomework fills in defaults and validates a homework before it is
+// stored.
+func prepareHomework(req *pb.CreateLearningHomeworksRequest) error {
+	if strings.TrimSpace(req.Title) == "" {
+		return errors.New("title is required")
+	}
+	if req.UserId == "" {
+		return errors.New("user_id is required")
+	}
+	if req.MaxXp < 0 || req.LatePenaltyPercent < 0 || req.MaxSubmissions < 0 {
+		return errors.New("max_xp, late_penalty_percent and max_submissions cannot be negative")
+	}
+	if req.MaxXp == 0 {
+		req.MaxXp = defaultHomeworkXp
+	}
+	if req.LatePolicy == "" {
+		req.LatePolicy = models.LatePolicyAccept
+	}
+	if !latePolicies[req.LatePolicy] {
+		return errors.New("late_policy must be accept, penalty or reject")
+	}
+	if req.LatePenaltyPercent > 100 {
+		return errors.New("late_penalty_percent must be at most 100")
+	}
+	if req.LatePenaltyPercent != 0 && req.LatePolicy != models.LatePolicyPenalty {
+		return errors.New("late_penalty_percent only applies to the penalty late policy")
+	}
+	if err := checkDueAt(req.DueAt); err != nil {
+		return err
+	}
+	rubric := make([]*models.RubricCriterion, len(req.Rubric))
+	for i, c := range req.Rubric {
+		c.Name = strings.TrimSpace(c.Name)
+		rubric[i] = &models.RubricCriterion{Name: c.Name, Description: c.Description, MaxPoints: float64(c.MaxPoints)}
+	}
+	return homework.ValidateRubric(rubric)
+}
+
+func (s *LearningService) homeworkAssigned(userIds []string, homeworkId, title, dueAt string) {
+	for _, userId := range userIds {
+		kafka.Publish(s.kaf, models.EventHomeworkAssigned, models.HomeworkAssignedEvent{
+			UserId:     userId,
+			HomeworkId: homeworkId,
+			Title:      title,
+			DueAt:      dueAt,
+		})
+	}
+}
+
+func (s *LearningService) CreateLearningHomeworks(ctx context.Context, req *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error) {
+	if err := prepareHomework(req); err != nil {
+		return nil, err
+	}
+	res, err := s.stg.Learning().CreateLearningHomeworks(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.homeworkAssigned(res.AssignedUserIds, res.Id, req.Title, req.DueAt)
+	return res, nil
+}
+
+func (s *LearningService) GetLearningHomeworks(ctx context.Context, req *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error) {
+	res, err := s.stg.Learning().GetLearningHomeworks(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// AssignHomework assigns a homework to more users or groups. A due date
+// given here overrides the homework's for the users assigned, including any
+// who already were.
+func (s *LearningService) AssignHomework(ctx context.Context, req *pb.AssignHomeworkRequest) (*pb.AssignHomeworkResponse, error) {
+	if req.HomeworkId == "" {
+		return nil, errors.New("homework_id is required")
+	}
+	if len(req.UserIds) == 0 && len(req.GroupIds) == 0 {
+		return nil, errors.New("user_ids or group_ids are required")
+	}
+	if err := checkDueAt(req.DueAt); err != nil {
+		return nil, err
+	}
+	hw, err := s.stg.Homework().GetHomework(ctx, req.HomeworkId)
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := s.stg.Homework().AssignHomework(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	dueAt := req.DueAt
+	if dueAt == "" && !hw.DueAt.IsZero() {
+		dueAt = hw.DueAt.Format(time.RFC3339)
+	}
+	s.homeworkAssigned(assigned, hw.Id, hw.Title, dueAt)
+	return &pb.AssignHomeworkResponse{Message: "success", AssignedUserIds: assigned}, nil
+}
+
+// SubmitHomework stores the user's next attempt at a homework assigned to
+// them. XP is paid once a reviewer grades it, not on submission.
+func (s *LearningService) SubmitHomework(ctx context.Context, req *pb.SubmitHomeworkRequest) (*pb.SubmitHomeworkResponse, error) {
+	if req.UserId == "" || req.HomeworkId == "" {
+		return nil, errors.New("user_id and homework_id are required")
+	}
+	sub := &models.HomeworkSubmission{
+		HomeworkId: req.HomeworkId,
+		UserId:     req.UserId,
+		Text:       req.Text,
+		Links:      req.Links,
+	}
+	for _, a := range req.Attachments {
+		sub.Attachments = append(sub.Attachments, &models.HomeworkAttachment{Name: a.Name, Url: a.Url, FileId: a.FileId, ContentType: a.ContentType, Size: a.Size})
+	}
+	if err := homework.ValidateSubmission(sub); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		hw, err := tx.Homework().GetHomework(ctx, req.HomeworkId)
+		if err != nil {
+			return err
+		}
+		a, err := tx.Homework().GetAssignment(ctx, req.HomeworkId, req.UserId)
+		if err != nil {
+			return err
+		}
+		if err := attachFiles(ctx, tx, req.UserId, sub.Attachments); err != nil {
+			return err
+		}
+		sub.DaysLate = homework.DaysLate(a.DueAt, now)
+		if err := homework.Accepts(hw, sub.DaysLate); err != nil {
+			return err
+		}
+		if err := tx.Homework().CreateSubmission(ctx, sub, hw.MaxSubmissions); err != nil {
+			return err
+		}
+		return recordActivity(ctx, tx, req.UserId, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.activityRecorded(req.UserId, models.XpSourceHomework, req.HomeworkId)
+	return &pb.SubmitHomeworkResponse{
+		Message:      "success",
+		SubmissionId: sub.Id,
+		Attempt:      sub.Attempt,
+		Status:       sub.Status,
+		Late:         sub.DaysLate > 0,
+		DaysLate:     sub.DaysLate,
+	}, nil
+}
+
+// GetHomeworkSubmissions lists submissions with download links for their
+// uploaded attachments, good for a while only.
+func (s *LearningService) GetHomeworkSubmissions(ctx context.Context, req *pb.GetHomeworkSubmissionsRequest) (*pb.GetHomeworkSubmissionsResponse, error) {
+	res, err := s.stg.Homework().GetSubmissions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var attachments []*pb.HomeworkAttachment
+	for _, sub := range res.Submissions {
+		attachments = append(attachments, sub.Attachments...)
+	}
+	if err := s.signAttachments(ctx, attachments); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GradeHomework records a reviewer's verdict on a submission waiting for
+// review: graded against the homework's rubric, or returned with a comment
+// for the learner to resubmit. Only grading pays XP, worth the grade less
+// any late penalty; a better grade on a resubmission pays the difference.
+func (s *LearningService) GradeHomework(ctx context.Context, req *pb.GradeHomeworkRequest) (*pb.GradeHomeworkResponse, error) {
+	if req.SubmissionId == "" || req.GraderId == "" {
+		return nil, errors.New("submission_id and grader_id are required")
+	}
+	if !canReview(req.GraderRole) {
+		return nil, errors.New("only reviewers and admins can grade homework")
+	}
+	if req.Return && strings.TrimSpace(req.Comment) == "" {
+		return nil, errors.New("a returned submission needs a comment")
+	}
+
+	var sub *models.HomeworkSubmission
+	var hw *models.Homework
+	var xp int32
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if sub, err = tx.Homework().GetSubmission(ctx, req.SubmissionId); err != nil {
+			return err
+		}
+		if sub.UserId == req.GraderId {
+			return errors.New("you cannot grade your own submission")
+		}
+		if sub.Status != models.SubmissionSubmitted {
+			return storage.ErrSubmissionClosed
+		}
+		if hw, err = tx.Homework().GetHomework(ctx, sub.HomeworkId); err != nil {
+			return err
+		}
+
+		sub.GradedBy, sub.Comment = req.GraderId, req.Comment
+		if req.Return {
+			sub.Status = models.SubmissionReturned
+		} else {
+			scores := make([]*models.RubricScore, len(req.Scores))
+			for i, sc := range req.Scores {
+				scores[i] = &models.RubricScore{Criterion: strings.TrimSpace(sc.Criterion), Points: float64(sc.Points), Comment: sc.Comment}
+			}
+			if sub.Score, sub.MaxScore, err = homework.Score(hw, scores, float64(req.Score)); err != nil {
+				return err
+			}
+			if len(hw.Rubric) > 0 {
+				sub.Scores = scores
+			}
+			sub.Status = models.SubmissionGraded
+			sub.XpEarned = homework.Xp(hw, sub.Score, sub.MaxScore, sub.DaysLate)
+		}
+		xp, err = tx.Homework().GradeSubmission(ctx, sub)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(sub.UserId, xp, models.XpSourceHomework)
+	kafka.Publish(s.kaf, models.EventHomeworkGraded, models.HomeworkGradedEvent{
+		UserId:       sub.UserId,
+		HomeworkId:   sub.HomeworkId,
+		SubmissionId: sub.Id,
+		Title:        hw.Title,
+		Status:       sub.Status,
+		Score:        sub.Score,
+		MaxScore:     sub.MaxScore,
+		Xp:           xp,
+	})
+	return &pb.GradeHomeworkResponse{
+		Message:  "success",
+		Status:   sub.Status,
+		Score:    float32(sub.Score),
+		MaxScore: float32(sub.MaxScore),
+		XpEarned: xp,
+	}, nil
+}
+
+func (s *LearningService) CreateStudyGroup(ctx context.Context, req *pb.CreateStudyGroupRequest) (*pb.CreateStudyGroupResponse, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	res, err := s.stg.Homework().CreateStudyGroup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) SetStudyGroupMembers(ctx context.Context, req *pb.SetStudyGroupMembersRequest) (*pb.SetStudyGroupMembersResponse, error) {
+	if req.GroupId == "" {
+		return nil, errors.New("group_id is required")
+	}
+	res, err := s.stg.Homework().SetStudyGroupMembers(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetStudyGroup(ctx context.Context, req *pb.GetStudyGroupRequest) (*pb.GetStudyGroupResponse, error) {
+	res, err := s.stg.Homework().GetStudyGroup(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
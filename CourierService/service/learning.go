@@ -2,21 +2,78 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"learning-service/blob"
 	pb "learning-service/genproto/learning"
-	s "learning-service/storage"
+	"learning-service/kafka"
+	"learning-service/models"
+	"learning-service/moderation"
+	"learning-service/recommendation"
+	"learning-service/storage"
 )
 
 type LearningService struct {
 	pb.UnimplementedLearningServiceServer
-	stg s.InitRoot
+	stg       storage.InitRoot
+	kaf       kafka.KafkaProducer
+	blobs     blob.Store
+	urlTtl    time.Duration
+	moderator *moderation.Filter
+}
+
+// NewLearningService hands out links to blobs good for urlTtl and holds
+// reviews the moderator flags.
+func NewLearningService(stg storage.InitRoot, kaf kafka.KafkaProducer, blobs blob.Store, urlTtl time.Duration, moderator *moderation.Filter) *LearningService {
+	return &LearningService{stg: stg, kaf: kaf, blobs: blobs, urlTtl: urlTtl, moderator: moderator}
+}
+
+// XP paid for completing a topic and an extra resource. The amounts are the
+// server's: whatever xp_earned a client sends is ignored.
+const (
+	topicXp         = 20
+	extraResourceXp = 10
+)
+
+func (s *LearningService) xpChanged(userId string, delta int32, source string) {
+	if delta == 0 {
+		return
+	}
+	kafka.Publish(s.kaf, models.EventXpChanged, models.XpChangedEvent{UserId: userId, Delta: delta, Source: source})
+}
+
+// activityRecorded lets the achievements engine re-evaluate the user.
+func (s *LearningService) activityRecorded(userId, kind, sourceId string) {
+	kafka.Publish(s.kaf, models.EventActivityRecorded, models.ActivityRecordedEvent{UserId: userId, Kind: kind, SourceId: sourceId})
 }
 
-func NewLearningService(stg s.InitRoot) *LearningService {
-	return &LearningService{stg: stg}
+// canReview reports whether role may grade homework, moderate feedback and
+// publish content.
+// The gateway passes the role on from the caller's token.
+func canReview(role string) bool {
+	return role == models.RoleReviewer || role == models.RoleAdmin
 }
 
+// CreateLearningTopic starts a topic as its author's draft. Learners see it
+// once a reviewer publishes it.
 func (s *LearningService) CreateLearningTopic(ctx context.Context, req *pb.CreateLearningTopicRequest) (*pb.CreateLearningTopicResponse, error) {
-	res, err := s.stg.Learning().CreateLearningTopic(req)
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	content, err := topicContent(&pb.UpdateLearningTopicRequest{Name: req.Name, Description: req.Description, Difficulty: req.Difficulty})
+	if err != nil {
+		return nil, err
+	}
+	var res *pb.CreateLearningTopicResponse
+	err = s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if res, err = tx.Learning().CreateLearningTopic(ctx, req); err != nil {
+			return err
+		}
+		return startContent(ctx, tx, models.CourseItemTopic, res.Id, req.AuthorId, content)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -24,23 +81,41 @@ func (s *LearningService) CreateLearningTopic(ctx context.Context, req *pb.Creat
 }
 
 func (s *LearningService) GetLearningTopics(ctx context.Context, req *pb.GetLearningTopicsRequest) (*pb.GetLearningTopicsResponse, error) {
-	res, err := s.stg.Learning().GetLearningTopics(req)
+	res, err := s.stg.Learning().GetLearningTopics(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// UpdateLearningTopic saves the change as a draft. Learners go on seeing
+// the published topic until a reviewer publishes the draft.
 func (s *LearningService) UpdateLearningTopic(ctx context.Context, req *pb.UpdateLearningTopicRequest) (*pb.UpdateLearningTopicResponse, error) {
-	res, err := s.stg.Learning().UpdateLearningTopic(req)
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return saveDraft(ctx, tx, models.CourseItemTopic, req.Id, req.AuthorId, func([]byte) ([]byte, error) {
+			return topicContent(req)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	return &pb.UpdateLearningTopicResponse{Message: "success"}, nil
 }
 
+// DeleteLearningTopic deletes a topic that was never published; one that
+// was is archived instead.
 func (s *LearningService) DeleteLearningTopic(ctx context.Context, req *pb.DeleteLearningTopicRequest) (*pb.DeleteLearningTopicResponse, error) {
-	res, err := s.stg.Learning().DeleteLearningTopic(req)
+	var res *pb.DeleteLearningTopicResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return deleteContent(ctx, tx, models.CourseItemTopic, req.Id, func() error {
+			var err error
+			res, err = tx.Learning().DeleteLearningTopic(ctx, req)
+			return err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -48,87 +123,217 @@ func (s *LearningService) DeleteLearningTopic(ctx context.Context, req *pb.Delet
 }
 
 func (s *LearningService) CompletedTopics(ctx context.Context, req *pb.CompletedTopicsRequest) (*pb.CompletedTopicsResponse, error) {
-	res, err := s.stg.Learning().CompletedTopics(req)
+	req.XpEarned = topicXp
+	var res *pb.CompletedTopicsResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		if err := s.checkUnlocked(ctx, tx, req.UserId, req.TopicId); err != nil {
+			return err
+		}
+		var err error
+		if res, err = tx.Learning().CompletedTopics(ctx, req); err != nil {
+			return err
+		}
+		entry := &models.XpEntry{UserId: req.UserId, SourceType: models.XpSourceTopic, SourceId: req.TopicId, Amount: topicXp, Reason: "topic completed"}
+		if res.XpEarned, err = tx.Xp().Award(ctx, entry); err != nil {
+			return err
+		}
+		return recordActivity(ctx, tx, req.UserId, res.XpEarned)
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.xpChanged(req.UserId, res.XpEarned, models.XpSourceTopic)
+	s.activityRecorded(req.UserId, models.XpSourceTopic, req.TopicId)
 	return res, nil
 }
 
 func (s *LearningService) GetCompletedTopics(ctx context.Context, req *pb.GetCompletedTopicsRequest) (*pb.GetCompletedTopicsResponse, error) {
-	res, err := s.stg.Learning().GetCompletedTopics(req)
+	res, err := s.stg.Learning().GetCompletedTopics(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// CreateQuiz starts a quiz as its author's draft.
 func (s *LearningService) CreateQuiz(ctx context.Context, req *pb.CreateQuizRequest) (*pb.CreateQuizResponse, error) {
-	res, err := s.stg.Learning().CreateQuiz(req)
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	if err := prepareQuiz(req); err != nil {
+		return nil, err
+	}
+	content, err := quizContent(quizUpdate(req))
+	if err != nil {
+		return nil, err
+	}
+	var res *pb.CreateQuizResponse
+	err = s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if res, err = tx.Learning().CreateQuiz(ctx, req); err != nil {
+			return err
+		}
+		return startContent(ctx, tx, models.CourseItemQuiz, res.Id, req.AuthorId, content)
+	})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// quizUpdate is the update that gives a quiz what req creates it with.
+func quizUpdate(req *pb.CreateQuizRequest) *pb.UpdateQuizRequest {
+	return &pb.UpdateQuizRequest{
+		TopicId:          req.TopicId,
+		Title:            req.Title,
+		TimeLimitSeconds: req.TimeLimitSeconds,
+		MaxAttempts:      req.MaxAttempts,
+		PartialCredit:    req.PartialCredit,
+		Shuffle:          req.Shuffle,
+		Xp:               req.Xp,
+		Questions:        req.Questions,
+		Rules:            req.Rules,
+	}
+}
+
 func (s *LearningService) GetQuiz(ctx context.Context, req *pb.GetQuizRequest) (*pb.GetQuizResponse, error) {
-	res, err := s.stg.Learning().GetQuiz(req)
+	res, err := s.stg.Learning().GetQuiz(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// UpdateQuiz saves the change as a draft. Questions or template rules left
+// out keep those of the latest revision.
 func (s *LearningService) UpdateQuiz(ctx context.Context, req *pb.UpdateQuizRequest) (*pb.UpdateQuizResponse, error) {
-	res, err := s.stg.Learning().UpdateQuiz(req)
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	if err := prepareQuestions(req.Questions, false); err != nil {
+		return nil, err
+	}
+	if err := prepareRules(req.Rules); err != nil {
+		return nil, err
+	}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return saveDraft(ctx, tx, models.CourseItemQuiz, req.Id, req.AuthorId, func(latest []byte) ([]byte, error) {
+			if len(latest) > 0 && (len(req.Questions) == 0 || len(req.Rules) == 0) {
+				base := pb.UpdateQuizRequest{}
+				if err := json.Unmarshal(latest, &base); err != nil {
+					return nil, err
+				}
+				if len(req.Questions) == 0 {
+					req.Questions = base.Questions
+				}
+				if len(req.Rules) == 0 {
+					req.Rules = base.Rules
+				}
+			}
+			return quizContent(req)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	return &pb.UpdateQuizResponse{Message: "success"}, nil
 }
 
+// DeleteQuiz deletes a quiz that was never published.
 func (s *LearningService) DeleteQuiz(ctx context.Context, req *pb.DeleteQuizRequest) (*pb.DeleteQuizResponse, error) {
-	res, err := s.stg.Learning().DeleteQuiz(req)
+	var res *pb.DeleteQuizResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return deleteContent(ctx, tx, models.CourseItemQuiz, req.Id, func() error {
+			var err error
+			res, err = tx.Learning().DeleteQuiz(ctx, req)
+			return err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (s *LearningService) SubmitQuiz(ctx context.Context, req *pb.SubmitQuizRequest) (*pb.SubmitQuizResponse, error) {
-	res, err := s.stg.Learning().SubmitQuiz(req)
+// CreateExtraResourses starts a resource as its author's draft.
+func (s *LearningService) CreateExtraResourses(ctx context.Context, req *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error) {
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	if err := prepareResource(ctx, s.stg, req); err != nil {
+		return nil, err
+	}
+	content, err := resourceContent(resourceUpdate(req))
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
-}
-
-func (s *LearningService) CreateExtraResourses(ctx context.Context, req *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error) {
-	res, err := s.stg.Learning().CreateExtraResourses(req)
+	var res *pb.CreateExtraResoursesResponse
+	err = s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if res, err = tx.Learning().CreateExtraResourses(ctx, req); err != nil {
+			return err
+		}
+		return startContent(ctx, tx, models.CourseItemExtraResource, res.Id, req.AuthorId, content)
+	})
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// resourceUpdate is the update that gives a resource what req creates it
+// with.
+func resourceUpdate(req *pb.CreateExtraResoursesRequest) *pb.UpdateExtraResoursesRequest {
+	return &pb.UpdateExtraResoursesRequest{
+		Title:       req.Title,
+		Type:        req.Type,
+		Url:         req.Url,
+		FileId:      req.FileId,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+		TopicIds:    req.TopicIds,
+	}
+}
+
 func (s *LearningService) GetExtraResourses(ctx context.Context, req *pb.GetExtraResourcesRequest) (*pb.GetExtraResourcesResponse, error) {
-	res, err := s.stg.Learning().GetExtraResourses(req)
+	res, err := s.stg.Learning().GetExtraResourses(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// UpdateExtraResourses saves the change as a draft.
 func (s *LearningService) UpdateExtraResourses(ctx context.Context, req *pb.UpdateExtraResoursesRequest) (*pb.UpdateExtraResoursesResponse, error) {
-	res, err := s.stg.Learning().UpdateExtraResourses(req)
+	if req.AuthorId == "" {
+		return nil, errors.New("author_id is required")
+	}
+	r := resourceFromPb(req.Type, req.Url, req.FileId, req.Metadata)
+	if err := checkResource(ctx, s.stg, r, req.TopicIds); err != nil {
+		return nil, err
+	}
+	req.Type, req.Url, req.Metadata = r.Type, r.Url, metadataToPb(r.Metadata)
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return saveDraft(ctx, tx, models.CourseItemExtraResource, req.Id, req.AuthorId, func([]byte) ([]byte, error) {
+			return resourceContent(req)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	return &pb.UpdateExtraResoursesResponse{Message: "success"}, nil
 }
 
+// DeleteExtraResourses deletes a resource that was never published.
 func (s *LearningService) DeleteExtraResourses(ctx context.Context, req *pb.DeleteExtraResoursesRequest) (*pb.DeleteExtraResoursesResponse, error) {
-	res, err := s.stg.Learning().DeleteExtraResourses(req)
+	var res *pb.DeleteExtraResoursesResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		return deleteContent(ctx, tx, models.CourseItemExtraResource, req.Id, func() error {
+			var err error
+			res, err = tx.Learning().DeleteExtraResourses(ctx, req)
+			return err
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -136,15 +341,37 @@ func (s *LearningService) DeleteExtraResourses(ctx context.Context, req *pb.Dele
 }
 
 func (s *LearningService) CompletedExtraResources(ctx context.Context, req *pb.CompletedExtraResourcesRequest) (*pb.CompletedExtraResourcesResponse, error) {
-	res, err := s.stg.Learning().CompletedExtraResources(req)
+	var res *pb.CompletedExtraResourcesResponse
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if res, err = tx.Learning().CompletedExtraResources(ctx, req); err != nil {
+			return err
+		}
+		if res.AlreadyCompleted {
+			return nil
+		}
+		entry := &models.XpEntry{UserId: req.UserId, SourceType: models.XpSourceExtraResource, SourceId: req.ExtraResourceId, Amount: extraResourceXp, Reason: "extra resource completed"}
+		if res.XpEarned, err = tx.Xp().Award(ctx, entry); err != nil {
+			return err
+		}
+		return recordActivity(ctx, tx, req.UserId, res.XpEarned)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if res.AlreadyCompleted {
+		return res, nil
+	}
+	s.xpChanged(req.UserId, res.XpEarned, models.XpSourceExtraResource)
+	s.activityRecorded(req.UserId, models.XpSourceExtraResource, req.ExtraResourceId)
 	return res, nil
 }
 
 func (s *LearningService) GetLearningProgress(ctx context.Context, req *pb.GetLearningProgressRequest) (*pb.GetLearningProgressResponse, error) {
-	res, err := s.stg.Learning().GetLearningProgress(req)
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	res, err := s.stg.Learning().GetLearningProgress(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -152,57 +379,53 @@ func (s *LearningService) GetLearningProgress(ctx context.Context, req *pb.GetLe
 }
 
 func (s *LearningService) CreateLearningRecommendations(ctx context.Context, req *pb.CreateLearningRecommendationsRequest) (*pb.CreateLearningRecommendationsResponse, error) {
-	res, err := s.stg.Learning().CreateLearningRecommendations(req)
+	res, err := s.stg.Learning().CreateLearningRecommendations(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	kafka.Publish(s.kaf, models.EventRecommendationCreated, models.RecommendationCreatedEvent{
+		UserId:           req.UserId,
+		RecommendationId: res.Id,
+		Name:             req.Name,
+		Reason:           req.Reason,
+	})
 	return res, nil
 }
 
+// GetLearningRecommendations lists the recommendations of one user, those
+// the engine generated and those made by hand.
 func (s *LearningService) GetLearningRecommendations(ctx context.Context, req *pb.GetLearningRecommendationsRequest) (*pb.GetLearningRecommendationsResponse, error) {
-	res, err := s.stg.Learning().GetLearningRecommendations(req)
-	if err != nil {
-		return nil, err
-	}
-	return res, nil
-}
-
-func (s *LearningService) CreateLearningFeedback(ctx context.Context, req *pb.CreateLearningFeedbackRequest) (*pb.CreateLearningFeedbackResponse, error) {
-	res, err := s.stg.Learning().CreateLearningFeedback(req)
-	if err != nil {
-		return nil, err
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
 	}
-	return res, nil
-}
-
-func (s *LearningService) GetLearningFeedback(ctx context.Context, req *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error) {
-	res, err := s.stg.Learning().GetLearningFeedback(req)
+	res, err := s.stg.Learning().GetLearningRecommendations(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (s *LearningService) CreateLearningHomeworks(ctx context.Context, req *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error) {
-	res, err := s.stg.Learning().CreateLearningHomeworks(req)
-	if err != nil {
-		return nil, err
+// RefreshRecommendations recomputes a user's recommendations right away
+// instead of waiting for the next event or background refresh.
+func (s *LearningService) RefreshRecommendations(ctx context.Context, req *pb.RefreshRecommendationsRequest) (*pb.RefreshRecommendationsResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
 	}
-	return res, nil
-}
-
-func (s *LearningService) GetLearningHomeworks(ctx context.Context, req *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error) {
-	res, err := s.stg.Learning().GetLearningHomeworks(req)
+	recs, err := recommendation.NewEngine(s.stg, s.kaf).Refresh(ctx, req.UserId)
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
-}
-
-func (s *LearningService) SubmitHomework(ctx context.Context, req *pb.SubmitHomeworkRequest) (*pb.SubmitHomeworkResponse, error) {
-	res, err := s.stg.Learning().SubmitHomework(req)
-	if err != nil {
-		return nil, err
+	res := &pb.RefreshRecommendationsResponse{Message: "success"}
+	for _, r := range recs {
+		res.Recommendations = append(res.Recommendations, &pb.Recommendation{
+			Id:     r.Id,
+			Type:   r.Type,
+			ItemId: r.ItemId,
+			Name:   r.Name,
+			UserId: r.UserId,
+			Reason: r.Reason,
+			Score:  r.Score,
+		})
 	}
 	return res, nil
 }
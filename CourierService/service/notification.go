@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+
+	pb "learning-service/genproto/learning"
+)
+
+func (s *LearningService) GetNotifications(ctx context.Context, req *pb.GetNotificationsRequest) (*pb.GetNotificationsResponse, error) {
+	res, err := s.stg.Notification().GetNotifications(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) MarkNotificationsRead(ctx context.Context, req *pb.MarkNotificationsReadRequest) (*pb.MarkNotificationsReadResponse, error) {
+	res, err := s.stg.Notification().MarkNotificationsRead(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetNotificationPreferences(ctx context.Context, req *pb.GetNotificationPreferencesRequest) (*pb.GetNotificationPreferencesResponse, error) {
+	res, err := s.stg.Notification().GetNotificationPreferences(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) UpdateNotificationPreferences(ctx context.Context, req *pb.UpdateNotificationPreferencesRequest) (*pb.UpdateNotificationPreferencesResponse, error) {
+	res, err := s.stg.Notification().UpdateNotificationPreferences(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) SavePushSubscription(ctx context.Context, req *pb.SavePushSubscriptionRequest) (*pb.SavePushSubscriptionResponse, error) {
+	res, err := s.stg.Notification().SavePushSubscription(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	"learning-service/quiz"
+	"learning-service/storage"
+)
+
+// prepareQuiz fills in defaults and validates every question before the quiz
+// is stored. A request in the old single-question shape becomes a quiz with
+// one single-choice question.
+func prepareQuiz(req *pb.CreateQuizRequest) error {
+	if len(req.Questions) == 0 && req.Question != "" {
+		req.Questions = []*pb.QuizQuestion{{
+			Type:    models.QuestionSingleChoice,
+			Prompt:  req.Question,
+			Options: splitOptions(req.Options),
+			Answer:  []string{req.Answer},
+			Points:  1,
+		}}
+	}
+	if req.Title == "" {
+		req.Title = req.Question
+	}
+	if req.Xp <= 0 {
+		req.Xp = 10
+	}
+	if req.TimeLimitSeconds < 0 || req.MaxAttempts < 0 {
+		return errors.New("time limit and max attempts cannot be negative")
+	}
+	if len(req.Rules) > 0 {
+		if len(req.Questions) > 0 {
+			return errors.New("a quiz has either questions or template rules, not both")
+		}
+		return prepareRules(req.Rules)
+	}
+	return prepareQuestions(req.Questions, true)
+}
+
+func prepareRules(rules []*pb.QuizTemplateRule) error {
+	for i, r := range rules {
+		if r.Count <= 0 {
+			return fmt.Errorf("template rule %d: count must be positive", i+1)
+		}
+	}
+	return nil
+}
+
+func prepareQuestions(questions []*pb.QuizQuestion, required bool) error {
+	if required && len(questions) == 0 {
+		return errors.New("a quiz needs at least one question")
+	}
+	for _, q := range questions {
+		if q.Points == 0 {
+			q.Points = 1
+		}
+		question, key := questionFromPb(q)
+		if err := quiz.Validate(question, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitOptions(options string) []string {
+	var list []string
+	for _, o := range strings.Split(options, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+func questionFromPb(q *pb.QuizQuestion) (*models.QuizQuestion, *models.AnswerKey) {
+	question := &models.QuizQuestion{
+		Type:    q.Type,
+		Prompt:  q.Prompt,
+		Options: q.Options,
+		Points:  float64(q.Points),
+	}
+	key := &models.AnswerKey{
+		Answer:      q.Answer,
+		Tolerance:   float64(q.Tolerance),
+		Explanation: q.Explanation,
+	}
+	return question, key
+}
+
+func questionToPb(q *models.QuizQuestion) *pb.QuizQuestion {
+	return &pb.QuizQuestion{
+		Id:         q.Id,
+		Position:   q.Position,
+		Type:       q.Type,
+		Prompt:     q.Prompt,
+		Options:    q.Options,
+		Points:     float32(q.Points),
+		Difficulty: q.Difficulty,
+	}
+}
+
+func questionIds(questions []*models.QuizQuestion) []string {
+	ids := make([]string, len(questions))
+	for i, q := range questions {
+		ids[i] = q.Id
+	}
+	return ids
+}
+
+// drawPaper decides which questions an attempt gets: a template draws them
+// from the bank, any other quiz uses its own. The seed is stored with the
+// attempt, so the same paper can be drawn again from the same bank.
+func (s *LearningService) drawPaper(ctx context.Context, qz *models.Quiz, seed int64) ([]*models.QuizQuestion, error) {
+	var paper []*models.QuizQuestion
+	if len(qz.Rules) == 0 {
+		questions, err := s.stg.Quiz().GetQuizQuestions(ctx, qz.Id)
+		if err != nil {
+			return nil, err
+		}
+		paper = questions
+	} else {
+		pools := make([][]*models.QuizQuestion, len(qz.Rules))
+		for i, rule := range qz.Rules {
+			pool, err := s.stg.Quiz().FindBankQuestions(ctx, rule)
+			if err != nil {
+				return nil, err
+			}
+			pools[i] = pool
+		}
+		drawn, err := quiz.Draw(seed, qz.Rules, pools)
+		if err != nil {
+			return nil, err
+		}
+		paper = drawn
+	}
+	if qz.Shuffle || len(qz.Rules) > 0 {
+		quiz.ShuffleOptions(seed, paper)
+	}
+	return paper, nil
+}
+
+func (s *LearningService) startAttempt(ctx context.Context, qz *models.Quiz, userId string) (*models.QuizAttempt, error) {
+	if qz.TopicId != "" {
+		if err := s.checkUnlocked(ctx, s.stg, userId, qz.TopicId); err != nil {
+			return nil, err
+		}
+	}
+	seed := rand.Int63()
+	paper, err := s.drawPaper(ctx, qz, seed)
+	if err != nil {
+		return nil, err
+	}
+	return s.stg.Quiz().StartQuizAttempt(ctx, qz, userId, seed, paper)
+}
+
+func (s *LearningService) StartQuizAttempt(ctx context.Context, req *pb.StartQuizAttemptRequest) (*pb.StartQuizAttemptResponse, error) {
+	qz, err := s.stg.Quiz().GetQuiz(ctx, req.QuizId)
+	if err != nil {
+		return nil, err
+	}
+	attempt, err := s.startAttempt(ctx, qz, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.stg.Quiz().GetAttemptQuestions(ctx, attempt.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.StartQuizAttemptResponse{
+		Attempt:   attemptToPb(attempt),
+		Questions: make([]*pb.QuizQuestion, 0, len(questions)),
+	}
+	for _, q := range questions {
+		res.Questions = append(res.Questions, questionToPb(q))
+	}
+	return res, nil
+}
+
+// SubmitQuiz grades and closes an attempt. Without an attempt id a new
+// attempt is started and closed at once, which is how single-question
+// quizzes are answered.
+func (s *LearningService) SubmitQuiz(ctx context.Context, req *pb.SubmitQuizRequest) (*pb.SubmitQuizResponse, error) {
+	var attempt *models.QuizAttempt
+	var err error
+	if req.AttemptId != "" {
+		attempt, err = s.stg.Quiz().GetQuizAttempt(ctx, req.AttemptId, req.UserId)
+		if err != nil {
+			return nil, err
+		}
+		if req.QuizId != "" && req.QuizId != attempt.QuizId {
+			return nil, errors.New("attempt does not belong to this quiz")
+		}
+		req.QuizId = attempt.QuizId
+	}
+
+	qz, err := s.stg.Quiz().GetQuiz(ctx, req.QuizId)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		attempt, err = s.startAttempt(ctx, qz, req.UserId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	questions, err := s.stg.Quiz().GetAttemptQuestions(ctx, attempt.Id)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.stg.Quiz().GetAnswerKeys(ctx, questionIds(questions))
+	if err != nil {
+		return nil, err
+	}
+
+	submitted := map[string][]string{}
+	for _, a := range req.Answers {
+		submitted[a.QuestionId] = a.Values
+	}
+	if len(req.Answers) == 0 && req.Answer != "" && len(questions) > 0 {
+		submitted[questions[0].Id] = []string{req.Answer}
+	}
+
+	now := time.Now()
+	result := &models.QuizAttemptResult{Attempt: attempt, Status: models.AttemptFinished}
+	attempt.MaxScore = quiz.MaxScore(questions)
+	attempt.Score = 0
+	expired := attempt.Expired(now)
+	if expired {
+		result.Status = models.AttemptExpired
+	}
+
+	res := &pb.SubmitQuizResponse{AttemptId: attempt.Id}
+	for _, q := range questions {
+		answer := &models.GradedAnswer{QuestionId: q.Id, Values: submitted[q.Id]}
+		if !expired {
+			answer.Points, answer.Correct = quiz.Grade(q, keys[q.Id], answer.Values, qz.PartialCredit)
+		}
+		attempt.Score += answer.Points
+		result.Answers = append(result.Answers, answer)
+		res.Results = append(res.Results, &pb.QuizAnswerResult{
+			QuestionId: q.Id,
+			Points:     float32(answer.Points),
+			Correct:    answer.Correct,
+		})
+	}
+	result.Xp = quiz.Xp(qz.Xp, attempt.Score, attempt.MaxScore)
+
+	var xp int32
+	err = s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		var err error
+		if xp, err = tx.Quiz().FinishQuizAttempt(ctx, result); err != nil {
+			return err
+		}
+		if !expired {
+			if err := scheduleReviews(ctx, tx, req.UserId, result.Answers, now); err != nil {
+				return err
+			}
+		}
+		return recordActivity(ctx, tx, req.UserId, xp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(req.UserId, xp, models.XpSourceQuiz)
+	s.activityRecorded(req.UserId, models.XpSourceQuiz, attempt.Id)
+
+	res.Message = "success"
+	if expired {
+		res.Message = "time limit exceeded"
+	}
+	res.XpEarned = xp
+	res.Score = float32(attempt.Score)
+	res.MaxScore = float32(attempt.MaxScore)
+	res.TimeSpentSeconds = int32(now.Sub(attempt.StartedAt).Seconds())
+	return res, nil
+}
+
+func (s *LearningService) GetQuizAttempts(ctx context.Context, req *pb.GetQuizAttemptsRequest) (*pb.GetQuizAttemptsResponse, error) {
+	if req.UserId == "" || req.QuizId == "" {
+		return nil, errors.New("user_id and quiz_id are required")
+	}
+	attempts, result, err := s.stg.Quiz().GetQuizAttempts(ctx, req.QuizId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetQuizAttemptsResponse{
+		BestScore: float32(result.BestScore),
+		MaxScore:  float32(result.MaxScore),
+		XpEarned:  result.XpAwarded,
+	}
+	for _, a := range attempts {
+		res.Attempts = append(res.Attempts, attemptToPb(a))
+	}
+	return res, nil
+}
+
+// GetQuizReview shows a closed attempt question by question: the user's
+// answer, the correct one and why. Keys are never shown while the attempt is
+// still open, nor while the user has attempts left and has not passed.
+func (s *LearningService) GetQuizReview(ctx context.Context, req *pb.GetQuizReviewRequest) (*pb.GetQuizReviewResponse, error) {
+	attempt, err := s.stg.Quiz().GetQuizAttempt(ctx, req.AttemptId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if attempt.Status == models.AttemptInProgress && !attempt.Expired(time.Now()) {
+		return nil, storage.ErrAttemptOpen
+	}
+	// A quiz that is no longer published cannot be retaken.
+	reveal := true
+	qz, err := s.stg.Quiz().GetQuiz(ctx, attempt.QuizId)
+	switch {
+	case err == nil:
+		attempts, result, err := s.stg.Quiz().GetQuizAttempts(ctx, attempt.QuizId, req.UserId)
+		if err != nil {
+			return nil, err
+		}
+		reveal = quiz.RevealKeys(qz.MaxAttempts, len(attempts), result)
+	case !errors.Is(err, storage.ErrQuizNotFound):
+		return nil, err
+	}
+
+	questions, err := s.stg.Quiz().GetAttemptQuestions(ctx, attempt.Id)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]*models.AnswerKey{}
+	if reveal {
+		keys, err = s.stg.Quiz().GetAnswerKeys(ctx, questionIds(questions))
+		if err != nil {
+			return nil, err
+		}
+	}
+	answers, err := s.stg.Quiz().GetAttemptAnswers(ctx, attempt.Id)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.stg.Quiz().GetQuestionResources(ctx, questionIds(questions))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetQuizReviewResponse{Attempt: attemptToPb(attempt)}
+	for _, q := range questions {
+		item := &pb.QuizReviewItem{
+			Question:  questionToPb(q),
+			Resources: resources[q.Id],
+		}
+		if key, ok := keys[q.Id]; ok {
+			item.CorrectAnswer = key.Answer
+			item.Explanation = key.Explanation
+		}
+		if a, ok := answers[q.Id]; ok {
+			item.Answer = a.Values
+			item.Points = float32(a.Points)
+			item.Correct = a.Correct
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, nil
+}
+
+func (s *LearningService) CreateBankQuestion(ctx context.Context, req *pb.CreateBankQuestionRequest) (*pb.CreateBankQuestionResponse, error) {
+	if req.Question == nil {
+		return nil, errors.New("question is required")
+	}
+	if err := prepareQuestions([]*pb.QuizQuestion{req.Question}, true); err != nil {
+		return nil, err
+	}
+	res, err := s.stg.Quiz().CreateBankQuestion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetBankQuestions(ctx context.Context, req *pb.GetBankQuestionsRequest) (*pb.GetBankQuestionsResponse, error) {
+	res, err := s.stg.Quiz().GetBankQuestions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) DeleteBankQuestion(ctx context.Context, req *pb.DeleteBankQuestionRequest) (*pb.DeleteBankQuestionResponse, error) {
+	res, err := s.stg.Quiz().DeleteBankQuestion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func attemptToPb(a *models.QuizAttempt) *pb.QuizAttempt {
+	res := &pb.QuizAttempt{
+		Id:        a.Id,
+		QuizId:    a.QuizId,
+		UserId:    a.UserId,
+		Status:    a.Status,
+		StartedAt: a.StartedAt.Format(time.RFC3339),
+		Score:     float32(a.Score),
+		MaxScore:  float32(a.MaxScore),
+	}
+	if a.ExpiresAt != nil {
+		res.ExpiresAt = a.ExpiresAt.Format(time.RFC3339)
+	}
+	if a.FinishedAt != nil {
+		res.FinishedAt = a.FinishedAt.Format(time.RFC3339)
+	}
+	return res
+}
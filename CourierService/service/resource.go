@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	"learning-service/resource"
+	"learning-service/storage"
+)
+
+const (
+	linkCheckBatch   = 50
+	linkCheckTimeout = 10 * time.Second
+)
+
+func resourceFromPb(typ, url, fileId string, m *pb.ResourceMetadata) *models.ExtraResource {
+	r := &models.ExtraResource{Type: typ, Url: url, FileId: fileId}
+	if m != nil {
+		r.Metadata = models.ResourceMetadata{
+			Author:          m.Author,
+			DurationMinutes: m.DurationMinutes,
+			Isbn:            m.Isbn,
+			Publisher:       m.Publisher,
+			Pages:           m.Pages,
+			Provider:        m.Provider,
+			Episode:         m.Episode,
+			PublishedOn:     m.PublishedOn,
+		}
+	}
+	return r
+}
+
+func metadataToPb(m models.ResourceMetadata) *pb.ResourceMetadata {
+	return &pb.ResourceMetadata{
+		Author:          m.Author,
+		DurationMinutes: m.DurationMinutes,
+		Isbn:            m.Isbn,
+		Publisher:       m.Publisher,
+		Pages:           m.Pages,
+		Provider:        m.Provider,
+		Episode:         m.Episode,
+		PublishedOn:     m.PublishedOn,
+	}
+}
+
+// checkResource validates and normalizes a resource, which covers at least
+// one topic and points at a url or a ready resource upload.
+func checkResource(ctx context.Context, stg storage.InitRoot, r *models.ExtraResource, topicIds []string) error {
+	if err := resource.Validate(r); err != nil {
+		return err
+	}
+	if len(topicIds) == 0 {
+		return errors.New("a resource needs at least one topic")
+	}
+	return resourceFile(ctx, stg, r.FileId)
+}
+
+func prepareResource(ctx context.Context, stg storage.InitRoot, req *pb.CreateExtraResoursesRequest) error {
+	r := resourceFromPb(req.Type, req.Url, req.FileId, req.Metadata)
+	if err := checkResource(ctx, stg, r, req.TopicIds); err != nil {
+		return err
+	}
+	req.Type, req.Url, req.Metadata = r.Type, r.Url, metadataToPb(r.Metadata)
+	return nil
+}
+
+// LinkChecker checks resource urls in the background, those never checked
+// first, and each again after recheckAfter.
+type LinkChecker struct {
+	stg          storage.InitRoot
+	client       *http.Client
+	interval     time.Duration
+	recheckAfter time.Duration
+}
+
+func NewLinkChecker(stg storage.InitRoot, interval, recheckAfter time.Duration) *LinkChecker {
+	return &LinkChecker{stg: stg, client: resource.NewLinkClient(linkCheckTimeout), interval: interval, recheckAfter: recheckAfter}
+}
+
+func (c *LinkChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *LinkChecker) check(ctx context.Context) {
+	for {
+		links, err := c.stg.Learning().ClaimResourceLinks(ctx, c.recheckAfter, linkCheckBatch)
+		if err != nil {
+			log.Println("resource: claim links: ", err)
+			return
+		}
+		for _, l := range links {
+			status, code := resource.CheckLink(ctx, c.client, l.Url)
+			if err := c.stg.Learning().SaveLinkCheck(ctx, l, status, code); err != nil {
+				log.Println("resource: save link check: ", err)
+				return
+			}
+		}
+		if len(links) < linkCheckBatch || ctx.Err() != nil {
+			return
+		}
+	}
+}
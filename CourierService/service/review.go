@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	"learning-service/quiz"
+	"learning-service/review"
+	"learning-service/storage"
+)
+
+// reviewXp is paid for each card recalled when it comes due.
+const reviewXp = 2
+
+const (
+	defaultReviewLimit = 20
+	maxReviewLimit     = 100
+)
+
+// scheduleReviews feeds the answers of a finished quiz attempt to the
+// user's review queue, adding the questions answered for the first time.
+func scheduleReviews(ctx context.Context, tx storage.InitRoot, userId string, answers []*models.GradedAnswer, now time.Time) error {
+	ids := make([]string, len(answers))
+	for i, a := range answers {
+		ids[i] = a.QuestionId
+	}
+	cards, err := tx.Review().GetCards(ctx, userId, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range answers {
+		card, ok := cards[a.QuestionId]
+		if !ok {
+			card = review.NewCard(userId, a.QuestionId, now)
+		}
+		grade := review.QuizGrade(a)
+		review.Schedule(card, grade, now)
+		if err := tx.Review().SaveReview(ctx, card, grade, models.ReviewSourceQuiz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LearningService) GetDueReviews(ctx context.Context, req *pb.GetDueReviewsRequest) (*pb.GetDueReviewsResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	if req.Limit < 0 || req.Limit > maxReviewLimit {
+		return nil, errors.New("limit must be between 1 and 100")
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultReviewLimit
+	}
+	cards, total, next, err := s.stg.Review().GetDueCards(ctx, req.UserId, time.Now(), req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetDueReviewsResponse{TotalDue: total}
+	if !next.IsZero() {
+		res.NextDueAt = next.Format(time.RFC3339)
+	}
+	for _, c := range cards {
+		res.Reviews = append(res.Reviews, &pb.ReviewItem{
+			QuestionId:   c.QuestionId,
+			Question:     questionToPb(c.Question),
+			DueAt:        c.DueAt.Format(time.RFC3339),
+			IntervalDays: c.IntervalDays,
+			Repetitions:  c.Repetitions,
+			Lapses:       c.Lapses,
+		})
+	}
+	return res, nil
+}
+
+// AnswerReview grades an answer to a card in the user's queue and schedules
+// its next review. Cards may be reviewed ahead of time, but only recalling a
+// card that is due earns XP.
+func (s *LearningService) AnswerReview(ctx context.Context, req *pb.AnswerReviewRequest) (*pb.AnswerReviewResponse, error) {
+	if req.UserId == "" || req.QuestionId == "" {
+		return nil, errors.New("user_id and question_id are required")
+	}
+	if _, err := review.Grade(&models.GradedAnswer{}, req.Rating); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := &pb.AnswerReviewResponse{QuestionId: req.QuestionId}
+	err := s.stg.WithTx(ctx, func(tx storage.InitRoot) error {
+		cards, err := tx.Review().GetCards(ctx, req.UserId, []string{req.QuestionId})
+		if err != nil {
+			return err
+		}
+		card, ok := cards[req.QuestionId]
+		if !ok {
+			return storage.ErrReviewCardNotFound
+		}
+		keys, err := tx.Quiz().GetAnswerKeys(ctx, []string{req.QuestionId})
+		if err != nil {
+			return err
+		}
+		key := keys[req.QuestionId]
+
+		answer := &models.GradedAnswer{QuestionId: req.QuestionId, Values: req.Values}
+		answer.Points, answer.Correct = quiz.Grade(card.Question, key, req.Values, true)
+		grade, err := review.Grade(answer, req.Rating)
+		if err != nil {
+			return err
+		}
+		due, dueAt := review.Due(card, now), card.DueAt
+		review.Schedule(card, grade, now)
+		if err := tx.Review().SaveReview(ctx, card, grade, models.ReviewSourceReview); err != nil {
+			return err
+		}
+
+		if due && grade >= review.PassingGrade {
+			entry := &models.XpEntry{
+				UserId:     req.UserId,
+				SourceType: models.XpSourceReview,
+				SourceId:   req.QuestionId + "@" + dueAt.Format(time.RFC3339),
+				Amount:     reviewXp,
+				Reason:     "review recalled",
+			}
+			if res.XpEarned, err = tx.Xp().Award(ctx, entry); err != nil {
+				return err
+			}
+		}
+
+		res.Correct = answer.Correct
+		res.Points = float32(answer.Points)
+		if key != nil {
+			res.CorrectAnswer = key.Answer
+			res.Explanation = key.Explanation
+		}
+		res.IntervalDays = card.IntervalDays
+		res.NextDueAt = card.DueAt.Format(time.RFC3339)
+		return recordReview(ctx, tx, req.UserId, res.XpEarned)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(req.UserId, res.XpEarned, models.XpSourceReview)
+	s.activityRecorded(req.UserId, models.XpSourceReview, req.QuestionId)
+	res.Message = "success"
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	pb "learning-service/genproto/learning"
+)
+
+const maxSearchLength = 200
+
+var searchTypes = map[string]bool{
+	"topic":          true,
+	"quiz":           true,
+	"extra_resource": true,
+	"homework":       true,
+}
+
+// Search looks up topics, quizzes, extra resources and, for the user given,
+// their homeworks, best matches first.
+func (s *LearningService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, errors.New("q is required")
+	}
+	if utf8.RuneCountInString(req.Query) > maxSearchLength {
+		return nil, errors.New("q is too long")
+	}
+	for _, t := range strings.Split(req.Types, ",") {
+		if t = strings.TrimSpace(t); t != "" && !searchTypes[t] {
+			return nil, errors.New("type must be topic, quiz, extra_resource or homework")
+		}
+	}
+	res, err := s.stg.Search().Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
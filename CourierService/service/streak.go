@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/kafka"
+	"learning-service/models"
+	"learning-service/storage"
+)
+
+// streakDays is how many days of activity GetStreak shows.
+const streakDays = 7
+
+const maxDailyGoal = 1000
+
+func (s *LearningService) GetStreak(ctx context.Context, req *pb.GetStreakRequest) (*pb.GetStreakResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	p, err := s.stg.Profile().GetProfile(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	today := p.Day(time.Now())
+	from := today.AddDate(0, 0, -(streakDays - 1))
+	activity, err := s.stg.Profile().GetActivity(ctx, req.UserId, from, today)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetStreakResponse{
+		UserId:        p.UserId,
+		CurrentStreak: p.StreakOn(today),
+		LongestStreak: p.LongestStreak,
+		StreakFreezes: p.StreakFreezes,
+		AtRisk:        p.AtRisk(today),
+		DailyGoal:     p.DailyGoal,
+		Timezone:      p.Timezone,
+	}
+	if !p.LastActiveOn.IsZero() {
+		res.LastActiveOn = p.LastActiveOn.Format(time.DateOnly)
+	}
+
+	byDay := make(map[time.Time]*models.DailyActivity, len(activity))
+	for _, a := range activity {
+		byDay[a.Day] = a
+	}
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		d := &pb.StreakDay{Date: day.Format(time.DateOnly)}
+		if a, ok := byDay[day]; ok {
+			d.Xp = a.Xp
+			d.Activities = a.Activities
+			d.Reviews = a.Reviews
+		}
+		d.GoalMet = d.Xp >= p.DailyGoal
+		res.Days = append(res.Days, d)
+	}
+	last := res.Days[len(res.Days)-1]
+	res.XpToday = last.Xp
+	res.GoalMet = last.GoalMet
+	return res, nil
+}
+
+func (s *LearningService) SetDailyGoal(ctx context.Context, req *pb.SetDailyGoalRequest) (*pb.SetDailyGoalResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	if req.DailyGoal < 0 || req.DailyGoal > maxDailyGoal {
+		return nil, errors.New("daily_goal must be between 1 and 1000, or 0 to keep the current goal")
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return nil, errors.New("invalid timezone")
+		}
+	}
+	return s.stg.Profile().SetDailyGoal(ctx, req)
+}
+
+func (s *LearningService) BuyStreakFreeze(ctx context.Context, req *pb.BuyStreakFreezeRequest) (*pb.BuyStreakFreezeResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	p, err := s.stg.Profile().BuyStreakFreeze(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	s.xpChanged(req.UserId, -models.StreakFreezeCost, models.XpSourceStreakFreeze)
+	return &pb.BuyStreakFreezeResponse{Message: "success", StreakFreezes: p.StreakFreezes, Xp: p.Xp}, nil
+}
+
+// recordActivity counts learning activity towards the user's streak, in the
+// unit of work of the write that caused it.
+func recordActivity(ctx context.Context, tx storage.InitRoot, userId string, xp int32) error {
+	return tx.Profile().RecordActivity(ctx, userId, xp, 0, time.Now())
+}
+
+// recordReview counts a review answer towards the user's streak and daily
+// review count.
+func recordReview(ctx context.Context, tx storage.InitRoot, userId string, xp int32) error {
+	return tx.Profile().RecordActivity(ctx, userId, xp, 1, time.Now())
+}
+
+const streakBatch = 100
+
+// StreakWatcher publishes models.EventStreakAtRisk for users whose streak
+// ends with their day unless they come back, once it is hour o'clock where
+// they are. Each user is warned at most once a day.
+type StreakWatcher struct {
+	stg      storage.InitRoot
+	kaf      kafka.KafkaProducer
+	interval time.Duration
+	hour     int32
+}
+
+func NewStreakWatcher(stg storage.InitRoot, kaf kafka.KafkaProducer, interval time.Duration, hour int32) *StreakWatcher {
+	return &StreakWatcher{stg: stg, kaf: kaf, interval: interval, hour: hour}
+}
+
+func (w *StreakWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *StreakWatcher) check(ctx context.Context) {
+	profiles, err := w.stg.Profile().ClaimStreaksAtRisk(ctx, w.hour, streakBatch)
+	if err != nil {
+		log.Println("streak: claim streaks at risk: ", err)
+		return
+	}
+	for _, p := range profiles {
+		kafka.Publish(w.kaf, models.EventStreakAtRisk, models.StreakAtRiskEvent{
+			UserId:        p.UserId,
+			CurrentStreak: p.CurrentStreak,
+			StreakFreezes: p.StreakFreezes,
+			Day:           p.Day(time.Now()).Format(time.DateOnly),
+		})
+	}
+}
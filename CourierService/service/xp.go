@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "learning-service/genproto/learning"
+)
+
+// GetXp is the one place XP, level and streaks are read from.
+func (s *LearningService) GetXp(ctx context.Context, req *pb.GetXpRequest) (*pb.GetXpResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	p, err := s.stg.Profile().GetProfile(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetXpResponse{
+		UserId:        p.UserId,
+		Xp:            p.Xp,
+		Level:         p.Level,
+		CurrentStreak: p.CurrentStreak,
+		LongestStreak: p.LongestStreak,
+	}
+	if !p.LastActiveOn.IsZero() {
+		res.LastActiveOn = p.LastActiveOn.Format(time.DateOnly)
+	}
+	return res, nil
+}
+
+func (s *LearningService) GetXpHistory(ctx context.Context, req *pb.GetXpHistoryRequest) (*pb.GetXpHistoryResponse, error) {
+	if req.UserId == "" {
+		return nil, errors.New("user_id is required")
+	}
+	res, err := s.stg.Xp().GetXpHistory(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *LearningService) ReconcileXp(ctx context.Context, req *pb.ReconcileXpRequest) (*pb.ReconcileXpResponse, error) {
+	res, err := s.stg.Xp().ReconcileXp(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
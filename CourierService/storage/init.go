@@ -1,47 +1,290 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	pb "learning-service/genproto/learning"
+	"learning-service/models"
+)
+
+var (
+	ErrQuizNotFound    = errors.New("quiz not found")
+	ErrQuizAttempted   = errors.New("quiz questions cannot change once it has been attempted")
+	ErrAttemptNotFound = errors.New("quiz attempt not found")
+	ErrAttemptClosed   = errors.New("quiz attempt is already closed")
+	ErrAttemptOpen     = errors.New("quiz attempt is still in progress")
+	ErrNoAttemptsLeft  = errors.New("no attempts left for this quiz")
+
+	ErrAlreadyCompleted = errors.New("already completed")
+	ErrProfileNotFound  = errors.New("learning profile not found")
+
+	ErrNotEnoughXp       = errors.New("not enough xp")
+	ErrStreakFreezeLimit = errors.New("streak freeze limit reached")
+
+	ErrCourseNotFound    = errors.New("course not found")
+	ErrModuleNotFound    = errors.New("course module not found")
+	ErrTopicNotFound     = errors.New("topic not found")
+	ErrPrerequisiteCycle = errors.New("prerequisites would form a cycle")
+	ErrTopicLocked       = errors.New("topic is locked until its prerequisites are completed")
+
+	ErrReviewCardNotFound = errors.New("question is not in your review queue")
+
+	ErrHomeworkNotFound    = errors.New("homework not found")
+	ErrHomeworkNotAssigned = errors.New("homework is not assigned to this user")
+	ErrNoSubmissionsLeft   = errors.New("no submissions left for this homework")
+	ErrSubmissionNotFound  = errors.New("homework submission not found")
+	ErrSubmissionClosed    = errors.New("homework submission has already been reviewed")
+	ErrGroupNotFound       = errors.New("study group not found")
+
+	ErrFileNotFound = errors.New("file not found")
+
+	ErrResourceNotFound = errors.New("extra resource not found")
+	ErrFeedbackNotFound = errors.New("feedback not found")
+	ErrRevisionNotFound = errors.New("content revision not found")
 )
 
 type InitRoot interface {
 	Learning() Learning
+	Notification() Notification
+	Quiz() Quiz
+	Xp() Xp
+	Profile() Profile
+	Achievement() Achievement
+	Course() Course
+	Recommendation() Recommendation
+	Review() Review
+	Homework() Homework
+	File() File
+	Search() Search
+	Content() Content
+
+	// WithTx runs fn as one unit of work: the repositories fn is given share
+	// a transaction, committed if fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(tx InitRoot) error) error
 }
 
 type Learning interface {
-	CreateLearningTopic(request *pb.CreateLearningTopicRequest) (*pb.CreateLearningTopicResponse, error)
-	GetLearningTopics(request *pb.GetLearningTopicsRequest) (*pb.GetLearningTopicsResponse, error)
-	UpdateLearningTopic(request *pb.UpdateLearningTopicRequest) (*pb.UpdateLearningTopicResponse, error)
-	DeleteLearningTopic(request *pb.DeleteLearningTopicRequest) (*pb.DeleteLearningTopicResponse, error)
+	CreateLearningTopic(ctx context.Context, request *pb.CreateLearningTopicRequest) (*pb.CreateLearningTopicResponse, error)
+	GetLearningTopics(ctx context.Context, request *pb.GetLearningTopicsRequest) (*pb.GetLearningTopicsResponse, error)
+	UpdateLearningTopic(ctx context.Context, request *pb.UpdateLearningTopicRequest) (*pb.UpdateLearningTopicResponse, error)
+	DeleteLearningTopic(ctx context.Context, request *pb.DeleteLearningTopicRequest) (*pb.DeleteLearningTopicResponse, error)
+
+	CompletedTopics(ctx context.Context, request *pb.CompletedTopicsRequest) (*pb.CompletedTopicsResponse, error)
+	GetCompletedTopics(ctx context.Context, request *pb.GetCompletedTopicsRequest) (*pb.GetCompletedTopicsResponse, error)
+
+	CreateQuiz(ctx context.Context, request *pb.CreateQuizRequest) (*pb.CreateQuizResponse, error)
+	GetQuiz(ctx context.Context, request *pb.GetQuizRequest) (*pb.GetQuizResponse, error)
+	UpdateQuiz(ctx context.Context, request *pb.UpdateQuizRequest) (*pb.UpdateQuizResponse, error)
+	DeleteQuiz(ctx context.Context, request *pb.DeleteQuizRequest) (*pb.DeleteQuizResponse, error)
+
+	CreateExtraResourses(ctx context.Context, request *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error)
+	GetExtraResourses(ctx context.Context, request *pb.GetExtraResourcesRequest) (*pb.GetExtraResourcesResponse, error)
+	UpdateExtraResourses(ctx context.Context, request *pb.UpdateExtraResoursesRequest) (*pb.UpdateExtraResoursesResponse, error)
+	DeleteExtraResourses(ctx context.Context, request *pb.DeleteExtraResoursesRequest) (*pb.DeleteExtraResoursesResponse, error)
+
+	CompletedExtraResources(ctx context.Context, request *pb.CompletedExtraResourcesRequest) (*pb.CompletedExtraResourcesResponse, error)
+
+	ClaimResourceLinks(ctx context.Context, recheckAfter time.Duration, limit int) ([]*models.ResourceLink, error)
+	SaveLinkCheck(ctx context.Context, link *models.ResourceLink, status string, code int32) error
+
+	GetLearningProgress(ctx context.Context, request *pb.GetLearningProgressRequest) (*pb.GetLearningProgressResponse, error)
+
+	CreateLearningRecommendations(ctx context.Context, request *pb.CreateLearningRecommendationsRequest) (*pb.CreateLearningRecommendationsResponse, error)
+	GetLearningRecommendations(ctx context.Context, request *pb.GetLearningRecommendationsRequest) (*pb.GetLearningRecommendationsResponse, error)
+
+	CreateLearningFeedback(ctx context.Context, request *pb.CreateLearningFeedbackRequest, status, reason string) (*pb.CreateLearningFeedbackResponse, error)
+	GetLearningFeedback(ctx context.Context, request *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error)
+	ModerateFeedback(ctx context.Context, request *pb.ModerateFeedbackRequest) (*models.Feedback, error)
+
+	CreateLearningHomeworks(ctx context.Context, request *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error)
+	GetLearningHomeworks(ctx context.Context, request *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error)
+
+	// MarkCommandProcessed records that the command with id was applied,
+	// and reports whether it was the first time.
+	MarkCommandProcessed(ctx context.Context, id, topic string) (bool, error)
+}
+
+// Xp is the XP ledger. Every award goes through it, so a balance can always
+// be explained by its entries.
+type Xp interface {
+	// Award books an entry and returns the XP it paid: none if its source
+	// has already paid out to the user.
+	Award(ctx context.Context, entry *models.XpEntry) (int32, error)
+	AwardXp(ctx context.Context, request *models.AwardXpCommand) (bool, error)
+	GetXpHistory(ctx context.Context, request *pb.GetXpHistoryRequest) (*pb.GetXpHistoryResponse, error)
+	ReconcileXp(ctx context.Context, request *pb.ReconcileXpRequest) (*pb.ReconcileXpResponse, error)
+}
+
+// Profile is the learning profile of each auth user: XP, level and streaks.
+// The auth service owns the user; profiles follow its user events.
+type Profile interface {
+	CreateProfile(ctx context.Context, userId string) error
+	DeleteProfile(ctx context.Context, userId string) error
+	GetProfile(ctx context.Context, userId string) (*models.LearningProfile, error)
 
-	CompletedTopics(request *pb.CompletedTopicsRequest) (*pb.CompletedTopicsResponse, error)
-	GetCompletedTopics(request *pb.GetCompletedTopicsRequest) (*pb.GetCompletedTopicsResponse, error)
+	RecordActivity(ctx context.Context, userId string, xp, reviews int32, at time.Time) error
+	GetActivity(ctx context.Context, userId string, from, to time.Time) ([]*models.DailyActivity, error)
+	SetDailyGoal(ctx context.Context, request *pb.SetDailyGoalRequest) (*pb.SetDailyGoalResponse, error)
+	BuyStreakFreeze(ctx context.Context, userId string) (*models.LearningProfile, error)
+	ClaimStreaksAtRisk(ctx context.Context, hour int32, limit int) ([]*models.LearningProfile, error)
+	// GetUserIds pages through live profiles in id order, starting after
+	// the given id.
+	GetUserIds(ctx context.Context, after string, limit int) ([]string, error)
+}
 
-	CreateQuiz(request *pb.CreateQuizRequest) (*pb.CreateQuizResponse, error)
-	GetQuiz(request *pb.GetQuizRequest) (*pb.GetQuizResponse, error)
-	UpdateQuiz(request *pb.UpdateQuizRequest) (*pb.UpdateQuizResponse, error)
-	DeleteQuiz(request *pb.DeleteQuizRequest) (*pb.DeleteQuizResponse, error)
+// Achievement holds the achievement rules and each user's progress on them.
+type Achievement interface {
+	CreateAchievement(ctx context.Context, request *pb.CreateAchievementRequest) (*pb.CreateAchievementResponse, error)
+	GetAchievements(ctx context.Context, request *pb.GetAchievementsRequest) (*pb.GetAchievementsResponse, error)
+	DeleteAchievement(ctx context.Context, request *pb.DeleteAchievementRequest) (*pb.DeleteAchievementResponse, error)
+	GetUserAchievements(ctx context.Context, request *pb.GetUserAchievementsRequest) (*pb.GetUserAchievementsResponse, error)
 
-	SubmitQuiz(request *pb.SubmitQuizRequest) (*pb.SubmitQuizResponse, error)
+	GetPendingRules(ctx context.Context, userId string, metrics []string) ([]*models.Achievement, error)
+	Measure(ctx context.Context, userId string, rule *models.Achievement) (int32, error)
+	SaveProgress(ctx context.Context, userId string, rule *models.Achievement, progress int32) (bool, error)
+	RecordGameLevel(ctx context.Context, userId, levelId string, seconds int32) error
+}
 
-	CreateExtraResourses(request *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error)
-	GetExtraResourses(request *pb.GetExtraResourcesRequest) (*pb.GetExtraResourcesResponse, error)
-	UpdateExtraResourses(request *pb.UpdateExtraResoursesRequest) (*pb.UpdateExtraResoursesResponse, error)
-	DeleteExtraResourses(request *pb.DeleteExtraResoursesRequest) (*pb.DeleteExtraResoursesResponse, error)
+// Course groups content into ordered modules. Topics may require others to
+// be completed first; those prerequisites form an acyclic graph.
+type Course interface {
+	CreateCourse(ctx context.Context, request *pb.CreateCourseRequest) (*pb.CreateCourseResponse, error)
+	GetCourses(ctx context.Context, request *pb.GetCoursesRequest) (*pb.GetCoursesResponse, error)
+	GetCourse(ctx context.Context, request *pb.GetCourseRequest) (*pb.GetCourseResponse, error)
+	UpdateCourse(ctx context.Context, request *pb.UpdateCourseRequest) (*pb.UpdateCourseResponse, error)
+	DeleteCourse(ctx context.Context, request *pb.DeleteCourseRequest) (*pb.DeleteCourseResponse, error)
 
-	CompletedExtraResources(request *pb.CompletedExtraResourcesRequest) (*pb.CompletedExtraResourcesResponse, error)
+	CreateCourseModule(ctx context.Context, request *pb.CreateCourseModuleRequest) (*pb.CreateCourseModuleResponse, error)
+	UpdateCourseModule(ctx context.Context, request *pb.UpdateCourseModuleRequest) (*pb.UpdateCourseModuleResponse, error)
+	DeleteCourseModule(ctx context.Context, request *pb.DeleteCourseModuleRequest) (*pb.DeleteCourseModuleResponse, error)
 
-	GetLearningProgress(request *pb.GetLearningProgressRequest) (*pb.GetLearningProgressResponse, error)
+	SetTopicPrerequisites(ctx context.Context, request *pb.SetTopicPrerequisitesRequest) (*pb.SetTopicPrerequisitesResponse, error)
+	GetTopicPrerequisites(ctx context.Context, request *pb.GetTopicPrerequisitesRequest) (*pb.GetTopicPrerequisitesResponse, error)
+	// MissingPrerequisites lists the prerequisites of a topic the user has
+	// not completed; the topic is locked for them until there are none.
+	MissingPrerequisites(ctx context.Context, userId, topicId string) ([]string, error)
 
-	CreateLearningRecommendations(request *pb.CreateLearningRecommendationsRequest) (*pb.CreateLearningRecommendationsResponse, error)
-	GetLearningRecommendations(request *pb.GetLearningRecommendationsRequest) (*pb.GetLearningRecommendationsResponse, error)
+	EnrollCourse(ctx context.Context, request *pb.EnrollCourseRequest) (*pb.EnrollCourseResponse, error)
+	GetEnrollments(ctx context.Context, request *pb.GetEnrollmentsRequest) (*pb.GetEnrollmentsResponse, error)
+	GetCourseProgress(ctx context.Context, request *pb.GetCourseProgressRequest) (*pb.GetCourseProgressResponse, error)
+}
 
-	CreateLearningFeedback(request *pb.CreateLearningFeedbackRequest) (*pb.CreateLearningFeedbackResponse, error)
-	GetLearningFeedback(request *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error)
+// Recommendation feeds the recommendation engine and keeps what it
+// generates for each user.
+type Recommendation interface {
+	// GetLearnerSignals loads the inputs for a user, with at most maxLevels
+	// game level candidates.
+	GetLearnerSignals(ctx context.Context, userId string, maxLevels int) (*models.LearnerSignals, error)
+	SaveRecommendations(ctx context.Context, userId string, recs []*models.Recommendation) ([]*models.Recommendation, error)
+	ClaimStaleRecommendations(ctx context.Context, maxAge time.Duration, limit int) ([]string, error)
+}
 
-	CreateLearningHomeworks(request *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error)
-	GetLearningHomeworks(request *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error)
+type Notification interface {
+	CreateNotification(ctx context.Context, notification *models.Notification, deliveries []*models.Delivery) error
+	GetNotificationSettings(ctx context.Context, userId, eventType string) (*models.NotificationSettings, error)
 
-	SubmitHomework(request *pb.SubmitHomeworkRequest) (*pb.SubmitHomeworkResponse, error)
+	GetNotifications(ctx context.Context, request *pb.GetNotificationsRequest) (*pb.GetNotificationsResponse, error)
+	MarkNotificationsRead(ctx context.Context, request *pb.MarkNotificationsReadRequest) (*pb.MarkNotificationsReadResponse, error)
 
-}
\ No newline at end of file
+	GetNotificationPreferences(ctx context.Context, request *pb.GetNotificationPreferencesRequest) (*pb.GetNotificationPreferencesResponse, error)
+	UpdateNotificationPreferences(ctx context.Context, request *pb.UpdateNotificationPreferencesRequest) (*pb.UpdateNotificationPreferencesResponse, error)
+
+	SavePushSubscription(ctx context.Context, request *pb.SavePushSubscriptionRequest) (*pb.SavePushSubscriptionResponse, error)
+	GetPushSubscriptions(ctx context.Context, userId string) ([]*models.PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, endpoint string) error
+
+	ClaimDueDeliveries(ctx context.Context, limit int) ([]*models.Delivery, error)
+	CompleteDelivery(ctx context.Context, id string) error
+	FailDelivery(ctx context.Context, id string, cause error, retryAfter time.Duration, final bool) error
+}
+
+type Quiz interface {
+	GetQuiz(ctx context.Context, id string) (*models.Quiz, error)
+	// GetQuizQuestions returns questions without their answers, which only
+	// GetAnswerKeys loads.
+	GetQuizQuestions(ctx context.Context, quizId string) ([]*models.QuizQuestion, error)
+	GetAnswerKeys(ctx context.Context, questionIds []string) (map[string]*models.AnswerKey, error)
+	GetQuestionResources(ctx context.Context, questionIds []string) (map[string][]*pb.CreateExtraResourses, error)
+
+	CreateBankQuestion(ctx context.Context, request *pb.CreateBankQuestionRequest) (*pb.CreateBankQuestionResponse, error)
+	GetBankQuestions(ctx context.Context, request *pb.GetBankQuestionsRequest) (*pb.GetBankQuestionsResponse, error)
+	DeleteBankQuestion(ctx context.Context, request *pb.DeleteBankQuestionRequest) (*pb.DeleteBankQuestionResponse, error)
+	FindBankQuestions(ctx context.Context, rule *models.QuizTemplateRule) ([]*models.QuizQuestion, error)
+
+	// StartQuizAttempt returns the user's open attempt if there is one.
+	StartQuizAttempt(ctx context.Context, quiz *models.Quiz, userId string, seed int64, paper []*models.QuizQuestion) (*models.QuizAttempt, error)
+	GetQuizAttempt(ctx context.Context, id, userId string) (*models.QuizAttempt, error)
+	GetAttemptQuestions(ctx context.Context, attemptId string) ([]*models.QuizQuestion, error)
+	// FinishQuizAttempt records the result and returns the XP paid out.
+	FinishQuizAttempt(ctx context.Context, result *models.QuizAttemptResult) (int32, error)
+	GetQuizAttempts(ctx context.Context, quizId, userId string) ([]*models.QuizAttempt, *models.QuizResult, error)
+	GetAttemptAnswers(ctx context.Context, attemptId string) (map[string]*models.GradedAnswer, error)
+}
+
+type Review interface {
+	GetCards(ctx context.Context, userId string, questionIds []string) (map[string]*models.ReviewCard, error)
+	SaveReview(ctx context.Context, card *models.ReviewCard, grade int32, source string) error
+	GetDueCards(ctx context.Context, userId string, now time.Time, limit int32) ([]*models.ReviewCard, int32, time.Time, error)
+}
+
+type Homework interface {
+	AssignHomework(ctx context.Context, request *pb.AssignHomeworkRequest) ([]string, error)
+	GetHomework(ctx context.Context, id string) (*models.Homework, error)
+	// GetAssignment and GetSubmission lock what they return for the rest of
+	// the unit of work.
+	GetAssignment(ctx context.Context, homeworkId, userId string) (*models.HomeworkAssignment, error)
+	CreateSubmission(ctx context.Context, submission *models.HomeworkSubmission, maxSubmissions int32) error
+	GetSubmission(ctx context.Context, id string) (*models.HomeworkSubmission, error)
+	GetSubmissions(ctx context.Context, request *pb.GetHomeworkSubmissionsRequest) (*pb.GetHomeworkSubmissionsResponse, error)
+	// GradeSubmission records the verdict and returns the XP paid out.
+	GradeSubmission(ctx context.Context, submission *models.HomeworkSubmission) (int32, error)
+
+	CreateStudyGroup(ctx context.Context, request *pb.CreateStudyGroupRequest) (*pb.CreateStudyGroupResponse, error)
+	SetStudyGroupMembers(ctx context.Context, request *pb.SetStudyGroupMembersRequest) (*pb.SetStudyGroupMembersResponse, error)
+	GetStudyGroup(ctx context.Context, request *pb.GetStudyGroupRequest) (*pb.GetStudyGroupResponse, error)
+}
+
+// File records the uploads kept in blob storage. Files with the same content
+// share one blob, which is queued for deletion once the last of them goes.
+type File interface {
+	CreateFile(ctx context.Context, file *models.File) error
+	GetFile(ctx context.Context, id string) (*models.File, error)
+	GetFiles(ctx context.Context, ids []string) (map[string]*models.File, error)
+	CompleteFile(ctx context.Context, id string) (*models.File, error)
+	DeleteFile(ctx context.Context, id string) error
+	// DeleteOrphanFiles deletes uploads never completed within pendingAge
+	// and ready files nothing has pointed at for orphanAge.
+	DeleteOrphanFiles(ctx context.Context, pendingAge, orphanAge time.Duration, limit int) (int, error)
+	ClaimBlobDeletions(ctx context.Context, limit int) ([]string, error)
+	BlobDeleted(ctx context.Context, key string) error
+
+	SetAvatar(ctx context.Context, userId, fileId string) error
+	GetAvatar(ctx context.Context, userId string) (*models.File, error)
+}
+
+// Search looks content up by full text, kept current by the database
+// itself as content changes.
+type Search interface {
+	Search(ctx context.Context, request *pb.SearchRequest) (*pb.SearchResponse, error)
+}
+
+// Content is the authoring workflow of topics, quizzes and extra resources:
+// where each is on its way to learners, and the revisions it went through.
+type Content interface {
+	// GetContentItem locks the item for the rest of the unit of work.
+	GetContentItem(ctx context.Context, kind, id string) (*models.ContentItem, error)
+	SetContentAuthor(ctx context.Context, kind, id, authorId string) error
+	SetContentStatus(ctx context.Context, item *models.ContentItem) error
+
+	// GetOpenRevision returns the item's draft or revision in review, or
+	// nil if there is none, locked for the rest of the unit of work.
+	GetOpenRevision(ctx context.Context, kind, id string) (*models.ContentRevision, error)
+	GetRevision(ctx context.Context, kind, id string, version int32) (*models.ContentRevision, error)
+	SaveRevision(ctx context.Context, revision *models.ContentRevision) error
+	DeleteRevisions(ctx context.Context, kind, id string) error
+
+	GetContentVersions(ctx context.Context, request *pb.GetContentVersionsRequest) (*pb.GetContentVersionsResponse, error)
+	GetContentRevisions(ctx context.Context, request *pb.GetContentRevisionsRequest) (*pb.GetContentRevisionsResponse, error)
+}
@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+
+	"github.com/lib/pq"
+)
+
+type AchievementStorage struct {
+	db conn
+}
+
+const achievementColumns = `a.id, a.code, a.name, a.description, a.metric, a.threshold, a.level_id, a.max_seconds, a.xp`
+
+func scanAchievement(row scanner, extra ...interface{}) (*models.Achievement, error) {
+	a := models.Achievement{}
+	dest := []interface{}{&a.Id, &a.Code, &a.Name, &a.Description, &a.Metric, &a.Threshold, &a.LevelId, &a.MaxSeconds, &a.Xp}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func achievementToPb(a *models.Achievement) *pb.Achievement {
+	return &pb.Achievement{
+		Id:          a.Id,
+		Code:        a.Code,
+		Name:        a.Name,
+		Description: a.Description,
+		Metric:      a.Metric,
+		Threshold:   a.Threshold,
+		LevelId:     a.LevelId,
+		MaxSeconds:  a.MaxSeconds,
+		Xp:          a.Xp,
+	}
+}
+
+func (c *AchievementStorage) CreateAchievement(ctx context.Context, req *pb.CreateAchievementRequest) (*pb.CreateAchievementResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO achievements(code, name, description, metric, threshold, level_id, max_seconds, xp)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+	var id string
+	err := c.db.QueryRowContext(ctx, query, req.Code, req.Name, req.Description, req.Metric, req.Threshold, req.LevelId, req.MaxSeconds, req.Xp).Scan(&id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.CreateAchievementResponse{Id: id, Message: "success"}, nil
+}
+
+func (c *AchievementStorage) GetAchievements(ctx context.Context, req *pb.GetAchievementsRequest) (*pb.GetAchievementsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	f := newFilter()
+	f.Where("a.deleted_at IS NULL")
+	f.In("a.metric", req.Metric)
+	query := `SELECT ` + achievementColumns + ` FROM achievements a` + f.Sql() + ` ORDER BY a.metric, a.threshold, a.code`
+	rows, err := c.db.QueryContext(ctx, query, f.Args()...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := pb.GetAchievementsResponse{}
+	for rows.Next() {
+		a, err := scanAchievement(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		res.Achievements = append(res.Achievements, achievementToPb(a))
+	}
+	return &res, rows.Err()
+}
+
+// DeleteAchievement retires a rule. Badges already awarded are kept.
+func (c *AchievementStorage) DeleteAchievement(ctx context.Context, req *pb.DeleteAchievementRequest) (*pb.DeleteAchievementResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE achievements SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+	if _, err := c.db.ExecContext(ctx, query, req.Id); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.DeleteAchievementResponse{Message: "success"}, nil
+}
+
+// GetUserAchievements lists every rule with the user's progress on it, and
+// the badges of retired rules the user holds.
+func (c *AchievementStorage) GetUserAchievements(ctx context.Context, req *pb.GetUserAchievementsRequest) (*pb.GetUserAchievementsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + achievementColumns + `, COALESCE(p.progress, 0), p.awarded_at
+		FROM achievements a
+		LEFT JOIN achievement_progress p ON p.achievement_id = a.id AND p.user_id = $1
+		WHERE (a.deleted_at IS NULL AND NOT $2) OR p.awarded_at IS NOT NULL
+		ORDER BY p.awarded_at DESC NULLS LAST, a.metric, a.threshold`
+	rows, err := c.db.QueryContext(ctx, query, req.UserId, req.AwardedOnly)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := pb.GetUserAchievementsResponse{}
+	for rows.Next() {
+		var progress int32
+		var awardedAt sql.NullTime
+		a, err := scanAchievement(rows, &progress, &awardedAt)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		ua := &pb.UserAchievement{Achievement: achievementToPb(a), Progress: progress}
+		if awardedAt.Valid {
+			ua.AwardedAt = awardedAt.Time.Format(time.RFC3339)
+		}
+		res.Achievements = append(res.Achievements, ua)
+	}
+	return &res, rows.Err()
+}
+
+// GetPendingRules returns the live rules on metrics, or on every metric if
+// none are given, that the user has not earned yet.
+func (c *AchievementStorage) GetPendingRules(ctx context.Context, userId string, metrics []string) ([]*models.Achievement, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + achievementColumns + ` FROM achievements a
+		WHERE a.deleted_at IS NULL
+		  AND (cardinality($2::text[]) = 0 OR a.metric = ANY($2))
+		  AND NOT EXISTS (
+			SELECT 1 FROM achievement_progress p
+			WHERE p.achievement_id = a.id AND p.user_id = $1 AND p.awarded_at IS NOT NULL)`
+	rows, err := c.db.QueryContext(ctx, query, userId, pq.Array(metrics))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*models.Achievement
+	for rows.Next() {
+		a, err := scanAchievement(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		rules = append(rules, a)
+	}
+	return rules, rows.Err()
+}
+
+var metricQueries = map[string]string{
+	models.MetricTopicsCompleted:    `SELECT COUNT(*) FROM completed_topics WHERE user_id = $1`,
+	models.MetricResourcesCompleted: `SELECT COUNT(*) FROM completed_extra_resources WHERE user_id = $1`,
+	models.MetricQuizzesFinished:    `SELECT COUNT(DISTINCT quiz_id) FROM quiz_attempts WHERE user_id = $1 AND status = 'finished'`,
+	models.MetricQuizzesPerfect: `
+		SELECT COUNT(DISTINCT quiz_id) FROM quiz_attempts
+		WHERE user_id = $1 AND status = 'finished' AND max_score > 0 AND score >= max_score`,
+	models.MetricHomeworksSubmitted: `SELECT COUNT(DISTINCT homework_id) FROM submitted_homeworks WHERE user_id = $1`,
+	models.MetricStreakDays:         `SELECT COALESCE((SELECT longest_streak FROM learning_profiles WHERE user_id = $1), 0)`,
+	models.MetricXp:                 `SELECT COALESCE((SELECT xp FROM learning_profiles WHERE user_id = $1), 0)`,
+}
+
+// Measure computes the user's value of a's metric from the data it counts,
+// so that evaluating a rule twice, or for a user who was active before the
+// rule existed, gives the same answer.
+func (c *AchievementStorage) Measure(ctx context.Context, userId string, a *models.Achievement) (int32, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var value int32
+	var err error
+	if a.Metric == models.MetricGameLevels {
+		query := `
+			SELECT COUNT(*) FROM game_level_completions
+			WHERE user_id = $1 AND ($2 = '' OR level_id = $2) AND ($3 = 0 OR best_seconds <= $3)`
+		err = c.db.QueryRowContext(ctx, query, userId, a.LevelId, a.MaxSeconds).Scan(&value)
+	} else {
+		query, ok := metricQueries[a.Metric]
+		if !ok {
+			return 0, fmt.Errorf("unknown metric %q", a.Metric)
+		}
+		err = c.db.QueryRowContext(ctx, query, userId).Scan(&value)
+	}
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return value, nil
+}
+
+// SaveProgress stores the user's progress on a and awards it, with its XP,
+// once the threshold is reached. It reports whether this call awarded it.
+func (c *AchievementStorage) SaveProgress(ctx context.Context, userId string, a *models.Achievement, progress int32) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO achievement_progress(user_id, achievement_id, progress) VALUES($1, $2, $3)
+		ON CONFLICT (user_id, achievement_id) DO UPDATE SET progress = EXCLUDED.progress, updated_at = now()
+		WHERE achievement_progress.awarded_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, userId, a.Id, progress); err != nil {
+		log.Println(err)
+		return false, err
+	}
+
+	awarded := false
+	if progress >= a.Threshold {
+		query := `
+			UPDATE achievement_progress SET awarded_at = now()
+			WHERE user_id = $1 AND achievement_id = $2 AND awarded_at IS NULL`
+		res, err := tx.ExecContext(ctx, query, userId, a.Id)
+		if err != nil {
+			log.Println(err)
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		awarded = n == 1
+	}
+	if awarded && a.Xp > 0 {
+		entry := &models.XpEntry{UserId: userId, SourceType: models.XpSourceAchievement, SourceId: a.Id, Amount: a.Xp, Reason: "achievement " + a.Code}
+		if _, err := awardXp(ctx, tx, entry); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return awarded, nil
+}
+
+// RecordGameLevel keeps the user's best time on a game level.
+func (c *AchievementStorage) RecordGameLevel(ctx context.Context, userId, levelId string, seconds int32) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO game_level_completions(user_id, level_id, best_seconds) VALUES($1, $2, $3)
+		ON CONFLICT (user_id, level_id) DO UPDATE
+		SET best_seconds = EXCLUDED.best_seconds, completed_at = now()
+		WHERE EXCLUDED.best_seconds < game_level_completions.best_seconds`
+	if _, err := c.db.ExecContext(ctx, query, userId, levelId, seconds); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"context"
+	"log"
+)
+
+func (c *LearningStorage) MarkCommandProcessed(ctx context.Context, id, topic string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := c.db.ExecContext(ctx, `INSERT INTO processed_commands (id, topic) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, id, topic)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,319 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+)
+
+type ContentStorage struct {
+	db conn
+}
+
+// contentTables are the tables each kind of content lives in, and what
+// not finding one of them is called.
+var contentTables = map[string]struct {
+	table    string
+	notFound error
+}{
+	models.CourseItemTopic:         {"topics", st.ErrTopicNotFound},
+	models.CourseItemQuiz:          {"quizzes", st.ErrQuizNotFound},
+	models.CourseItemExtraResource: {"extra_resources", st.ErrResourceNotFound},
+}
+
+func contentTable(kind string) (string, error) {
+	t, ok := contentTables[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown content kind %q", kind)
+	}
+	return t.table, nil
+}
+
+// GetContentItem locks the item for the rest of the unit of work. Deleted
+// topics are not found.
+func (c *ContentStorage) GetContentItem(ctx context.Context, kind, id string) (*models.ContentItem, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	table, err := contentTable(kind)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT status, COALESCE(author_id::text, ''), published_version FROM ` + table + ` WHERE id = $1`
+	if kind == models.CourseItemTopic {
+		query += ` AND deleted_at = 0`
+	}
+
+	item := models.ContentItem{Kind: kind, Id: id}
+	err = c.db.QueryRowContext(ctx, query+` FOR UPDATE`, id).Scan(&item.Status, &item.AuthorId, &item.PublishedVersion)
+	if err == sql.ErrNoRows {
+		return nil, contentTables[kind].notFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (c *ContentStorage) SetContentAuthor(ctx context.Context, kind, id, authorId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	table, err := contentTable(kind)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, `UPDATE `+table+` SET author_id = NULLIF($2, '')::uuid WHERE id = $1`, id, authorId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// SetContentStatus saves the item's status and published version.
+func (c *ContentStorage) SetContentStatus(ctx context.Context, item *models.ContentItem) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	table, err := contentTable(item.Kind)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE ` + table + ` SET status = $2, published_version = $3 WHERE id = $1`
+	res, err := c.db.ExecContext(ctx, query, item.Id, item.Status, item.PublishedVersion)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return contentTables[item.Kind].notFound
+	}
+	return nil
+}
+
+const revisionColumns = `id, kind, item_id, COALESCE(version, 0), status, content,
+	COALESCE(author_id::text, ''), COALESCE(reviewer_id::text, ''), review_comment, COALESCE(rollback_of, 0)`
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (*models.ContentRevision, error) {
+	var r models.ContentRevision
+	err := row.Scan(&r.Id, &r.Kind, &r.ItemId, &r.Version, &r.Status, &r.Content, &r.AuthorId, &r.ReviewerId, &r.Comment, &r.RollbackOf)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetOpenRevision returns the item's draft or revision in review, locked
+// for the rest of the unit of work, or nil if it has neither.
+func (c *ContentStorage) GetOpenRevision(ctx context.Context, kind, id string) (*models.ContentRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + revisionColumns + ` FROM content_revisions
+		WHERE kind = $1 AND item_id = $2 AND status IN ('draft', 'in_review') FOR UPDATE`
+	rev, err := scanRevision(c.db.QueryRowContext(ctx, query, kind, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return rev, nil
+}
+
+func (c *ContentStorage) GetRevision(ctx context.Context, kind, id string, version int32) (*models.ContentRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + revisionColumns + ` FROM content_revisions WHERE kind = $1 AND item_id = $2 AND version = $3`
+	rev, err := scanRevision(c.db.QueryRowContext(ctx, query, kind, id, version))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrRevisionNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return rev, nil
+}
+
+// SaveRevision inserts a revision without an id and updates one with an id.
+// A revision is stamped when it is submitted and when it is published.
+func (c *ContentStorage) SaveRevision(ctx context.Context, rev *models.ContentRevision) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if rev.Id == "" {
+		id := uuid.NewString()
+		query := `
+			INSERT INTO content_revisions (id, kind, item_id, version, status, content, author_id, reviewer_id, review_comment, rollback_of, published_at)
+			VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, NULLIF($7, '')::uuid, NULLIF($8, '')::uuid, $9, NULLIF($10, 0),
+				CASE WHEN $5 = 'published' THEN now() END)`
+		_, err := c.db.ExecContext(ctx, query, id, rev.Kind, rev.ItemId, rev.Version, rev.Status, string(rev.Content),
+			rev.AuthorId, rev.ReviewerId, rev.Comment, rev.RollbackOf)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		rev.Id = id
+		return nil
+	}
+
+	query := `
+		UPDATE content_revisions
+		SET version = NULLIF($2, 0), status = $3, content = $4, author_id = NULLIF($5, '')::uuid,
+			reviewer_id = NULLIF($6, '')::uuid, review_comment = $7, updated_at = now(),
+			submitted_at = CASE WHEN $3 = 'in_review' THEN now() ELSE submitted_at END,
+			published_at = CASE WHEN $3 = 'published' THEN now() END
+		WHERE id = $1`
+	res, err := c.db.ExecContext(ctx, query, rev.Id, rev.Version, rev.Status, string(rev.Content), rev.AuthorId, rev.ReviewerId, rev.Comment)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return st.ErrRevisionNotFound
+	}
+	return nil
+}
+
+func (c *ContentStorage) DeleteRevisions(ctx context.Context, kind, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `DELETE FROM content_revisions WHERE kind = $1 AND item_id = $2`, kind, id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// revisionSummary is what revision listings show: everything but the
+// content, of which only the title.
+const revisionSummary = `id, kind, item_id, COALESCE(content->>'title', content->>'name', ''), COALESCE(version, 0), status,
+	COALESCE(author_id::text, ''), COALESCE(reviewer_id::text, ''), review_comment, COALESCE(rollback_of, 0),
+	created_at, updated_at, submitted_at, published_at`
+
+func scanRevisionSummary(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*pb.ContentRevision, error) {
+	var r pb.ContentRevision
+	var createdAt, updatedAt time.Time
+	var submittedAt, publishedAt sql.NullTime
+	dest := []interface{}{&r.Id, &r.Kind, &r.ItemId, &r.Title, &r.Version, &r.Status,
+		&r.AuthorId, &r.ReviewerId, &r.Comment, &r.RollbackOf, &createdAt, &updatedAt, &submittedAt, &publishedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	r.CreatedAt = createdAt.Format(time.RFC3339)
+	r.UpdatedAt = updatedAt.Format(time.RFC3339)
+	if submittedAt.Valid {
+		r.SubmittedAt = submittedAt.Time.Format(time.RFC3339)
+	}
+	if publishedAt.Valid {
+		r.PublishedAt = publishedAt.Time.Format(time.RFC3339)
+	}
+	return &r, nil
+}
+
+// GetContentVersions lists an item's revisions, the open one first and
+// then the published ones newest first.
+func (c *ContentStorage) GetContentVersions(ctx context.Context, req *pb.GetContentVersionsRequest) (*pb.GetContentVersionsResponse, error) {
+	item, err := c.GetContentItem(ctx, req.Kind, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + revisionSummary + ` FROM content_revisions
+		WHERE kind = $1 AND item_id = $2
+		ORDER BY version DESC NULLS FIRST`
+	rows, err := c.db.QueryContext(ctx, query, req.Kind, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &pb.GetContentVersionsResponse{Status: item.Status, AuthorId: item.AuthorId, PublishedVersion: item.PublishedVersion}
+	for rows.Next() {
+		r, err := scanRevisionSummary(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		res.Versions = append(res.Versions, r)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+var revisionSorts = map[string]string{
+	"updated_at": "updated_at",
+	"created_at": "created_at",
+}
+
+// GetContentRevisions lists revisions across content, such as those
+// waiting for review or an author's drafts.
+func (c *ContentStorage) GetContentRevisions(ctx context.Context, req *pb.GetContentRevisionsRequest) (*pb.GetContentRevisionsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "updated_at", revisionSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	f.In("kind", req.Kind).In("status", req.Status).Eq("author_id", req.AuthorId).Eq("item_id", req.ItemId)
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM content_revisions`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`SELECT `+revisionSummary+`, `+p.sortKey()+` FROM content_revisions`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*pb.ContentRevision
+	var keys, ids []string
+	for rows.Next() {
+		var key string
+		r, err := scanRevisionSummary(rows, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		revisions = append(revisions, r)
+		keys = append(keys, key)
+		ids = append(ids, r.Id)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetContentRevisionsResponse{Revisions: revisions[:n], TotalCount: total, NextPageToken: token}, nil
+}
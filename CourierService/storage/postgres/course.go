@@ -0,0 +1,610 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type CourseStorage struct {
+	db conn
+}
+
+// itemTables are where the content a module item points to lives, with the
+// condition that makes it available: courses only take published content.
+var itemTables = map[string]string{
+	models.CourseItemTopic:         `SELECT 1 FROM topics WHERE id = $1 AND deleted_at = 0 AND status = 'published'`,
+	models.CourseItemQuiz:          `SELECT 1 FROM quizzes WHERE id = $1 AND status = 'published'`,
+	models.CourseItemExtraResource: `SELECT 1 FROM extra_resources WHERE id = $1 AND status = 'published'`,
+	models.CourseItemHomework:      `SELECT 1 FROM homeworks WHERE id = $1`,
+}
+
+const itemTitle = `COALESCE(CASE i.item_type
+		WHEN 'topic' THEN (SELECT name FROM topics WHERE id = i.item_id)
+		WHEN 'quiz' THEN (SELECT title FROM quizzes WHERE id = i.item_id)
+		WHEN 'extra_resource' THEN (SELECT title FROM extra_resources WHERE id = i.item_id)
+		WHEN 'homework' THEN (SELECT title FROM homeworks WHERE id = i.item_id)
+	END, '')`
+
+// itemTopic is the topic whose prerequisites lock an item: the topic itself,
+// or the one a quiz is about.
+const itemTopic = `CASE i.item_type
+		WHEN 'topic' THEN i.item_id
+		WHEN 'quiz' THEN (SELECT topic_id FROM quizzes WHERE id = i.item_id)
+	END`
+
+// itemDone tells whether user $1 is done with an item: a topic, resource or
+// homework once completed or submitted, a quiz once passed.
+const itemDone = `CASE i.item_type
+		WHEN 'topic' THEN EXISTS (SELECT 1 FROM completed_topics WHERE user_id = $1 AND topic_id = i.item_id)
+		WHEN 'quiz' THEN EXISTS (
+			SELECT 1 FROM quiz_attempts a
+			WHERE a.quiz_id = i.item_id AND a.user_id = $1 AND a.status = 'finished'
+			  AND a.max_score > 0 AND a.score >= a.max_score * $3)
+		WHEN 'extra_resource' THEN EXISTS (SELECT 1 FROM completed_extra_resources WHERE user_id = $1 AND extra_resource_id = i.item_id)
+		WHEN 'homework' THEN EXISTS (SELECT 1 FROM submitted_homeworks WHERE user_id = $1 AND homework_id = i.item_id)
+	END`
+
+func (c *CourseStorage) CreateCourse(ctx context.Context, req *pb.CreateCourseRequest) (*pb.CreateCourseResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	id := uuid.NewString()
+	query := `INSERT INTO courses(id, title, description) VALUES($1, $2, $3)`
+	if _, err := c.db.ExecContext(ctx, query, id, req.Title, req.Description); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.CreateCourseResponse{Id: id, Message: "success"}, nil
+}
+
+var courseSorts = map[string]string{
+	"created_at": "created_at",
+	"title":      "title",
+}
+
+func (c *CourseStorage) GetCourses(ctx context.Context, req *pb.GetCoursesRequest) (*pb.GetCoursesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", courseSorts)
+	if err != nil {
+		return nil, err
+	}
+	f := newFilter()
+	f.Where("deleted_at IS NULL")
+	f.Like("title", req.Title)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM courses`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`SELECT id, title, description, created_at, `+p.sortKey()+` FROM courses`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := []*pb.Course{}
+	var keys, ids []string
+	for rows.Next() {
+		var course pb.Course
+		var createdAt time.Time
+		var key string
+		if err := rows.Scan(&course.Id, &course.Title, &course.Description, &createdAt, &key); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		course.CreatedAt = createdAt.Format(time.RFC3339)
+		courses = append(courses, &course)
+		keys = append(keys, key)
+		ids = append(ids, course.Id)
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetCoursesResponse{Courses: courses[:n], TotalCount: total, NextPageToken: token}, nil
+}
+
+// GetCourse returns a course with its modules and their items, in order.
+func (c *CourseStorage) GetCourse(ctx context.Context, req *pb.GetCourseRequest) (*pb.GetCourseResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	course := pb.Course{Id: req.Id}
+	var createdAt time.Time
+	query := `SELECT title, description, created_at FROM courses WHERE id = $1 AND deleted_at IS NULL`
+	err := c.db.QueryRowContext(ctx, query, req.Id).Scan(&course.Title, &course.Description, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrCourseNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	course.CreatedAt = createdAt.Format(time.RFC3339)
+
+	rows, err := c.db.QueryContext(ctx, `SELECT id, title, position FROM course_modules WHERE course_id = $1 ORDER BY position, id`, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	byId := map[string]*pb.CourseModule{}
+	for rows.Next() {
+		m := pb.CourseModule{CourseId: req.Id}
+		if err := rows.Scan(&m.Id, &m.Title, &m.Position); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		course.Modules = append(course.Modules, &m)
+		byId[m.Id] = &m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT i.module_id, i.item_type, i.item_id, i.position, ` + itemTitle + `
+		FROM course_module_items i JOIN course_modules m ON m.id = i.module_id
+		WHERE m.course_id = $1
+		ORDER BY i.position`
+	items, err := c.db.QueryContext(ctx, query, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer items.Close()
+	for items.Next() {
+		var moduleId string
+		item := pb.CourseItem{}
+		if err := items.Scan(&moduleId, &item.Type, &item.Id, &item.Position, &item.Title); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		byId[moduleId].Items = append(byId[moduleId].Items, &item)
+	}
+	if err := items.Err(); err != nil {
+		return nil, err
+	}
+	return &pb.GetCourseResponse{Course: &course}, nil
+}
+
+func (c *CourseStorage) UpdateCourse(ctx context.Context, req *pb.UpdateCourseRequest) (*pb.UpdateCourseResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE courses SET title = $1, description = $2, updated_at = now()
+		WHERE id = $3 AND deleted_at IS NULL`
+	res, err := c.db.ExecContext(ctx, query, req.Title, req.Description, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, st.ErrCourseNotFound
+	}
+	return &pb.UpdateCourseResponse{Message: "success"}, nil
+}
+
+// DeleteCourse hides a course. Enrollments are kept, so that restoring it
+// by hand loses nothing.
+func (c *CourseStorage) DeleteCourse(ctx context.Context, req *pb.DeleteCourseRequest) (*pb.DeleteCourseResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE courses SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+	if _, err := c.db.ExecContext(ctx, query, req.Id); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.DeleteCourseResponse{Message: "success"}, nil
+}
+
+// setModuleItems replaces the items of a module with items, in that order.
+func setModuleItems(ctx context.Context, tx *txn, moduleId string, items []*pb.CourseItem) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM course_module_items WHERE module_id = $1`, moduleId); err != nil {
+		log.Println(err)
+		return err
+	}
+	for i, item := range items {
+		exists, ok := itemTables[item.Type]
+		if !ok {
+			return fmt.Errorf("unknown item type %q", item.Type)
+		}
+		var found int
+		err := tx.QueryRowContext(ctx, exists, item.Id).Scan(&found)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s %s not found", item.Type, item.Id)
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		query := `
+			INSERT INTO course_module_items(module_id, item_type, item_id, position) VALUES($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, moduleId, item.Type, item.Id, i+1); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateCourseModule adds a module to a course, at the end unless a position
+// is given.
+func (c *CourseStorage) CreateCourseModule(ctx context.Context, req *pb.CreateCourseModuleRequest) (*pb.CreateCourseModuleResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM courses WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, req.CourseId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrCourseNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	query := `
+		INSERT INTO course_modules(id, course_id, title, position)
+		SELECT $1, $2, $3, COALESCE(NULLIF($4, 0), (SELECT COALESCE(MAX(position), 0) + 1 FROM course_modules WHERE course_id = $2))`
+	if _, err := tx.ExecContext(ctx, query, id, req.CourseId, req.Title, req.Position); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := setModuleItems(ctx, tx, id, req.Items); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.CreateCourseModuleResponse{Id: id, Message: "success"}, nil
+}
+
+// UpdateCourseModule renames and moves a module and replaces its items.
+func (c *CourseStorage) UpdateCourseModule(ctx context.Context, req *pb.UpdateCourseModuleRequest) (*pb.UpdateCourseModuleResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE course_modules SET title = $1, position = COALESCE(NULLIF($2, 0), position) WHERE id = $3`
+	res, err := tx.ExecContext(ctx, query, req.Title, req.Position, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, st.ErrModuleNotFound
+	}
+	if err := setModuleItems(ctx, tx, req.Id, req.Items); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.UpdateCourseModuleResponse{Message: "success"}, nil
+}
+
+func (c *CourseStorage) DeleteCourseModule(ctx context.Context, req *pb.DeleteCourseModuleRequest) (*pb.DeleteCourseModuleResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM course_modules WHERE id = $1`, req.Id); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.DeleteCourseModuleResponse{Message: "success"}, nil
+}
+
+// SetTopicPrerequisites replaces the prerequisites of a topic. Edges are
+// changed one writer at a time, so two concurrent changes cannot together
+// close a cycle that each alone would not.
+func (c *CourseStorage) SetTopicPrerequisites(ctx context.Context, req *pb.SetTopicPrerequisitesRequest) (*pb.SetTopicPrerequisitesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('topic_prerequisites'))`); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var found int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM topics WHERE id = $1 AND deleted_at = 0`, req.TopicId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrTopicNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM topic_prerequisites WHERE topic_id = $1`, req.TopicId); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, prerequisite := range req.PrerequisiteIds {
+		if prerequisite == req.TopicId {
+			return nil, st.ErrPrerequisiteCycle
+		}
+		query := `
+			INSERT INTO topic_prerequisites(topic_id, prerequisite_id)
+			SELECT $1, id FROM topics WHERE id = $2 AND deleted_at = 0
+			ON CONFLICT DO NOTHING`
+		res, err := tx.ExecContext(ctx, query, req.TopicId, prerequisite)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			var dup bool
+			query := `SELECT EXISTS (SELECT 1 FROM topic_prerequisites WHERE topic_id = $1 AND prerequisite_id = $2)`
+			if err := tx.QueryRowContext(ctx, query, req.TopicId, prerequisite).Scan(&dup); err != nil {
+				log.Println(err)
+				return nil, err
+			}
+			if !dup {
+				return nil, fmt.Errorf("prerequisite %s: %w", prerequisite, st.ErrTopicNotFound)
+			}
+		}
+	}
+
+	// The graph was acyclic before, so a cycle now runs through this topic.
+	query := `
+		WITH RECURSIVE reach(id) AS (
+			SELECT prerequisite_id FROM topic_prerequisites WHERE topic_id = $1
+			UNION
+			SELECT p.prerequisite_id FROM topic_prerequisites p JOIN reach r ON p.topic_id = r.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reach WHERE id = $1)`
+	var cycle bool
+	if err := tx.QueryRowContext(ctx, query, req.TopicId).Scan(&cycle); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if cycle {
+		return nil, st.ErrPrerequisiteCycle
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.SetTopicPrerequisitesResponse{Message: "success"}, nil
+}
+
+func (c *CourseStorage) GetTopicPrerequisites(ctx context.Context, req *pb.GetTopicPrerequisitesRequest) (*pb.GetTopicPrerequisitesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res := pb.GetTopicPrerequisitesResponse{TopicId: req.TopicId}
+	query := `SELECT ARRAY(SELECT prerequisite_id::text FROM topic_prerequisites WHERE topic_id = $1 ORDER BY prerequisite_id)`
+	if err := c.db.QueryRowContext(ctx, query, req.TopicId).Scan(pq.Array(&res.PrerequisiteIds)); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MissingPrerequisites lists the prerequisites of a topic the user has not
+// completed yet. The topic is locked for them while there are any.
+// Prerequisites that are deleted or not published cannot be completed and
+// are not counted.
+func (c *CourseStorage) MissingPrerequisites(ctx context.Context, userId, topicId string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ARRAY(
+			SELECT p.prerequisite_id::text FROM topic_prerequisites p
+			JOIN topics pt ON pt.id = p.prerequisite_id AND pt.deleted_at = 0 AND pt.status = 'published'
+			WHERE p.topic_id = $2
+			  AND NOT EXISTS (SELECT 1 FROM completed_topics ct WHERE ct.user_id = $1 AND ct.topic_id = p.prerequisite_id)
+			ORDER BY p.prerequisite_id)`
+	var missing []string
+	if err := c.db.QueryRowContext(ctx, query, userId, topicId).Scan(pq.Array(&missing)); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return missing, nil
+}
+
+// EnrollCourse enrolls a user in a live course. Enrolling twice is a no-op.
+func (c *CourseStorage) EnrollCourse(ctx context.Context, req *pb.EnrollCourseRequest) (*pb.EnrollCourseResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO course_enrollments(user_id, course_id)
+		SELECT $1, id FROM courses WHERE id = $2 AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING
+		RETURNING course_id`
+	var id string
+	err := c.db.QueryRowContext(ctx, query, req.UserId, req.CourseId).Scan(&id)
+	if err == sql.ErrNoRows {
+		var enrolled bool
+		query := `SELECT EXISTS (SELECT 1 FROM course_enrollments WHERE user_id = $1 AND course_id = $2)`
+		if err := c.db.QueryRowContext(ctx, query, req.UserId, req.CourseId).Scan(&enrolled); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if !enrolled {
+			return nil, st.ErrCourseNotFound
+		}
+	} else if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.EnrollCourseResponse{Message: "success"}, nil
+}
+
+// GetEnrollments lists the live courses a user is enrolled in, with their
+// progress in each.
+func (c *CourseStorage) GetEnrollments(ctx context.Context, req *pb.GetEnrollmentsRequest) (*pb.GetEnrollmentsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT e.course_id, co.title, e.enrolled_at
+		FROM course_enrollments e JOIN courses co ON co.id = e.course_id AND co.deleted_at IS NULL
+		WHERE e.user_id = $1
+		ORDER BY e.enrolled_at DESC`
+	rows, err := c.db.QueryContext(ctx, query, req.UserId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := pb.GetEnrollmentsResponse{}
+	for rows.Next() {
+		e := pb.Enrollment{}
+		var enrolledAt time.Time
+		if err := rows.Scan(&e.CourseId, &e.Title, &enrolledAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		e.EnrolledAt = enrolledAt.Format(time.RFC3339)
+		res.Enrollments = append(res.Enrollments, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE ` + itemDone + `)
+		FROM course_module_items i JOIN course_modules m ON m.id = i.module_id
+		WHERE m.course_id = $2`
+	for _, e := range res.Enrollments {
+		if err := c.db.QueryRowContext(ctx, query, req.UserId, e.CourseId, models.QuizPassRatio).Scan(&e.TotalItems, &e.CompletedItems); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		e.Progress = percent(e.CompletedItems, e.TotalItems)
+	}
+	return &res, nil
+}
+
+// GetCourseProgress reports, module by module, which items of a course the
+// user is done with and which are still locked behind prerequisites.
+func (c *CourseStorage) GetCourseProgress(ctx context.Context, req *pb.GetCourseProgressRequest) (*pb.GetCourseProgressResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res := pb.GetCourseProgressResponse{CourseId: req.CourseId}
+	var enrolledAt sql.NullTime
+	query := `
+		SELECT (SELECT enrolled_at FROM course_enrollments WHERE user_id = $1 AND course_id = $2)
+		FROM courses WHERE id = $2 AND deleted_at IS NULL`
+	err := c.db.QueryRowContext(ctx, query, req.UserId, req.CourseId).Scan(&enrolledAt)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrCourseNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if enrolledAt.Valid {
+		res.Enrolled = true
+		res.EnrolledAt = enrolledAt.Time.Format(time.RFC3339)
+	}
+
+	query = `
+		SELECT m.id, m.title, i.item_type, i.item_id, ` + itemTitle + `, ` + itemDone + `,
+			ARRAY(
+				SELECT p.prerequisite_id::text FROM topic_prerequisites p
+				JOIN topics pt ON pt.id = p.prerequisite_id AND pt.deleted_at = 0 AND pt.status = 'published'
+				WHERE p.topic_id = ` + itemTopic + `
+				  AND NOT EXISTS (SELECT 1 FROM completed_topics ct WHERE ct.user_id = $1 AND ct.topic_id = p.prerequisite_id)
+				ORDER BY p.prerequisite_id)
+		FROM course_modules m LEFT JOIN course_module_items i ON i.module_id = m.id
+		WHERE m.course_id = $2
+		ORDER BY m.position, m.id, i.position`
+	rows, err := c.db.QueryContext(ctx, query, req.UserId, req.CourseId, models.QuizPassRatio)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var module *pb.ModuleProgress
+	for rows.Next() {
+		var moduleId, title string
+		var itemType, itemId, itemName sql.NullString
+		var done sql.NullBool
+		var missing []string
+		if err := rows.Scan(&moduleId, &title, &itemType, &itemId, &itemName, &done, pq.Array(&missing)); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if module == nil || module.ModuleId != moduleId {
+			module = &pb.ModuleProgress{ModuleId: moduleId, Title: title}
+			res.Modules = append(res.Modules, module)
+		}
+		if !itemType.Valid {
+			continue
+		}
+		item := &pb.ItemProgress{
+			Type:                 itemType.String,
+			Id:                   itemId.String,
+			Title:                itemName.String,
+			Completed:            done.Bool,
+			Locked:               len(missing) > 0 && !done.Bool,
+			MissingPrerequisites: missing,
+		}
+		module.Items = append(module.Items, item)
+		module.TotalItems++
+		res.TotalItems++
+		if item.Completed {
+			module.CompletedItems++
+			res.CompletedItems++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, m := range res.Modules {
+		m.Progress = percent(m.CompletedItems, m.TotalItems)
+	}
+	res.Progress = percent(res.CompletedItems, res.TotalItems)
+	return &res, nil
+}
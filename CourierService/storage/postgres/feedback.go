@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CreateLearningFeedback saves the user's review of a topic, replacing the
+// one they left before. A review a moderator rejected stays rejected until
+// its comment changes.
+func (c *LearningStorage) CreateLearningFeedback(ctx context.Context, req *pb.CreateLearningFeedbackRequest, status, reason string) (*pb.CreateLearningFeedbackResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO feedback(id, user_id, topic_id, rating, comment, status, moderation_reason)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE EXISTS (SELECT 1 FROM topics WHERE id = $3 AND deleted_at = 0 AND status = 'published')
+		ON CONFLICT (user_id, topic_id) DO UPDATE
+		SET rating = EXCLUDED.rating, comment = EXCLUDED.comment, updated_at = now(),
+			status = CASE WHEN feedback.status = 'rejected' AND feedback.comment = EXCLUDED.comment
+				THEN feedback.status ELSE EXCLUDED.status END,
+			moderation_reason = CASE WHEN feedback.status = 'rejected' AND feedback.comment = EXCLUDED.comment
+				THEN feedback.moderation_reason ELSE EXCLUDED.moderation_reason END
+		RETURNING id, status, updated_at IS NOT NULL`
+
+	res := &pb.CreateLearningFeedbackResponse{Message: "success"}
+	err := c.db.QueryRowContext(ctx, query, uuid.NewString(), req.UserId, req.TopicId, req.Rating, req.Comment, status, reason).
+		Scan(&res.Id, &res.Status, &res.Updated)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrTopicNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return res, nil
+}
+
+var feedbackSorts = map[string]string{
+	"created_at": "created_at",
+	"rating":     "rating",
+}
+
+func (c *LearningStorage) GetLearningFeedback(ctx context.Context, req *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", feedbackSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	f.Eq("user_id", req.UserId).Eq("topic_id", req.TopicId).In("status", req.Status)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM feedback`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`
+		SELECT id, user_id, topic_id, rating, comment, status, moderation_reason, created_at, updated_at, `+p.sortKey()+`
+		FROM feedback`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feedbacks []*pb.LearningFeedback
+	var keys, ids []string
+	for rows.Next() {
+		var feedback pb.LearningFeedback
+		var createdAt time.Time
+		var updatedAt sql.NullTime
+		var key string
+		err := rows.Scan(&feedback.Id, &feedback.UserId, &feedback.TopicId, &feedback.Rating, &feedback.Comment,
+			&feedback.Status, &feedback.ModerationReason, &createdAt, &updatedAt, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		feedback.CreatedAt = createdAt.Format(time.RFC3339)
+		if updatedAt.Valid {
+			feedback.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
+		}
+		feedbacks = append(feedbacks, &feedback)
+		keys = append(keys, key)
+		ids = append(ids, feedback.Id)
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetLearningFeedbackResponse{Feedback: feedbacks[:n], TotalCount: total, NextPageToken: token}, nil
+}
+
+// ModerateFeedback records a moderator's decision on a review.
+func (c *LearningStorage) ModerateFeedback(ctx context.Context, req *pb.ModerateFeedbackRequest) (*models.Feedback, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE feedback f
+		SET status = $2, moderation_reason = $3, moderated_by = $4, moderated_at = now()
+		FROM topics t
+		WHERE f.id = $1 AND t.id = f.topic_id
+		RETURNING f.id, f.user_id, f.topic_id, t.name, f.status`
+
+	fb := models.Feedback{}
+	err := c.db.QueryRowContext(ctx, query, req.Id, req.Status, req.Reason, req.ModeratorId).
+		Scan(&fb.Id, &fb.UserId, &fb.TopicId, &fb.TopicName, &fb.Status)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrFeedbackNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &fb, nil
+}
+
+// topicRatings fills in the average and distribution of the accepted
+// ratings of topics.
+func (c *LearningStorage) topicRatings(ctx context.Context, topics []*pb.LearningTopic) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	byId := make(map[string]*pb.LearningTopic, len(topics))
+	ids := make([]string, len(topics))
+	for i, t := range topics {
+		t.RatingDistribution = make([]int32, 5)
+		byId[t.Id] = t
+		ids[i] = t.Id
+	}
+
+	query := `
+		SELECT topic_id, rating, COUNT(*)
+		FROM feedback
+		WHERE topic_id = ANY($1::uuid[]) AND status = 'accepted'
+		GROUP BY topic_id, rating`
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var rating, count int32
+		if err := rows.Scan(&id, &rating, &count); err != nil {
+			log.Println(err)
+			return err
+		}
+		t := byId[id]
+		t.RatingDistribution[rating-1] = count
+		t.RatingCount += count
+		t.RatingAverage += float64(rating * count)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+	for _, t := range topics {
+		if t.RatingCount > 0 {
+			t.RatingAverage = math.Round(t.RatingAverage/float64(t.RatingCount)*100) / 100
+		}
+	}
+	return nil
+}
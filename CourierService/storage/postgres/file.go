@@ -0,0 +1,304 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type FileStorage struct {
+	db conn
+}
+
+const fileColumns = `id, owner_id, purpose, filename, content_type, size, sha256, status, created_at, completed_at`
+
+func scanFile(row scanner, extra ...interface{}) (*models.File, error) {
+	f := models.File{}
+	var completedAt sql.NullTime
+	dest := []interface{}{&f.Id, &f.OwnerId, &f.Purpose, &f.Filename, &f.ContentType, &f.Size, &f.Sha256, &f.Status, &f.CreatedAt, &completedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	f.CompletedAt = completedAt.Time
+	return &f, nil
+}
+
+// fileReferenced is true of a file something points at: a resource, a
+// profile's avatar or a homework attachment.
+const fileReferenced = `(
+	EXISTS (SELECT 1 FROM extra_resources r WHERE r.file_id = f.id)
+	OR EXISTS (SELECT 1 FROM learning_profiles p WHERE p.avatar_file_id = f.id)
+	OR EXISTS (SELECT 1 FROM submitted_homeworks s WHERE s.attachments @> jsonb_build_array(jsonb_build_object('file_id', f.id::text)))
+)`
+
+func (c *FileStorage) CreateFile(ctx context.Context, f *models.File) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if f.Id == "" {
+		f.Id = uuid.NewString()
+	}
+	var completedAt sql.NullTime
+	if f.Status == models.FileReady {
+		completedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	query := `
+		INSERT INTO files(id, owner_id, purpose, filename, content_type, size, sha256, status, completed_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at`
+	err := c.db.QueryRowContext(ctx, query, f.Id, f.OwnerId, f.Purpose, f.Filename, f.ContentType, f.Size, f.Sha256, f.Status, completedAt).
+		Scan(&f.CreatedAt)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	f.CompletedAt = completedAt.Time
+	return nil
+}
+
+func (c *FileStorage) GetFile(ctx context.Context, id string) (*models.File, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	f, err := scanFile(c.db.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM files WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrFileNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *FileStorage) GetFiles(ctx context.Context, ids []string) (map[string]*models.File, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, `SELECT `+fileColumns+` FROM files WHERE id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := map[string]*models.File{}
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		files[f.Id] = f
+	}
+	return files, rows.Err()
+}
+
+func (c *FileStorage) CompleteFile(ctx context.Context, id string) (*models.File, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE files SET status = 'ready', completed_at = COALESCE(completed_at, now())
+		WHERE id = $1
+		RETURNING ` + fileColumns
+	f, err := scanFile(c.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrFileNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return f, nil
+}
+
+// deleteFiles deletes the files matching where and queues for deletion the
+// blobs no file points at any more, and the uploads of those never completed.
+func deleteFiles(ctx context.Context, tx *txn, where string, args ...interface{}) (int, error) {
+	rows, err := tx.QueryContext(ctx, `DELETE FROM files f WHERE `+where+` RETURNING id, sha256, status`, args...)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	var shas, uploads []string
+	for rows.Next() {
+		var id, sha, status string
+		if err := rows.Scan(&id, &sha, &status); err != nil {
+			rows.Close()
+			log.Println(err)
+			return 0, err
+		}
+		shas = append(shas, sha)
+		if status == models.FilePending {
+			uploads = append(uploads, "uploads/"+id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if len(shas) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO blob_deletions(key)
+		SELECT DISTINCT 'sha256/' || s FROM unnest($1::text[]) s
+		WHERE NOT EXISTS (SELECT 1 FROM files WHERE sha256 = s)
+		UNION
+		SELECT unnest($2::text[])
+		ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(shas), pq.Array(uploads)); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return len(shas), nil
+}
+
+func (c *FileStorage) DeleteFile(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := deleteFiles(ctx, tx, `f.id = $1`, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteOrphanFiles deletes uploads never completed within pendingAge and
+// ready files nothing has pointed at for orphanAge, at most limit of them.
+func (c *FileStorage) DeleteOrphanFiles(ctx context.Context, pendingAge, orphanAge time.Duration, limit int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	where := `f.id IN (
+		SELECT f.id FROM files f
+		WHERE (f.status = 'pending' AND f.created_at < now() - make_interval(secs => $1))
+			OR (f.status = 'ready' AND f.completed_at < now() - make_interval(secs => $2) AND NOT ` + fileReferenced + `)
+		ORDER BY f.created_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)`
+	n, err := deleteFiles(ctx, tx, where, pendingAge.Seconds(), orphanAge.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return n, nil
+}
+
+// ClaimBlobDeletions returns queued blobs that are still unused. A blob a new
+// upload has claimed since is taken off the queue instead.
+func (c *FileStorage) ClaimBlobDeletions(ctx context.Context, limit int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `
+		DELETE FROM blob_deletions d
+		WHERE d.key LIKE 'sha256/%' AND EXISTS (SELECT 1 FROM files f WHERE f.sha256 = substring(d.key from 8))`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT key FROM blob_deletions ORDER BY queued_at LIMIT $1`, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (c *FileStorage) BlobDeleted(ctx context.Context, key string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM blob_deletions WHERE key = $1`, key); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (c *FileStorage) SetAvatar(ctx context.Context, userId, fileId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `INSERT INTO learning_profiles(user_id) VALUES($1) ON CONFLICT DO NOTHING`, userId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	query := `
+		UPDATE learning_profiles SET avatar_file_id = NULLIF($2, '')::uuid, updated_at = now()
+		WHERE user_id = $1 AND deleted_at IS NULL`
+	res, err := c.db.ExecContext(ctx, query, userId, fileId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return st.ErrProfileNotFound
+	}
+	return nil
+}
+
+func (c *FileStorage) GetAvatar(ctx context.Context, userId string) (*models.File, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT f.id, f.owner_id, f.purpose, f.filename, f.content_type, f.size, f.sha256, f.status, f.created_at, f.completed_at
+		FROM learning_profiles p
+		JOIN files f ON f.id = p.avatar_file_id
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL`
+	f, err := scanFile(c.db.QueryRowContext(ctx, query, userId))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrFileNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return f, nil
+}
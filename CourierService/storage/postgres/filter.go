@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// filter composes the WHERE clause of a list query. Values always travel as
+// positional arguments; column names come from the storage code, never from
+// the request. Empty values are skipped, so a request field left out simply
+// does not filter.
+type filter struct {
+	conds []string
+	args  []interface{}
+}
+
+func newFilter() *filter {
+	return &filter{}
+}
+
+// arg binds value and returns its placeholder.
+func (f *filter) arg(value interface{}) string {
+	f.args = append(f.args, value)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+// Where adds a raw condition. Every "?" in cond is replaced by the
+// placeholder of the matching value.
+func (f *filter) Where(cond string, values ...interface{}) *filter {
+	for _, v := range values {
+		cond = strings.Replace(cond, "?", f.arg(v), 1)
+	}
+	f.conds = append(f.conds, cond)
+	return f
+}
+
+func (f *filter) Eq(column, value string) *filter {
+	if value == "" {
+		return f
+	}
+	return f.Where(column+" = ?", value)
+}
+
+// In matches any of values. A single comma separated string is accepted too,
+// which is how list filters arrive in query strings.
+func (f *filter) In(column string, values ...string) *filter {
+	var list []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+	}
+	switch len(list) {
+	case 0:
+		return f
+	case 1:
+		return f.Where(column+" = ?", list[0])
+	}
+	placeholders := make([]string, len(list))
+	for i, v := range list {
+		placeholders[i] = f.arg(v)
+	}
+	f.conds = append(f.conds, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")))
+	return f
+}
+
+// Like matches rows whose column contains value, case-insensitively.
+// Wildcards in value are matched literally.
+func (f *filter) Like(column, value string) *filter {
+	if value == "" {
+		return f
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return f.Where(column+" ILIKE ?", "%"+escaped+"%")
+}
+
+// Range bounds column inclusively; a zero bound is left open.
+func (f *filter) Range(column string, min, max int32) *filter {
+	if min != 0 {
+		f.Where(column+" >= ?", min)
+	}
+	if max != 0 {
+		f.Where(column+" <= ?", max)
+	}
+	return f
+}
+
+// DateRange bounds column by RFC 3339 timestamps or plain dates. A plain
+// "to" date includes the whole day.
+func (f *filter) DateRange(column, from, to string) error {
+	if from != "" {
+		t, err := parseDate(from)
+		if err != nil {
+			return err
+		}
+		f.Where(column+" >= ?", t)
+	}
+	if to != "" {
+		t, err := parseDate(to)
+		if err != nil {
+			return err
+		}
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.Where(column+" < ?", t)
+	}
+	return nil
+}
+
+// Sql returns the WHERE clause, or nothing if there are no conditions.
+func (f *filter) Sql() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+func (f *filter) Args() []interface{} {
+	return f.args
+}
+
+// clone lets the count query share the filters without the keyset condition
+// the page adds afterwards.
+func (f *filter) clone() *filter {
+	return &filter{
+		conds: append([]string(nil), f.conds...),
+		args:  append([]interface{}(nil), f.args...),
+	}
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(f *filter)
+		sql   string
+		args  []interface{}
+	}{
+		{
+			name:  "empty",
+			build: func(f *filter) {},
+			sql:   "",
+		},
+		{
+			name:  "eq",
+			build: func(f *filter) { f.Eq("status", "published") },
+			sql:   " WHERE status = $1",
+			args:  []interface{}{"published"},
+		},
+		{
+			name:  "eq skips empty",
+			build: func(f *filter) { f.Eq("status", "") },
+			sql:   "",
+		},
+		{
+			name:  "in single value",
+			build: func(f *filter) { f.In("type", "quiz") },
+			sql:   " WHERE type = $1",
+			args:  []interface{}{"quiz"},
+		},
+		{
+			name:  "in splits commas",
+			build: func(f *filter) { f.In("type", "quiz, topic,,homework ") },
+			sql:   " WHERE type IN ($1, $2, $3)",
+			args:  []interface{}{"quiz", "topic", "homework"},
+		},
+		{
+			name:  "in joins several values",
+			build: func(f *filter) { f.In("type", "quiz,topic", "homework") },
+			sql:   " WHERE type IN ($1, $2, $3)",
+			args:  []interface{}{"quiz", "topic", "homework"},
+		},
+		{
+			name:  "in skips blanks",
+			build: func(f *filter) { f.In("type", " , ", "") },
+			sql:   "",
+		},
+		{
+			name:  "like",
+			build: func(f *filter) { f.Like("title", "Go") },
+			sql:   " WHERE title ILIKE $1",
+			args:  []interface{}{"%Go%"},
+		},
+		{
+			name:  "like escapes wildcards",
+			build: func(f *filter) { f.Like("title", `50%_off\`) },
+			sql:   " WHERE title ILIKE $1",
+			args:  []interface{}{`%50\%\_off\\%`},
+		},
+		{
+			name:  "range",
+			build: func(f *filter) { f.Range("difficulty", 2, 4) },
+			sql:   " WHERE difficulty >= $1 AND difficulty <= $2",
+			args:  []interface{}{int32(2), int32(4)},
+		},
+		{
+			name:  "range open below",
+			build: func(f *filter) { f.Range("difficulty", 0, 4) },
+			sql:   " WHERE difficulty <= $1",
+			args:  []interface{}{int32(4)},
+		},
+		{
+			name: "placeholders number across conditions",
+			build: func(f *filter) {
+				f.Eq("status", "published").In("type", "quiz,topic").Range("difficulty", 1, 0).Like("title", "go")
+			},
+			sql:  " WHERE status = $1 AND type IN ($2, $3) AND difficulty >= $4 AND title ILIKE $5",
+			args: []interface{}{"published", "quiz", "topic", int32(1), "%go%"},
+		},
+		{
+			name:  "where with several values",
+			build: func(f *filter) { f.Eq("a", "x").Where("(b, c) < (?, ?)", "y", "z") },
+			sql:   " WHERE a = $1 AND (b, c) < ($2, $3)",
+			args:  []interface{}{"x", "y", "z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFilter()
+			tt.build(f)
+			if got := f.Sql(); got != tt.sql {
+				t.Errorf("Sql() = %q, want %q", got, tt.sql)
+			}
+			if got := f.Args(); !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("Args() = %#v, want %#v", got, tt.args)
+			}
+		})
+	}
+}
+
+func TestFilterDateRange(t *testing.T) {
+	day := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02", s)
+		return t
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		sql      string
+		args     []interface{}
+		wantErr  bool
+	}{
+		{
+			name: "dates",
+			from: "2024-03-01", to: "2024-03-31",
+			sql:  " WHERE kind = $1 AND created_at >= $2 AND created_at < $3",
+			args: []interface{}{"quiz", day("2024-03-01"), day("2024-04-01")},
+		},
+		{
+			name: "timestamp to is exclusive as given",
+			to:   "2024-03-31T12:00:00Z",
+			sql:  " WHERE kind = $1 AND created_at < $2",
+			args: []interface{}{"quiz", time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "open",
+			sql:  " WHERE kind = $1",
+			args: []interface{}{"quiz"},
+		},
+		{
+			name:    "invalid",
+			from:    "yesterday",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFilter().Eq("kind", "quiz")
+			err := f.DateRange("created_at", tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("DateRange() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Sql(); got != tt.sql {
+				t.Errorf("Sql() = %q, want %q", got, tt.sql)
+			}
+			if got := f.Args(); !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("Args() = %#v, want %#v", got, tt.args)
+			}
+		})
+	}
+}
+
+func TestFilterCloneIsIndependent(t *testing.T) {
+	f := newFilter().Eq("status", "published")
+	c := f.clone()
+	c.Eq("type", "quiz")
+	if got := f.Sql(); got != " WHERE status = $1" {
+		t.Errorf("original Sql() = %q after changing the clone", got)
+	}
+	if len(f.Args()) != 1 {
+		t.Errorf("original has %d args after changing the clone, want 1", len(f.Args()))
+	}
+}
@@ -0,0 +1,575 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type HomeworkStorage struct {
+	db conn
+}
+
+func rubricJson(rubric []*pb.RubricCriterion) (string, error) {
+	criteria := make([]*models.RubricCriterion, len(rubric))
+	for i, c := range rubric {
+		criteria[i] = &models.RubricCriterion{Name: c.Name, Description: c.Description, MaxPoints: float64(c.MaxPoints)}
+	}
+	raw, err := json.Marshal(criteria)
+	return string(raw), err
+}
+
+func rubricToPb(rubric []*models.RubricCriterion) []*pb.RubricCriterion {
+	criteria := make([]*pb.RubricCriterion, len(rubric))
+	for i, c := range rubric {
+		criteria[i] = &pb.RubricCriterion{Name: c.Name, Description: c.Description, MaxPoints: float32(c.MaxPoints)}
+	}
+	return criteria
+}
+
+// dueAt parses an optional due date; an empty one is NULL.
+func dueAt(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := parseDate(value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// assignHomework assigns a homework to users and to the current members of
+// groups, and returns the users it was not assigned to before. A valid due
+// overrides the homework's due date for all of them.
+func assignHomework(ctx context.Context, tx *txn, homeworkId string, userIds, groupIds []string, due sql.NullTime) ([]string, error) {
+	for _, id := range groupIds {
+		var found int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM study_groups WHERE id = $1`, id).Scan(&found)
+		if err == sql.ErrNoRows {
+			return nil, st.ErrGroupNotFound
+		}
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	// A user listed directly and through a group is assigned once, directly.
+	// xmax is 0 on a row the statement inserted rather than updated.
+	query := `
+		INSERT INTO homework_assignments(homework_id, user_id, group_id, due_at)
+		SELECT DISTINCT ON (u.user_id) $1::uuid, u.user_id, u.group_id, $4::timestamp
+		FROM (
+			SELECT unnest($2::uuid[]) AS user_id, NULL::uuid AS group_id
+			UNION ALL
+			SELECT user_id, group_id FROM study_group_members WHERE group_id = ANY($3::uuid[])
+		) u
+		ORDER BY u.user_id, u.group_id NULLS FIRST
+		ON CONFLICT (homework_id, user_id) DO UPDATE
+		SET due_at = COALESCE(EXCLUDED.due_at, homework_assignments.due_at)
+		RETURNING user_id, xmax = 0`
+	rows, err := tx.QueryContext(ctx, query, homeworkId, pq.Array(userIds), pq.Array(groupIds), due)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assigned []string
+	for rows.Next() {
+		var userId string
+		var inserted bool
+		if err := rows.Scan(&userId, &inserted); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if inserted {
+			assigned = append(assigned, userId)
+		}
+	}
+	return assigned, rows.Err()
+}
+
+// AssignHomework assigns an existing homework and returns the users it was
+// not assigned to before.
+func (c *HomeworkStorage) AssignHomework(ctx context.Context, req *pb.AssignHomeworkRequest) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	due, err := dueAt(req.DueAt)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM homeworks WHERE id = $1`, req.HomeworkId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrHomeworkNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	assigned, err := assignHomework(ctx, tx, req.HomeworkId, req.UserIds, req.GroupIds, due)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return assigned, nil
+}
+
+func (c *HomeworkStorage) GetHomework(ctx context.Context, id string) (*models.Homework, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	hw := models.Homework{Id: id}
+	var due sql.NullTime
+	var rubric []byte
+	query := `
+		SELECT user_id, title, description, difficulty, due_at, max_xp, late_policy, late_penalty_percent, max_submissions, rubric
+		FROM homeworks WHERE id = $1`
+	err := c.db.QueryRowContext(ctx, query, id).Scan(&hw.UserId, &hw.Title, &hw.Description, &hw.Difficulty, &due,
+		&hw.MaxXp, &hw.LatePolicy, &hw.LatePenaltyPercent, &hw.MaxSubmissions, &rubric)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrHomeworkNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := json.Unmarshal(rubric, &hw.Rubric); err != nil {
+		return nil, err
+	}
+	hw.DueAt = due.Time
+	return &hw, nil
+}
+
+// GetAssignment returns the user's assignment of a homework, locked for the
+// rest of the unit of work.
+func (c *HomeworkStorage) GetAssignment(ctx context.Context, homeworkId, userId string) (*models.HomeworkAssignment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	a := models.HomeworkAssignment{HomeworkId: homeworkId, UserId: userId}
+	var due sql.NullTime
+	query := `
+		SELECT COALESCE(a.group_id::text, ''), COALESCE(a.due_at, h.due_at), a.xp_awarded, a.assigned_at
+		FROM homework_assignments a JOIN homeworks h ON h.id = a.homework_id
+		WHERE a.homework_id = $1 AND a.user_id = $2
+		FOR UPDATE OF a`
+	err := c.db.QueryRowContext(ctx, query, homeworkId, userId).Scan(&a.GroupId, &due, &a.XpAwarded, &a.AssignedAt)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrHomeworkNotAssigned
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	a.DueAt = due.Time
+	return &a, nil
+}
+
+// CreateSubmission stores s as the user's next attempt at the homework, and
+// supersedes an earlier attempt still waiting for review. maxSubmissions, if
+// not 0, caps the attempts.
+func (c *HomeworkStorage) CreateSubmission(ctx context.Context, s *models.HomeworkSubmission, maxSubmissions int32) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	links, err := json.Marshal(nonNil(s.Links))
+	if err != nil {
+		return err
+	}
+	attachments, err := json.Marshal(s.Attachments)
+	if err != nil {
+		return err
+	}
+	if s.Attachments == nil {
+		attachments = []byte("[]")
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serializes submissions of the same user to the same homework.
+	var found int
+	query := `SELECT 1 FROM homework_assignments WHERE homework_id = $1 AND user_id = $2 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, s.HomeworkId, s.UserId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return st.ErrHomeworkNotAssigned
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	query = `SELECT COALESCE(MAX(attempt), 0) + 1 FROM submitted_homeworks WHERE homework_id = $1 AND user_id = $2`
+	if err := tx.QueryRowContext(ctx, query, s.HomeworkId, s.UserId).Scan(&s.Attempt); err != nil {
+		log.Println(err)
+		return err
+	}
+	if maxSubmissions > 0 && s.Attempt > maxSubmissions {
+		return st.ErrNoSubmissionsLeft
+	}
+
+	query = `
+		UPDATE submitted_homeworks SET status = 'superseded'
+		WHERE homework_id = $1 AND user_id = $2 AND status = 'submitted'`
+	if _, err := tx.ExecContext(ctx, query, s.HomeworkId, s.UserId); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	s.Id = uuid.NewString()
+	s.Status = models.SubmissionSubmitted
+	query = `
+		INSERT INTO submitted_homeworks(id, user_id, homework_id, attempt, content, links, attachments, days_late, status)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at`
+	err = tx.QueryRowContext(ctx, query, s.Id, s.UserId, s.HomeworkId, s.Attempt, s.Text, string(links), string(attachments),
+		s.DaysLate, s.Status).Scan(&s.SubmittedAt)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+const submissionColumns = `id, homework_id, user_id, attempt, content, links, attachments, days_late, status, created_at,
+	COALESCE(score, 0), COALESCE(max_score, 0), rubric_scores, comment, COALESCE(graded_by::text, ''), graded_at, xp_earned`
+
+func scanSubmission(row scanner, extra ...interface{}) (*models.HomeworkSubmission, error) {
+	s := models.HomeworkSubmission{}
+	var links, attachments, scores []byte
+	var gradedAt sql.NullTime
+	dest := []interface{}{&s.Id, &s.HomeworkId, &s.UserId, &s.Attempt, &s.Text, &links, &attachments, &s.DaysLate, &s.Status,
+		&s.SubmittedAt, &s.Score, &s.MaxScore, &scores, &s.Comment, &s.GradedBy, &gradedAt, &s.XpEarned}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(links, &s.Links); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attachments, &s.Attachments); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scores, &s.Scores); err != nil {
+		return nil, err
+	}
+	s.GradedAt = gradedAt.Time
+	return &s, nil
+}
+
+// GetSubmission returns a submission, locked for the rest of the unit of
+// work.
+func (c *HomeworkStorage) GetSubmission(ctx context.Context, id string) (*models.HomeworkSubmission, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	row := c.db.QueryRowContext(ctx, `SELECT `+submissionColumns+` FROM submitted_homeworks WHERE id = $1 FOR UPDATE`, id)
+	s, err := scanSubmission(row)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrSubmissionNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return s, nil
+}
+
+func submissionToPb(s *models.HomeworkSubmission) *pb.HomeworkSubmission {
+	res := &pb.HomeworkSubmission{
+		Id:          s.Id,
+		HomeworkId:  s.HomeworkId,
+		UserId:      s.UserId,
+		Attempt:     s.Attempt,
+		Text:        s.Text,
+		Links:       s.Links,
+		Late:        s.DaysLate > 0,
+		DaysLate:    s.DaysLate,
+		Status:      s.Status,
+		SubmittedAt: s.SubmittedAt.Format(time.RFC3339),
+		Score:       float32(s.Score),
+		MaxScore:    float32(s.MaxScore),
+		Comment:     s.Comment,
+		GradedBy:    s.GradedBy,
+		XpEarned:    s.XpEarned,
+	}
+	for _, a := range s.Attachments {
+		res.Attachments = append(res.Attachments, &pb.HomeworkAttachment{Name: a.Name, Url: a.Url, FileId: a.FileId, ContentType: a.ContentType, Size: a.Size})
+	}
+	for _, sc := range s.Scores {
+		res.Scores = append(res.Scores, &pb.RubricScore{Criterion: sc.Criterion, Points: float32(sc.Points), Comment: sc.Comment})
+	}
+	if !s.GradedAt.IsZero() {
+		res.GradedAt = s.GradedAt.Format(time.RFC3339)
+	}
+	return res
+}
+
+var submissionSorts = map[string]string{
+	"created_at": "created_at",
+	"attempt":    "attempt",
+}
+
+// GetSubmissions lists submissions, by default the oldest first so that
+// reviewers work through the queue in order.
+func (c *HomeworkStorage) GetSubmissions(ctx context.Context, req *pb.GetHomeworkSubmissionsRequest) (*pb.GetHomeworkSubmissionsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if req.SortOrder == "" {
+		req.SortOrder = "asc"
+	}
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", submissionSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	f.Eq("homework_id", req.HomeworkId).Eq("user_id", req.UserId).In("status", req.Status)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM submitted_homeworks`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`SELECT `+submissionColumns+`, `+p.sortKey()+` FROM submitted_homeworks`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submissions []*pb.HomeworkSubmission
+	var keys, ids []string
+	for rows.Next() {
+		var key string
+		s, err := scanSubmission(rows, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		submissions = append(submissions, submissionToPb(s))
+		keys = append(keys, key)
+		ids = append(ids, s.Id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetHomeworkSubmissionsResponse{Submissions: submissions[:n], TotalCount: total, NextPageToken: token}, nil
+}
+
+// GradeSubmission records a reviewer's verdict on a submission waiting for
+// review. A graded submission pays what its XpEarned exceeds the user's
+// earlier grades on the homework by; the XP paid is returned.
+func (c *HomeworkStorage) GradeSubmission(ctx context.Context, s *models.HomeworkSubmission) (int32, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	scores, err := json.Marshal(s.Scores)
+	if err != nil {
+		return 0, err
+	}
+	if s.Scores == nil {
+		scores = []byte("[]")
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE submitted_homeworks
+		SET status = $2, score = $3, max_score = $4, rubric_scores = $5, comment = $6, graded_by = $7, graded_at = now()
+		WHERE id = $1 AND status = 'submitted'
+		RETURNING graded_at`
+	err = tx.QueryRowContext(ctx, query, s.Id, s.Status, s.Score, s.MaxScore, string(scores), s.Comment, s.GradedBy).Scan(&s.GradedAt)
+	if err == sql.ErrNoRows {
+		return 0, st.ErrSubmissionClosed
+	}
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	var xp int32
+	if s.Status == models.SubmissionGraded {
+		var awarded int32
+		query = `SELECT xp_awarded FROM homework_assignments WHERE homework_id = $1 AND user_id = $2 FOR UPDATE`
+		if err := tx.QueryRowContext(ctx, query, s.HomeworkId, s.UserId).Scan(&awarded); err != nil {
+			log.Println(err)
+			return 0, err
+		}
+		if s.XpEarned > awarded {
+			xp = s.XpEarned - awarded
+		}
+		query = `UPDATE homework_assignments SET xp_awarded = xp_awarded + $3 WHERE homework_id = $1 AND user_id = $2`
+		if _, err := tx.ExecContext(ctx, query, s.HomeworkId, s.UserId, xp); err != nil {
+			log.Println(err)
+			return 0, err
+		}
+		entry := &models.XpEntry{UserId: s.UserId, SourceType: models.XpSourceHomework, SourceId: s.Id, Amount: xp, Reason: "homework graded"}
+		if _, err := awardXp(ctx, tx, entry); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE submitted_homeworks SET xp_earned = $2 WHERE id = $1`, s.Id, xp); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return xp, nil
+}
+
+func (c *HomeworkStorage) CreateStudyGroup(ctx context.Context, req *pb.CreateStudyGroupRequest) (*pb.CreateStudyGroupResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id := uuid.NewString()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO study_groups(id, name) VALUES($1, $2)`, id, req.Name); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := setGroupMembers(ctx, tx, id, req.UserIds); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.CreateStudyGroupResponse{Id: id, Message: "success"}, nil
+}
+
+// setGroupMembers makes userIds the members of a group. Homework already
+// assigned through the group stays with users who leave it.
+func setGroupMembers(ctx context.Context, tx *txn, groupId string, userIds []string) error {
+	query := `DELETE FROM study_group_members WHERE group_id = $1 AND NOT (user_id = ANY($2::uuid[]))`
+	if _, err := tx.ExecContext(ctx, query, groupId, pq.Array(userIds)); err != nil {
+		log.Println(err)
+		return err
+	}
+	query = `
+		INSERT INTO study_group_members(group_id, user_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, groupId, pq.Array(userIds)); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (c *HomeworkStorage) SetStudyGroupMembers(ctx context.Context, req *pb.SetStudyGroupMembersRequest) (*pb.SetStudyGroupMembersResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM study_groups WHERE id = $1 FOR UPDATE`, req.GroupId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrGroupNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := setGroupMembers(ctx, tx, req.GroupId, req.UserIds); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.SetStudyGroupMembersResponse{Message: "success"}, nil
+}
+
+func (c *HomeworkStorage) GetStudyGroup(ctx context.Context, req *pb.GetStudyGroupRequest) (*pb.GetStudyGroupResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	group := pb.StudyGroup{Id: req.Id}
+	var createdAt time.Time
+	err := c.db.QueryRowContext(ctx, `SELECT name, created_at FROM study_groups WHERE id = $1`, req.Id).Scan(&group.Name, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrGroupNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	group.CreatedAt = createdAt.Format(time.RFC3339)
+
+	rows, err := c.db.QueryContext(ctx, `SELECT user_id FROM study_group_members WHERE group_id = $1 ORDER BY added_at, user_id`, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userId string
+		if err := rows.Scan(&userId); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		group.UserIds = append(group.UserIds, userId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &pb.GetStudyGroupResponse{Group: &group}, nil
+}
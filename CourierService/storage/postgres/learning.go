@@ -1,31 +1,38 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
 	"log"
 	"time"
 
 	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type LearningStorage struct {
-	db *sql.DB
+	db conn
 }
 
-func NewLearningStorage(db *sql.DB) *LearningStorage {
+func NewLearningStorage(db conn) *LearningStorage {
 	return &LearningStorage{db: db}
 }
 
-func (c *LearningStorage) CreateLearningTopic(req *pb.CreateLearningTopicRequest) (*pb.CreateLearningTopicResponse, error) {
+func (c *LearningStorage) CreateLearningTopic(ctx context.Context, req *pb.CreateLearningTopicRequest) (*pb.CreateLearningTopicResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	id := uuid.NewString()
 	query := `
 		INSERT INTO topics(id, name, description, difficulty)
 		VALUES($1, $2, $3, $4)`
 
-	_, err := c.db.Exec(query, id, req.Name, req.Description, req.Difficulty)
+	_, err := c.db.ExecContext(ctx, query, id, req.Name, req.Description, req.Difficulty)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -34,61 +41,77 @@ func (c *LearningStorage) CreateLearningTopic(req *pb.CreateLearningTopicRequest
 	return &pb.CreateLearningTopicResponse{Id: id, Message: "success"}, nil
 }
 
-func (c *LearningStorage) GetLearningTopics(req *pb.GetLearningTopicsRequest) (*pb.GetLearningTopicsResponse, error) {
-	var query string
-	topics := []*pb.LearningTopic{}
-	query = `
-	SELECT id, name, description, difficulty FROM topics WHERE deleted_at = 0`
-	var arr []interface{}
-	var request pb.LearningTopic
-	count := 1
+var topicSorts = map[string]string{
+	"created_at": "created_at",
+	"name":       "name",
+	"difficulty": "difficulty",
+}
 
-	if len(request.Id) > 0 {
-		query += fmt.Sprintf(" and id = $%d", count)
-		count++
-		arr = append(arr, request.Id)
-	}
-	if len(request.Name) > 0 {
-		query += fmt.Sprintf(" and name = $%d", count)
-		count++
-		arr = append(arr, request.Name)
+func (c *LearningStorage) GetLearningTopics(ctx context.Context, req *pb.GetLearningTopicsRequest) (*pb.GetLearningTopicsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", topicSorts)
+	if err != nil {
+		return nil, err
 	}
-	if len(request.Description) > 0 {
-		query += fmt.Sprintf(" and description = $%d", count)
-		count++
-		arr = append(arr, request.Description)
+
+	f := newFilter()
+	f.Where("deleted_at = 0").Where("status = 'published'")
+	f.Eq("id", req.Id).Like("name", req.Name).Like("description", req.Description).In("difficulty", req.Difficulty)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
 	}
-	if len(request.Difficulty) > 0 {
-		query += fmt.Sprintf(" and phone_number = $%d", count)
-		count++
-		arr = append(arr, request.Difficulty)
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM topics`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
 	}
 
-	rows, err := c.db.Query(query, arr...)
+	query, args := p.query(`SELECT id, name, description, difficulty, `+p.sortKey()+` FROM topics`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 	defer rows.Close()
+
+	topics := []*pb.LearningTopic{}
+	var keys, ids []string
 	for rows.Next() {
-		err := rows.Scan(&request.Id, &request.Name, &request.Description, &request.Difficulty)
+		var topic pb.LearningTopic
+		var key string
+		err := rows.Scan(&topic.Id, &topic.Name, &topic.Description, &topic.Difficulty, &key)
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
-		topics = append(topics, &request)
+		topics = append(topics, &topic)
+		keys = append(keys, key)
+		ids = append(ids, topic.Id)
 	}
-	return &pb.GetLearningTopicsResponse{Topics: topics}, nil
+	rows.Close()
 
+	n, token := p.next(keys, ids)
+	topics = topics[:n]
+	if err := c.topicRatings(ctx, topics); err != nil {
+		return nil, err
+	}
+	return &pb.GetLearningTopicsResponse{Topics: topics, TotalCount: total, NextPageToken: token}, nil
 }
 
-func (c *LearningStorage) UpdateLearningTopic(req *pb.UpdateLearningTopicRequest) (*pb.UpdateLearningTopicResponse, error) {
+func (c *LearningStorage) UpdateLearningTopic(ctx context.Context, req *pb.UpdateLearningTopicRequest) (*pb.UpdateLearningTopicResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE topics
 		SET name = $1, description = $2, difficulty = $3
 		WHERE id = $4`
 
-	_, err := c.db.Exec(query, req.Name, req.Description, req.Difficulty, req.Id)
+	_, err := c.db.ExecContext(ctx, query, req.Name, req.Description, req.Difficulty, req.Id)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -97,12 +120,15 @@ func (c *LearningStorage) UpdateLearningTopic(req *pb.UpdateLearningTopicRequest
 	return &pb.UpdateLearningTopicResponse{Message: "success"}, nil
 }
 
-func (c *LearningStorage) DeleteLearningTopic(req *pb.DeleteLearningTopicRequest) (*pb.DeleteLearningTopicResponse, error) {
+func (c *LearningStorage) DeleteLearningTopic(ctx context.Context, req *pb.DeleteLearningTopicRequest) (*pb.DeleteLearningTopicResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE topics SET deleted_at = $1
 		WHERE id = $2`
 
-	_, err := c.db.Exec(query, time.Now().Unix(), req.Id)
+	_, err := c.db.ExecContext(ctx, query, time.Now().Unix(), req.Id)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -112,304 +138,345 @@ func (c *LearningStorage) DeleteLearningTopic(req *pb.DeleteLearningTopicRequest
 }
 
 
-func (c *LearningStorage) CompletedTopics(req *pb.CompletedTopicsRequest) (*pb.CompletedTopicsResponse, error) {
+func (c *LearningStorage) CompletedTopics(ctx context.Context, req *pb.CompletedTopicsRequest) (*pb.CompletedTopicsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var found int
+	err := c.db.QueryRowContext(ctx, `SELECT 1 FROM topics WHERE id = $1 AND deleted_at = 0 AND status = 'published'`, req.TopicId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrTopicNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
 	id := uuid.NewString()
 	query := `
 		INSERT INTO completed_topics (id, user_id, topic_id, xp_earned)
-		VALUES ($1, $2, $3, $4)`
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, topic_id) DO NOTHING`
 
-	_, err := c.db.Exec(query, id, req.UserId, req.TopicId, req.XpEarned)
+	res, err := c.db.ExecContext(ctx, query, id, req.UserId, req.TopicId, req.XpEarned)
 	if err != nil {
 		log.Println(err)
 		return nil, err
-	} else {
-		query := `UPDATE users SET xp = xp + $1 WHERE id = $2`
-		_, err := c.db.Exec(query, req.XpEarned, req.UserId)
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-		return &pb.CompletedTopicsResponse{Message: "success", XpEarned: req.XpEarned}, nil
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, st.ErrAlreadyCompleted
+	}
+	return &pb.CompletedTopicsResponse{Message: "success"}, nil
+}
 
+var completedTopicSorts = map[string]string{
+	"created_at": "created_at",
+	"xp_earned":  "xp_earned",
 }
 
-func (c *LearningStorage) GetCompletedTopics(req *pb.GetCompletedTopicsRequest) (*pb.GetCompletedTopicsResponse, error) {
-	var query string
-	topics := []*pb.CompletedTopics{}
-	query = `SELECT id, user_id, topic_id, xp_earned FROM completed_topics`
-	var request pb.CompletedTopics
-	var arr []interface{}
-	count := 1
+func (c *LearningStorage) GetCompletedTopics(ctx context.Context, req *pb.GetCompletedTopicsRequest) (*pb.GetCompletedTopicsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	if len(request.Id) > 0 {
-		query += fmt.Sprintf(" and id = $%d", count)
-		count++
-		arr = append(arr, request.Id)
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", completedTopicSorts)
+	if err != nil {
+		return nil, err
 	}
-	if len(request.UserId) > 0 {
-		query += fmt.Sprintf(" and user_id = $%d", count)
-		count++
-		arr = append(arr, request.UserId)
+
+	f := newFilter()
+	f.Eq("id", req.Id).Eq("user_id", req.UserId).In("topic_id", req.TopicId).Range("xp_earned", req.MinXp, req.MaxXp)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
 	}
-	if len(request.TopicId) > 0 {
-		query += fmt.Sprintf(" and topic_id = $%d", count)
-		count++
-		arr = append(arr, request.TopicId)
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM completed_topics`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
 	}
 
-	rows, err := c.db.Query(query, arr...)
+	query, args := p.query(`SELECT id, user_id, topic_id, xp_earned, `+p.sortKey()+` FROM completed_topics`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
+	defer rows.Close()
+
+	topics := []*pb.CompletedTopics{}
+	var keys, ids []string
 	for rows.Next() {
-		err := rows.Scan(&request.Id, &request.UserId, &request.TopicId, &request.XpEarned)
+		var topic pb.CompletedTopics
+		var key string
+		err := rows.Scan(&topic.Id, &topic.UserId, &topic.TopicId, &topic.XpEarned, &key)
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
-		topics = append(topics, &request)
+		topics = append(topics, &topic)
+		keys = append(keys, key)
+		ids = append(ids, topic.Id)
 	}
-
-	return &pb.GetCompletedTopicsResponse{Topics: topics}, nil
+	n, token := p.next(keys, ids)
+	return &pb.GetCompletedTopicsResponse{Topics: topics[:n], TotalCount: total, NextPageToken: token}, nil
 }
 
-func (c *LearningStorage) CreateQuiz(req *pb.CreateQuizRequest) (*pb.CreateQuizResponse, error) {
-	id := uuid.NewString()
-	query := `
-		INSERT INTO quizzes(id, topic_id, question, options, answer)
-		VALUES($1, $2, $3, $4, $5)`
+func (c *LearningStorage) CreateQuiz(ctx context.Context, req *pb.CreateQuizRequest) (*pb.CreateQuizResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	_, err := c.db.Exec(query, id, req.TopicId, req.Question, req.Options, req.Answer)
+	id := uuid.NewString()
+	tx, err := begin(ctx, c.db)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &pb.CreateQuizResponse{Id: id, Message: "success"}, nil
-}
-
-func (c *LearningStorage) GetQuiz(req *pb.GetQuizRequest) (*pb.GetQuizResponse, error) {
-	query := `SELECT id, topic_id, question, options FROM quizzes`
+	query := `
+		INSERT INTO quizzes(id, topic_id, title, time_limit_seconds, max_attempts, partial_credit, shuffle, xp)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	rows, err := c.db.Query(query)
+	_, err = tx.ExecContext(ctx, query, id, req.TopicId, req.Title, req.TimeLimitSeconds, req.MaxAttempts, req.PartialCredit, req.Shuffle, req.Xp)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	defer rows.Close()
-	var request pb.Quiz
-
-	var quizzes []*pb.Quiz
-	var arr []interface{}
-	count := 1
-
-	if len(request.Id) > 0 {
-		query += fmt.Sprintf(" and id = $%d", count)
-		count++
-		arr = append(arr, request.Id)
+	if err := insertQuizQuestions(ctx, tx, id, req.Questions); err != nil {
+		return nil, err
 	}
-	if len(request.TopicId) > 0 {
-		query += fmt.Sprintf(" and topic_id = $%d", count)
-		count++
-		arr = append(arr, request.TopicId)
+	if err := insertTemplateRules(ctx, tx, id, req.Rules); err != nil {
+		return nil, err
 	}
-
-	rows, err = c.db.Query(query, arr...)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	for rows.Next() {
-		var quiz pb.Quiz
-		err := rows.Scan(&quiz.Id, &quiz.TopicId, &quiz.Question, &quiz.Options)
-		if err != nil {
-			log.Println(err)
-			return nil, err
+
+	return &pb.CreateQuizResponse{Id: id, Message: "success"}, nil
+}
+
+func insertQuizQuestions(ctx context.Context, tx *txn, quizId string, questions []*pb.QuizQuestion) error {
+	for i, q := range questions {
+		if _, err := insertQuestion(ctx, tx, quizId, int32(i+1), q); err != nil {
+			return err
 		}
-		quizzes = append(quizzes, &quiz)
 	}
-	return &pb.GetQuizResponse{Quiz: quizzes}, nil
+	return nil
 }
 
-func (c *LearningStorage) UpdateQuiz(req *pb.UpdateQuizRequest) (*pb.UpdateQuizResponse, error) {
+func insertTemplateRules(ctx context.Context, tx *txn, quizId string, rules []*pb.QuizTemplateRule) error {
 	query := `
-		UPDATE quizzes
-		SET topic_id = $1, question = $2, options = $3, answer = $4
-		WHERE id = $5`
+		INSERT INTO quiz_template_rules(id, quiz_id, position, topic_id, difficulty, tag, count)
+		VALUES($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, ''), NULLIF($6, ''), $7)`
+	for i, r := range rules {
+		_, err := tx.ExecContext(ctx, query, uuid.NewString(), quizId, i+1, r.TopicId, r.Difficulty, r.Tag, r.Count)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
 
-	_, err := c.db.Exec(query, req.TopicId, req.Question, req.Options, req.Answer, req.Id)
-	if err != nil {
-		log.Println(err)
-		return nil, err
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
 	}
+	return list
+}
 
-	return &pb.UpdateQuizResponse{Message: "success"}, nil
+var quizSorts = map[string]string{
+	"created_at": "created_at",
+	"title":      "title",
 }
 
-func (c *LearningStorage) DeleteQuiz(req *pb.DeleteQuizRequest) (*pb.DeleteQuizResponse, error) {
-	query := `DELETE FROM quizzes WHERE id = $1`
+func (c *LearningStorage) GetQuiz(ctx context.Context, req *pb.GetQuizRequest) (*pb.GetQuizResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	_, err := c.db.Exec(query, req.Id)
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", quizSorts)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.DeleteQuizResponse{Message: "success"}, nil
-}
+	f := newFilter()
+	f.Where("status = 'published'")
+	f.Eq("id", req.Id).In("topic_id", req.TopicId).Like("title", req.Title)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
 
-func (c *LearningStorage) SubmitQuiz(req *pb.SubmitQuizRequest) (*pb.SubmitQuizResponse, error) {
-	query := `SELECT id, answer FROM quizzes WHERE id = $1 AND answer = $2`
-	err := c.db.QueryRow(query, req.QuizId, req.Answer).Scan(&req.QuizId, &req.Answer)
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quizzes`+f.Sql(), f.Args()...).Scan(&total)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Print("Incorrect answer or quiz not found")
-			return &pb.SubmitQuizResponse{Message: "Incorrect answer or quiz not found", XpEarned: 0}, nil
-		}
 		log.Println(err)
 		return nil, err
 	}
-	updateQuery := `UPDATE users SET xp = xp + $1 WHERE id = $2`
-	_, err = c.db.Exec(updateQuery, 10, req.UserId)
+
+	query, args := p.query(`
+		SELECT id, topic_id, COALESCE(title, ''), COALESCE(question, ''), COALESCE(options, ''),
+			time_limit_seconds, max_attempts, partial_credit, shuffle, xp,
+			(SELECT COUNT(*) FROM quiz_questions qq WHERE qq.quiz_id = quizzes.id)
+				+ (SELECT COALESCE(SUM(count), 0) FROM quiz_template_rules r WHERE r.quiz_id = quizzes.id), `+p.sortKey()+`
+		FROM quizzes`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &pb.SubmitQuizResponse{Message: "success", XpEarned: 10}, nil
+	var quizzes []*pb.Quiz
+	var keys, ids []string
+	for rows.Next() {
+		var quiz pb.Quiz
+		var key string
+		err := rows.Scan(&quiz.Id, &quiz.TopicId, &quiz.Title, &quiz.Question, &quiz.Options,
+			&quiz.TimeLimitSeconds, &quiz.MaxAttempts, &quiz.PartialCredit, &quiz.Shuffle, &quiz.Xp, &quiz.QuestionCount, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		quizzes = append(quizzes, &quiz)
+		keys = append(keys, key)
+		ids = append(ids, quiz.Id)
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetQuizResponse{Quiz: quizzes[:n], TotalCount: total, NextPageToken: token}, nil
 }
 
+// UpdateQuiz changes the quiz settings. Questions are only replaced while
+// nobody has attempted the quiz, so recorded answers keep their questions.
+// Template rules are replaced whenever given.
+func (c *LearningStorage) UpdateQuiz(ctx context.Context, req *pb.UpdateQuizRequest) (*pb.UpdateQuizResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-
-func (c *LearningStorage) CreateExtraResourses(req *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error) {
-	id := uuid.NewString()
-	query := `
-		INSERT INTO extra_resources(id, title, type, url)
-		VALUES($1, $2, $3, $4)`
-
-	_, err := c.db.Exec(query, id, req.Title, req.Type, req.Url)
+	tx, err := begin(ctx, c.db)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &pb.CreateExtraResoursesResponse{Id: id, Message: "success"}, nil
-}
+	query := `
+		UPDATE quizzes
+		SET topic_id = $1, title = $2, time_limit_seconds = $3, max_attempts = $4, partial_credit = $5, shuffle = $6, xp = $7
+		WHERE id = $8`
 
-func (c *LearningStorage) GetExtraResourses(req *pb.GetExtraResourcesRequest) (*pb.GetExtraResourcesResponse, error) {
-	query := `SELECT id, title, type, url FROM extra_resources`
-	rows, err := c.db.Query(query)
+	res, err := tx.ExecContext(ctx, query, req.TopicId, req.Title, req.TimeLimitSeconds, req.MaxAttempts, req.PartialCredit, req.Shuffle, req.Xp, req.Id)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	defer rows.Close()
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, st.ErrQuizNotFound
+	}
 
-	var resources []*pb.CreateExtraResourses
-	for rows.Next() {
-		var resource pb.CreateExtraResourses
-		err := rows.Scan(&resource.Id, &resource.Title, &resource.Type, &resource.Url)
+	if len(req.Questions) > 0 {
+		var attempted bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_id = $1)`, req.Id).Scan(&attempted)
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
-		resources = append(resources, &resource)
+		if attempted {
+			return nil, st.ErrQuizAttempted
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM quiz_questions WHERE quiz_id = $1`, req.Id); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := insertQuizQuestions(ctx, tx, req.Id, req.Questions); err != nil {
+			return nil, err
+		}
 	}
-	return &pb.GetExtraResourcesResponse{ExtraResources: resources}, nil
-}
 
-func (c *LearningStorage) UpdateExtraResourses(req *pb.UpdateExtraResoursesRequest) (*pb.UpdateExtraResoursesResponse, error) {
-	query := `
-		UPDATE extra_resources
-		SET title = $1, type = $2, url = $3
-		WHERE id = $4`
-
-	_, err := c.db.Exec(query, req.Title, req.Type, req.Url, req.Id)
-	if err != nil {
+	// Rules only steer future draws, so they can change at any time.
+	if len(req.Rules) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM quiz_template_rules WHERE quiz_id = $1`, req.Id); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := insertTemplateRules(ctx, tx, req.Id, req.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.UpdateExtraResoursesResponse{Message: "success"}, nil
+	return &pb.UpdateQuizResponse{Message: "success"}, nil
 }
 
-func (c *LearningStorage) DeleteExtraResourses(req *pb.DeleteExtraResoursesRequest) (*pb.DeleteExtraResoursesResponse, error) {
-	query := `DELETE FROM extra_resources WHERE id = $1`
+func (c *LearningStorage) DeleteQuiz(ctx context.Context, req *pb.DeleteQuizRequest) (*pb.DeleteQuizResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	_, err := c.db.Exec(query, req.Id)
+	query := `DELETE FROM quizzes WHERE id = $1`
+
+	_, err := c.db.ExecContext(ctx, query, req.Id)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.DeleteExtraResoursesResponse{Message: "success"}, nil
+	return &pb.DeleteQuizResponse{Message: "success"}, nil
 }
 
-func (c *LearningStorage) CompletedExtraResources(req *pb.CompletedExtraResourcesRequest) (*pb.CompletedExtraResourcesResponse, error) {
+func (c *LearningStorage) CreateLearningRecommendations(ctx context.Context, req *pb.CreateLearningRecommendationsRequest) (*pb.CreateLearningRecommendationsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	id := uuid.NewString()
 	query := `
-		INSERT INTO completed_extra_resources (user_id, extra_resource_id)
-		VALUES ($1, $2)`
+		INSERT INTO recommendations(id, type, name, user_id, reason)
+		VALUES($1, $2, $3, $4, $5)`
 
-	_, err := c.db.Exec(query, req.UserId, req.ExtraResourceId)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-	updateQuery := `UPDATE users SET xp = xp + $1 WHERE id = $2`
-	_, err = c.db.Exec(updateQuery, 10, req.UserId)
+	_, err := c.db.ExecContext(ctx, query, id, req.Type, req.Name, req.UserId, req.Reason)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.CompletedExtraResourcesResponse{Message: "success", XpEarned: 10}, nil
+	return &pb.CreateLearningRecommendationsResponse{Id: id, Message: "success"}, nil
+}
+
+var recommendationSorts = map[string]string{
+	"created_at": "created_at",
+	"name":       "name",
+	"type":       "type",
+	"score":      "score",
 }
 
+func (c *LearningStorage) GetLearningRecommendations(ctx context.Context, req *pb.GetLearningRecommendationsRequest) (*pb.GetLearningRecommendationsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-func (c *LearningStorage) GetLearningProgress(req *pb.GetLearningProgressRequest) (*pb.GetLearningProgressResponse, error) {
-	fmt.Println(req.UserId)
-	totalQuery := `SELECT 
-		(SELECT COUNT(*) FROM topics) as total_topics, 
-		(SELECT COUNT(*) FROM quizzes) as total_quizzes,
-		(SELECT COUNT(*) FROM extra_resources) as total_resources,
-		(SELECT COUNT(*) FROM completed_topics WHERE user_id = $1) as completed_topics,
-		(SELECT COUNT(*) FROM completed_extra_resources WHERE user_id = $1) as completed_resources`
-	var progress pb.GetLearningProgressResponse
-	err := c.db.QueryRow(totalQuery, req.UserId).Scan(
-		&progress.TotalTopics, &progress.TotalQuizzes, &progress.TotalResourses,
-		&progress.CompletedTopics, &progress.CompletedResourses)
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", recommendationSorts)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
 
-	// Calculate overall progress
-	progress.OverallProgress = (float32(progress.CompletedTopics+progress.CompletedQuizzes+progress.CompletedResourses) /
-		float32(progress.TotalTopics+progress.TotalQuizzes+progress.TotalResourses)) * 100
-
-	return &progress, nil
-}
-
-func (c *LearningStorage) CreateLearningRecommendations(req *pb.CreateLearningRecommendationsRequest) (*pb.CreateLearningRecommendationsResponse, error) {
-	id := uuid.NewString()
-	query := `
-		INSERT INTO recommendations(id, type, name, user_id, reason)
-		VALUES($1, $2, $3, $4, $5)`
+	f := newFilter()
+	f.Eq("user_id", req.UserId).Eq("type", req.Type)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
 
-	_, err := c.db.Exec(query, id, req.Type, req.Name, req.UserId, req.Reason)
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM recommendations`+f.Sql(), f.Args()...).Scan(&total)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.CreateLearningRecommendationsResponse{Id: id, Message: "success"}, nil
-}
-
-func (c *LearningStorage) GetLearningRecommendations(req *pb.GetLearningRecommendationsRequest) (*pb.GetLearningRecommendationsResponse, error) {
-	query := `SELECT id, type, name, user_id, reason FROM recommendations`
-	rows, err := c.db.Query(query)
+	query, args := p.query(`SELECT id, type, item_id, name, user_id, reason, score, `+p.sortKey()+` FROM recommendations`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -417,80 +484,111 @@ func (c *LearningStorage) GetLearningRecommendations(req *pb.GetLearningRecommen
 	defer rows.Close()
 
 	var recommendations []*pb.Recommendation
+	var keys, ids []string
 	for rows.Next() {
 		var recommendation pb.Recommendation
-		err := rows.Scan(&recommendation.Id, &recommendation.Type, &recommendation.Name, &recommendation.UserId, &recommendation.Reason)
+		var key string
+		err := rows.Scan(&recommendation.Id, &recommendation.Type, &recommendation.ItemId, &recommendation.Name, &recommendation.UserId, &recommendation.Reason, &recommendation.Score, &key)
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
 		recommendations = append(recommendations, &recommendation)
+		keys = append(keys, key)
+		ids = append(ids, recommendation.Id)
 	}
-	return &pb.GetLearningRecommendationsResponse{Recommendations: recommendations}, nil
+	n, token := p.next(keys, ids)
+	return &pb.GetLearningRecommendationsResponse{Recommendations: recommendations[:n], TotalCount: total, NextPageToken: token}, nil
 }
 
-func (c *LearningStorage) CreateLearningFeedback(req *pb.CreateLearningFeedbackRequest) (*pb.CreateLearningFeedbackResponse, error) {
+// CreateLearningHomeworks creates a homework and assigns it to the users and
+// groups asked for, or to its owner if none are.
+func (c *LearningStorage) CreateLearningHomeworks(ctx context.Context, req *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	due, err := dueAt(req.DueAt)
+	if err != nil {
+		return nil, err
+	}
+	rubric, err := rubricJson(req.Rubric)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	id := uuid.NewString()
 	query := `
-		INSERT INTO feedback(id, user_id, topic_id, rating, comment)
-		VALUES($1, $2, $3, $4, $5)`
+		INSERT INTO homeworks(id, user_id, title, description, difficulty, due_at, max_xp, late_policy, late_penalty_percent, max_submissions, rubric)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	_, err := c.db.Exec(query, id, req.UserId, req.TopicId, req.Rating, req.Comment)
+	_, err = tx.ExecContext(ctx, query, id, req.UserId, req.Title, req.Description, req.Difficulty, due,
+		req.MaxXp, req.LatePolicy, req.LatePenaltyPercent, req.MaxSubmissions, rubric)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	updateQuery := `UPDATE users SET xp = xp + $1 WHERE id = $2`
 
-	_, err = c.db.Exec(updateQuery, 10, req.UserId)
+	userIds := req.UserIds
+	if len(userIds) == 0 && len(req.GroupIds) == 0 {
+		userIds = []string{req.UserId}
+	}
+	assigned, err := assignHomework(ctx, tx, id, userIds, req.GroupIds, sql.NullTime{})
 	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.CreateLearningFeedbackResponse{Message: "success", XpEarned: 10}, nil
+	return &pb.CreateLearningHomeworksResponse{Id: id, Message: "success", AssignedUserIds: assigned}, nil
 }
 
-func (c *LearningStorage) GetLearningFeedback(req *pb.GetLearningFeedbackRequest) (*pb.GetLearningFeedbackResponse, error) {
-	query := `SELECT id, user_id, topic_id, rating, comment FROM feedback`
-	rows, err := c.db.Query(query)
+var homeworkSorts = map[string]string{
+	"created_at": "created_at",
+	"title":      "title",
+	"difficulty": "difficulty",
+}
+
+// GetLearningHomeworks lists homeworks. Filtered by user, it lists the
+// homeworks assigned to them, with their due date and where their latest
+// submission stands.
+func (c *LearningStorage) GetLearningHomeworks(ctx context.Context, req *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", homeworkSorts)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
-	defer rows.Close()
 
-	var feedbacks []*pb.LearningFeedback
-	for rows.Next() {
-		var feedback pb.LearningFeedback
-		err := rows.Scan(&feedback.Id, &feedback.UserId, &feedback.TopicId, &feedback.Rating, &feedback.Comment)
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-		feedbacks = append(feedbacks, &feedback)
+	f := newFilter()
+	f.In("difficulty", req.Difficulty)
+	if req.UserId != "" {
+		f.Where("id IN (SELECT homework_id FROM homework_assignments WHERE user_id = ?)", req.UserId)
+	}
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
 	}
-	return &pb.GetLearningFeedbackResponse{Feedback: feedbacks}, nil
-}
-
-func (c *LearningStorage) CreateLearningHomeworks(req *pb.CreateLearningHomeworksRequest) (*pb.CreateLearningHomeworksResponse, error) {
-	id := uuid.NewString()
-	query := `
-		INSERT INTO homeworks(id, user_id, title, description, difficulty)
-		VALUES($1, $2, $3, $4, $5)`
 
-	_, err := c.db.Exec(query, id, req.UserId, req.Title, req.Description, req.Difficulty)
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM homeworks`+f.Sql(), f.Args()...).Scan(&total)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &pb.CreateLearningHomeworksResponse{Id: id, Message: "success"}, nil
-}
-
-func (c *LearningStorage) GetLearningHomeworks(req *pb.GetLearningHomeworksRequest) (*pb.GetLearningHomeworksResponse, error) {
-	query := `SELECT id, user_id, title, description, difficulty FROM homeworks`
-	rows, err := c.db.Query(query)
+	query, args := p.query(`
+		SELECT id, user_id, title, description, difficulty, due_at, max_xp, late_policy, late_penalty_percent, max_submissions, rubric,
+			`+p.sortKey()+` FROM homeworks`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -498,39 +596,82 @@ func (c *LearningStorage) GetLearningHomeworks(req *pb.GetLearningHomeworksReque
 	defer rows.Close()
 
 	var homeworks []*pb.LearningHomeworks
+	var keys, ids []string
 	for rows.Next() {
 		var homework pb.LearningHomeworks
-		err := rows.Scan(&homework.Id, &homework.UserId, &homework.Title, &homework.Description, &homework.Difficulty)
+		var key string
+		var due sql.NullTime
+		var rubric []byte
+		err := rows.Scan(&homework.Id, &homework.UserId, &homework.Title, &homework.Description, &homework.Difficulty, &due,
+			&homework.MaxXp, &homework.LatePolicy, &homework.LatePenaltyPercent, &homework.MaxSubmissions, &rubric, &key)
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
+		var criteria []*models.RubricCriterion
+		if err := json.Unmarshal(rubric, &criteria); err != nil {
+			return nil, err
+		}
+		homework.Rubric = rubricToPb(criteria)
+		if due.Valid {
+			homework.DueAt = due.Time.Format(time.RFC3339)
+		}
 		homeworks = append(homeworks, &homework)
+		keys = append(keys, key)
+		ids = append(ids, homework.Id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return &pb.GetLearningHomeworksResponse{Homeworks: homeworks}, nil
+	n, token := p.next(keys, ids)
+	homeworks = homeworks[:n]
+	if req.UserId != "" {
+		if err := c.homeworkStanding(ctx, req.UserId, homeworks); err != nil {
+			return nil, err
+		}
+	}
+	return &pb.GetLearningHomeworksResponse{Homeworks: homeworks, TotalCount: total, NextPageToken: token}, nil
 }
 
-func (c *LearningStorage) SubmitHomework(req *pb.SubmitHomeworkRequest) (*pb.SubmitHomeworkResponse, error) {
-	id := uuid.NewString()
+// homeworkStanding fills in the user's due date, where it overrides the
+// homework's, and the status and score of their latest submission.
+func (c *LearningStorage) homeworkStanding(ctx context.Context, userId string, homeworks []*pb.LearningHomeworks) error {
+	byId := make(map[string]*pb.LearningHomeworks, len(homeworks))
+	ids := make([]string, len(homeworks))
+	for i, hw := range homeworks {
+		byId[hw.Id] = hw
+		ids[i] = hw.Id
+	}
 	query := `
-		INSERT INTO submitted_homeworks (id, user_id, homework_id, xp_earned)
-		VALUES ($1, $2, $3, $4)`
-
-	_, err := c.db.Exec(query, id, req.UserId, req.HomeworkId, req.XpEarned)
+		SELECT a.homework_id, a.due_at, COALESCE(s.status, ''), COALESCE(s.score, 0), COALESCE(s.max_score, 0), COALESCE(s.attempt, 0)
+		FROM homework_assignments a
+		LEFT JOIN LATERAL (
+			SELECT status, score, max_score, attempt FROM submitted_homeworks
+			WHERE homework_id = a.homework_id AND user_id = a.user_id
+			ORDER BY attempt DESC LIMIT 1
+		) s ON true
+		WHERE a.user_id = $1 AND a.homework_id = ANY($2)`
+	rows, err := c.db.QueryContext(ctx, query, userId, pq.Array(ids))
 	if err != nil {
 		log.Println(err)
-		return nil, err
+		return err
 	}
-
-	updateQuery := `UPDATE users SET xp = xp + $1 WHERE id = $2`
-
-	_, err = c.db.Exec(updateQuery, req.XpEarned, req.UserId)
-	if err != nil {
-		log.Println(err)
-		return nil, err
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var due sql.NullTime
+		var status string
+		var score, maxScore float32
+		var attempts int32
+		if err := rows.Scan(&id, &due, &status, &score, &maxScore, &attempts); err != nil {
+			log.Println(err)
+			return err
+		}
+		hw := byId[id]
+		if due.Valid {
+			hw.DueAt = due.Time.Format(time.RFC3339)
+		}
+		hw.SubmissionStatus, hw.Score, hw.MaxScore, hw.Submissions = status, score, maxScore, attempts
 	}
-
-	return &pb.SubmitHomeworkResponse{Message: "success", XpEarned: req.XpEarned}, nil
+	return rows.Err()
 }
-
-
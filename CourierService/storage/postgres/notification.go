@@ -0,0 +1,423 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+
+	"github.com/lib/pq"
+)
+
+type NotificationStorage struct {
+	db conn
+}
+
+func NewNotificationStorage(db conn) *NotificationStorage {
+	return &NotificationStorage{db: db}
+}
+
+// CreateNotification is idempotent on the notification id so that a
+// redelivered event doesn't notify the user twice.
+func (c *NotificationStorage) CreateNotification(ctx context.Context, n *models.Notification, deliveries []*models.Delivery) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notifications(id, user_id, type, title, body, in_app)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, n.Id, n.UserId, n.Type, n.Title, n.Body, n.InApp)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil
+	}
+
+	for _, d := range deliveries {
+		query := `
+			INSERT INTO notification_deliveries(notification_id, channel, send_after)
+			VALUES($1, $2, $3)
+			ON CONFLICT (notification_id, channel) DO NOTHING`
+		_, err := tx.ExecContext(ctx, query, n.Id, d.Channel, d.SendAfter)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *NotificationStorage) GetNotificationSettings(ctx context.Context, userId, eventType string) (*models.NotificationSettings, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	settings := models.NotificationSettings{Channels: map[string]bool{}, Timezone: "UTC"}
+
+	// '*' rows come first so the event-specific rows override them.
+	query := `
+		SELECT channel, enabled FROM notification_preferences
+		WHERE user_id = $1 AND event_type IN ($2, '*')
+		ORDER BY event_type = '*' DESC`
+	rows, err := c.db.QueryContext(ctx, query, userId, eventType)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			channel string
+			enabled bool
+		)
+		if err := rows.Scan(&channel, &enabled); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		settings.Channels[channel] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var start, end sql.NullInt32
+	query = `SELECT quiet_start, quiet_end, timezone FROM notification_settings WHERE user_id = $1`
+	err = c.db.QueryRowContext(ctx, query, userId).Scan(&start, &end, &settings.Timezone)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, err
+	}
+	if start.Valid && end.Valid {
+		s, e := int(start.Int32), int(end.Int32)
+		settings.QuietStart, settings.QuietEnd = &s, &e
+	}
+	return &settings, nil
+}
+
+func (c *NotificationStorage) GetNotifications(ctx context.Context, req *pb.GetNotificationsRequest) (*pb.GetNotificationsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := `
+		SELECT id, user_id, type, title, body, read_at IS NOT NULL, created_at
+		FROM notifications
+		WHERE user_id = $1 AND in_app`
+	if req.UnreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC, id LIMIT $2 OFFSET $3`
+
+	rows, err := c.db.QueryContext(ctx, query, req.UserId, limit, req.Offset)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*pb.Notification{}
+	for rows.Next() {
+		var (
+			n         pb.Notification
+			createdAt time.Time
+		)
+		err := rows.Scan(&n.Id, &n.UserId, &n.Type, &n.Title, &n.Body, &n.IsRead, &createdAt)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		n.CreatedAt = createdAt.Format(time.RFC3339)
+		notifications = append(notifications, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := pb.GetNotificationsResponse{Notifications: notifications}
+	query = `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE read_at IS NULL)
+		FROM notifications WHERE user_id = $1 AND in_app`
+	err = c.db.QueryRowContext(ctx, query, req.UserId).Scan(&res.TotalCount, &res.UnreadCount)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *NotificationStorage) MarkNotificationsRead(ctx context.Context, req *pb.MarkNotificationsReadRequest) (*pb.MarkNotificationsReadResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+	args := []interface{}{req.UserId}
+	if len(req.Ids) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, pq.Array(req.Ids))
+	}
+
+	res, err := c.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MarkNotificationsReadResponse{Message: "success", Updated: int32(updated)}, nil
+}
+
+func (c *NotificationStorage) GetNotificationPreferences(ctx context.Context, req *pb.GetNotificationPreferencesRequest) (*pb.GetNotificationPreferencesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res := pb.GetNotificationPreferencesResponse{Timezone: "UTC", Preferences: []*pb.NotificationPreference{}}
+
+	query := `SELECT event_type, channel, enabled FROM notification_preferences WHERE user_id = $1 ORDER BY event_type, channel`
+	rows, err := c.db.QueryContext(ctx, query, req.UserId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p pb.NotificationPreference
+		if err := rows.Scan(&p.EventType, &p.Channel, &p.Enabled); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		res.Preferences = append(res.Preferences, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var start, end sql.NullInt32
+	query = `SELECT quiet_start, quiet_end, timezone FROM notification_settings WHERE user_id = $1`
+	err = c.db.QueryRowContext(ctx, query, req.UserId).Scan(&start, &end, &res.Timezone)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, err
+	}
+	if start.Valid && end.Valid {
+		res.QuietStart = formatMinutes(int(start.Int32))
+		res.QuietEnd = formatMinutes(int(end.Int32))
+	}
+	return &res, nil
+}
+
+func (c *NotificationStorage) UpdateNotificationPreferences(ctx context.Context, req *pb.UpdateNotificationPreferencesRequest) (*pb.UpdateNotificationPreferencesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var start, end sql.NullInt32
+	if req.QuietStart != "" || req.QuietEnd != "" {
+		s, err := parseMinutes(req.QuietStart)
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseMinutes(req.QuietEnd)
+		if err != nil {
+			return nil, err
+		}
+		start = sql.NullInt32{Int32: int32(s), Valid: true}
+		end = sql.NullInt32{Int32: int32(e), Valid: true}
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", timezone)
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notification_settings(user_id, quiet_start, quiet_end, timezone)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end, timezone = EXCLUDED.timezone`
+	if _, err := tx.ExecContext(ctx, query, req.UserId, start, end, timezone); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	for _, p := range req.Preferences {
+		query := `
+			INSERT INTO notification_preferences(user_id, event_type, channel, enabled)
+			VALUES($1, $2, $3, $4)
+			ON CONFLICT (user_id, event_type, channel) DO UPDATE SET enabled = EXCLUDED.enabled`
+		if _, err := tx.ExecContext(ctx, query, req.UserId, p.EventType, p.Channel, p.Enabled); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.UpdateNotificationPreferencesResponse{Message: "success"}, nil
+}
+
+func (c *NotificationStorage) SavePushSubscription(ctx context.Context, req *pb.SavePushSubscriptionRequest) (*pb.SavePushSubscriptionResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO push_subscriptions(endpoint, user_id, p256dh, auth)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (endpoint) DO UPDATE
+		SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth`
+
+	_, err := c.db.ExecContext(ctx, query, req.Endpoint, req.UserId, req.P256Dh, req.Auth)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &pb.SavePushSubscriptionResponse{Message: "success"}, nil
+}
+
+func (c *NotificationStorage) GetPushSubscriptions(ctx context.Context, userId string) ([]*models.PushSubscription, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT endpoint, user_id, p256dh, auth FROM push_subscriptions WHERE user_id = $1`
+	rows, err := c.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*models.PushSubscription
+	for rows.Next() {
+		var sub models.PushSubscription
+		if err := rows.Scan(&sub.Endpoint, &sub.UserId, &sub.P256dh, &sub.Auth); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
+
+func (c *NotificationStorage) DeletePushSubscription(ctx context.Context, endpoint string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `DELETE FROM push_subscriptions WHERE endpoint = $1`, endpoint)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries. The lease is the
+// pushed-back send_after, so a dispatcher that dies mid-send gives its work
+// back after five minutes and concurrent dispatchers never share a row.
+func (c *NotificationStorage) ClaimDueDeliveries(ctx context.Context, limit int) ([]*models.Delivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH due AS (
+			SELECT id FROM notification_deliveries
+			WHERE status = 'pending' AND send_after <= NOW()
+			ORDER BY send_after
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE notification_deliveries d
+		SET attempts = d.attempts + 1, send_after = NOW() + INTERVAL '5 minutes'
+		FROM due, notifications n
+		WHERE d.id = due.id AND n.id = d.notification_id
+		RETURNING d.id, d.channel, d.attempts, n.id, n.user_id, n.type, n.title, n.body`
+
+	rows, err := c.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.Delivery
+	for rows.Next() {
+		var d models.Delivery
+		err := rows.Scan(&d.Id, &d.Channel, &d.Attempts,
+			&d.Notification.Id, &d.Notification.UserId, &d.Notification.Type, &d.Notification.Title, &d.Notification.Body)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (c *NotificationStorage) CompleteDelivery(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE notification_deliveries SET status = 'sent', sent_at = NOW(), last_error = NULL WHERE id = $1`
+	_, err := c.db.ExecContext(ctx, query, id)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (c *NotificationStorage) FailDelivery(ctx context.Context, id string, cause error, retryAfter time.Duration, final bool) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	status := "pending"
+	if final {
+		status = "failed"
+	}
+	query := `
+		UPDATE notification_deliveries
+		SET status = $1, last_error = $2, send_after = NOW() + $3 * INTERVAL '1 second'
+		WHERE id = $4`
+	_, err := c.db.ExecContext(ctx, query, status, cause.Error(), int(retryAfter.Seconds()), id)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func parseMinutes(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", hhmm)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatMinutes(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"learning-service/config"
+
+	"github.com/spf13/cast"
+)
+
+const maxLimit = 100
+
+var (
+	defaultLimit  = 10
+	defaultOffset = 0
+
+	ErrInvalidPageToken = errors.New("invalid page token")
+	ErrInvalidSort      = errors.New("invalid sort field")
+)
+
+// setPageDefaults applies DEFAULT_LIMIT and DEFAULT_OFFSET to requests
+// that leave them out.
+func setPageDefaults(cfg config.Config) {
+	if l := cast.ToInt(cfg.DefaultLimit); l > 0 {
+		defaultLimit = l
+	}
+	if o := cast.ToInt(cfg.DefaultOffset); o > 0 {
+		defaultOffset = o
+	}
+}
+
+// pageCursor is what a next-page token encodes: the sort key and id of the
+// last row returned, plus the ordering it belongs to.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	Id    string `json:"id"`
+}
+
+// page is one request's pagination and ordering. With a page token it pages
+// by keyset on (sort column, id); otherwise by limit/offset. Either way id
+// breaks ties so the order is stable.
+type page struct {
+	limit  int
+	offset int
+	sort   string
+	column string
+	desc   bool
+	cursor *pageCursor
+}
+
+// newPage validates the request. sortable maps the sort names clients may
+// use to SQL columns. Without an explicit order the newest/largest come first.
+func newPage(limit, offset int32, token, sortBy, order string, defaultSort string, sortable map[string]string) (*page, error) {
+	p := &page{limit: int(limit), offset: int(offset), sort: defaultSort, desc: true}
+	if p.limit <= 0 {
+		p.limit = defaultLimit
+	}
+	if p.limit > maxLimit {
+		p.limit = maxLimit
+	}
+	if p.offset <= 0 {
+		p.offset = defaultOffset
+	}
+
+	if sortBy != "" {
+		p.sort = sortBy
+	}
+	switch strings.ToLower(order) {
+	case "asc":
+		p.desc = false
+	case "", "desc":
+	default:
+		return nil, fmt.Errorf("invalid sort order %q", order)
+	}
+
+	column, ok := sortable[p.sort]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSort, p.sort)
+	}
+	p.column = column
+
+	if token != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return nil, ErrInvalidPageToken
+		}
+		c := pageCursor{}
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, ErrInvalidPageToken
+		}
+		// A token only makes sense for the ordering it was issued for.
+		if c.Sort != p.sort || c.Desc != p.desc {
+			return nil, ErrInvalidPageToken
+		}
+		p.cursor = &c
+		p.offset = 0
+	}
+	return p, nil
+}
+
+// sortKey is selected as the last column of every paged query so that the
+// next-page token can be built from the last row.
+func (p *page) sortKey() string {
+	return p.column + "::text"
+}
+
+// query builds the page's SELECT from base, which must not have a WHERE
+// clause of its own: the filter's conditions, the keyset condition (if any),
+// ORDER BY with id as tiebreak and LIMIT/OFFSET are appended.
+func (p *page) query(base string, f *filter, idColumn string) (string, []interface{}) {
+	f = f.clone()
+	dir, cmp := "ASC", ">"
+	if p.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if p.cursor != nil {
+		f.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", p.column, idColumn, cmp), p.cursor.Value, p.cursor.Id)
+	}
+	query := base + f.Sql()
+	args := f.Args()
+	args = append(args, p.limit+1, p.offset)
+	query += fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT $%d OFFSET $%d", p.column, dir, idColumn, dir, len(args)-1, len(args))
+	return query, args
+}
+
+// next reports how many of the fetched rows belong to the page and the
+// token of the following page, if there is one. apply fetches one row more
+// than the limit to find out.
+func (p *page) next(keys, ids []string) (int, string) {
+	if len(ids) <= p.limit {
+		return len(ids), ""
+	}
+	i := p.limit - 1
+	raw, _ := json.Marshal(pageCursor{Sort: p.sort, Desc: p.desc, Value: keys[i], Id: ids[i]})
+	return p.limit, base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// parseDate accepts RFC 3339 timestamps and plain dates.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
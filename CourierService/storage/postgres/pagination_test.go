@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var testSortable = map[string]string{"created_at": "t.created_at", "name": "t.name"}
+
+func TestNewPage(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit, offset int32
+		sortBy, order string
+		wantLimit     int
+		wantOffset    int
+		wantColumn    string
+		wantDesc      bool
+		wantErr       bool
+		errIs         error
+	}{
+		{name: "defaults", wantLimit: defaultLimit, wantOffset: defaultOffset, wantColumn: "t.created_at", wantDesc: true},
+		{name: "capped", limit: 500, offset: 20, wantLimit: maxLimit, wantOffset: 20, wantColumn: "t.created_at", wantDesc: true},
+		{name: "ascending", limit: 5, sortBy: "name", order: "ASC", wantLimit: 5, wantColumn: "t.name"},
+		{name: "unknown sort", sortBy: "password", wantErr: true, errIs: ErrInvalidSort},
+		{name: "unknown order", order: "sideways", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPage(tt.limit, tt.offset, "", tt.sortBy, tt.order, "created_at", testSortable)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newPage() succeeded, want an error")
+				}
+				if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+					t.Fatalf("newPage() = %v, want %v", err, tt.errIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.limit != tt.wantLimit || p.offset != tt.wantOffset || p.column != tt.wantColumn || p.desc != tt.wantDesc {
+				t.Errorf("page = limit %d offset %d column %s desc %t, want %d %d %s %t",
+					p.limit, p.offset, p.column, p.desc, tt.wantLimit, tt.wantOffset, tt.wantColumn, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestPageNext(t *testing.T) {
+	rows := func(n int) (keys, ids []string) {
+		for i := 1; i <= n; i++ {
+			keys = append(keys, fmt.Sprintf("k%d", i))
+			ids = append(ids, fmt.Sprintf("id%d", i))
+		}
+		return keys, ids
+	}
+	tests := []struct {
+		name      string
+		fetched   int
+		wantRows  int
+		wantToken bool
+	}{
+		{"short page", 2, 2, false},
+		{"exactly the limit", 3, 3, false},
+		{"one more than the limit", 4, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPage(3, 0, "", "", "", "created_at", testSortable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, token := p.next(rows(tt.fetched))
+			if n != tt.wantRows {
+				t.Errorf("next() kept %d rows, want %d", n, tt.wantRows)
+			}
+			if (token != "") != tt.wantToken {
+				t.Errorf("next() token = %q, want one: %t", token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	first, err := newPage(2, 5, "", "name", "asc", "created_at", testSortable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token := first.next([]string{"a", "b", "c"}, []string{"id1", "id2", "id3"})
+	if token == "" {
+		t.Fatal("no token for a full page")
+	}
+
+	second, err := newPage(2, 5, token, "name", "asc", "created_at", testSortable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The cursor is the last row of the page, not the extra row fetched.
+	want := &pageCursor{Sort: "name", Desc: false, Value: "b", Id: "id2"}
+	if !reflect.DeepEqual(second.cursor, want) {
+		t.Errorf("cursor = %+v, want %+v", second.cursor, want)
+	}
+	if second.offset != 0 {
+		t.Errorf("offset = %d with a token, want 0", second.offset)
+	}
+
+	query, args := second.query("SELECT t.id FROM topics t", newFilter().Eq("t.status", "published"), "t.id")
+	wantQuery := "SELECT t.id FROM topics t WHERE t.status = $1 AND (t.name, t.id) > ($2, $3) ORDER BY t.name ASC, t.id ASC LIMIT $4 OFFSET $5"
+	if query != wantQuery {
+		t.Errorf("query =\n%s\nwant\n%s", query, wantQuery)
+	}
+	wantArgs := []interface{}{"published", "b", "id2", 3, 0}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+
+	// A token is only good for the ordering it came from.
+	for _, order := range []string{"desc", ""} {
+		if _, err := newPage(2, 0, token, "name", order, "created_at", testSortable); !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("token with order %q: %v, want %v", order, err, ErrInvalidPageToken)
+		}
+	}
+	if _, err := newPage(2, 0, token, "created_at", "asc", "created_at", testSortable); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("token with another sort: %v, want %v", err, ErrInvalidPageToken)
+	}
+	if _, err := newPage(2, 0, "not a token!", "", "", "created_at", testSortable); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("garbage token: %v, want %v", err, ErrInvalidPageToken)
+	}
+}
+
+func TestPageQueryWithoutToken(t *testing.T) {
+	p, err := newPage(10, 20, "", "", "", "created_at", testSortable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFilter().In("t.type", "quiz,topic")
+	query, args := p.query("SELECT t.id FROM topics t", f, "t.id")
+	want := "SELECT t.id FROM topics t WHERE t.type IN ($1, $2) ORDER BY t.created_at DESC, t.id DESC LIMIT $3 OFFSET $4"
+	if query != want {
+		t.Errorf("query =\n%s\nwant\n%s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"quiz", "topic", 11, 20}) {
+		t.Errorf("args = %#v", args)
+	}
+	if len(f.Args()) != 2 {
+		t.Errorf("query changed the caller's filter: %d args", len(f.Args()))
+	}
+}
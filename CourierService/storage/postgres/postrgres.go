@@ -1,22 +1,37 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	
 	"learning-service/config"
+	"learning-service/migrations"
 	st "learning-service/storage"
 
 	_ "github.com/lib/pq"
 )
 
 type PostgresStorage struct {
-	db *sql.DB
+	db conn
 	learning st.Learning
+	notification st.Notification
+	quiz st.Quiz
+	xp st.Xp
+	profile st.Profile
+	achievement st.Achievement
+	course st.Course
+	recommendation st.Recommendation
+	review st.Review
+	homework st.Homework
+	file st.File
+	search st.Search
+	content st.Content
 }
 
-func NewpostgresStorage() (st.InitRoot, error) {
-	config := config.Load()
+// Connect opens and pings the learning database.
+func Connect(config config.Config) (*sql.DB, error) {
 	con := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", config.PostgresHost, config.PostgresPort, config.PostgresUser, config.PostgresPassword, config.PostgresDatabase)
 	db, err := sql.Open("postgres", con)
 	if err != nil {
@@ -26,7 +41,24 @@ func NewpostgresStorage() (st.InitRoot, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PostgresStorage{db:db, learning: &LearningStorage{db}}, nil
+	return db, nil
+}
+
+// NewpostgresStorage refuses a database whose schema is behind the
+// migrations built into the binary.
+func NewpostgresStorage() (st.InitRoot, error) {
+	config := config.Load()
+	db, err := Connect(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrations.Check(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	setPageDefaults(config)
+	setQueryTimeout(config)
+	return &PostgresStorage{db:db, learning: &LearningStorage{db}, notification: &NotificationStorage{db}, quiz: &QuizStorage{db}, xp: &XpStorage{db}, profile: &ProfileStorage{db}, achievement: &AchievementStorage{db}, course: &CourseStorage{db}, recommendation: &RecommendationStorage{db}, review: &ReviewStorage{db}, homework: &HomeworkStorage{db}, file: &FileStorage{db}, search: &SearchStorage{db}, content: &ContentStorage{db}}, nil
 }
 
 func (s *PostgresStorage) Learning() st.Learning {
@@ -36,3 +68,104 @@ func (s *PostgresStorage) Learning() st.Learning {
 	return s.learning
 }
 
+func (s *PostgresStorage) Notification() st.Notification {
+	if s.notification == nil {
+		s.notification = &NotificationStorage{s.db}
+	}
+	return s.notification
+}
+
+func (s *PostgresStorage) Quiz() st.Quiz {
+	if s.quiz == nil {
+		s.quiz = &QuizStorage{s.db}
+	}
+	return s.quiz
+}
+
+func (s *PostgresStorage) Xp() st.Xp {
+	if s.xp == nil {
+		s.xp = &XpStorage{s.db}
+	}
+	return s.xp
+}
+
+func (s *PostgresStorage) Profile() st.Profile {
+	if s.profile == nil {
+		s.profile = &ProfileStorage{s.db}
+	}
+	return s.profile
+}
+
+func (s *PostgresStorage) Achievement() st.Achievement {
+	if s.achievement == nil {
+		s.achievement = &AchievementStorage{s.db}
+	}
+	return s.achievement
+}
+
+func (s *PostgresStorage) Course() st.Course {
+	if s.course == nil {
+		s.course = &CourseStorage{s.db}
+	}
+	return s.course
+}
+
+func (s *PostgresStorage) Recommendation() st.Recommendation {
+	if s.recommendation == nil {
+		s.recommendation = &RecommendationStorage{s.db}
+	}
+	return s.recommendation
+}
+
+func (s *PostgresStorage) Review() st.Review {
+	if s.review == nil {
+		s.review = &ReviewStorage{s.db}
+	}
+	return s.review
+}
+
+func (s *PostgresStorage) Homework() st.Homework {
+	if s.homework == nil {
+		s.homework = &HomeworkStorage{s.db}
+	}
+	return s.homework
+}
+
+func (s *PostgresStorage) File() st.File {
+	if s.file == nil {
+		s.file = &FileStorage{s.db}
+	}
+	return s.file
+}
+
+func (s *PostgresStorage) Search() st.Search {
+	if s.search == nil {
+		s.search = &SearchStorage{s.db}
+	}
+	return s.search
+}
+
+func (s *PostgresStorage) Content() st.Content {
+	if s.content == nil {
+		s.content = &ContentStorage{s.db}
+	}
+	return s.content
+}
+
+func (s *PostgresStorage) WithTx(ctx context.Context, fn func(tx st.InitRoot) error) error {
+	tx, err := begin(ctx, s.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&PostgresStorage{db: &unitOfWork{tx.Tx}}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
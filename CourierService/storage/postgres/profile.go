@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+)
+
+type ProfileStorage struct {
+	db conn
+}
+
+const profileColumns = `user_id, xp, level, current_streak, longest_streak, streak_freezes, last_active_on, daily_goal, timezone`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProfile(row scanner, extra ...interface{}) (*models.LearningProfile, error) {
+	p := models.LearningProfile{}
+	var lastActive sql.NullTime
+	dest := []interface{}{&p.UserId, &p.Xp, &p.Level, &p.CurrentStreak, &p.LongestStreak, &p.StreakFreezes, &lastActive, &p.DailyGoal, &p.Timezone}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	p.LastActiveOn = lastActive.Time
+	return &p, nil
+}
+
+// lockProfile loads a user's profile for the rest of tx, opening it first if
+// need be. A deleted profile is not found.
+func lockProfile(ctx context.Context, tx *txn, userId string) (*models.LearningProfile, error) {
+	_, err := tx.ExecContext(ctx, `INSERT INTO learning_profiles(user_id) VALUES($1) ON CONFLICT DO NOTHING`, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var deleted bool
+	query := `SELECT ` + profileColumns + `, deleted_at IS NOT NULL FROM learning_profiles WHERE user_id = $1 FOR UPDATE`
+	p, err := scanProfile(tx.QueryRowContext(ctx, query, userId), &deleted)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if deleted {
+		return nil, st.ErrProfileNotFound
+	}
+	return p, nil
+}
+
+func saveStreak(ctx context.Context, tx *txn, p *models.LearningProfile) error {
+	var lastActive sql.NullTime
+	if !p.LastActiveOn.IsZero() {
+		lastActive = sql.NullTime{Time: p.LastActiveOn, Valid: true}
+	}
+	query := `
+		UPDATE learning_profiles
+		SET current_streak = $1, longest_streak = $2, streak_freezes = $3, last_active_on = $4, updated_at = now()
+		WHERE user_id = $5`
+	_, err := tx.ExecContext(ctx, query, p.CurrentStreak, p.LongestStreak, p.StreakFreezes, lastActive, p.UserId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// CreateProfile opens a learning profile for a new auth user. It is a no-op
+// if an award already opened one, or if the user has since been deleted.
+func (c *ProfileStorage) CreateProfile(ctx context.Context, userId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `INSERT INTO learning_profiles(user_id) VALUES($1) ON CONFLICT DO NOTHING`, userId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteProfile forgets a deleted auth user's progress. The profile row is
+// kept, marked deleted, so that awards still in flight are refused.
+func (c *ProfileStorage) DeleteProfile(ctx context.Context, userId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO learning_profiles(user_id, deleted_at) VALUES($1, now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			xp = 0, level = 1, current_streak = 0, longest_streak = 0, streak_freezes = 0, last_active_on = NULL,
+			avatar_file_id = NULL, updated_at = now(), deleted_at = COALESCE(learning_profiles.deleted_at, now())`
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		log.Println(err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM xp_ledger WHERE user_id = $1`, userId); err != nil {
+		log.Println(err)
+		return err
+	}
+	for _, table := range []string{"learning_activity", "achievement_progress", "game_level_completions", "course_enrollments", "recommendations", "review_cards", "review_log", "homework_assignments", "study_group_members"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userId); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (c *ProfileStorage) GetProfile(ctx context.Context, userId string) (*models.LearningProfile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + profileColumns + ` FROM learning_profiles WHERE user_id = $1 AND deleted_at IS NULL`
+	p, err := scanProfile(c.db.QueryRowContext(ctx, query, userId))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrProfileNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// RecordActivity counts a completion, quiz submission, homework or reviews
+// towards the user's streak and the local day it happened on. Activity of a
+// deleted user is dropped.
+func (c *ProfileStorage) RecordActivity(ctx context.Context, userId string, xp, reviews int32, at time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	p, err := lockProfile(ctx, tx, userId)
+	if errors.Is(err, st.ErrProfileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	day := p.Day(at)
+	p.Touch(day)
+	if err := saveStreak(ctx, tx, p); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO learning_activity(user_id, day, xp, activities, reviews) VALUES($1, $2, $3, 1, $4)
+		ON CONFLICT (user_id, day) DO UPDATE
+		SET xp = learning_activity.xp + EXCLUDED.xp, activities = learning_activity.activities + 1,
+			reviews = learning_activity.reviews + EXCLUDED.reviews`
+	if _, err := tx.ExecContext(ctx, query, userId, day, xp, reviews); err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// GetActivity lists the local days from..to, inclusive, the user was active
+// on.
+func (c *ProfileStorage) GetActivity(ctx context.Context, userId string, from, to time.Time) ([]*models.DailyActivity, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT day, xp, activities, reviews FROM learning_activity
+		WHERE user_id = $1 AND day BETWEEN $2 AND $3
+		ORDER BY day`
+	rows, err := c.db.QueryContext(ctx, query, userId, from, to)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []*models.DailyActivity
+	for rows.Next() {
+		d := models.DailyActivity{}
+		if err := rows.Scan(&d.Day, &d.Xp, &d.Activities, &d.Reviews); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		days = append(days, &d)
+	}
+	return days, rows.Err()
+}
+
+// SetDailyGoal changes the user's daily XP goal and time zone. Zero values
+// keep the current ones.
+func (c *ProfileStorage) SetDailyGoal(ctx context.Context, req *pb.SetDailyGoalRequest) (*pb.SetDailyGoalResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.db.ExecContext(ctx, `INSERT INTO learning_profiles(user_id) VALUES($1) ON CONFLICT DO NOTHING`, req.UserId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	query := `
+		UPDATE learning_profiles
+		SET daily_goal = COALESCE(NULLIF($2, 0), daily_goal), timezone = COALESCE(NULLIF($3, ''), timezone), updated_at = now()
+		WHERE user_id = $1 AND deleted_at IS NULL`
+	res, err := c.db.ExecContext(ctx, query, req.UserId, req.DailyGoal, req.Timezone)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, st.ErrProfileNotFound
+	}
+	return &pb.SetDailyGoalResponse{Message: "success"}, nil
+}
+
+// BuyStreakFreeze spends models.StreakFreezeCost XP on a streak freeze.
+func (c *ProfileStorage) BuyStreakFreeze(ctx context.Context, userId string) (*models.LearningProfile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	p, err := lockProfile(ctx, tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if p.StreakFreezes >= models.MaxStreakFreezes {
+		return nil, st.ErrStreakFreezeLimit
+	}
+	if p.Xp < models.StreakFreezeCost {
+		return nil, st.ErrNotEnoughXp
+	}
+
+	entry := &models.XpEntry{
+		UserId:     userId,
+		SourceType: models.XpSourceStreakFreeze,
+		SourceId:   uuid.NewString(),
+		Amount:     -models.StreakFreezeCost,
+		Reason:     "streak freeze bought",
+	}
+	if _, err := awardXp(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	p.Xp -= models.StreakFreezeCost
+	p.Level = models.Level(p.Xp)
+	p.StreakFreezes++
+	if err := saveStreak(ctx, tx, p); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// ClaimStreaksAtRisk returns up to limit users whose streak ends with their
+// local day, once it is hour o'clock or later there, and marks them so that
+// each is returned at most once a day. Streaks found already broken are
+// reset on the way. Concurrent callers claim disjoint users.
+func (c *ProfileStorage) ClaimStreaksAtRisk(ctx context.Context, hour int32, limit int) ([]*models.LearningProfile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + profileColumns + ` FROM learning_profiles
+		WHERE deleted_at IS NULL AND current_streak > 0
+		  AND last_active_on < (now() AT TIME ZONE timezone)::date
+		  AND streak_nudged_on IS DISTINCT FROM (now() AT TIME ZONE timezone)::date
+		  AND extract(hour FROM now() AT TIME ZONE timezone) >= $1
+		ORDER BY last_active_on
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, hour, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var candidates []*models.LearningProfile
+	for rows.Next() {
+		p, err := scanProfile(rows)
+		if err != nil {
+			rows.Close()
+			log.Println(err)
+			return nil, err
+		}
+		candidates = append(candidates, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var atRisk []*models.LearningProfile
+	for _, p := range candidates {
+		day := p.Day(now)
+		if p.StreakOn(day) == 0 {
+			p.CurrentStreak = 0
+		} else if p.AtRisk(day) {
+			atRisk = append(atRisk, p)
+		}
+		query := `UPDATE learning_profiles SET current_streak = $1, streak_nudged_on = $2 WHERE user_id = $3`
+		if _, err := tx.ExecContext(ctx, query, p.CurrentStreak, day, p.UserId); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return atRisk, nil
+}
+
+func (c *ProfileStorage) GetUserIds(ctx context.Context, after string, limit int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT user_id FROM learning_profiles
+		WHERE deleted_at IS NULL AND user_id::text > $1
+		ORDER BY user_id::text
+		LIMIT $2`
+	rows, err := c.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,257 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+)
+
+// progressDays is the report range when the request sets no start.
+const progressDays = 30
+
+// maxProgressDays caps the range, so a daily series stays a sensible size.
+const maxProgressDays = 366
+
+// progressRange resolves the report's [from, to) range. A plain "to" date
+// includes the whole day.
+func progressRange(from, to string) (time.Time, time.Time, error) {
+	end := time.Now().UTC()
+	if to != "" {
+		t, err := parseDate(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -progressDays)
+	if from != "" {
+		t, err := parseDate(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	if end.Sub(start) > maxProgressDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("date range is limited to 366 days")
+	}
+	return start, end, nil
+}
+
+func percent(done, total int32) float32 {
+	if total == 0 {
+		return 0
+	}
+	return float32(done) / float32(total) * 100
+}
+
+// GetLearningProgress reports the user's progress through the live catalog,
+// per topic and per difficulty, their XP over the requested range and how
+// they compare with the other users active in it. Overall progress counts
+// completed topics and resources and passed quizzes.
+func (c *LearningStorage) GetLearningProgress(ctx context.Context, req *pb.GetLearningProgressRequest) (*pb.GetLearningProgressResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	from, to, err := progressRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	interval := req.Interval
+	if interval == "" {
+		interval = "day"
+	}
+	if interval != "day" && interval != "week" {
+		return nil, errors.New("interval must be day or week")
+	}
+
+	res := &pb.GetLearningProgressResponse{
+		From:     from.Format(time.RFC3339),
+		To:       to.Format(time.RFC3339),
+		Interval: interval,
+	}
+	if err := c.topicProgress(ctx, req.UserId, res); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT
+		(SELECT COUNT(*) FROM extra_resources WHERE status = 'published'),
+		(SELECT COUNT(*) FROM completed_extra_resources WHERE user_id = $1),
+		(SELECT COUNT(*) FROM homework_assignments WHERE user_id = $1),
+		(SELECT COUNT(DISTINCT homework_id) FROM submitted_homeworks WHERE user_id = $1)`
+	err = c.db.QueryRowContext(ctx, query, req.UserId).Scan(
+		&res.TotalResourses, &res.CompletedResourses, &res.TotalHomeworks, &res.SubmittedHomeworks)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	res.OverallProgress = percent(res.CompletedTopics+res.PassedQuizzes+res.CompletedResourses,
+		res.TotalTopics+res.TotalQuizzes+res.TotalResourses)
+
+	if res.XpSeries, err = c.xpSeries(ctx, req.UserId, interval, from, to); err != nil {
+		return nil, err
+	}
+	if res.Cohort, err = c.cohort(ctx, req.UserId, from, to); err != nil {
+		return nil, err
+	}
+	res.EstimatedDaysToFinish = estimateDays(res.TotalTopics-res.CompletedTopics, res.Cohort.TopicsCompleted, to.Sub(from))
+	return res, nil
+}
+
+// topicProgress fills in the per-topic and per-difficulty breakdowns and the
+// catalog totals they add up to. Only published topics and quizzes count.
+func (c *LearningStorage) topicProgress(ctx context.Context, userId string, res *pb.GetLearningProgressResponse) error {
+	query := `
+		SELECT t.id, t.name, t.difficulty,
+			EXISTS (SELECT 1 FROM completed_topics ct WHERE ct.topic_id = t.id AND ct.user_id = $1),
+			(SELECT COUNT(*) FROM quizzes q WHERE q.topic_id = t.id AND q.status = 'published'),
+			(SELECT COUNT(*) FROM quizzes q WHERE q.topic_id = t.id AND q.status = 'published' AND EXISTS (
+				SELECT 1 FROM quiz_attempts a
+				WHERE a.quiz_id = q.id AND a.user_id = $1 AND a.status = 'finished')),
+			(SELECT COUNT(*) FROM quizzes q WHERE q.topic_id = t.id AND q.status = 'published' AND EXISTS (
+				SELECT 1 FROM quiz_attempts a
+				WHERE a.quiz_id = q.id AND a.user_id = $1 AND a.status = 'finished'
+				  AND a.max_score > 0 AND a.score >= a.max_score * $2))
+		FROM topics t
+		WHERE t.deleted_at = 0 AND t.status = 'published'
+		ORDER BY t.created_at, t.id`
+	rows, err := c.db.QueryContext(ctx, query, userId, models.QuizPassRatio)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer rows.Close()
+
+	byDifficulty := map[string]*pb.DifficultyProgress{}
+	for rows.Next() {
+		t := pb.TopicProgress{}
+		if err := rows.Scan(&t.TopicId, &t.Name, &t.Difficulty, &t.Completed, &t.TotalQuizzes, &t.CompletedQuizzes, &t.PassedQuizzes); err != nil {
+			log.Println(err)
+			return err
+		}
+		res.Topics = append(res.Topics, &t)
+
+		d, ok := byDifficulty[t.Difficulty]
+		if !ok {
+			d = &pb.DifficultyProgress{Difficulty: t.Difficulty}
+			byDifficulty[t.Difficulty] = d
+			res.Difficulties = append(res.Difficulties, d)
+		}
+		d.TotalTopics++
+		d.TotalQuizzes += t.TotalQuizzes
+		d.PassedQuizzes += t.PassedQuizzes
+		res.TotalTopics++
+		res.TotalQuizzes += t.TotalQuizzes
+		res.CompletedQuizzes += t.CompletedQuizzes
+		res.PassedQuizzes += t.PassedQuizzes
+		if t.Completed {
+			d.CompletedTopics++
+			res.CompletedTopics++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, d := range res.Difficulties {
+		d.Progress = percent(d.CompletedTopics+d.PassedQuizzes, d.TotalTopics+d.TotalQuizzes)
+	}
+	return nil
+}
+
+// xpSeries sums the XP the user earned, and counts the reviews they answered,
+// in each UTC day or week of the range, including the empty ones. Spending,
+// such as on streak freezes, is left out.
+func (c *LearningStorage) xpSeries(ctx context.Context, userId, interval string, from, to time.Time) ([]*pb.XpPoint, error) {
+	query := `
+		SELECT s.period, COALESCE(SUM(x.amount), 0),
+			(SELECT COUNT(*) FROM review_log r WHERE r.user_id = $1 AND r.source = 'review'
+			 AND r.reviewed_at >= GREATEST(s.period, $3::timestamp)
+			 AND r.reviewed_at < LEAST(s.period + ('1 ' || $2)::interval, $4::timestamp))
+		FROM generate_series(date_trunc($2, $3::timestamp), $4::timestamp - interval '1 microsecond', ('1 ' || $2)::interval) s(period)
+		LEFT JOIN xp_ledger x ON x.user_id = $1 AND x.amount > 0
+			AND x.created_at >= GREATEST(s.period, $3::timestamp)
+			AND x.created_at < LEAST(s.period + ('1 ' || $2)::interval, $4::timestamp)
+		GROUP BY s.period
+		ORDER BY s.period`
+	rows, err := c.db.QueryContext(ctx, query, userId, interval, from, to)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []*pb.XpPoint
+	for rows.Next() {
+		var period time.Time
+		p := pb.XpPoint{}
+		if err := rows.Scan(&period, &p.Xp, &p.Reviews); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		p.Period = period.Format(time.DateOnly)
+		series = append(series, &p)
+	}
+	return series, rows.Err()
+}
+
+// cohort compares what the user did in the range with the medians of
+// everyone who earned XP in it, the user included.
+func (c *LearningStorage) cohort(ctx context.Context, userId string, from, to time.Time) (*pb.CohortComparison, error) {
+	query := `
+		WITH members AS (
+			SELECT DISTINCT user_id FROM xp_ledger WHERE amount > 0 AND created_at >= $2 AND created_at < $3
+			UNION
+			SELECT $1::uuid
+		), stats AS (
+			SELECT m.user_id,
+				(SELECT COUNT(*) FROM completed_topics ct JOIN topics t ON t.id = ct.topic_id AND t.deleted_at = 0
+				 WHERE ct.user_id = m.user_id AND ct.created_at >= $2 AND ct.created_at < $3) AS topics,
+				(SELECT COUNT(DISTINCT a.quiz_id) FROM quiz_attempts a
+				 WHERE a.user_id = m.user_id AND a.status = 'finished' AND a.max_score > 0 AND a.score >= a.max_score * $4
+				   AND a.finished_at >= $2 AND a.finished_at < $3) AS quizzes,
+				(SELECT COALESCE(SUM(x.amount), 0) FROM xp_ledger x
+				 WHERE x.user_id = m.user_id AND x.amount > 0 AND x.created_at >= $2 AND x.created_at < $3) AS xp
+			FROM members m
+		)
+		SELECT COUNT(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY topics),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY quizzes),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY xp),
+			(SELECT topics FROM stats WHERE user_id = $1),
+			(SELECT quizzes FROM stats WHERE user_id = $1),
+			(SELECT xp FROM stats WHERE user_id = $1)
+		FROM stats`
+	cmp := pb.CohortComparison{}
+	err := c.db.QueryRowContext(ctx, query, userId, from, to, models.QuizPassRatio).Scan(
+		&cmp.Users, &cmp.MedianTopicsCompleted, &cmp.MedianQuizzesPassed, &cmp.MedianXp,
+		&cmp.TopicsCompleted, &cmp.QuizzesPassed, &cmp.Xp)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &cmp, nil
+}
+
+// estimateDays projects how long the remaining topics take at the pace the
+// user completed topics in the range: 0 when nothing is left, -1 when there
+// is no pace to go by.
+func estimateDays(remaining, completed int32, period time.Duration) int32 {
+	if remaining <= 0 {
+		return 0
+	}
+	days := period.Hours() / 24
+	if completed == 0 || days <= 0 {
+		return -1
+	}
+	return int32(math.Ceil(float64(remaining) / (float64(completed) / days)))
+}
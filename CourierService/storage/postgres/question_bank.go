@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// insertQuestion stores a question and, separately, its answer key, the
+// extra resources linked to its explanation, its tags and its topics. An
+// empty quizId puts the question in the bank.
+func insertQuestion(ctx context.Context, tx *txn, quizId string, position int32, q *pb.QuizQuestion) (string, error) {
+	id := uuid.NewString()
+	options, err := json.Marshal(nonNil(q.Options))
+	if err != nil {
+		return "", err
+	}
+	query := `
+		INSERT INTO quiz_questions(id, quiz_id, position, type, prompt, options, points, difficulty)
+		VALUES($1, NULLIF($2, '')::uuid, NULLIF($3, 0), $4, $5, $6, $7, NULLIF($8, ''))`
+	_, err = tx.ExecContext(ctx, query, id, quizId, position, q.Type, q.Prompt, string(options), q.Points, q.Difficulty)
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+
+	answer, err := json.Marshal(nonNil(q.Answer))
+	if err != nil {
+		return "", err
+	}
+	query = `
+		INSERT INTO quiz_answer_keys(question_id, answer, tolerance, explanation)
+		VALUES($1, $2, $3, $4)`
+	_, err = tx.ExecContext(ctx, query, id, string(answer), q.Tolerance, q.Explanation)
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+
+	links := []struct {
+		query string
+		ids   []string
+	}{
+		{`INSERT INTO quiz_answer_resources(question_id, extra_resource_id) VALUES($1, $2) ON CONFLICT DO NOTHING`, q.ResourceIds},
+		{`INSERT INTO quiz_question_tags(question_id, tag) VALUES($1, $2) ON CONFLICT DO NOTHING`, q.Tags},
+		{`INSERT INTO quiz_question_topics(question_id, topic_id) VALUES($1, $2) ON CONFLICT DO NOTHING`, q.TopicIds},
+	}
+	for _, link := range links {
+		for _, v := range link.ids {
+			if _, err := tx.ExecContext(ctx, link.query, id, v); err != nil {
+				log.Println(err)
+				return "", err
+			}
+		}
+	}
+	return id, nil
+}
+
+func (c *QuizStorage) CreateBankQuestion(ctx context.Context, req *pb.CreateBankQuestionRequest) (*pb.CreateBankQuestionResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertQuestion(ctx, tx, "", 0, req.Question)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.CreateBankQuestionResponse{Id: id, Message: "success"}, nil
+}
+
+// bankQuestion filters questions in the bank by topic, difficulty and tag.
+func bankQuestion(topicId, difficulty, tag string) *filter {
+	f := newFilter()
+	f.Where("q.quiz_id IS NULL")
+	f.In("q.difficulty", difficulty)
+	if topicId != "" {
+		f.Where("EXISTS (SELECT 1 FROM quiz_question_topics t WHERE t.question_id = q.id AND t.topic_id = ?)", topicId)
+	}
+	if tag != "" {
+		f.Where("EXISTS (SELECT 1 FROM quiz_question_tags g WHERE g.question_id = q.id AND g.tag = ?)", tag)
+	}
+	return f
+}
+
+var bankSorts = map[string]string{
+	"created_at": "q.created_at",
+	"difficulty": "COALESCE(q.difficulty, '')",
+}
+
+// GetBankQuestions lists bank questions without their answer keys.
+func (c *QuizStorage) GetBankQuestions(ctx context.Context, req *pb.GetBankQuestionsRequest) (*pb.GetBankQuestionsResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", bankSorts)
+	if err != nil {
+		return nil, err
+	}
+	f := bankQuestion(req.TopicId, req.Difficulty, req.Tag)
+	f.Like("q.prompt", req.Prompt)
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM quiz_questions q`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`
+		SELECT q.id, q.type, q.prompt, q.options, q.points, COALESCE(q.difficulty, ''),
+			ARRAY(SELECT tag FROM quiz_question_tags WHERE question_id = q.id ORDER BY tag),
+			ARRAY(SELECT topic_id::text FROM quiz_question_topics WHERE question_id = q.id),
+			`+p.sortKey()+`
+		FROM quiz_questions q`, f, "q.id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []*pb.QuizQuestion
+	var keys, ids []string
+	for rows.Next() {
+		var q pb.QuizQuestion
+		var options []byte
+		var key string
+		err := rows.Scan(&q.Id, &q.Type, &q.Prompt, &options, &q.Points, &q.Difficulty, pq.Array(&q.Tags), pq.Array(&q.TopicIds), &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &q.Options); err != nil {
+			return nil, err
+		}
+		questions = append(questions, &q)
+		keys = append(keys, key)
+		ids = append(ids, q.Id)
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetBankQuestionsResponse{Questions: questions[:n], TotalCount: total, NextPageToken: token}, nil
+}
+
+func (c *QuizStorage) DeleteBankQuestion(ctx context.Context, req *pb.DeleteBankQuestionRequest) (*pb.DeleteBankQuestionResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM quiz_questions WHERE id = $1 AND quiz_id IS NULL`
+
+	_, err := c.db.ExecContext(ctx, query, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.DeleteBankQuestionResponse{Message: "success"}, nil
+}
+
+// FindBankQuestions returns every bank question matching rule, sorted by id
+// so that a seeded draw over them is reproducible.
+func (c *QuizStorage) FindBankQuestions(ctx context.Context, rule *models.QuizTemplateRule) ([]*models.QuizQuestion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	f := bankQuestion(rule.TopicId, rule.Difficulty, rule.Tag)
+	query := `
+		SELECT q.id, '', 0, q.type, q.prompt, q.options, q.points, COALESCE(q.difficulty, '')
+		FROM quiz_questions q` + f.Sql() + ` ORDER BY q.id`
+
+	rows, err := c.db.QueryContext(ctx, query, f.Args()...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanQuestions(rows)
+}
@@ -0,0 +1,465 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type QuizStorage struct {
+	db conn
+}
+
+func NewQuizStorage(db conn) *QuizStorage {
+	return &QuizStorage{db: db}
+}
+
+func (c *QuizStorage) GetQuiz(ctx context.Context, id string) (*models.Quiz, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, topic_id, COALESCE(title, ''), time_limit_seconds, max_attempts, partial_credit, shuffle, xp
+		FROM quizzes WHERE id = $1 AND status = 'published'`
+
+	var quiz models.Quiz
+	var timeLimit int64
+	err := c.db.QueryRowContext(ctx, query, id).Scan(&quiz.Id, &quiz.TopicId, &quiz.Title, &timeLimit, &quiz.MaxAttempts, &quiz.PartialCredit, &quiz.Shuffle, &quiz.Xp)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrQuizNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	quiz.TimeLimit = time.Duration(timeLimit) * time.Second
+
+	query = `
+		SELECT COALESCE(topic_id::text, ''), COALESCE(difficulty, ''), COALESCE(tag, ''), count
+		FROM quiz_template_rules WHERE quiz_id = $1 ORDER BY position`
+	rows, err := c.db.QueryContext(ctx, query, id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rule models.QuizTemplateRule
+		if err := rows.Scan(&rule.TopicId, &rule.Difficulty, &rule.Tag, &rule.Count); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		quiz.Rules = append(quiz.Rules, &rule)
+	}
+	return &quiz, rows.Err()
+}
+
+// GetQuizQuestions never loads answer keys; see GetAnswerKeys.
+func (c *QuizStorage) GetQuizQuestions(ctx context.Context, quizId string) ([]*models.QuizQuestion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, quiz_id, position, type, prompt, options, points, COALESCE(difficulty, '')
+		FROM quiz_questions WHERE quiz_id = $1 ORDER BY position`
+
+	rows, err := c.db.QueryContext(ctx, query, quizId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanQuestions(rows)
+}
+
+// GetAttemptQuestions returns the paper an attempt was given, in the order
+// it was shown.
+func (c *QuizStorage) GetAttemptQuestions(ctx context.Context, attemptId string) ([]*models.QuizQuestion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT q.id, COALESCE(q.quiz_id::text, ''), a.position, q.type, q.prompt, a.options, q.points, COALESCE(q.difficulty, '')
+		FROM quiz_attempt_questions a
+		JOIN quiz_questions q ON q.id = a.question_id
+		WHERE a.attempt_id = $1
+		ORDER BY a.position`
+
+	rows, err := c.db.QueryContext(ctx, query, attemptId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanQuestions(rows)
+}
+
+func scanQuestions(rows *sql.Rows) ([]*models.QuizQuestion, error) {
+	var questions []*models.QuizQuestion
+	for rows.Next() {
+		var q models.QuizQuestion
+		var options []byte
+		err := rows.Scan(&q.Id, &q.QuizId, &q.Position, &q.Type, &q.Prompt, &options, &q.Points, &q.Difficulty)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := json.Unmarshal(options, &q.Options); err != nil {
+			return nil, err
+		}
+		questions = append(questions, &q)
+	}
+	return questions, rows.Err()
+}
+
+// GetAnswerKeys returns the keys of the given questions by question id. It
+// is only meant for grading and for reviewing closed attempts.
+func (c *QuizStorage) GetAnswerKeys(ctx context.Context, questionIds []string) (map[string]*models.AnswerKey, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT question_id, answer, tolerance, explanation
+		FROM quiz_answer_keys WHERE question_id = ANY($1)`
+
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(questionIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[string]*models.AnswerKey{}
+	for rows.Next() {
+		var key models.AnswerKey
+		var answer []byte
+		err := rows.Scan(&key.QuestionId, &answer, &key.Tolerance, &key.Explanation)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := json.Unmarshal(answer, &key.Answer); err != nil {
+			return nil, err
+		}
+		keys[key.QuestionId] = &key
+	}
+	return keys, rows.Err()
+}
+
+// GetQuestionResources returns the extra resources linked to the
+// explanations of the given questions, by question id.
+func (c *QuizStorage) GetQuestionResources(ctx context.Context, questionIds []string) (map[string][]*pb.CreateExtraResourses, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT r.question_id, e.id, e.title, e.type, e.url
+		FROM quiz_answer_resources r
+		JOIN extra_resources e ON e.id = r.extra_resource_id
+		WHERE r.question_id = ANY($1) AND e.status = 'published'
+		ORDER BY e.title`
+
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(questionIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := map[string][]*pb.CreateExtraResourses{}
+	for rows.Next() {
+		var questionId string
+		var resource pb.CreateExtraResourses
+		if err := rows.Scan(&questionId, &resource.Id, &resource.Title, &resource.Type, &resource.Url); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		resources[questionId] = append(resources[questionId], &resource)
+	}
+	return resources, rows.Err()
+}
+
+func (c *QuizStorage) GetAttemptAnswers(ctx context.Context, attemptId string) (map[string]*models.GradedAnswer, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT question_id, answer, points, correct FROM quiz_attempt_answers WHERE attempt_id = $1`
+
+	rows, err := c.db.QueryContext(ctx, query, attemptId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := map[string]*models.GradedAnswer{}
+	for rows.Next() {
+		var a models.GradedAnswer
+		var values []byte
+		if err := rows.Scan(&a.QuestionId, &values, &a.Points, &a.Correct); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if err := json.Unmarshal(values, &a.Values); err != nil {
+			return nil, err
+		}
+		answers[a.QuestionId] = &a
+	}
+	return answers, rows.Err()
+}
+
+// StartQuizAttempt records paper as the questions of a new attempt. If the
+// user already has an open attempt, that one is returned and paper is
+// discarded.
+func (c *QuizStorage) StartQuizAttempt(ctx context.Context, quiz *models.Quiz, userId string, seed int64, paper []*models.QuizQuestion) (*models.QuizAttempt, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// An attempt left open past its time limit no longer blocks a new one.
+	query := `
+		UPDATE quiz_attempts SET status = 'expired', finished_at = now()
+		WHERE quiz_id = $1 AND user_id = $2 AND status = 'in_progress'
+			AND expires_at IS NOT NULL AND expires_at + $3 * interval '1 second' < now()`
+	_, err = tx.ExecContext(ctx, query, quiz.Id, userId, models.AttemptGrace.Seconds())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	attempt, err := openAttempt(ctx, tx, quiz.Id, userId)
+	if err != nil || attempt != nil {
+		return attempt, err
+	}
+
+	if quiz.MaxAttempts > 0 {
+		var used int32
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM quiz_attempts WHERE quiz_id = $1 AND user_id = $2`, quiz.Id, userId).Scan(&used)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if used >= quiz.MaxAttempts {
+			return nil, st.ErrNoAttemptsLeft
+		}
+	}
+
+	var expiresAt *time.Time
+	if quiz.TimeLimit > 0 {
+		t := time.Now().Add(quiz.TimeLimit)
+		expiresAt = &t
+	}
+	// The partial unique index allows one open attempt per user and quiz;
+	// a concurrent start loses the race and resumes the winner's attempt.
+	id := uuid.NewString()
+	query = `
+		INSERT INTO quiz_attempts(id, quiz_id, user_id, expires_at, seed)
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (quiz_id, user_id) WHERE status = 'in_progress' DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, id, quiz.Id, userId, expiresAt, seed)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		query := `
+			INSERT INTO quiz_attempt_questions(attempt_id, question_id, position, options)
+			VALUES($1, $2, $3, $4)`
+		for i, q := range paper {
+			options, err := json.Marshal(nonNil(q.Options))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tx.ExecContext(ctx, query, id, q.Id, i+1, string(options)); err != nil {
+				log.Println(err)
+				return nil, err
+			}
+		}
+	}
+	attempt, err = openAttempt(ctx, tx, quiz.Id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return attempt, nil
+}
+
+const attemptColumns = `id, quiz_id, user_id, status, started_at, expires_at, finished_at, score, max_score, seed`
+
+func scanAttempt(row interface{ Scan(...interface{}) error }) (*models.QuizAttempt, error) {
+	var a models.QuizAttempt
+	var expiresAt, finishedAt sql.NullTime
+	err := row.Scan(&a.Id, &a.QuizId, &a.UserId, &a.Status, &a.StartedAt, &expiresAt, &finishedAt, &a.Score, &a.MaxScore, &a.Seed)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		a.ExpiresAt = &expiresAt.Time
+	}
+	if finishedAt.Valid {
+		a.FinishedAt = &finishedAt.Time
+	}
+	return &a, nil
+}
+
+func openAttempt(ctx context.Context, tx *txn, quizId, userId string) (*models.QuizAttempt, error) {
+	query := `SELECT ` + attemptColumns + ` FROM quiz_attempts
+		WHERE quiz_id = $1 AND user_id = $2 AND status = 'in_progress'`
+	attempt, err := scanAttempt(tx.QueryRowContext(ctx, query, quizId, userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (c *QuizStorage) GetQuizAttempt(ctx context.Context, id, userId string) (*models.QuizAttempt, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + attemptColumns + ` FROM quiz_attempts WHERE id = $1 AND user_id = $2`
+	attempt, err := scanAttempt(c.db.QueryRowContext(ctx, query, id, userId))
+	if err == sql.ErrNoRows {
+		return nil, st.ErrAttemptNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// FinishQuizAttempt closes the attempt and pays out XP for the quiz up to
+// what this attempt is worth. A user's XP for a quiz therefore follows their
+// best score and retaking it never pays twice.
+func (c *QuizStorage) FinishQuizAttempt(ctx context.Context, result *models.QuizAttemptResult) (int32, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	a := result.Attempt
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE quiz_attempts SET status = $1, finished_at = now(), score = $2, max_score = $3
+		WHERE id = $4 AND status = 'in_progress'`
+	res, err := tx.ExecContext(ctx, query, result.Status, a.Score, a.MaxScore, a.Id)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return 0, st.ErrAttemptClosed
+	}
+
+	query = `
+		INSERT INTO quiz_attempt_answers(attempt_id, question_id, answer, points, correct)
+		VALUES($1, $2, $3, $4, $5)`
+	for _, answer := range result.Answers {
+		values, err := json.Marshal(nonNil(answer.Values))
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, query, a.Id, answer.QuestionId, string(values), answer.Points, answer.Correct)
+		if err != nil {
+			log.Println(err)
+			return 0, err
+		}
+	}
+
+	query = `
+		INSERT INTO quiz_results(user_id, quiz_id)
+		VALUES($1, $2)
+		ON CONFLICT (user_id, quiz_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, a.UserId, a.QuizId); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	var awarded int32
+	query = `SELECT xp_awarded FROM quiz_results WHERE user_id = $1 AND quiz_id = $2 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, a.UserId, a.QuizId).Scan(&awarded); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	var xp int32
+	if result.Xp > awarded {
+		xp = result.Xp - awarded
+	}
+	query = `
+		UPDATE quiz_results
+		SET best_score = GREATEST(best_score, $1), max_score = $2, xp_awarded = xp_awarded + $3, updated_at = now()
+		WHERE user_id = $4 AND quiz_id = $5`
+	if _, err := tx.ExecContext(ctx, query, a.Score, a.MaxScore, xp, a.UserId, a.QuizId); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	entry := &models.XpEntry{UserId: a.UserId, SourceType: models.XpSourceQuiz, SourceId: a.Id, Amount: xp, Reason: "quiz attempt finished"}
+	if _, err := awardXp(ctx, tx, entry); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return xp, nil
+}
+
+func (c *QuizStorage) GetQuizAttempts(ctx context.Context, quizId, userId string) ([]*models.QuizAttempt, *models.QuizResult, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + attemptColumns + ` FROM quiz_attempts
+		WHERE quiz_id = $1 AND user_id = $2 ORDER BY started_at DESC, id`
+	rows, err := c.db.QueryContext(ctx, query, quizId, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*models.QuizAttempt
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, nil, err
+		}
+		attempts = append(attempts, a)
+	}
+
+	var result models.QuizResult
+	query = `SELECT best_score, max_score, xp_awarded FROM quiz_results WHERE user_id = $1 AND quiz_id = $2`
+	err = c.db.QueryRowContext(ctx, query, userId, quizId).Scan(&result.BestScore, &result.MaxScore, &result.XpAwarded)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, nil, err
+	}
+	return attempts, &result, nil
+}
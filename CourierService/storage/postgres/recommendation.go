@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"learning-service/models"
+
+	"github.com/lib/pq"
+)
+
+type RecommendationStorage struct {
+	db conn
+}
+
+// GetLearnerSignals loads what the recommendation engine weighs for a user:
+// their standing on each live topic, resources covering questions they got
+// wrong, and game levels learners of about their level completed.
+func (c *RecommendationStorage) GetLearnerSignals(ctx context.Context, userId string, maxLevels int) (*models.LearnerSignals, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	s := models.LearnerSignals{UserId: userId}
+	query := `SELECT COALESCE((SELECT level FROM learning_profiles WHERE user_id = $1 AND deleted_at IS NULL), 1)`
+	if err := c.db.QueryRowContext(ctx, query, userId).Scan(&s.Level); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var err error
+	if s.Topics, err = c.topicSignals(ctx, userId); err != nil {
+		return nil, err
+	}
+	if s.Resources, err = c.resourceSignals(ctx, userId); err != nil {
+		return nil, err
+	}
+	if s.Levels, err = c.gameLevelSignals(ctx, userId, s.Level, maxLevels); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *RecommendationStorage) topicSignals(ctx context.Context, userId string) ([]*models.TopicSignal, error) {
+	query := `
+		SELECT t.id, t.name, t.difficulty,
+			EXISTS (SELECT 1 FROM completed_topics ct WHERE ct.topic_id = t.id AND ct.user_id = $1),
+			(SELECT COUNT(*) FROM topic_prerequisites p
+			 JOIN topics pt ON pt.id = p.prerequisite_id AND pt.deleted_at = 0 AND pt.status = 'published'
+			 WHERE p.topic_id = t.id),
+			(SELECT COUNT(*) FROM topic_prerequisites p
+			 JOIN topics pt ON pt.id = p.prerequisite_id AND pt.deleted_at = 0 AND pt.status = 'published'
+			 WHERE p.topic_id = t.id AND NOT EXISTS (
+				SELECT 1 FROM completed_topics ct WHERE ct.user_id = $1 AND ct.topic_id = p.prerequisite_id)),
+			(SELECT MAX(a.score / a.max_score) FROM quiz_attempts a JOIN quizzes q ON q.id = a.quiz_id
+			 WHERE q.topic_id = t.id AND a.user_id = $1 AND a.status = 'finished' AND a.max_score > 0),
+			COALESCE((SELECT AVG(f.rating) FROM feedback f WHERE f.topic_id = t.id AND f.status = 'accepted'), 0),
+			(SELECT COUNT(*) FROM feedback f WHERE f.topic_id = t.id AND f.status = 'accepted'),
+			COALESCE((SELECT MAX(f.rating) FROM feedback f WHERE f.topic_id = t.id AND f.user_id = $1), 0)
+		FROM topics t
+		WHERE t.deleted_at = 0 AND t.status = 'published'
+		ORDER BY t.created_at, t.id`
+	rows, err := c.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var topics []*models.TopicSignal
+	for rows.Next() {
+		t := models.TopicSignal{}
+		var best sql.NullFloat64
+		err := rows.Scan(&t.Id, &t.Name, &t.Difficulty, &t.Completed, &t.Prerequisites, &t.Missing,
+			&best, &t.AvgRating, &t.Ratings, &t.UserRating)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		t.Attempted, t.BestScore = best.Valid, best.Float64
+		topics = append(topics, &t)
+	}
+	return topics, rows.Err()
+}
+
+func (c *RecommendationStorage) resourceSignals(ctx context.Context, userId string) ([]*models.ResourceSignal, error) {
+	query := `
+		SELECT r.id, r.title, COALESCE(q.topic_id::text, ''), COUNT(DISTINCT aa.question_id)
+		FROM quiz_attempts a
+		JOIN quizzes q ON q.id = a.quiz_id
+		JOIN quiz_attempt_answers aa ON aa.attempt_id = a.id AND NOT aa.correct
+		JOIN quiz_answer_resources qr ON qr.question_id = aa.question_id
+		JOIN extra_resources r ON r.id = qr.extra_resource_id
+		WHERE a.user_id = $1 AND a.status = 'finished' AND r.status = 'published'
+		  AND NOT EXISTS (SELECT 1 FROM completed_extra_resources ce WHERE ce.user_id = $1 AND ce.extra_resource_id = r.id)
+		GROUP BY r.id, r.title, q.topic_id`
+	rows, err := c.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resources []*models.ResourceSignal
+	for rows.Next() {
+		r := models.ResourceSignal{}
+		if err := rows.Scan(&r.Id, &r.Title, &r.TopicId, &r.Missed); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		resources = append(resources, &r)
+	}
+	return resources, rows.Err()
+}
+
+// gameLevelSignals returns the levels most completed by users within one
+// level of the user's that the user has not completed.
+func (c *RecommendationStorage) gameLevelSignals(ctx context.Context, userId string, level int32, limit int) ([]*models.GameLevelSignal, error) {
+	query := `
+		SELECT g.level_id, COUNT(*), (percentile_cont(0.5) WITHIN GROUP (ORDER BY g.best_seconds))::int
+		FROM game_level_completions g
+		JOIN learning_profiles p ON p.user_id = g.user_id AND p.deleted_at IS NULL
+		WHERE g.user_id <> $1 AND p.level BETWEEN $2 - 1 AND $2 + 1
+		  AND NOT EXISTS (SELECT 1 FROM game_level_completions m WHERE m.user_id = $1 AND m.level_id = g.level_id)
+		GROUP BY g.level_id
+		ORDER BY COUNT(*) DESC, g.level_id
+		LIMIT $3`
+	rows, err := c.db.QueryContext(ctx, query, userId, level, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []*models.GameLevelSignal
+	for rows.Next() {
+		l := models.GameLevelSignal{}
+		if err := rows.Scan(&l.LevelId, &l.Players, &l.MedianSeconds); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		levels = append(levels, &l)
+	}
+	return levels, rows.Err()
+}
+
+// SaveRecommendations replaces the user's generated recommendations with
+// recs and returns those that are new. Recommendations made by hand are
+// left alone. A deleted user gets none.
+func (c *RecommendationStorage) SaveRecommendations(ctx context.Context, userId string, recs []*models.Recommendation) ([]*models.Recommendation, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serializes refreshes of the same user, from events and the job alike.
+	if _, err := lockProfile(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(recs))
+	for _, r := range recs {
+		keys = append(keys, r.Type+"/"+r.ItemId)
+	}
+	query := `DELETE FROM recommendations WHERE user_id = $1 AND generated AND NOT (type || '/' || item_id = ANY($2))`
+	if _, err := tx.ExecContext(ctx, query, userId, pq.Array(keys)); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// xmax is 0 on a row the statement inserted rather than updated.
+	query = `
+		INSERT INTO recommendations(id, user_id, type, item_id, name, reason, score, generated)
+		VALUES(gen_random_uuid(), $1, $2, $3, $4, $5, $6, true)
+		ON CONFLICT (user_id, type, item_id) WHERE generated DO UPDATE
+		SET name = EXCLUDED.name, reason = EXCLUDED.reason, score = EXCLUDED.score, updated_at = now()
+		RETURNING id, xmax = 0`
+	var created []*models.Recommendation
+	for _, r := range recs {
+		var inserted bool
+		err := tx.QueryRowContext(ctx, query, userId, r.Type, r.ItemId, r.Name, r.Reason, r.Score).Scan(&r.Id, &inserted)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		r.UserId = userId
+		if inserted {
+			created = append(created, r)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE learning_profiles SET recommended_at = now() WHERE user_id = $1`, userId); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return created, nil
+}
+
+// ClaimStaleRecommendations returns up to limit live users whose
+// recommendations were last refreshed more than maxAge ago, or never, and
+// marks them refreshed so that concurrent callers claim disjoint users.
+func (c *RecommendationStorage) ClaimStaleRecommendations(ctx context.Context, maxAge time.Duration, limit int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE learning_profiles SET recommended_at = now()
+		WHERE user_id IN (
+			SELECT user_id FROM learning_profiles
+			WHERE deleted_at IS NULL AND (recommended_at IS NULL OR recommended_at < now() - make_interval(secs => $1))
+			ORDER BY recommended_at NULLS FIRST
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED)
+		RETURNING user_id`
+	rows, err := c.db.QueryContext(ctx, query, maxAge.Seconds(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
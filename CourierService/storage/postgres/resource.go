@@ -0,0 +1,412 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func metadataJson(m *pb.ResourceMetadata) (string, error) {
+	meta := models.ResourceMetadata{}
+	if m != nil {
+		meta = models.ResourceMetadata{
+			Author:          m.Author,
+			DurationMinutes: m.DurationMinutes,
+			Isbn:            m.Isbn,
+			Publisher:       m.Publisher,
+			Pages:           m.Pages,
+			Provider:        m.Provider,
+			Episode:         m.Episode,
+			PublishedOn:     m.PublishedOn,
+		}
+	}
+	raw, err := json.Marshal(meta)
+	return string(raw), err
+}
+
+func metadataToPb(raw []byte) (*pb.ResourceMetadata, error) {
+	m := models.ResourceMetadata{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return &pb.ResourceMetadata{
+		Author:          m.Author,
+		DurationMinutes: m.DurationMinutes,
+		Isbn:            m.Isbn,
+		Publisher:       m.Publisher,
+		Pages:           m.Pages,
+		Provider:        m.Provider,
+		Episode:         m.Episode,
+		PublishedOn:     m.PublishedOn,
+		LegacyType:      m.LegacyType,
+	}, nil
+}
+
+// setResourceTopics replaces the topics a resource covers.
+func setResourceTopics(ctx context.Context, tx *txn, resourceId string, topicIds []string) error {
+	var found int
+	query := `SELECT COUNT(*) FROM topics WHERE id = ANY($1::uuid[]) AND deleted_at = 0`
+	if err := tx.QueryRowContext(ctx, query, pq.Array(topicIds)).Scan(&found); err != nil {
+		log.Println(err)
+		return err
+	}
+	distinct := map[string]bool{}
+	for _, id := range topicIds {
+		distinct[id] = true
+	}
+	if found != len(distinct) {
+		return st.ErrTopicNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM resource_topics WHERE extra_resource_id = $1`, resourceId); err != nil {
+		log.Println(err)
+		return err
+	}
+	query = `
+		INSERT INTO resource_topics(extra_resource_id, topic_id)
+		SELECT $1, t FROM unnest($2::uuid[]) t
+		ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, resourceId, pq.Array(topicIds)); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (c *LearningStorage) CreateExtraResourses(ctx context.Context, req *pb.CreateExtraResoursesRequest) (*pb.CreateExtraResoursesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := metadataJson(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id := uuid.NewString()
+	query := `
+		INSERT INTO extra_resources(id, title, type, url, file_id, description, metadata)
+		VALUES($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7)`
+
+	_, err = tx.ExecContext(ctx, query, id, req.Title, req.Type, req.Url, req.FileId, req.Description, metadata)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := setResourceTopics(ctx, tx, id, req.TopicIds); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.CreateExtraResoursesResponse{Id: id, Message: "success"}, nil
+}
+
+var resourceSorts = map[string]string{
+	"created_at": "created_at",
+	"title":      "title",
+	"type":       "type",
+}
+
+// GetExtraResourses lists resources with the topics they cover and, for a
+// user, whether they have completed each.
+func (c *LearningStorage) GetExtraResourses(ctx context.Context, req *pb.GetExtraResourcesRequest) (*pb.GetExtraResourcesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", resourceSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	f.Where("status = 'published'")
+	f.In("type", req.Type)
+	f.In("url_status", req.UrlStatus)
+	if req.TopicId != "" {
+		f.Where("EXISTS (SELECT 1 FROM resource_topics rt WHERE rt.extra_resource_id = extra_resources.id AND rt.topic_id = ?)", req.TopicId)
+	}
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM extra_resources`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`
+		SELECT id, title, type, url, COALESCE(file_id::text, ''), description, metadata,
+			url_status, url_status_code, url_checked_at, created_at, `+p.sortKey()+`
+		FROM extra_resources`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resources []*pb.CreateExtraResourses
+	var keys, ids []string
+	for rows.Next() {
+		var resource pb.CreateExtraResourses
+		var metadata []byte
+		var checkedAt sql.NullTime
+		var createdAt time.Time
+		var key string
+		err := rows.Scan(&resource.Id, &resource.Title, &resource.Type, &resource.Url, &resource.FileId, &resource.Description, &metadata,
+			&resource.UrlStatus, &resource.UrlStatusCode, &checkedAt, &createdAt, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if resource.Metadata, err = metadataToPb(metadata); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if checkedAt.Valid {
+			resource.UrlCheckedAt = checkedAt.Time.Format(time.RFC3339)
+		}
+		resource.CreatedAt = createdAt.Format(time.RFC3339)
+		resources = append(resources, &resource)
+		keys = append(keys, key)
+		ids = append(ids, resource.Id)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	rows.Close()
+
+	n, token := p.next(keys, ids)
+	resources = resources[:n]
+	if err := c.resourceDetails(ctx, req.UserId, resources); err != nil {
+		return nil, err
+	}
+	return &pb.GetExtraResourcesResponse{ExtraResources: resources, TotalCount: total, NextPageToken: token}, nil
+}
+
+// resourceDetails fills in the topics of resources and, given a user,
+// when they completed each.
+func (c *LearningStorage) resourceDetails(ctx context.Context, userId string, resources []*pb.CreateExtraResourses) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	byId := make(map[string]*pb.CreateExtraResourses, len(resources))
+	ids := make([]string, len(resources))
+	for i, r := range resources {
+		byId[r.Id] = r
+		ids[i] = r.Id
+	}
+
+	query := `SELECT extra_resource_id, topic_id FROM resource_topics WHERE extra_resource_id = ANY($1::uuid[]) ORDER BY topic_id`
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, topicId string
+		if err := rows.Scan(&id, &topicId); err != nil {
+			log.Println(err)
+			return err
+		}
+		byId[id].TopicIds = append(byId[id].TopicIds, topicId)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+	rows.Close()
+
+	if userId == "" {
+		return nil
+	}
+	query = `SELECT extra_resource_id, created_at FROM completed_extra_resources WHERE user_id = $1 AND extra_resource_id = ANY($2::uuid[])`
+	rows, err = c.db.QueryContext(ctx, query, userId, pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var completedAt time.Time
+		if err := rows.Scan(&id, &completedAt); err != nil {
+			log.Println(err)
+			return err
+		}
+		byId[id].Completed = true
+		byId[id].CompletedAt = completedAt.Format(time.RFC3339)
+	}
+	return rows.Err()
+}
+
+// UpdateExtraResourses replaces a resource. A new url is checked again.
+func (c *LearningStorage) UpdateExtraResourses(ctx context.Context, req *pb.UpdateExtraResoursesRequest) (*pb.UpdateExtraResoursesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := metadataJson(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE extra_resources
+		SET title = $1, type = $2, file_id = NULLIF($5, '')::uuid, description = $6, metadata = $7,
+			url_status = CASE WHEN url = $3 THEN url_status ELSE 'unchecked' END,
+			url_status_code = CASE WHEN url = $3 THEN url_status_code ELSE 0 END,
+			url_checked_at = CASE WHEN url = $3 THEN url_checked_at END,
+			url = $3
+		WHERE id = $4`
+
+	res, err := tx.ExecContext(ctx, query, req.Title, req.Type, req.Url, req.Id, req.FileId, req.Description, metadata)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, st.ErrResourceNotFound
+	}
+	if err := setResourceTopics(ctx, tx, req.Id, req.TopicIds); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.UpdateExtraResoursesResponse{Message: "success"}, nil
+}
+
+func (c *LearningStorage) DeleteExtraResourses(ctx context.Context, req *pb.DeleteExtraResoursesRequest) (*pb.DeleteExtraResoursesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM extra_resources WHERE id = $1`
+
+	_, err := c.db.ExecContext(ctx, query, req.Id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.DeleteExtraResoursesResponse{Message: "success"}, nil
+}
+
+// CompletedExtraResources records that the user completed a resource.
+// Completing it again changes nothing and says so.
+func (c *LearningStorage) CompletedExtraResources(ctx context.Context, req *pb.CompletedExtraResourcesRequest) (*pb.CompletedExtraResourcesResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var found int
+	err := c.db.QueryRowContext(ctx, `SELECT 1 FROM extra_resources WHERE id = $1 AND status = 'published'`, req.ExtraResourceId).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, st.ErrResourceNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	res := &pb.CompletedExtraResourcesResponse{Message: "success"}
+	var completedAt time.Time
+	query := `
+		INSERT INTO completed_extra_resources (id, user_id, extra_resource_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, extra_resource_id) DO NOTHING
+		RETURNING id, created_at`
+	err = c.db.QueryRowContext(ctx, query, uuid.NewString(), req.UserId, req.ExtraResourceId).Scan(&res.CompletionId, &completedAt)
+	if err == sql.ErrNoRows {
+		res.AlreadyCompleted = true
+		query = `SELECT id, created_at FROM completed_extra_resources WHERE user_id = $1 AND extra_resource_id = $2`
+		err = c.db.QueryRowContext(ctx, query, req.UserId, req.ExtraResourceId).Scan(&res.CompletionId, &completedAt)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	res.CompletedAt = completedAt.Format(time.RFC3339)
+	return res, nil
+}
+
+// ClaimResourceLinks returns resource urls never checked or last checked
+// more than recheckAfter ago, and marks them checked now so that no one
+// else picks them up meanwhile.
+func (c *LearningStorage) ClaimResourceLinks(ctx context.Context, recheckAfter time.Duration, limit int) ([]*models.ResourceLink, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE extra_resources SET url_checked_at = now()
+		WHERE id IN (
+			SELECT id FROM extra_resources
+			WHERE url <> '' AND (url_checked_at IS NULL OR url_checked_at < now() - make_interval(secs => $1))
+			ORDER BY url_checked_at NULLS FIRST
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, url`
+	rows, err := c.db.QueryContext(ctx, query, recheckAfter.Seconds(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*models.ResourceLink
+	for rows.Next() {
+		l := models.ResourceLink{}
+		if err := rows.Scan(&l.Id, &l.Url); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		links = append(links, &l)
+	}
+	return links, rows.Err()
+}
+
+// SaveLinkCheck records how a check of a resource url went, unless the url
+// has changed since.
+func (c *LearningStorage) SaveLinkCheck(ctx context.Context, link *models.ResourceLink, status string, code int32) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE extra_resources SET url_status = $3, url_status_code = $4, url_checked_at = now()
+		WHERE id = $1 AND url = $2`
+	if _, err := c.db.ExecContext(ctx, query, link.Id, link.Url, status, code); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"learning-service/models"
+
+	"github.com/lib/pq"
+)
+
+type ReviewStorage struct {
+	db conn
+}
+
+const reviewCardColumns = `c.user_id, c.question_id, c.ease, c.interval_days, c.repetitions, c.lapses, c.due_at, c.last_reviewed_at,
+	q.id, COALESCE(q.quiz_id::text, ''), COALESCE(q.position, 0), q.type, q.prompt, q.options, q.points, COALESCE(q.difficulty, '')`
+
+func scanReviewCard(row scanner) (*models.ReviewCard, error) {
+	c := models.ReviewCard{Question: &models.QuizQuestion{}}
+	q := c.Question
+	var lastReviewed sql.NullTime
+	var options []byte
+	err := row.Scan(&c.UserId, &c.QuestionId, &c.Ease, &c.IntervalDays, &c.Repetitions, &c.Lapses, &c.DueAt, &lastReviewed,
+		&q.Id, &q.QuizId, &q.Position, &q.Type, &q.Prompt, &options, &q.Points, &q.Difficulty)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(options, &q.Options); err != nil {
+		return nil, err
+	}
+	c.LastReviewedAt = lastReviewed.Time
+	return &c, nil
+}
+
+// GetCards returns the user's cards on the given questions by question id,
+// locked for the rest of the unit of work.
+func (c *ReviewStorage) GetCards(ctx context.Context, userId string, questionIds []string) (map[string]*models.ReviewCard, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + reviewCardColumns + `
+		FROM review_cards c JOIN quiz_questions q ON q.id = c.question_id
+		WHERE c.user_id = $1 AND c.question_id = ANY($2)
+		FOR UPDATE OF c`
+	rows, err := c.db.QueryContext(ctx, query, userId, pq.Array(questionIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := map[string]*models.ReviewCard{}
+	for rows.Next() {
+		card, err := scanReviewCard(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		cards[card.QuestionId] = card
+	}
+	return cards, rows.Err()
+}
+
+// SaveReview stores a card as scheduled after an answer graded grade, and
+// logs the answer.
+func (c *ReviewStorage) SaveReview(ctx context.Context, card *models.ReviewCard, grade int32, source string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO review_cards(user_id, question_id, ease, interval_days, repetitions, lapses, due_at, last_reviewed_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, question_id) DO UPDATE
+		SET ease = EXCLUDED.ease, interval_days = EXCLUDED.interval_days, repetitions = EXCLUDED.repetitions,
+			lapses = EXCLUDED.lapses, due_at = EXCLUDED.due_at, last_reviewed_at = EXCLUDED.last_reviewed_at`
+	_, err = tx.ExecContext(ctx, query, card.UserId, card.QuestionId, card.Ease, card.IntervalDays, card.Repetitions, card.Lapses,
+		card.DueAt.UTC(), card.LastReviewedAt.UTC())
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	query = `
+		INSERT INTO review_log(user_id, question_id, grade, source, interval_days, reviewed_at)
+		VALUES($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.ExecContext(ctx, query, card.UserId, card.QuestionId, grade, source, card.IntervalDays, card.LastReviewedAt.UTC()); err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// GetDueCards returns up to limit of the user's cards due at now, the most
+// overdue first, how many are due in all, and when the next card not yet
+// due comes up, zero if none.
+func (c *ReviewStorage) GetDueCards(ctx context.Context, userId string, now time.Time, limit int32) ([]*models.ReviewCard, int32, time.Time, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int32
+	var next sql.NullTime
+	query := `
+		SELECT COUNT(*) FILTER (WHERE due_at <= $2), MIN(due_at) FILTER (WHERE due_at > $2)
+		FROM review_cards WHERE user_id = $1`
+	if err := c.db.QueryRowContext(ctx, query, userId, now.UTC()).Scan(&total, &next); err != nil {
+		log.Println(err)
+		return nil, 0, time.Time{}, err
+	}
+
+	query = `
+		SELECT ` + reviewCardColumns + `
+		FROM review_cards c JOIN quiz_questions q ON q.id = c.question_id
+		WHERE c.user_id = $1 AND c.due_at <= $2
+		ORDER BY c.due_at, c.question_id
+		LIMIT $3`
+	rows, err := c.db.QueryContext(ctx, query, userId, now.UTC(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var cards []*models.ReviewCard
+	for rows.Next() {
+		card, err := scanReviewCard(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, 0, time.Time{}, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, total, next.Time, rows.Err()
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	pb "learning-service/genproto/learning"
+)
+
+type SearchStorage struct {
+	db conn
+}
+
+// titleSimilarity is how close a word of the query must come to a word of a
+// title to match it despite a typo.
+const titleSimilarity = "0.4"
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+
+var searchSorts = map[string]string{
+	"rank": "rank",
+}
+
+// Search finds topics, quizzes, resources and homeworks matching the query
+// by their stemmed words or, for titles, by trigram similarity. Homeworks
+// are only found by their owner and the users they are assigned to.
+func (c *SearchStorage) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, "rank", "", "rank", searchSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	q := f.arg(req.Query)
+	matches := fmt.Sprintf(`
+		SELECT d.kind, d.id, d.title, d.body, d.language, search_query(%[1]s) AS query,
+			ts_rank_cd(d.document, search_query(%[1]s), 32) + word_similarity(lower(%[1]s), lower(d.title)) AS rank
+		FROM search_documents d
+		WHERE d.document @@ search_query(%[1]s) OR lower(%[1]s) <%% lower(d.title)`, q)
+
+	f.In("kind", req.Types)
+	if req.UserId == "" {
+		f.Where("m.kind <> 'homework'")
+	} else {
+		f.Where(`(m.kind <> 'homework'
+			OR EXISTS (SELECT 1 FROM homework_assignments a WHERE a.homework_id = m.id AND a.user_id = ?)
+			OR EXISTS (SELECT 1 FROM homeworks h WHERE h.id = m.id AND h.user_id = ?))`, req.UserId, req.UserId)
+	}
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The similarity operator's threshold is a setting, kept to this
+	// transaction.
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, titleSimilarity); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var total int32
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM (`+matches+`) m`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`
+		SELECT kind, id, title, ts_headline(language, title, query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			ts_headline(language, body, query, '`+headlineOptions+`'), rank, `+p.sortKey()+`
+		FROM (`+matches+`) m`, f, "id")
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*pb.SearchResult
+	var keys, ids []string
+	for rows.Next() {
+		var r pb.SearchResult
+		var key string
+		if err := rows.Scan(&r.Type, &r.Id, &r.Title, &r.TitleHighlight, &r.Snippet, &r.Rank, &key); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		results = append(results, &r)
+		keys = append(keys, key)
+		ids = append(ids, r.Id)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	rows.Close()
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	n, token := p.next(keys, ids)
+	return &pb.SearchResponse{Results: results[:n], TotalCount: total, NextPageToken: token}, nil
+}
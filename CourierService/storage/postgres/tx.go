@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"learning-service/config"
+)
+
+// queryTimeout bounds every storage call, on top of whatever deadline the
+// caller's context already carries.
+var queryTimeout = 5 * time.Second
+
+// setQueryTimeout applies DB_QUERY_TIMEOUT.
+func setQueryTimeout(cfg config.Config) {
+	if cfg.QueryTimeout == "" {
+		return
+	}
+	d, err := time.ParseDuration(cfg.QueryTimeout)
+	if err != nil || d <= 0 {
+		log.Printf("invalid DB_QUERY_TIMEOUT %q, keeping %s", cfg.QueryTimeout, queryTimeout)
+		return
+	}
+	queryTimeout = d
+}
+
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
+
+// conn is what repositories run their statements on: the connection pool,
+// or the shared transaction of the unit of work they were created for. Only
+// the context variants are offered, so that no statement escapes the
+// request's deadline.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// unitOfWork is the transaction shared by the repositories of one WithTx
+// call.
+type unitOfWork struct {
+	*sql.Tx
+}
+
+// txn is the transaction a repository method runs its statements in. Inside
+// a unit of work it is the shared transaction, and committing or rolling it
+// back is left to the unit's owner.
+type txn struct {
+	*sql.Tx
+	owned bool
+}
+
+func (t *txn) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t *txn) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// begin starts a transaction on c, or joins the unit of work c belongs to.
+func begin(ctx context.Context, c conn) (*txn, error) {
+	switch c := c.(type) {
+	case *unitOfWork:
+		return &txn{Tx: c.Tx}, nil
+	case *sql.DB:
+		tx, err := c.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: tx, owned: true}, nil
+	}
+	return nil, errors.New("cannot begin a transaction on this connection")
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	pb "learning-service/genproto/learning"
+	"learning-service/models"
+	st "learning-service/storage"
+
+	"github.com/google/uuid"
+)
+
+type XpStorage struct {
+	db conn
+}
+
+// awardXp appends e to the ledger and moves the user's balance and level
+// with it, in the caller's transaction. It reports false, and changes
+// nothing, if the source has already paid out to the user or the user has
+// been deleted.
+func awardXp(ctx context.Context, tx *txn, e *models.XpEntry) (bool, error) {
+	if e.Amount == 0 {
+		return false, nil
+	}
+
+	// Locking the profile makes concurrent awards to one user add up.
+	p, err := lockProfile(ctx, tx, e.UserId)
+	if errors.Is(err, st.ErrProfileNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO xp_ledger(id, user_id, source_type, source_id, amount, reason)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, source_type, source_id) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, uuid.NewString(), e.UserId, e.SourceType, e.SourceId, e.Amount, e.Reason)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	xp := p.Xp + e.Amount
+	query = `UPDATE learning_profiles SET xp = $1, level = $2, updated_at = now() WHERE user_id = $3`
+	if _, err := tx.ExecContext(ctx, query, xp, models.Level(xp), e.UserId); err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Award books e and returns the XP it paid: none if its source already has.
+func (c *XpStorage) Award(ctx context.Context, e *models.XpEntry) (int32, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	awarded, err := awardXp(ctx, tx, e)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if !awarded {
+		return 0, nil
+	}
+	return e.Amount, nil
+}
+
+// AwardXp credits xp asked for by another service and reports whether it
+// did. The source id is what keeps a command from paying twice, so it is
+// required.
+func (c *XpStorage) AwardXp(ctx context.Context, req *models.AwardXpCommand) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	source, sourceId := req.Source, req.SourceId
+	if source == "" {
+		source = models.XpSourceCommand
+	}
+	if sourceId == "" {
+		return false, errors.New("source_id is required")
+	}
+
+	entry := &models.XpEntry{UserId: req.UserId, SourceType: source, SourceId: sourceId, Amount: req.Xp, Reason: req.Reason}
+	xp, err := c.Award(ctx, entry)
+	if err != nil {
+		return false, err
+	}
+	return xp != 0, nil
+}
+
+var xpSorts = map[string]string{
+	"created_at": "created_at",
+	"amount":     "amount",
+}
+
+// GetXpHistory lists a user's ledger entries together with their balance as
+// the ledger has it.
+func (c *XpStorage) GetXpHistory(ctx context.Context, req *pb.GetXpHistoryRequest) (*pb.GetXpHistoryResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p, err := newPage(req.Limit, req.Offset, req.PageToken, req.SortBy, req.SortOrder, "created_at", xpSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFilter()
+	f.Eq("user_id", req.UserId).In("source_type", req.SourceType)
+	if err := f.DateRange("created_at", req.CreatedFrom, req.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	var balance int32
+	err = c.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM xp_ledger WHERE user_id = $1`, req.UserId).Scan(&balance)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var total int32
+	err = c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM xp_ledger`+f.Sql(), f.Args()...).Scan(&total)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query, args := p.query(`SELECT id, user_id, source_type, source_id, amount, reason, created_at, `+p.sortKey()+` FROM xp_ledger`, f, "id")
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*pb.XpEntry
+	var keys, ids []string
+	for rows.Next() {
+		var e pb.XpEntry
+		var createdAt time.Time
+		var key string
+		err := rows.Scan(&e.Id, &e.UserId, &e.SourceType, &e.SourceId, &e.Amount, &e.Reason, &createdAt, &key)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		e.CreatedAt = createdAt.Format(time.RFC3339)
+		entries = append(entries, &e)
+		keys = append(keys, key)
+		ids = append(ids, e.Id)
+	}
+	n, token := p.next(keys, ids)
+	return &pb.GetXpHistoryResponse{Entries: entries[:n], Balance: balance, TotalCount: total, NextPageToken: token}, nil
+}
+
+// ReconcileXp resets balances that drifted from their ledger, and the levels
+// that go with them, for one user or for everyone, and reports how many were
+// corrected. The level is models.Level in SQL.
+func (c *XpStorage) ReconcileXp(ctx context.Context, req *pb.ReconcileXpRequest) (*pb.ReconcileXpResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE learning_profiles p
+		SET xp = l.total, level = floor(sqrt(GREATEST(l.total, 0)::float / $2))::int + 1, updated_at = now()
+		FROM (
+			SELECT v.user_id, COALESCE(SUM(x.amount), 0) AS total
+			FROM learning_profiles v LEFT JOIN xp_ledger x ON x.user_id = v.user_id
+			WHERE v.deleted_at IS NULL AND ($1::text = '' OR v.user_id::text = $1)
+			GROUP BY v.user_id
+		) l
+		WHERE p.user_id = l.user_id AND p.xp <> l.total`
+
+	res, err := c.db.ExecContext(ctx, query, req.UserId, models.XpPerLevel)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &pb.ReconcileXpResponse{Message: "success", Corrected: int32(n)}, nil
+}
@@ -82,6 +82,64 @@ func (h *HTTPHandler) UnbanUser(c *gin.Context) {
 	}
 }
 
+// SetRole godoc
+// @Summary Set a user's role
+// @Description Makes a user an author, who writes learning content, a reviewer, who publishes it, or a plain user again. The new role applies from the user's next login. Only admins are allowed to use this function.
+// @Tags admin-panel > roles
+// @Accept json
+// @Produce json
+// @Param id path string true "id of the user"
+// @Param role query string true "New role" Enums(user, author, reviewer)
+// @Success 200 {object} string "Role is set"
+// @Failure 400 {object} string "Invalid request payload"
+// @Failure 500 {object} string "Server error"
+// @Security BearerAuth
+// @Router /role/{id} [put]
+func (h *HTTPHandler) SetRole(c *gin.Context) {
+	id := c.Param("id")
+	if err := config.IsValidUUID(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := c.Query("role")
+	if role != "user" && role != "author" && role != "reviewer" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be user, author or reviewer"})
+		return
+	}
+	err := h.US.SetRole(&models.SetRoleReq{ID: id, Role: role})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Couldn't set role": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"Role is set": role})
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Deletes a user, banned or not, and their data in other services. Admins and couriers cannot be deleted this way. Only admins are allowed to use this function.
+// @Tags admin-panel > users
+// @Accept json
+// @Produce json
+// @Param id path string true "id of the user"
+// @Success 200 {object} string "User is deleted"
+// @Failure 400 {object} string "Invalid request payload"
+// @Failure 500 {object} string "Server error"
+// @Security BearerAuth
+// @Router /delete-user/{id} [delete]
+func (h *HTTPHandler) DeleteUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := config.IsValidUUID(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.US.DeleteUser(&models.DeleteUserReq{ID: id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Couldn't delete user": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"User is deleted": id})
+}
+
 // AddCourier godoc
 // @Summary Add a courier
 // @Description Adds a courier to the system. Only admins are allowed to use this function.
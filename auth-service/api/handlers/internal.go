@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendEmail mails a user by id. Other services use it so that user emails
+// never leave the auth service.
+func (h *HTTPHandler) SendEmail(c *gin.Context) {
+	var req models.SendEmailReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Invalid request payload": err.Error()})
+		return
+	}
+	if err := config.IsValidUUID(req.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.US.GetByID(&models.GetProfileByIdReq{ID: req.UserID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "details": err.Error()})
+		return
+	}
+
+	if err := sendEmail(user.Email, req.Subject, req.Body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't send email", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email sent"})
+}
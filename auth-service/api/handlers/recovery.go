@@ -27,15 +27,7 @@ func (h *HTTPHandler) SendConfirmationCode(email string) error {
 		return err
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", config.Load().SENDER_EMAIL)
-	m.SetHeader("To", email)
-	m.SetHeader("Subject", "Password Recovery Code")
-	m.SetBody("text/plain", fmt.Sprintf("Your password recovery code is: %d", code))
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, config.Load().SENDER_EMAIL, config.Load().APP_PASSWORD)
-
-	if err := d.DialAndSend(m); err != nil {
+	if err := sendEmail(email, "Password Recovery Code", fmt.Sprintf("Your password recovery code is: %d", code)); err != nil {
 		return err
 	}
 
@@ -46,6 +38,18 @@ func (h *HTTPHandler) SendConfirmationCode(email string) error {
 	return nil
 }
 
+func sendEmail(to, subject, body string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.Load().SENDER_EMAIL)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+
+	d := gomail.NewDialer("smtp.gmail.com", 587, config.Load().SENDER_EMAIL, config.Load().APP_PASSWORD)
+
+	return d.DialAndSend(m)
+}
+
 func generateConfirmationCode() (int, error) {
 	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
 	if err != nil {
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"auth-service/api/token"
+	"auth-service/config"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -50,3 +51,16 @@ func IsAdminMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// InternalMiddleware guards routes that are only called by other services.
+func InternalMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		want := config.Load().INTERNAL_TOKEN
+		if want == "" || c.GetHeader("X-Internal-Token") != want {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
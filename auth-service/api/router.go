@@ -30,10 +30,15 @@ func NewRouter(h *handlers.HTTPHandler) *gin.Engine {
 	protected.GET("/profile", h.Profile)
 	protected.PUT("/ban/:id", middleware.IsAdminMiddleware(), h.BanUser)
 	protected.PUT("/unban/:id", middleware.IsAdminMiddleware(), h.UnbanUser)
+	protected.PUT("/role/:id", middleware.IsAdminMiddleware(), h.SetRole)
+	protected.DELETE("/delete-user/:id", middleware.IsAdminMiddleware(), h.DeleteUser)
 	protected.POST("/add-courier", middleware.IsAdminMiddleware(), h.AddCourier)
 	protected.DELETE("/delete-courier/:id", middleware.IsAdminMiddleware(), h.DeleteCourier)
 
 	router.GET("/user/:id", h.GetByID)
 
+	internal := router.Group("/internal", middleware.InternalMiddleware())
+	internal.POST("/send-email", h.SendEmail)
+
 	return router
 }
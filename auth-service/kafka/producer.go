@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	TopicUserBanned     = "user.banned"
+	TopicUserRegistered = "user.registered"
+	TopicUserDeleted    = "user.deleted"
+)
+
+type KafkaProducer interface {
+	ProduceMessages(topic string, message []byte) error
+	Close() error
+}
+
+type Producer struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaProducer(brokers []string) (KafkaProducer, error) {
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		AllowAutoTopicCreation: true,
+	}
+	return &Producer{writer: writer}, nil
+}
+
+func (p *Producer) ProduceMessages(topic string, message []byte) error {
+	return p.writer.WriteMessages(context.Background(), kafka.Message{
+		Topic: topic,
+		Value: message,
+	})
+}
+
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
+
+// ProduceJSON marshals v and produces it to topic.
+func ProduceJSON(p KafkaProducer, topic string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.ProduceMessages(topic, data)
+}
@@ -4,20 +4,30 @@ import (
 	"auth-service/api"
 	"auth-service/api/handlers"
 	"auth-service/config"
+	"auth-service/kafka"
 	"auth-service/service"
 	"auth-service/storage"
+	"errors"
 	"fmt"
+	"strings"
 )
 
 func main() {
 	cf := config.Load()
 	em := config.NewErrorManager()
+	if cf.INTERNAL_TOKEN == "" {
+		em.CheckErr(errors.New("INTERNAL_TOKEN must be set"))
+	}
 
 	pgsql, mongo, err := storage.ConnectDB(&cf)
 	em.CheckErr(err)
 	defer pgsql.Close()
 
-	us := service.NewUserService(pgsql, mongo)
+	kaf, err := kafka.NewKafkaProducer(strings.Split(cf.KAFKA_BROKERS, ","))
+	em.CheckErr(err)
+	defer kaf.Close()
+
+	us := service.NewUserService(pgsql, mongo, kaf)
 	handler := handlers.NewHandler(us)
 
 	roter := api.NewRouter(handler)
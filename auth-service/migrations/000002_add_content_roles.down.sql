@@ -0,0 +1,7 @@
+-- Enum values cannot be dropped, so the type is rebuilt without them.
+UPDATE users SET role = 'user' WHERE role IN ('author', 'reviewer');
+
+ALTER TYPE user_role RENAME TO user_role_old;
+CREATE TYPE user_role AS ENUM ('admin', 'user', 'courier', 'manager', 'banned');
+ALTER TABLE users ALTER COLUMN role TYPE user_role USING role::text::user_role;
+DROP TYPE user_role_old;
@@ -0,0 +1,3 @@
+-- Authors write learning content and reviewers publish it.
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'author';
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'reviewer';
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type RegisterReqSwag struct {
 	Email    string `json:"email"`    // User's email address
 	Password string `json:"password"` // User's password
@@ -49,6 +51,11 @@ type UnbanUserReq struct {
 	Email string `json:"email"` // Username of the profile to retrieve
 }
 
+type SetRoleReq struct {
+	ID   string `json:"id"`
+	Role string `json:"role"` // user, author or reviewer
+}
+
 type ForgotPasswordReq struct {
 	Email string `json:"email"` // User's email address
 }
@@ -87,3 +94,29 @@ type DeleteCourierReq struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
 }
+
+type DeleteUserReq struct {
+	ID string `json:"id"`
+}
+
+type UserBannedEvent struct {
+	UserID   string    `json:"user_id"`
+	BannedAt time.Time `json:"banned_at"`
+}
+
+type UserRegisteredEvent struct {
+	UserID       string    `json:"user_id"`
+	Role         string    `json:"role"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+type UserDeletedEvent struct {
+	UserID    string    `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type SendEmailReq struct {
+	UserID  string `json:"user_id"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
@@ -2,20 +2,24 @@ package service
 
 import (
 	"auth-service/config"
+	"auth-service/kafka"
 	"auth-service/models"
 	"auth-service/storage/managers"
 	"database/sql"
+	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type UserService struct {
-	UM managers.UserManager
+	UM  managers.UserManager
+	Kaf kafka.KafkaProducer
 }
 
-func NewUserService(PsqlConn *sql.DB, MongoConn *mongo.Client) *UserService {
-	return &UserService{UM: *managers.NewUserManager(PsqlConn, MongoConn, config.Load().MONGO_DB_NAME, config.Load().MONGO_COLLECTION_NAME)}
+func NewUserService(PsqlConn *sql.DB, MongoConn *mongo.Client, kaf kafka.KafkaProducer) *UserService {
+	return &UserService{UM: *managers.NewUserManager(PsqlConn, MongoConn, config.Load().MONGO_DB_NAME, config.Load().MONGO_COLLECTION_NAME), Kaf: kaf}
 }
 
 func (u *UserService) Register(req *models.RegisterReq) error {
@@ -28,6 +32,12 @@ func (u *UserService) Register(req *models.RegisterReq) error {
 	if err := u.UM.Register(*req); err != nil {
 		return err
 	}
+	event := models.UserRegisteredEvent{UserID: req.ID, Role: req.Role, RegisteredAt: time.Now().UTC()}
+	if err := kafka.ProduceJSON(u.Kaf, kafka.TopicUserRegistered, event); err != nil {
+		// Services that keep per-user data also create it lazily, so the
+		// registration stands.
+		log.Println("cannot produce user registered event: ", err)
+	}
 	return nil
 }
 
@@ -44,17 +54,53 @@ func (u *UserService) GetByID(id *models.GetProfileByIdReq) (*models.GetProfileB
 }
 
 func (u *UserService) BanUser(req *models.BanUserReq) error {
-	return u.UM.BanUser(*req)
+	id, err := u.UM.BanUser(*req)
+	if err != nil {
+		return err
+	}
+	event := models.UserBannedEvent{UserID: id, BannedAt: time.Now().UTC()}
+	if err := kafka.ProduceJSON(u.Kaf, kafka.TopicUserBanned, event); err != nil {
+		// The ban itself succeeded; a missed notification is not worth failing the request.
+		log.Println("cannot produce user banned event: ", err)
+	}
+	return nil
 }
 
 func (u *UserService) UnbanUser(req *models.UnbanUserReq) error {
 	return u.UM.UnbanUser(*req)
 }
 
+func (u *UserService) SetRole(req *models.SetRoleReq) error {
+	return u.UM.SetRole(*req)
+}
+
 func (u *UserService) AddCourier(req *models.AddCourierReq) error {
 	return u.UM.AddCourier(req)
 }
 
+func (u *UserService) DeleteUser(req *models.DeleteUserReq) error {
+	id, err := u.UM.DeleteUser(*req)
+	if err != nil {
+		return err
+	}
+	u.userDeleted(id)
+	return nil
+}
+
 func (u *UserService) DeleteCourier(req *models.DeleteCourierReq) error {
-	return u.UM.DeleteCourier(*req)
+	id, err := u.UM.DeleteCourier(*req)
+	if err != nil {
+		return err
+	}
+	u.userDeleted(id)
+	return nil
+}
+
+// userDeleted tells the services that keep per-user data to drop it. Every
+// way of deleting a user must call it.
+func (u *UserService) userDeleted(id string) {
+	event := models.UserDeletedEvent{UserID: id, DeletedAt: time.Now().UTC()}
+	if err := kafka.ProduceJSON(u.Kaf, kafka.TopicUserDeleted, event); err != nil {
+		log.Println("cannot produce user deleted event: ", err)
+	}
 }
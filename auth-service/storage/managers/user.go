@@ -81,35 +81,37 @@ func (m *UserManager) IsEmailExists(email string) error {
 func (m *UserManager) GetByID(id *models.GetProfileByIdReq) (*models.GetProfileByIdResp, error) {
 	query := "SELECT id, email, role FROM users WHERE id = $1"
 	user := &models.GetProfileByIdResp{}
-	err := m.PgClient.QueryRow(query, id.ID).Scan(&user.ID, &user, &user.Email, &user.Role)
+	err := m.PgClient.QueryRow(query, id.ID).Scan(&user.ID, &user.Email, &user.Role)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (m *UserManager) BanUser(req models.BanUserReq) error {
-	var query string
+func (m *UserManager) BanUser(req models.BanUserReq) (string, error) {
+	var (
+		query string
+		arg   string
+	)
 	if req.ID != "" {
-		query = "UPDATE users SET role = 'banned' WHERE id = $1 and role = 'user'"
-		res, err := m.PgClient.Exec(query, req.ID)
-		if err != nil {
-			return err
-		}
-		if err := config.CheckRowsAffected(res, "user"); err != nil {
-			return err
-		}
+		query = "UPDATE users SET role = 'banned' WHERE id = $1 and role IN ('user', 'author', 'reviewer') RETURNING id"
+		arg = req.ID
 	} else if req.Email != "" {
-		query = "UPDATE users SET role = 'banned' WHERE email = $1 and role = 'user'"
-		res, err := m.PgClient.Exec(query, req.Email)
-		if err != nil {
-			return err
-		}
-		if err := config.CheckRowsAffected(res, "user"); err != nil {
-			return err
-		}
+		query = "UPDATE users SET role = 'banned' WHERE email = $1 and role IN ('user', 'author', 'reviewer') RETURNING id"
+		arg = req.Email
+	} else {
+		return "", errors.New("user id or email is required")
 	}
-	return nil
+
+	var id string
+	err := m.PgClient.QueryRow(query, arg).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", errors.New("user not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
 }
 
 func (m *UserManager) UnbanUser(req models.UnbanUserReq) error {
@@ -136,6 +138,25 @@ func (m *UserManager) UnbanUser(req models.UnbanUserReq) error {
 	return nil
 }
 
+// SetRole moves a user between the learner and content roles. Admins,
+// couriers, managers and banned users keep their roles.
+func (m *UserManager) SetRole(req models.SetRoleReq) error {
+	if uuid.Validate(req.ID) != nil {
+		return errors.New("invalid user uuid")
+	}
+	switch req.Role {
+	case "user", "author", "reviewer":
+	default:
+		return errors.New("role must be user, author or reviewer")
+	}
+	query := "UPDATE users SET role = $2 WHERE id = $1 and role IN ('user', 'author', 'reviewer')"
+	res, err := m.PgClient.Exec(query, req.ID, req.Role)
+	if err != nil {
+		return err
+	}
+	return config.CheckRowsAffected(res, "user")
+}
+
 func (m *UserManager) AddCourier(courier *models.AddCourierReq) error {
 	query := "INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)"
 	_, err := m.PgClient.Exec(query, uuid.NewString(), courier.Email, courier.Password, "courier")
@@ -145,29 +166,51 @@ func (m *UserManager) AddCourier(courier *models.AddCourierReq) error {
 	return nil
 }
 
-func (m *UserManager) DeleteCourier(req models.DeleteCourierReq) error {
-	var query string
+// DeleteUser deletes a learner, banned or not, and returns their id. Admins
+// and couriers are not deleted this way.
+func (m *UserManager) DeleteUser(req models.DeleteUserReq) (string, error) {
+	if uuid.Validate(req.ID) != nil {
+		return "", errors.New("invalid user uuid")
+	}
+	query := "DELETE FROM users WHERE id = $1 and role IN ('user', 'author', 'reviewer', 'banned') RETURNING id"
+
+	var id string
+	err := m.PgClient.QueryRow(query, req.ID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", errors.New("user not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// DeleteCourier returns the id of the deleted courier.
+func (m *UserManager) DeleteCourier(req models.DeleteCourierReq) (string, error) {
+	var (
+		query string
+		arg   string
+	)
 	if req.ID != "" {
-		query = "DELETE FROM users WHERE id = $1 and role = 'courier'"
 		if uuid.Validate(req.ID) != nil {
-			return errors.New("invalid user uuid")
-		}
-		res, err := m.PgClient.Exec(query, req.ID)
-		if err != nil {
-			return err
-		}
-		if err := config.CheckRowsAffected(res, "courier"); err != nil {
-			return err
+			return "", errors.New("invalid user uuid")
 		}
+		query = "DELETE FROM users WHERE id = $1 and role = 'courier' RETURNING id"
+		arg = req.ID
 	} else if req.Email != "" {
-		query = "DELETE FROM users WHERE email = $1 and role = 'courier'"
-		res, err := m.PgClient.Exec(query, req.Email)
-		if err != nil {
-			return err
-		}
-		if err := config.CheckRowsAffected(res, "courier"); err != nil {
-			return err
-		}
+		query = "DELETE FROM users WHERE email = $1 and role = 'courier' RETURNING id"
+		arg = req.Email
+	} else {
+		return "", errors.New("courier id or email is required")
 	}
-	return nil
+
+	var id string
+	err := m.PgClient.QueryRow(query, arg).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", errors.New("courier not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
 }